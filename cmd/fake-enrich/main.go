@@ -0,0 +1,50 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/fakeenrich"
+)
+
+var (
+	addr     string
+	seedPath string
+	faults   fakeenrich.Faults
+)
+
+func init() {
+	flag.StringVar(&addr, "addr", "localhost:8089", "listen address")
+	flag.StringVar(&seedPath, "seed-file", "", "seed json file, built in seed if empty")
+	flag.DurationVar(&faults.Latency, "latency", 0, "delay before every response")
+	flag.Float64Var(&faults.ErrorRate, "error-rate", 0, "share of requests failing with 500, 0..1")
+	flag.Float64Var(&faults.TooManyRequestsRate, "too-many-requests-rate", 0, "share of requests failing with 429, 0..1")
+	flag.IntVar(&faults.DailyLimit, "daily-limit", 1000, "names answered by each api per day before its requests fail with 429, 0 is unlimited")
+	flag.Int64Var(&faults.RandomSeed, "random-seed", 1, "faults random seed")
+
+	flag.Parse()
+}
+
+// Local stand-in for enrichment apis. Point the http server to it with
+//
+//	ENRICH_AGIFY_URL=http://localhost:8089/agify/
+//	ENRICH_GENDERIZE_URL=http://localhost:8089/genderize/
+//	ENRICH_NATIONALIZE_URL=http://localhost:8089/nationalize/
+func main() {
+	seed, err := fakeenrich.LoadSeed(seedPath)
+
+	if err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+
+	slog.Info(fmt.Sprintf("fake enrichment apis listening on %s", addr))
+
+	if err := http.ListenAndServe(addr, fakeenrich.New(seed, faults)); err != nil {
+		slog.Error(err.Error())
+		os.Exit(1)
+	}
+}
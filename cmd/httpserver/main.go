@@ -1,24 +1,34 @@
 package main
 
 import (
+	"errors"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 
 	"github.com/sletkov/effective-mobile-test-task/internal/app"
-	_ "github.com/sletkov/effective-mobile-test-task/migrations"
+	"github.com/sletkov/effective-mobile-test-task/internal/config"
 )
 
+const defaultConfigPath = ".env"
+
 var (
-	configPath     string
-	makeMigrations bool
-	dropMigrations bool
+	configPath  string
+	printConfig bool
+	configFlags *config.Flags
 )
 
 func init() {
-	flag.StringVar(&configPath, "config-path", ".env", "config path")
-	flag.BoolVar(&makeMigrations, "make-migrations", false, "make migrations")
-	flag.BoolVar(&dropMigrations, "drop-migrations", false, "rollback migrations")
+	flag.StringVar(&configPath, "config-path", defaultConfigPath, "config path (.env, .yaml, .json or .toml)")
+	flag.BoolVar(&printConfig, "print-config", false, "print effective config with secrets redacted and exit")
+
+	configFlags = config.RegisterFlags(flag.CommandLine)
+
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: %s [FLAGS] [migrate COMMAND]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 
 	flag.Parse()
 }
@@ -31,11 +41,54 @@ func init() {
 // @BasePath /api/v1/users
 
 func main() {
-	// Run server
-	err := app.Run(configPath, makeMigrations, dropMigrations)
+	var err error
+
+	switch {
+	case flag.Arg(0) == "migrate" && !printConfig:
+		// Manage db schema, config is read by the commands needing it
+		err = app.Migrate(loadConfig, flag.Args()[1:])
+	case flag.Arg(0) != "" && flag.Arg(0) != "migrate":
+		flag.Usage()
+		err = fmt.Errorf("unknown command %q", flag.Arg(0))
+	default:
+		err = run()
+	}
 
 	if err != nil {
 		slog.Error(err.Error())
 		os.Exit(1)
 	}
 }
+
+// Run server or print its config
+func run() error {
+	cfg, err := loadConfig()
+
+	if err != nil {
+		return err
+	}
+
+	if printConfig {
+		fmt.Print(cfg)
+		return nil
+	}
+
+	return app.Run(cfg)
+}
+
+func loadConfig() (*config.Config, error) {
+	return config.Load(resolveConfigPath(), configFlags)
+}
+
+// Default config file is optional, explicitly passed one is not
+func resolveConfigPath() string {
+	if configPath != defaultConfigPath {
+		return configPath
+	}
+
+	if _, err := os.Stat(configPath); errors.Is(err, os.ErrNotExist) {
+		return ""
+	}
+
+	return configPath
+}
@@ -3,100 +3,449 @@ package app
 import (
 	"context"
 	"database/sql"
+	"expvar"
 	"fmt"
 	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"github.com/ilyakaznacheev/cleanenv"
-	_ "github.com/lib/pq"
-	"github.com/pressly/goose/v3"
+	"google.golang.org/grpc"
 
+	"github.com/sletkov/effective-mobile-test-task/internal/auth"
 	"github.com/sletkov/effective-mobile-test-task/internal/config"
+	graphqlv1 "github.com/sletkov/effective-mobile-test-task/internal/controller/graphql/v1"
+	grpcv1 "github.com/sletkov/effective-mobile-test-task/internal/controller/grpc/v1"
 	v1 "github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1"
+	"github.com/sletkov/effective-mobile-test-task/internal/events"
+	"github.com/sletkov/effective-mobile-test-task/internal/outbox"
+	"github.com/sletkov/effective-mobile-test-task/internal/ratelimit"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/memory"
 	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/sqlite"
 	"github.com/sletkov/effective-mobile-test-task/internal/service"
 	httptransport "github.com/sletkov/effective-mobile-test-task/internal/transport/http"
+	"github.com/sletkov/effective-mobile-test-task/internal/webhook"
 )
 
 // Run application
-func Run(configPath string, makeMigrations, dropMigrations bool) error {
+func Run(cfg *config.Config) error {
 
 	// Initialize logger
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelDebug,
-	}))
+	if err := initLogger(cfg.LogLevel); err != nil {
+		return err
+	}
 
-	slog.SetDefault(logger)
+	var (
+		db   *sql.DB
+		repo service.UserRepository
+		err  error
+	)
 
-	// Read config from .env
-	slog.Info("reading config")
+	// Database is always reachable in memory
+	ping := func(context.Context) error { return nil }
 
-	var config config.Config
+	switch cfg.Database.Driver {
+	case memory.Driver:
+		slog.Warn("users are kept in memory, they are lost on exit")
 
-	err := cleanenv.ReadConfig(configPath, &config)
+		repo = memory.New()
+	case sqlite.Driver:
+		slog.Info("initializing sqlite db")
+		db, err = sqlite.Open(cfg.Database)
 
-	if err != nil {
-		return fmt.Errorf("reading config: %w", err)
+		if err != nil {
+			return fmt.Errorf("initializing db: %w", err)
+		}
+
+		defer db.Close()
+
+		slog.Info("db was initialized successfully")
+
+		ping = db.PingContext
+		repo = sqlite.New(db)
+	default:
+		// Initialize database
+		slog.Info("initializing db")
+		db, err = postgres.Open(cfg.Database)
+
+		if err != nil {
+			return fmt.Errorf("initializing db: %w", err)
+		}
+
+		defer db.Close()
+
+		slog.Info("db was initialized successfully")
+
+		ping = db.PingContext
+
+		var repoOpts []postgres.Option
+
+		if cfg.Outbox.Enabled {
+			repoOpts = append(repoOpts, postgres.WithOutbox())
+
+			stopRelay, err := startRelay(db, cfg.Outbox)
+
+			if err != nil {
+				return fmt.Errorf("initializing outbox: %w", err)
+			}
+
+			defer stopRelay()
+		}
+
+		repo = postgres.New(db, repoOpts...)
 	}
 
-	slog.Info("config was read successfully")
+	// Connection pool stats of either sql database
+	if db != nil {
+		expvar.Publish("db", expvar.Func(func() any {
+			return db.Stats()
+		}))
+	}
 
-	// Initialize database
-	slog.Info("initializing db")
-	db, err := initDB(config.DatabaseURL)
+	client, err := httptransport.NewClient(httptransport.ClientOptions{
+		Timeout:            cfg.Enrichment.Timeout,
+		Proxy:              cfg.Enrichment.Proxy,
+		DisableCompression: cfg.Enrichment.DisableCompression,
+	})
 
 	if err != nil {
-		return fmt.Errorf("initializing db: %w", err)
+		return fmt.Errorf("initializing transport: %w", err)
+	}
+
+	transportOpts := []httptransport.Option{
+		httptransport.WithUserAgent(cfg.Enrichment.UserAgent),
+		httptransport.WithMaxResponseBytes(cfg.Enrichment.MaxResponseBytes),
+	}
+
+	if cfg.Enrichment.APIKey != "" {
+		transportOpts = append(transportOpts, httptransport.WithAPIKey(cfg.Enrichment.APIKey))
+	}
+
+	if cfg.Enrichment.BreakerFailureThreshold > 0 {
+		transportOpts = append(transportOpts, httptransport.WithBreaker(httptransport.BreakerSettings{
+			FailureThreshold: cfg.Enrichment.BreakerFailureThreshold,
+			OpenTimeout:      cfg.Enrichment.BreakerOpenTimeout,
+			HalfOpenRequests: cfg.Enrichment.BreakerHalfOpenRequests,
+		}))
 	}
 
-	slog.Info("db was initialized successfully")
+	upstream := httptransport.New(client, transportOpts...)
+
+	expvar.Publish("upstream_breakers", expvar.Func(func() any {
+		return upstream.BreakerStates()
+	}))
+
+	var transport service.Transport = upstream
 
-	// Migrations control
-	if makeMigrations {
-		// Make migrations
-		slog.Info("making migrations")
+	// Protect upstream quota shared by all clients
+	budgetOpts := httptransport.BudgetOptions{
+		DailyBudget: cfg.RateLimit.DailyBudget,
+		Reserve:     cfg.RateLimit.BudgetReserve,
+	}
 
-		if err := goose.Up(db, "migrations"); err != nil {
-			return fmt.Errorf("making migrations: %w", err)
+	if cfg.Cache.Enabled {
+		budgetOpts.CacheSize = cfg.Cache.Size
+		budgetOpts.CacheTTL = cfg.Cache.TTL
+	}
+
+	transport = httptransport.NewBudgetGuard(transport, budgetOpts)
+
+	serviceOpts := []service.Option{
+		service.WithProviders(
+			cfg.Enrichment.AgifyURL,
+			cfg.Enrichment.GenderizeURL,
+			cfg.Enrichment.NationalizeURL,
+		),
+	}
+
+	if cfg.Cache.Enabled && cfg.Cache.StatsTTL > 0 {
+		serviceOpts = append(serviceOpts, service.WithStatsCache(cfg.Cache.Size, cfg.Cache.StatsTTL))
+	}
+
+	var webhookService *service.WebhookService
+
+	if cfg.Webhooks.Enabled {
+		webhookService = service.NewWebhook(postgres.NewWebhook(db))
+		serviceOpts = append(serviceOpts, service.WithHooks(webhookService.Notify))
+
+		defer startDispatcher(db, cfg.Webhooks)()
+	}
+
+	service := service.New(repo, transport, serviceOpts...)
+
+	var (
+		controllerOpts []v1.Option
+		authenticator  auth.Authenticator
+	)
+
+	if cfg.Auth.Enabled {
+		authenticator, err = auth.New(cfg.Auth)
+
+		if err != nil {
+			return fmt.Errorf("initializing auth: %w", err)
+		}
+
+		controllerOpts = append(controllerOpts, v1.WithAuth(authenticator))
+	}
+
+	if cfg.RateLimit.Enabled {
+		controllerOpts = append(controllerOpts, v1.WithRateLimit(ratelimit.New(cfg.RateLimit.RPS, cfg.RateLimit.Burst)))
+	}
+
+	if cfg.GraphQL.Enabled {
+		graphqlOpts := []graphqlv1.Option{
+			graphqlv1.WithLimits(cfg.GraphQL.MaxDepth, cfg.GraphQL.MaxComplexity),
 		}
 
-		slog.Info("migrations were made successfully")
+		if authenticator != nil {
+			graphqlOpts = append(graphqlOpts, graphqlv1.WithRoles())
+		}
 
-	} else if dropMigrations {
-		// Rollback migrations
-		slog.Info("rollback migrations")
+		graphqlHandler, err := graphqlv1.New(service, graphqlOpts...)
 
-		if err := goose.Down(db, "migrations"); err != nil {
-			return fmt.Errorf("making migrations: %w", err)
+		if err != nil {
+			return fmt.Errorf("initializing graphql: %w", err)
 		}
 
-		slog.Info("migrations were rollbacked successfully")
+		controllerOpts = append(controllerOpts, v1.WithGraphQL(graphqlHandler))
+	}
+
+	if webhookService != nil {
+		controllerOpts = append(controllerOpts, v1.WithWebhooks(webhookService))
+	}
+
+	var broker *events.Broker
+
+	if cfg.Events.Enabled {
+		broker = events.NewBroker(
+			events.WithBufferSize(cfg.Events.BufferSize),
+			events.WithSubscriberBuffer(cfg.Events.SubscriberBuffer),
+		)
+
+		defer startListener(cfg.Database.URL, broker)()
+
+		controllerOpts = append(controllerOpts, v1.WithEvents(broker, cfg.Events.Heartbeat))
+	}
+
+	// Runtime, db pool and circuit breaker stats
+	if cfg.Server.DebugVars {
+		controllerOpts = append(controllerOpts, v1.WithDebugVars(expvar.Handler()))
 	}
 
-	repo := postgres.New(db)
+	controller := v1.New(service, controllerOpts...)
+
+	router := controller.InitRoutes()
+
+	// Probes
+	router.Get("/healthz", handleHealth)
+	router.Get("/readyz", handleReady(ping, upstream.BreakerStates))
+
+	server := &http.Server{
+		Addr:              net.JoinHostPort(cfg.Server.Host, cfg.Server.Port),
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
 
-	transport := httptransport.New(http.DefaultClient)
+	if broker != nil {
+		server.RegisterOnShutdown(broker.Close)
+	}
 
-	service := service.New(repo, transport)
+	var grpcServer *grpc.Server
 
-	controller := v1.New(service)
+	if cfg.GRPC.Enabled {
+		var grpcOpts []grpcv1.Option
 
-	router := controller.InitRoutes(context.Background())
+		if authenticator != nil {
+			grpcOpts = append(grpcOpts, grpcv1.WithAuth(authenticator))
+		}
 
-	slog.Debug(fmt.Sprintf("http server started on port: %s", config.Port))
+		grpcServer = grpcv1.New(service, grpcOpts...).Register()
+	}
 
-	return http.ListenAndServe(net.JoinHostPort(config.Host, config.Port), router)
+	return serve(server, grpcServer, net.JoinHostPort(cfg.GRPC.Host, cfg.GRPC.Port), cfg.Server.ShutdownTimeout)
 }
 
-// Initialize postgres database
-func initDB(url string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", url)
+// Publish outbox events in background until returned stop is called
+func startRelay(db *sql.DB, cfg config.Outbox) (func(), error) {
+	sink, err := outbox.NewSink(cfg)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return db, nil
+	relay := outbox.NewRelay(
+		postgres.NewOutbox(db),
+		sink,
+		outbox.WithInterval(cfg.Interval),
+		outbox.WithBatchSize(cfg.BatchSize),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		slog.Info(fmt.Sprintf("outbox relay started, publishing to %s", cfg.Sink))
+		relay.Run(ctx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+
+		if err := sink.Close(); err != nil {
+			slog.Error(fmt.Sprintf("closing outbox sink: %s", err.Error()))
+		}
+
+		slog.Info("outbox relay was stopped successfully")
+	}, nil
+}
+
+// Send webhook deliveries in background until returned stop is called
+func startDispatcher(db *sql.DB, cfg config.Webhooks) func() {
+	client := &http.Client{Timeout: cfg.Timeout}
+
+	dispatcher := webhook.NewDispatcher(
+		postgres.NewWebhook(db),
+		client,
+		webhook.WithInterval(cfg.Interval),
+		webhook.WithBatchSize(cfg.BatchSize),
+		webhook.WithConcurrency(cfg.Concurrency),
+		webhook.WithSchedule(cfg.RetrySchedule),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		slog.Info("webhook dispatcher started")
+		dispatcher.Run(ctx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+
+		client.CloseIdleConnections()
+
+		slog.Info("webhook dispatcher was stopped successfully")
+	}
+}
+
+// Feed broker with changes of users in background until returned stop is called
+func startListener(dsn string, broker *events.Broker) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		slog.Info(fmt.Sprintf("listening to %s notifications", events.Channel))
+
+		if err := events.Listen(ctx, dsn, broker); err != nil {
+			slog.Error(err.Error())
+		}
+	}()
+
+	return func() {
+		cancel()
+		<-done
+
+		slog.Info("events listener was stopped successfully")
+	}
+}
+
+// Initialize default json logger
+func initLogger(logLevel string) error {
+	var level slog.Level
+
+	if err := level.UnmarshalText([]byte(logLevel)); err != nil {
+		return fmt.Errorf("parsing log level: %w", err)
+	}
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
+		Level: level,
+	}))
+
+	slog.SetDefault(logger)
+
+	return nil
+}
+
+// Listen and serve until SIGINT or SIGTERM, then shut down gracefully.
+// Grpc server is optional.
+func serve(server *http.Server, grpcServer *grpc.Server, grpcAddr string, shutdownTimeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 2)
+
+	go func() {
+		slog.Info(fmt.Sprintf("http server started on %s", server.Addr))
+		errCh <- server.ListenAndServe()
+	}()
+
+	if grpcServer != nil {
+		listener, err := net.Listen("tcp", grpcAddr)
+
+		if err != nil {
+			return fmt.Errorf("listening grpc: %w", err)
+		}
+
+		go func() {
+			slog.Info(fmt.Sprintf("grpc server started on %s", grpcAddr))
+			errCh <- grpcServer.Serve(listener)
+		}()
+	}
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down http server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	grpcStopped := make(chan struct{})
+
+	if grpcServer != nil {
+		slog.Info("shutting down grpc server")
+
+		go func() {
+			grpcServer.GracefulStop()
+			close(grpcStopped)
+		}()
+	}
+
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("shutting down http server: %w", err)
+	}
+
+	slog.Info("http server was shut down successfully")
+
+	if grpcServer != nil {
+		select {
+		case <-grpcStopped:
+		case <-shutdownCtx.Done():
+			// Streams still running when time is out are cut
+			grpcServer.Stop()
+		}
+
+		slog.Info("grpc server was shut down successfully")
+	}
+
+	return nil
 }
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+type readiness struct {
+	Status    string            `json:"status"`
+	Database  string            `json:"database"`
+	Upstreams map[string]string `json:"upstreams"`
+}
+
+// Liveness probe
+func handleHealth(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Readiness probe. Instance is not ready without db. Open upstream circuits
+// only degrade it, reads still work and creating users fails fast.
+func handleReady(ping func(ctx context.Context) error, breakerStates func() map[string]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		result := readiness{
+			Status:    "ready",
+			Database:  "ok",
+			Upstreams: breakerStates(),
+		}
+
+		status := http.StatusOK
+
+		for _, state := range result.Upstreams {
+			if state != "closed" {
+				result.Status = "degraded"
+			}
+		}
+
+		if err := ping(ctx); err != nil {
+			slog.Error("readiness: " + err.Error())
+
+			result.Status = "unavailable"
+			result.Database = err.Error()
+			status = http.StatusServiceUnavailable
+		}
+
+		data, err := json.Marshal(result)
+
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write(data)
+	}
+}
@@ -0,0 +1,170 @@
+package app
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"github.com/pressly/goose/v3"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/config"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/memory"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/sqlite"
+	"github.com/sletkov/effective-mobile-test-task/migrations"
+)
+
+// Arbitrary key of postgres advisory lock held while migrations are applied
+const migrationsLockKey int64 = 7_240_118_153_339
+
+const migrateUsage = `usage: migrate [-dir DIR] COMMAND [ARGS]
+
+commands:
+  up                 apply all pending migrations
+  up-to VERSION      apply migrations up to VERSION
+  down               roll back the latest migration
+  down-to VERSION    roll back migrations down to VERSION
+  redo               roll back and reapply the latest migration
+  status             print status of all migrations
+  version            print current db version
+  create NAME [sql]  create new migration file in DIR`
+
+// Commands changing the schema, they are serialized between replicas
+var lockedCommands = map[string]bool{
+	"up":      true,
+	"up-to":   true,
+	"down":    true,
+	"down-to": true,
+	"redo":    true,
+}
+
+// Run migrate subcommand. Config is loaded only by commands using the database,
+// so migrations can be created without a valid one.
+func Migrate(loadConfig func() (*config.Config, error), args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dir := fs.String("dir", "migrations", "migrations source dir, used by create only")
+
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), migrateUsage)
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("migrate: command is required")
+	}
+
+	command, commandArgs := fs.Arg(0), fs.Args()[1:]
+
+	// Creating a migration doesn't need a database
+	if command == "create" {
+		if len(commandArgs) == 1 {
+			commandArgs = append(commandArgs, "sql")
+		}
+
+		return goose.Run(command, nil, *dir, commandArgs...)
+	}
+
+	if _, ok := lockedCommands[command]; !ok && command != "status" && command != "version" {
+		fs.Usage()
+		return fmt.Errorf("migrate: unknown command %q", command)
+	}
+
+	cfg, err := loadConfig()
+
+	if err != nil {
+		return err
+	}
+
+	if err := initLogger(cfg.LogLevel); err != nil {
+		return err
+	}
+
+	if cfg.Database.Driver == memory.Driver {
+		return errors.New("migrate: in-memory users have no migrations")
+	}
+
+	db, dialect, migrationsDir, err := openMigrated(cfg.Database)
+
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	defer db.Close()
+
+	goose.SetBaseFS(migrations.FS)
+
+	if err := goose.SetDialect(dialect); err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	ctx := context.Background()
+
+	// Sqlite database is a single file locked by its writer
+	if lockedCommands[command] && cfg.Database.Postgres() {
+		unlock, err := lockMigrations(ctx, db)
+
+		if err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+
+		defer unlock()
+	}
+
+	slog.Info(fmt.Sprintf("migrate: running %s", command))
+
+	if err := goose.RunContext(ctx, command, db, migrationsDir, commandArgs...); err != nil {
+		return fmt.Errorf("migrate: %s: %w", command, err)
+	}
+
+	slog.Info(fmt.Sprintf("migrate: %s finished successfully", command))
+
+	return nil
+}
+
+// Open database with goose dialect and dir of its migrations
+func openMigrated(cfg config.Database) (*sql.DB, string, string, error) {
+	if cfg.Driver == sqlite.Driver {
+		db, err := sqlite.Open(cfg)
+
+		return db, "sqlite3", migrations.SQLiteDir, err
+	}
+
+	db, err := postgres.Open(cfg)
+
+	return db, "postgres", ".", err
+}
+
+// Take session level advisory lock so concurrent replicas wait for each other.
+// Lock is held on a dedicated connection until unlock is called.
+func lockMigrations(ctx context.Context, db *sql.DB) (func(), error) {
+	conn, err := db.Conn(ctx)
+
+	if err != nil {
+		return nil, fmt.Errorf("taking migrations lock: %w", err)
+	}
+
+	slog.Info("migrate: waiting for migrations lock")
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationsLockKey); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("taking migrations lock: %w", err)
+	}
+
+	slog.Info("migrate: migrations lock was taken")
+
+	return func() {
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationsLockKey); err != nil {
+			slog.Error(fmt.Sprintf("migrate: releasing migrations lock: %s", err.Error()))
+		}
+
+		conn.Close()
+	}, nil
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+)
+
+const APIKeyHeader = "X-API-Key"
+
+// Static api keys from config
+type APIKeyAuthenticator struct {
+	// Keys are stored hashed, so lookups don't leak them through timing
+	roles map[[sha256.Size]byte]Role
+}
+
+// Create authenticator from api keys mapped to role names
+func NewAPIKeyAuthenticator(keys map[string]string) (*APIKeyAuthenticator, error) {
+	a := &APIKeyAuthenticator{
+		roles: make(map[[sha256.Size]byte]Role, len(keys)),
+	}
+
+	for key, roleName := range keys {
+		role, err := ParseRole(roleName)
+
+		if err != nil {
+			return nil, fmt.Errorf("auth: api key: %w", err)
+		}
+
+		a.roles[sha256.Sum256([]byte(key))] = role
+	}
+
+	return a, nil
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	key := r.Header.Get(APIKeyHeader)
+
+	if key == "" {
+		return nil, ErrNoCredentials
+	}
+
+	hash := sha256.Sum256([]byte(key))
+
+	role, ok := a.roles[hash]
+
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &Principal{
+		// Short hash identifies the key in logs without revealing it
+		Subject: hex.EncodeToString(hash[:4]),
+		Role:    role,
+		Method:  "apikey",
+	}, nil
+}
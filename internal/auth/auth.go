@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/config"
+)
+
+var (
+	ErrNoCredentials      = errors.New("no credentials")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type Role string
+
+const (
+	RoleReader Role = "reader"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var roleLevels = map[Role]int{
+	RoleReader: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+// Check if role grants everything the required role grants
+func (r Role) Allows(required Role) bool {
+	return roleLevels[r] >= roleLevels[required] && roleLevels[r] > 0
+}
+
+func ParseRole(s string) (Role, error) {
+	role := Role(strings.ToLower(s))
+
+	if _, ok := roleLevels[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", s)
+	}
+
+	return role, nil
+}
+
+// Authenticated client
+type Principal struct {
+	Subject string
+	Role    Role
+	// Authentication method: apikey or jwt
+	Method string
+}
+
+func (p *Principal) String() string {
+	return fmt.Sprintf("%s:%s", p.Method, p.Subject)
+}
+
+type Authenticator interface {
+	// Return ErrNoCredentials if request has no credentials for this authenticator
+	Authenticate(r *http.Request) (*Principal, error)
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// Get authenticated principal from context, nil if there is none
+func PrincipalFromContext(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+
+	return p
+}
+
+// Authenticators tried in order, the first one finding credentials wins
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (*Principal, error) {
+	for _, a := range c {
+		p, err := a.Authenticate(r)
+
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+
+		return p, err
+	}
+
+	return nil, ErrNoCredentials
+}
+
+// Build authenticator from config
+func New(cfg config.Auth) (Authenticator, error) {
+	var chain Chain
+
+	if len(cfg.APIKeys) > 0 {
+		a, err := NewAPIKeyAuthenticator(cfg.APIKeys)
+
+		if err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, a)
+	}
+
+	if cfg.JWTSecret != "" || cfg.JWKSFile != "" {
+		var (
+			a   *JWTAuthenticator
+			err error
+		)
+
+		if cfg.JWTSecret != "" {
+			a, err = NewHMACAuthenticator([]byte(cfg.JWTSecret), cfg.JWTIssuer, cfg.JWTAudience)
+		} else {
+			a, err = NewJWKSAuthenticator(cfg.JWKSFile, cfg.JWTIssuer, cfg.JWTAudience)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		chain = append(chain, a)
+	}
+
+	if len(chain) == 0 {
+		return nil, errors.New("auth: no authenticators configured")
+	}
+
+	return chain, nil
+}
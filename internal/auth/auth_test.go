@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func signHMAC(t *testing.T, secret string, role string, exp time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			Issuer:    "issuer",
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+		Role: role,
+	})
+
+	signed, err := token.SignedString([]byte(secret))
+	assert.NoError(t, err)
+
+	return signed
+}
+
+func TestMiddleware(t *testing.T) {
+	apiKeys, err := NewAPIKeyAuthenticator(map[string]string{
+		"reader-key": "reader",
+		"admin-key":  "admin",
+	})
+	assert.NoError(t, err)
+
+	hmac, err := NewHMACAuthenticator([]byte("secret"), "issuer", "")
+	assert.NoError(t, err)
+
+	authenticator := Chain{apiKeys, hmac}
+
+	testCases := []struct {
+		name               string
+		header             string
+		value              string
+		role               Role
+		expectedStatusCode int
+		expectedSubject    string
+	}{
+		{
+			name:               "no credentials",
+			role:               RoleReader,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+
+		{
+			name:               "unknown api key",
+			header:             APIKeyHeader,
+			value:              "unknown",
+			role:               RoleReader,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+
+		{
+			name:               "reader api key reads",
+			header:             APIKeyHeader,
+			value:              "reader-key",
+			role:               RoleReader,
+			expectedStatusCode: http.StatusOK,
+		},
+
+		{
+			name:               "reader api key deletes",
+			header:             APIKeyHeader,
+			value:              "reader-key",
+			role:               RoleAdmin,
+			expectedStatusCode: http.StatusForbidden,
+		},
+
+		{
+			name:               "admin api key edits",
+			header:             APIKeyHeader,
+			value:              "admin-key",
+			role:               RoleEditor,
+			expectedStatusCode: http.StatusOK,
+		},
+
+		{
+			name:               "editor jwt",
+			header:             "Authorization",
+			value:              "Bearer " + signHMAC(t, "secret", "editor", time.Now().Add(time.Hour)),
+			role:               RoleEditor,
+			expectedStatusCode: http.StatusOK,
+			expectedSubject:    "user-1",
+		},
+
+		{
+			name:               "expired jwt",
+			header:             "Authorization",
+			value:              "Bearer " + signHMAC(t, "secret", "editor", time.Now().Add(-time.Hour)),
+			role:               RoleReader,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+
+		{
+			name:               "jwt with wrong secret",
+			header:             "Authorization",
+			value:              "Bearer " + signHMAC(t, "other", "admin", time.Now().Add(time.Hour)),
+			role:               RoleReader,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+
+		{
+			name:               "jwt with unknown role",
+			header:             "Authorization",
+			value:              "Bearer " + signHMAC(t, "secret", "root", time.Now().Add(time.Hour)),
+			role:               RoleReader,
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var principal *Principal
+
+			handler := Middleware(authenticator)(RequireRole(tc.role)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				principal = PrincipalFromContext(r.Context())
+			})))
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+
+			handler.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+
+			if tc.expectedStatusCode == http.StatusOK {
+				assert.NotNil(t, principal)
+			}
+
+			if tc.expectedSubject != "" {
+				assert.Equal(t, tc.expectedSubject, principal.Subject)
+			}
+		})
+	}
+}
+
+func TestJWKSAuthenticator(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	jwks, err := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "key-1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, os.WriteFile(path, jwks, 0o600))
+
+	a, err := NewJWKSAuthenticator(path, "", "api")
+	assert.NoError(t, err)
+
+	sign := func(kid string, aud string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:   "service",
+				Audience:  jwt.ClaimStrings{aud},
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			},
+			Role: "admin",
+		})
+		token.Header["kid"] = kid
+
+		signed, err := token.SignedString(key)
+		assert.NoError(t, err)
+
+		return signed
+	}
+
+	testCases := []struct {
+		name    string
+		token   string
+		isValid bool
+	}{
+		{
+			name:    "valid",
+			token:   sign("key-1", "api"),
+			isValid: true,
+		},
+
+		{
+			name:    "unknown kid",
+			token:   sign("key-2", "api"),
+			isValid: false,
+		},
+
+		{
+			name:    "wrong audience",
+			token:   sign("key-1", "other"),
+			isValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+
+			p, err := a.Authenticate(req)
+
+			if !tc.isValid {
+				assert.ErrorIs(t, err, ErrInvalidCredentials)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, &Principal{Subject: "service", Role: RoleAdmin, Method: "jwt"}, p)
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type claims struct {
+	jwt.RegisteredClaims
+	Role string `json:"role"`
+}
+
+// Bearer JWT signed with HMAC secret or keys from JWKS file
+type JWTAuthenticator struct {
+	parser  *jwt.Parser
+	keyFunc jwt.Keyfunc
+}
+
+// Create authenticator for HS256/HS384/HS512 tokens
+func NewHMACAuthenticator(secret []byte, issuer, audience string) (*JWTAuthenticator, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("auth: empty jwt secret")
+	}
+
+	return &JWTAuthenticator{
+		parser: newParser([]string{"HS256", "HS384", "HS512"}, issuer, audience),
+		keyFunc: func(*jwt.Token) (interface{}, error) {
+			return secret, nil
+		},
+	}, nil
+}
+
+// Create authenticator for RS*/ES* tokens verified by keys from JWKS file
+func NewJWKSAuthenticator(path, issuer, audience string) (*JWTAuthenticator, error) {
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("auth: reading jwks: %w", err)
+	}
+
+	keys, err := parseJWKS(data)
+
+	if err != nil {
+		return nil, fmt.Errorf("auth: parsing jwks: %w", err)
+	}
+
+	methods := []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}
+
+	return &JWTAuthenticator{
+		parser: newParser(methods, issuer, audience),
+		keyFunc: func(t *jwt.Token) (interface{}, error) {
+			kid, _ := t.Header["kid"].(string)
+
+			// Token without kid is allowed only if there is a single key
+			if kid == "" && len(keys) == 1 {
+				for _, key := range keys {
+					return key, nil
+				}
+			}
+
+			key, ok := keys[kid]
+
+			if !ok {
+				return nil, fmt.Errorf("unknown key id %q", kid)
+			}
+
+			return key, nil
+		},
+	}, nil
+}
+
+func newParser(methods []string, issuer, audience string) *jwt.Parser {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(methods),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30 * time.Second),
+	}
+
+	if issuer != "" {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+
+	if audience != "" {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	return jwt.NewParser(opts...)
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (*Principal, error) {
+	header := r.Header.Get("Authorization")
+
+	scheme, token, ok := strings.Cut(header, " ")
+
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, ErrNoCredentials
+	}
+
+	var c claims
+
+	if _, err := a.parser.ParseWithClaims(strings.TrimSpace(token), &c, a.keyFunc); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCredentials, err.Error())
+	}
+
+	role, err := ParseRole(c.Role)
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCredentials, err.Error())
+	}
+
+	return &Principal{
+		Subject: c.Subject,
+		Role:    role,
+		Method:  "jwt",
+	}, nil
+}
+
+// Parse public keys from JWKS document, keyed by kid
+func parseJWKS(data []byte) (map[string]interface{}, error) {
+	var set struct {
+		Keys []struct {
+			Kid string `json:"kid"`
+			Kty string `json:"kty"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}
+
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		switch k.Kty {
+		case "RSA":
+			n, err := decodeBigInt(k.N)
+
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+			}
+
+			e, err := decodeBigInt(k.E)
+
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+			}
+
+			keys[k.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			var curve elliptic.Curve
+
+			switch k.Crv {
+			case "P-256":
+				curve = elliptic.P256()
+			case "P-384":
+				curve = elliptic.P384()
+			case "P-521":
+				curve = elliptic.P521()
+			default:
+				return nil, fmt.Errorf("key %q: unsupported curve %q", k.Kid, k.Crv)
+			}
+
+			x, err := decodeBigInt(k.X)
+
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+			}
+
+			y, err := decodeBigInt(k.Y)
+
+			if err != nil {
+				return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+			}
+
+			keys[k.Kid] = &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+		default:
+			return nil, fmt.Errorf("key %q: unsupported key type %q", k.Kid, k.Kty)
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys")
+	}
+
+	return keys, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(data), nil
+}
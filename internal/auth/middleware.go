@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// Authenticate requests and put principal on the request context
+func Middleware(a Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p, err := a.Authenticate(r)
+
+			if err != nil {
+				if !errors.Is(err, ErrNoCredentials) {
+					slog.Warn(fmt.Sprintf("auth: %s %s: %s", r.Method, r.URL.Path, err.Error()))
+				}
+
+				w.Header().Set("WWW-Authenticate", `Bearer, ApiKey header="`+APIKeyHeader+`"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			// Audit log
+			slog.Info(
+				fmt.Sprintf("auth: %s %s", r.Method, r.URL.Path),
+				"principal", p.String(),
+				"role", string(p.Role),
+			)
+
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), p)))
+		})
+	}
+}
+
+// Allow only principals with at least the given role
+func RequireRole(role Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			p := PrincipalFromContext(r.Context())
+
+			if p == nil {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			if !p.Role.Allows(role) {
+				slog.Warn(fmt.Sprintf("auth: %s is not allowed to %s %s", p, r.Method, r.URL.Path))
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
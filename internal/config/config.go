@@ -1,15 +1,416 @@
 package config
 
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/ilyakaznacheev/cleanenv"
+	"github.com/joho/godotenv"
+)
+
+// Config is the effective application configuration.
+//
+// Values are layered: struct defaults, then the config file, then
+// environment variables, then command line flags.
 type Config struct {
-	Host        string `env:"SERVER_HOST" env-default:"localhost"`
-	Port        string `env:"SERVER_PORT" env-default:"9999"`
-	DatabaseURL string `env:"DB_URL"`
+	LogLevel   string     `yaml:"log_level" env:"LOG_LEVEL" env-default:"info"`
+	Server     Server     `yaml:"server" env-prefix:"SERVER_"`
+	GRPC       GRPC       `yaml:"grpc" env-prefix:"GRPC_"`
+	GraphQL    GraphQL    `yaml:"graphql" env-prefix:"GRAPHQL_"`
+	Database   Database   `yaml:"database" env-prefix:"DB_"`
+	Enrichment Enrichment `yaml:"enrichment" env-prefix:"ENRICH_"`
+	Cache      Cache      `yaml:"cache" env-prefix:"CACHE_"`
+	Auth       Auth       `yaml:"auth" env-prefix:"AUTH_"`
+	RateLimit  RateLimit  `yaml:"rate_limit" env-prefix:"RATE_LIMIT_"`
+	Outbox     Outbox     `yaml:"outbox" env-prefix:"OUTBOX_"`
+	Webhooks   Webhooks   `yaml:"webhooks" env-prefix:"WEBHOOKS_"`
+	Events     Events     `yaml:"events" env-prefix:"EVENTS_"`
+}
+
+type Server struct {
+	Host              string        `yaml:"host" env:"HOST" env-default:"localhost"`
+	Port              string        `yaml:"port" env:"PORT" env-default:"9999"`
+	ReadTimeout       time.Duration `yaml:"read_timeout" env:"READ_TIMEOUT" env-default:"10s"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" env:"READ_HEADER_TIMEOUT" env-default:"5s"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" env:"WRITE_TIMEOUT" env-default:"30s"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" env:"IDLE_TIMEOUT" env-default:"120s"`
+	ShutdownTimeout   time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"15s"`
+	// Serve /debug/vars to admins, it shows command line flags like -db-url
+	DebugVars bool `yaml:"debug_vars" env:"DEBUG_VARS"`
+}
+
+type GRPC struct {
+	Enabled bool   `yaml:"enabled" env:"ENABLED"`
+	Host    string `yaml:"host" env:"HOST" env-default:"localhost"`
+	Port    string `yaml:"port" env:"PORT" env-default:"9998"`
+}
+
+type GraphQL struct {
+	Enabled bool `yaml:"enabled" env:"ENABLED"`
+	// Deepest selection allowed in a query
+	MaxDepth int `yaml:"max_depth" env:"MAX_DEPTH" env-default:"10"`
+	// Max cost of a query, lists cost their items times page size
+	MaxComplexity int `yaml:"max_complexity" env:"MAX_COMPLEXITY" env-default:"1000"`
+}
+
+type Database struct {
+	URL string `yaml:"url" env:"URL"`
+	// Database/sql driver: postgres (lib/pq) or pgx (pgx stdlib), sqlite keeps users
+	// in a file at url, memory keeps them in memory without a database, they are lost on exit
+	Driver          string        `yaml:"driver" env:"DRIVER" env-default:"postgres"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"MAX_OPEN_CONNS" env-default:"25"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"MAX_IDLE_CONNS" env-default:"25"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"CONN_MAX_LIFETIME" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"CONN_MAX_IDLE_TIME" env-default:"5m"`
+	// Prepared statements cached per connection by pgx driver, 0 disables cache
+	StatementCacheCapacity int `yaml:"statement_cache_capacity" env:"STATEMENT_CACHE_CAPACITY" env-default:"512"`
+}
+
+type Enrichment struct {
+	AgifyURL       string        `yaml:"agify_url" env:"AGIFY_URL" env-default:"https://api.agify.io/"`
+	GenderizeURL   string        `yaml:"genderize_url" env:"GENDERIZE_URL" env-default:"https://api.genderize.io/"`
+	NationalizeURL string        `yaml:"nationalize_url" env:"NATIONALIZE_URL" env-default:"https://api.nationalize.io/"`
+	Timeout        time.Duration `yaml:"timeout" env:"TIMEOUT" env-default:"5s"`
+	UserAgent      string        `yaml:"user_agent" env:"USER_AGENT" env-default:"effective-mobile-test-task/1.0"`
+	// Key of paid upstream plan sent with every request, free plan is used if empty
+	APIKey string `yaml:"api_key" env:"API_KEY"`
+	// Proxy url, HTTP_PROXY/HTTPS_PROXY are used if empty
+	Proxy              string `yaml:"proxy" env:"PROXY"`
+	MaxResponseBytes   int64  `yaml:"max_response_bytes" env:"MAX_RESPONSE_BYTES" env-default:"1048576"`
+	DisableCompression bool   `yaml:"disable_compression" env:"DISABLE_COMPRESSION"`
+	// Consecutive upstream failures opening the circuit, 0 disables circuit breaker
+	BreakerFailureThreshold int           `yaml:"breaker_failure_threshold" env:"BREAKER_FAILURE_THRESHOLD" env-default:"5"`
+	BreakerOpenTimeout      time.Duration `yaml:"breaker_open_timeout" env:"BREAKER_OPEN_TIMEOUT" env-default:"30s"`
+	BreakerHalfOpenRequests int           `yaml:"breaker_half_open_requests" env:"BREAKER_HALF_OPEN_REQUESTS" env-default:"1"`
+}
+
+type Cache struct {
+	Enabled bool          `yaml:"enabled" env:"ENABLED"`
+	TTL     time.Duration `yaml:"ttl" env:"TTL" env-default:"24h"`
+	Size    int           `yaml:"size" env:"SIZE" env-default:"10000"`
+	// Users stats are cached for a short time, zero disables it
+	StatsTTL time.Duration `yaml:"stats_ttl" env:"STATS_TTL" env-default:"30s"`
+}
+
+type Auth struct {
+	Enabled bool `yaml:"enabled" env:"ENABLED"`
+	// API keys mapped to roles, e.g. "key1:admin,key2:reader"
+	APIKeys     map[string]string `yaml:"api_keys" env:"API_KEYS"`
+	JWTSecret   string            `yaml:"jwt_secret" env:"JWT_SECRET"`
+	JWKSFile    string            `yaml:"jwks_file" env:"JWKS_FILE"`
+	JWTIssuer   string            `yaml:"jwt_issuer" env:"JWT_ISSUER"`
+	JWTAudience string            `yaml:"jwt_audience" env:"JWT_AUDIENCE"`
+}
+
+type RateLimit struct {
+	Enabled bool    `yaml:"enabled" env:"ENABLED"`
+	RPS     float64 `yaml:"rps" env:"RPS" env-default:"5"`
+	Burst   int     `yaml:"burst" env:"BURST" env-default:"10"`
+	// Upstream requests per day to each enrichment api shared by all clients
+	DailyBudget int `yaml:"daily_budget" env:"DAILY_BUDGET" env-default:"1000"`
+	// Remaining upstream quota at which enrichment switches to cache only
+	BudgetReserve int `yaml:"budget_reserve" env:"BUDGET_RESERVE" env-default:"50"`
+}
+
+type Outbox struct {
+	Enabled bool `yaml:"enabled" env:"ENABLED"`
+	// Where events are published: file, webhook, nats or kafka
+	Sink      string        `yaml:"sink" env:"SINK" env-default:"file"`
+	Interval  time.Duration `yaml:"interval" env:"INTERVAL" env-default:"1s"`
+	BatchSize int           `yaml:"batch_size" env:"BATCH_SIZE" env-default:"100"`
+	// Events are appended as json lines
+	FilePath       string        `yaml:"file_path" env:"FILE_PATH" env-default:"events.ndjson"`
+	WebhookURL     string        `yaml:"webhook_url" env:"WEBHOOK_URL"`
+	WebhookTimeout time.Duration `yaml:"webhook_timeout" env:"WEBHOOK_TIMEOUT" env-default:"5s"`
+	// Events are published to a JetStream stream bound to the subject
+	NATSURL      string   `yaml:"nats_url" env:"NATS_URL" env-default:"nats://localhost:4222"`
+	NATSSubject  string   `yaml:"nats_subject" env:"NATS_SUBJECT" env-default:"users.events"`
+	KafkaBrokers []string `yaml:"kafka_brokers" env:"KAFKA_BROKERS" env-default:"localhost:9092"`
+	KafkaTopic   string   `yaml:"kafka_topic" env:"KAFKA_TOPIC" env-default:"users.events"`
+}
+
+type Webhooks struct {
+	Enabled     bool          `yaml:"enabled" env:"ENABLED"`
+	Interval    time.Duration `yaml:"interval" env:"INTERVAL" env-default:"1s"`
+	BatchSize   int           `yaml:"batch_size" env:"BATCH_SIZE" env-default:"50"`
+	Concurrency int           `yaml:"concurrency" env:"CONCURRENCY" env-default:"10"`
+	Timeout     time.Duration `yaml:"timeout" env:"TIMEOUT" env-default:"10s"`
+	// Delays before retries of a failed delivery, it fails for good once they are over
+	RetrySchedule []time.Duration `yaml:"retry_schedule" env:"RETRY_SCHEDULE" env-default:"1m,5m,30m,2h,12h"`
+}
+
+type Events struct {
+	Enabled bool `yaml:"enabled" env:"ENABLED"`
+	// Recent changes clients resume from
+	BufferSize int `yaml:"buffer_size" env:"BUFFER_SIZE" env-default:"1000"`
+	// Changes queued for a slow client before it is disconnected
+	SubscriberBuffer int           `yaml:"subscriber_buffer" env:"SUBSCRIBER_BUFFER" env-default:"64"`
+	Heartbeat        time.Duration `yaml:"heartbeat" env:"HEARTBEAT" env-default:"15s"`
+}
+
+// Flags that override file and environment values
+type Flags struct {
+	host     string
+	port     string
+	dbURL    string
+	logLevel string
+	fs       *flag.FlagSet
+}
+
+// Register config override flags in flag set
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{fs: fs}
+
+	fs.StringVar(&f.host, "host", "", "server host (overrides SERVER_HOST)")
+	fs.StringVar(&f.port, "port", "", "server port (overrides SERVER_PORT)")
+	fs.StringVar(&f.dbURL, "db-url", "", "database url (overrides DB_URL)")
+	fs.StringVar(&f.logLevel, "log-level", "", "log level: debug, info, warn, error (overrides LOG_LEVEL)")
+
+	return f
+}
+
+// Load config from defaults, file, environment and flags and validate it.
+// File is skipped if path is empty. Flags may be nil.
+func Load(path string, flags *Flags) (*Config, error) {
+	var cfg Config
+
+	if path != "" {
+		if err := readFile(path, &cfg); err != nil {
+			return nil, fmt.Errorf("config: reading %s: %w", path, err)
+		}
+	}
+
+	if err := cleanenv.ReadEnv(&cfg); err != nil {
+		return nil, fmt.Errorf("config: reading env: %w", err)
+	}
+
+	if flags != nil {
+		flags.apply(&cfg)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("config: invalid: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// Read config file. Variables from .env files never override the ones
+// already set in the environment.
+func readFile(path string, cfg *Config) error {
+	if strings.ToLower(filepath.Ext(path)) != ".env" {
+		return cleanenv.ReadConfig(path, cfg)
+	}
+
+	vars, err := godotenv.Read(path)
+
+	if err != nil {
+		return err
+	}
+
+	for k, v := range vars {
+		if _, ok := os.LookupEnv(k); ok {
+			continue
+		}
+
+		if err := os.Setenv(k, v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Apply flags explicitly set on the command line
+func (f *Flags) apply(cfg *Config) {
+	f.fs.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "host":
+			cfg.Server.Host = f.host
+		case "port":
+			cfg.Server.Port = f.port
+		case "db-url":
+			cfg.Database.URL = f.dbURL
+		case "log-level":
+			cfg.LogLevel = f.logLevel
+		}
+	})
+}
+
+func (c *Config) Validate() error {
+	return validation.ValidateStruct(c,
+		validation.Field(&c.LogLevel, validation.Required, validation.In("debug", "info", "warn", "error")),
+		validation.Field(&c.Server),
+		validation.Field(&c.GRPC),
+		validation.Field(&c.GraphQL),
+		validation.Field(&c.Database),
+		validation.Field(&c.Enrichment),
+		validation.Field(&c.Cache),
+		validation.Field(&c.Auth),
+		validation.Field(&c.RateLimit),
+		validation.Field(&c.Outbox, when(!c.Database.Postgres(), requirePostgres(c.Outbox.Enabled))...),
+		validation.Field(&c.Webhooks, when(!c.Database.Postgres(), requirePostgres(c.Webhooks.Enabled))...),
+		validation.Field(&c.Events, when(!c.Database.Postgres(), requirePostgres(c.Events.Enabled))...),
+	)
+}
+
+func (s Server) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Port, validation.Required, is.Port),
+		validation.Field(&s.ReadTimeout, validation.Min(time.Duration(0))),
+		validation.Field(&s.ReadHeaderTimeout, validation.Min(time.Duration(0))),
+		validation.Field(&s.WriteTimeout, validation.Min(time.Duration(0))),
+		validation.Field(&s.IdleTimeout, validation.Min(time.Duration(0))),
+		validation.Field(&s.ShutdownTimeout, validation.Min(time.Duration(0))),
+	)
+}
+
+func (g GRPC) Validate() error {
+	return validation.ValidateStruct(&g,
+		validation.Field(&g.Port, when(g.Enabled, validation.Required, is.Port)...),
+	)
+}
+
+func (g GraphQL) Validate() error {
+	return validation.ValidateStruct(&g,
+		validation.Field(&g.MaxDepth, when(g.Enabled, validation.Required, validation.Min(1))...),
+		validation.Field(&g.MaxComplexity, when(g.Enabled, validation.Required, validation.Min(1))...),
+	)
+}
+
+func (o Outbox) Validate() error {
+	return validation.ValidateStruct(&o,
+		validation.Field(&o.Sink, when(o.Enabled, validation.Required, validation.In("file", "webhook", "nats", "kafka"))...),
+		validation.Field(&o.Interval, when(o.Enabled, validation.Required, validation.Min(time.Millisecond))...),
+		validation.Field(&o.BatchSize, when(o.Enabled, validation.Required, validation.Min(1), validation.Max(1000))...),
+		validation.Field(&o.FilePath, when(o.Enabled && o.Sink == "file", validation.Required)...),
+		validation.Field(&o.WebhookURL, when(o.Enabled && o.Sink == "webhook", validation.Required, is.URL)...),
+		validation.Field(&o.NATSURL, when(o.Enabled && o.Sink == "nats", validation.Required)...),
+		validation.Field(&o.NATSSubject, when(o.Enabled && o.Sink == "nats", validation.Required)...),
+		validation.Field(&o.KafkaBrokers, when(o.Enabled && o.Sink == "kafka", validation.Required)...),
+		validation.Field(&o.KafkaTopic, when(o.Enabled && o.Sink == "kafka", validation.Required)...),
+	)
+}
+
+func (w Webhooks) Validate() error {
+	return validation.ValidateStruct(&w,
+		validation.Field(&w.Interval, when(w.Enabled, validation.Required, validation.Min(time.Millisecond))...),
+		validation.Field(&w.BatchSize, when(w.Enabled, validation.Required, validation.Min(1), validation.Max(1000))...),
+		validation.Field(&w.Concurrency, when(w.Enabled, validation.Required, validation.Min(1))...),
+		validation.Field(&w.Timeout, when(w.Enabled, validation.Required, validation.Min(time.Millisecond))...),
+		validation.Field(&w.RetrySchedule, when(w.Enabled, validation.Each(validation.Min(time.Second)))...),
+	)
+}
+
+func (e Events) Validate() error {
+	return validation.ValidateStruct(&e,
+		validation.Field(&e.BufferSize, when(e.Enabled, validation.Required, validation.Min(1))...),
+		validation.Field(&e.SubscriberBuffer, when(e.Enabled, validation.Required, validation.Min(1))...),
+		validation.Field(&e.Heartbeat, when(e.Enabled, validation.Required, validation.Min(time.Second))...),
+	)
+}
+
+// Check if database is postgres with any driver
+func (d Database) Postgres() bool {
+	return d.Driver == "postgres" || d.Driver == "pgx"
+}
+
+func (d Database) Validate() error {
+	return validation.ValidateStruct(&d,
+		validation.Field(&d.URL, when(d.Driver != "memory", validation.Required)...),
+		validation.Field(&d.Driver, validation.Required, validation.In("postgres", "pgx", "sqlite", "memory")),
+		validation.Field(&d.MaxOpenConns, validation.Min(0)),
+		validation.Field(&d.MaxIdleConns, validation.Min(0)),
+		validation.Field(&d.ConnMaxLifetime, validation.Min(time.Duration(0))),
+		validation.Field(&d.ConnMaxIdleTime, validation.Min(time.Duration(0))),
+		validation.Field(&d.StatementCacheCapacity, validation.Min(0)),
+	)
+}
+
+func (e Enrichment) Validate() error {
+	return validation.ValidateStruct(&e,
+		validation.Field(&e.AgifyURL, validation.Required, is.URL),
+		validation.Field(&e.GenderizeURL, validation.Required, is.URL),
+		validation.Field(&e.NationalizeURL, validation.Required, is.URL),
+		validation.Field(&e.Timeout, validation.Required, validation.Min(time.Millisecond)),
+		validation.Field(&e.UserAgent, validation.Required),
+		validation.Field(&e.Proxy, is.URL),
+		validation.Field(&e.MaxResponseBytes, validation.Required, validation.Min(int64(1))),
+		validation.Field(&e.BreakerFailureThreshold, validation.Min(0)),
+		validation.Field(&e.BreakerOpenTimeout, when(e.BreakerFailureThreshold > 0, validation.Required, validation.Min(time.Millisecond))...),
+		validation.Field(&e.BreakerHalfOpenRequests, when(e.BreakerFailureThreshold > 0, validation.Required, validation.Min(1))...),
+	)
+}
+
+func (c Cache) Validate() error {
+	return validation.ValidateStruct(&c,
+		validation.Field(&c.TTL, when(c.Enabled, validation.Required, validation.Min(time.Second))...),
+		validation.Field(&c.Size, when(c.Enabled, validation.Required, validation.Min(1))...),
+		validation.Field(&c.StatsTTL, validation.Min(time.Duration(0))),
+	)
+}
+
+func (a Auth) Validate() error {
+	return validation.ValidateStruct(&a,
+		validation.Field(&a.APIKeys, append(
+			when(a.Enabled && a.JWTSecret == "" && a.JWKSFile == "",
+				validation.Required.Error("api keys, jwt secret or jwks file are required when auth is enabled")),
+			validation.By(validateRoles),
+		)...),
+		validation.Field(&a.JWKSFile, when(a.JWTSecret != "",
+			validation.By(func(interface{}) error {
+				return errors.New("must not be set together with jwt secret")
+			}))...),
+	)
 }
 
-func New(host, port, databaseURL, logLevel string) *Config {
-	return &Config{
-		Host:        host,
-		Port:        port,
-		DatabaseURL: databaseURL,
+func (r RateLimit) Validate() error {
+	return validation.ValidateStruct(&r,
+		validation.Field(&r.RPS, when(r.Enabled, validation.Required, validation.Min(0.0))...),
+		validation.Field(&r.Burst, when(r.Enabled, validation.Required, validation.Min(1))...),
+		validation.Field(&r.DailyBudget, validation.Min(0)),
+		validation.Field(&r.BudgetReserve, validation.Min(0)),
+	)
+}
+
+// Apply rules only if condition is true
+func when(condition bool, rules ...validation.Rule) []validation.Rule {
+	if !condition {
+		return nil
 	}
+
+	return rules
+}
+
+// Features stored in postgres can't be enabled without it
+func requirePostgres(enabled bool) validation.Rule {
+	return validation.By(func(interface{}) error {
+		if enabled {
+			return errors.New("requires postgres database")
+		}
+
+		return nil
+	})
+}
+
+// Check that every api key is mapped to a known role
+func validateRoles(value interface{}) error {
+	keys, _ := value.(map[string]string)
+
+	for _, role := range keys {
+		switch role {
+		case "reader", "editor", "admin":
+		default:
+			return errors.New("roles must be one of: reader, editor, admin")
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,225 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+type UserService interface {
+	Get(ctx context.Context, userFilter *domain.UserFilter) ([]domain.User, error)
+	Delete(ctx context.Context, id int) error
+	Update(ctx context.Context, id int, u *domain.User) error
+	Create(ctx context.Context, u *domain.User) error
+	GetById(ctx context.Context, id int, fields ...string) (*domain.User, error)
+}
+
+const (
+	defaultMaxDepth      = 10
+	defaultMaxComplexity = 1000
+
+	// Largest request body accepted
+	maxRequestBytes = 1 << 20
+)
+
+// Graphql endpoint over users
+type Handler struct {
+	service       UserService
+	schema        graphql.Schema
+	checkRoles    bool
+	maxDepth      int
+	maxComplexity int
+}
+
+type Option func(h *Handler)
+
+// Check roles of principals put on request context by auth middleware,
+// mutations need the same roles as the http methods doing the same
+func WithRoles() Option {
+	return func(h *Handler) {
+		h.checkRoles = true
+	}
+}
+
+// Reject queries deeper than maxDepth or costing more than maxComplexity
+func WithLimits(maxDepth, maxComplexity int) Option {
+	return func(h *Handler) {
+		h.maxDepth = maxDepth
+		h.maxComplexity = maxComplexity
+	}
+}
+
+func New(service UserService, opts ...Option) (*Handler, error) {
+	h := &Handler{
+		service:       service,
+		maxDepth:      defaultMaxDepth,
+		maxComplexity: defaultMaxComplexity,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	schema, err := h.newSchema()
+
+	if err != nil {
+		return nil, fmt.Errorf("graphql: building schema: %w", err)
+	}
+
+	h.schema = schema
+
+	return h, nil
+}
+
+type request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Serve queries sent with GET and queries and mutations sent with POST
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	req, status, err := readRequest(w, r)
+
+	if err != nil {
+		slog.Error(fmt.Sprintf("graphql: %s", err.Error()))
+		w.WriteHeader(status)
+		return
+	}
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	if result := graphql.ValidateDocument(&h.schema, doc, nil); !result.IsValid {
+		writeResult(w, http.StatusBadRequest, &graphql.Result{Errors: result.Errors})
+		return
+	}
+
+	op, err := operation(doc, req.OperationName)
+
+	if err != nil {
+		writeResult(w, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	// GET must not change anything
+	if r.Method == http.MethodGet && op.Operation != ast.OperationTypeQuery {
+		w.Header().Set("Allow", http.MethodPost)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err := h.checkLimits(doc, op, req.Variables); err != nil {
+		slog.Warn(fmt.Sprintf("graphql: %s", err.Error()))
+		writeResult(w, http.StatusBadRequest, &graphql.Result{Errors: gqlerrors.FormatErrors(err)})
+		return
+	}
+
+	result := graphql.Execute(graphql.ExecuteParams{
+		Schema:        h.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       r.Context(),
+	})
+
+	writeResult(w, http.StatusOK, result)
+}
+
+// Read request from GET params or POST json body
+func readRequest(w http.ResponseWriter, r *http.Request) (*request, int, error) {
+	req := &request{}
+
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+
+		req.Query = query.Get("query")
+		req.OperationName = query.Get("operationName")
+
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return nil, http.StatusBadRequest, fmt.Errorf("parsing variables: %w", err)
+			}
+		}
+	case http.MethodPost:
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		if mediaType != "application/json" {
+			return nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q", mediaType)
+		}
+
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBytes)).Decode(req); err != nil {
+			var maxBytesErr *http.MaxBytesError
+
+			if errors.As(err, &maxBytesErr) {
+				return nil, http.StatusRequestEntityTooLarge, err
+			}
+
+			return nil, http.StatusBadRequest, fmt.Errorf("decoding request: %w", err)
+		}
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		return nil, http.StatusMethodNotAllowed, fmt.Errorf("method %s is not allowed", r.Method)
+	}
+
+	if req.Query == "" {
+		return nil, http.StatusBadRequest, errors.New("query is required")
+	}
+
+	return req, http.StatusOK, nil
+}
+
+// Operation to execute, the only one if name is empty
+func operation(doc *ast.Document, name string) (*ast.OperationDefinition, error) {
+	var found *ast.OperationDefinition
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+
+		if !ok {
+			continue
+		}
+
+		if name == "" && found != nil {
+			return nil, errors.New("operationName is required for documents with several operations")
+		}
+
+		if name == "" || (op.Name != nil && op.Name.Value == name) {
+			found = op
+		}
+	}
+
+	if found == nil {
+		return nil, fmt.Errorf("unknown operation %q", name)
+	}
+
+	return found, nil
+}
+
+func writeResult(w http.ResponseWriter, status int, result *graphql.Result) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.Error(fmt.Sprintf("graphql: %s", err.Error()))
+	}
+}
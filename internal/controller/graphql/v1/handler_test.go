@@ -0,0 +1,357 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/auth"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	mock_service "github.com/sletkov/effective-mobile-test-task/internal/service/mocks"
+)
+
+type response struct {
+	Data   map[string]interface{} `json:"data"`
+	Errors []struct {
+		Message    string                 `json:"message"`
+		Extensions map[string]interface{} `json:"extensions"`
+	} `json:"errors"`
+}
+
+func post(t *testing.T, h http.Handler, ctx context.Context, query string, variables map[string]interface{}) (int, *response) {
+	body, err := json.Marshal(request{Query: query, Variables: variables})
+	assert.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewReader(body)).WithContext(ctx)
+	r.Header.Set("Content-Type", "application/json")
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	resp := &response{}
+
+	if w.Body.Len() > 0 {
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), resp))
+	}
+
+	return w.Code, resp
+}
+
+func TestHandlerUsers(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockUserService, userFilter *domain.UserFilter)
+
+	query := `query($filter: UserFilter, $after: String) {
+		users(filter: $filter, sort: [{field: AGE, desc: true}], first: 2, after: $after) {
+			edges { cursor node { id name patronymic gender } }
+			pageInfo { hasNextPage hasPreviousPage endCursor }
+		}
+	}`
+
+	testCases := []struct {
+		name               string
+		variables          map[string]interface{}
+		userFilter         *domain.UserFilter
+		mockBehavior       mockBehavior
+		expectedConnection map[string]interface{}
+		expectedCode       string
+	}{
+		{
+			name: "OK",
+			variables: map[string]interface{}{
+				"filter": map[string]interface{}{
+					"nationality": map[string]interface{}{"in": []string{"RU", "UA"}},
+					"or": []map[string]interface{}{
+						{"age": map[string]interface{}{"lt": 30}},
+						{"patronymic": map[string]interface{}{"null": true}},
+					},
+				},
+				"after": encodeCursor(1),
+			},
+			userFilter: &domain.UserFilter{
+				Expr: domain.And(
+					domain.Cond("nationality", domain.FilterIn, "RU", "UA"),
+					domain.Or(
+						domain.And(domain.Cond("age", domain.FilterLt, "30")),
+						domain.And(domain.FilterExpr{Op: domain.FilterNull, Field: "patronymic"}),
+					),
+				),
+				Sort:   []domain.SortField{{Field: "age", Desc: true}},
+				Limit:  3,
+				Offset: 2,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {
+				s.EXPECT().Get(gomock.Any(), userFilter).Return([]domain.User{
+					{Id: 4, Name: "Ivan", Gender: "male"},
+					{Id: 7, Name: "Anna", Patronymic: "Ivanovna", Gender: "female"},
+					{Id: 2, Name: "Petr", Gender: "male"},
+				}, nil)
+			},
+			expectedConnection: map[string]interface{}{
+				"edges": []interface{}{
+					map[string]interface{}{
+						"cursor": encodeCursor(2),
+						"node":   map[string]interface{}{"id": float64(4), "name": "Ivan", "patronymic": nil, "gender": "MALE"},
+					},
+					map[string]interface{}{
+						"cursor": encodeCursor(3),
+						"node":   map[string]interface{}{"id": float64(7), "name": "Anna", "patronymic": "Ivanovna", "gender": "FEMALE"},
+					},
+				},
+				"pageInfo": map[string]interface{}{
+					"hasNextPage":     true,
+					"hasPreviousPage": true,
+					"endCursor":       encodeCursor(3),
+				},
+			},
+		},
+
+		{
+			name: "last page",
+			userFilter: &domain.UserFilter{
+				Sort:  []domain.SortField{{Field: "age", Desc: true}},
+				Limit: 3,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {
+				s.EXPECT().Get(gomock.Any(), userFilter).Return([]domain.User{}, nil)
+			},
+			expectedConnection: map[string]interface{}{
+				"edges": []interface{}{},
+				"pageInfo": map[string]interface{}{
+					"hasNextPage":     false,
+					"hasPreviousPage": false,
+					"endCursor":       nil,
+				},
+			},
+		},
+
+		{
+			name: "operator not allowed",
+			variables: map[string]interface{}{
+				"filter": map[string]interface{}{"name": map[string]interface{}{"null": true}},
+			},
+			mockBehavior: func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {},
+			expectedCode: "BAD_USER_INPUT",
+		},
+
+		{
+			name:         "invalid cursor",
+			variables:    map[string]interface{}{"after": "cursor"},
+			mockBehavior: func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {},
+			expectedCode: "BAD_USER_INPUT",
+		},
+
+		{
+			name: "service error",
+			userFilter: &domain.UserFilter{
+				Sort:  []domain.SortField{{Field: "age", Desc: true}},
+				Limit: 3,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {
+				s.EXPECT().Get(gomock.Any(), userFilter).Return(nil, context.DeadlineExceeded)
+			},
+			expectedCode: "INTERNAL",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			userService := mock_service.NewMockUserService(c)
+			tc.mockBehavior(userService, tc.userFilter)
+
+			h, err := New(userService)
+			assert.NoError(t, err)
+
+			status, resp := post(t, h, context.Background(), query, tc.variables)
+			assert.Equal(t, http.StatusOK, status)
+
+			if tc.expectedCode != "" {
+				assert.Len(t, resp.Errors, 1)
+				assert.Equal(t, tc.expectedCode, resp.Errors[0].Extensions["code"])
+				return
+			}
+
+			assert.Empty(t, resp.Errors)
+			assert.Equal(t, tc.expectedConnection, resp.Data["users"])
+		})
+	}
+}
+
+func TestHandlerMutations(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	userService := mock_service.NewMockUserService(c)
+
+	h, err := New(userService, WithRoles())
+	assert.NoError(t, err)
+
+	editor := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "editor", Role: auth.RoleEditor})
+	admin := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "admin", Role: auth.RoleAdmin})
+
+	userService.EXPECT().Create(gomock.Any(), &domain.User{Name: "Ivan", Surname: "Ivanov"}).DoAndReturn(
+		func(ctx context.Context, u *domain.User) error {
+			u.Id, u.Age, u.Gender, u.Nationality = 1, 20, "male", "RU"
+			return nil
+		})
+
+	_, resp := post(t, h, editor, `mutation { createUser(input: {name: "Ivan", surname: "Ivanov"}) { id age gender } }`, nil)
+	assert.Empty(t, resp.Errors)
+	assert.Equal(t, map[string]interface{}{"id": float64(1), "age": float64(20), "gender": "MALE"}, resp.Data["createUser"])
+
+	_, resp = post(t, h, editor, `mutation { createUser(input: {name: "Ivan1", surname: "Ivanov"}) { id } }`, nil)
+	assert.Equal(t, "BAD_USER_INPUT", resp.Errors[0].Extensions["code"])
+
+	userService.EXPECT().GetById(gomock.Any(), 1).Return(&domain.User{Id: 1, Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "male", Nationality: "RU"}, nil)
+	userService.EXPECT().Update(gomock.Any(), 1, &domain.User{Id: 1, Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "female", Nationality: "RU"}).Return(nil)
+
+	_, resp = post(t, h, editor, `mutation { updateUser(id: 1, input: {gender: FEMALE}) { gender } }`, nil)
+	assert.Empty(t, resp.Errors)
+	assert.Equal(t, map[string]interface{}{"gender": "FEMALE"}, resp.Data["updateUser"])
+
+	userService.EXPECT().GetById(gomock.Any(), 2).Return(nil, domain.ErrUserNotFound)
+
+	_, resp = post(t, h, editor, `mutation { updateUser(id: 2, input: {age: 30}) { id } }`, nil)
+	assert.Equal(t, "NOT_FOUND", resp.Errors[0].Extensions["code"])
+
+	_, resp = post(t, h, editor, `mutation { deleteUser(id: 1) }`, nil)
+	assert.Equal(t, "FORBIDDEN", resp.Errors[0].Extensions["code"])
+
+	_, resp = post(t, h, context.Background(), `mutation { deleteUser(id: 1) }`, nil)
+	assert.Equal(t, "UNAUTHENTICATED", resp.Errors[0].Extensions["code"])
+
+	userService.EXPECT().Delete(gomock.Any(), 1).Return(nil)
+
+	_, resp = post(t, h, admin, `mutation { deleteUser(id: 1) }`, nil)
+	assert.Empty(t, resp.Errors)
+	assert.Equal(t, true, resp.Data["deleteUser"])
+}
+
+func TestHandlerUser(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	userService := mock_service.NewMockUserService(c)
+
+	h, err := New(userService)
+	assert.NoError(t, err)
+
+	userService.EXPECT().GetById(gomock.Any(), 3).Return(nil, domain.ErrUserNotFound)
+
+	_, resp := post(t, h, context.Background(), `{ user(id: 3) { name } }`, nil)
+	assert.Empty(t, resp.Errors)
+	assert.Nil(t, resp.Data["user"])
+
+	userService.EXPECT().GetById(gomock.Any(), 1).Return(&domain.User{Id: 1, Name: "Ivan"}, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(`{ user(id: 1) { name } }`), nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data": {"user": {"name": "Ivan"}}}`, w.Body.String())
+
+	r = httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(`mutation { deleteUser(id: 1) }`), nil)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	r = httptest.NewRequest(http.MethodPost, "/graphql", bytes.NewBufferString(`{ user(id: 1) { name } }`))
+	r.Header.Set("Content-Type", "application/graphql")
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestHandlerLimits(t *testing.T) {
+	testCases := []struct {
+		name      string
+		query     string
+		variables map[string]interface{}
+		isValid   bool
+	}{
+		{
+			name:    "default page",
+			query:   `{ users { edges { cursor } pageInfo { hasNextPage endCursor } } }`,
+			isValid: true,
+		},
+
+		{
+			name:    "too deep",
+			query:   `{ users(first: 1) { edges { node { id } } } }`,
+			isValid: false,
+		},
+
+		{
+			name:    "too complex page",
+			query:   `{ users(first: 50) { edges { cursor } } }`,
+			isValid: false,
+		},
+
+		{
+			name:      "page size from variable",
+			query:     `query($first: Int) { users(first: $first) { edges { cursor } } }`,
+			variables: map[string]interface{}{"first": 60},
+			isValid:   false,
+		},
+
+		{
+			name:    "page size from variable default",
+			query:   `query($first: Int = 60) { users(first: $first) { edges { cursor } } }`,
+			isValid: false,
+		},
+
+		{
+			name:    "fragments are counted",
+			query:   `{ users(first: 30) { ...page } } fragment page on UserConnection { edges { cursor } pageInfo { hasNextPage } }`,
+			isValid: false,
+		},
+
+		{
+			name:    "introspection",
+			query:   `{ __schema { types { name fields { name type { name ofType { name ofType { name } } } } } } }`,
+			isValid: true,
+		},
+
+		{
+			name:    "invalid query",
+			query:   `{ users { edges { node { password } } } }`,
+			isValid: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			userService := mock_service.NewMockUserService(c)
+			userService.EXPECT().Get(gomock.Any(), gomock.Any()).Return([]domain.User{}, nil).AnyTimes()
+
+			h, err := New(userService, WithLimits(3, 100))
+			assert.NoError(t, err)
+
+			status, resp := post(t, h, context.Background(), tc.query, tc.variables)
+
+			if !tc.isValid {
+				assert.Equal(t, http.StatusBadRequest, status)
+				assert.NotEmpty(t, resp.Errors)
+				return
+			}
+
+			assert.Equal(t, http.StatusOK, status)
+			assert.Empty(t, resp.Errors)
+		})
+	}
+}
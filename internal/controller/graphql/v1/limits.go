@@ -0,0 +1,132 @@
+package v1
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Fields returning a page of items and page size when first is not given
+var connectionFields = map[string]int{
+	"users": defaultFirst,
+}
+
+// Depth and cost of operation. Every field costs 1, fields under a connection
+// are counted once per item of the requested page.
+type cost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]interface{}
+}
+
+func (h *Handler) checkLimits(doc *ast.Document, op *ast.OperationDefinition, variables map[string]interface{}) error {
+	c := &cost{
+		fragments: make(map[string]*ast.FragmentDefinition),
+		variables: make(map[string]interface{}, len(variables)),
+	}
+
+	// Defaults of variables not given may size pages too
+	for _, def := range op.VariableDefinitions {
+		if v, ok := def.DefaultValue.(*ast.IntValue); ok {
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				c.variables[def.Variable.Name.Value] = n
+			}
+		}
+	}
+
+	for k, v := range variables {
+		c.variables[k] = v
+	}
+
+	for _, def := range doc.Definitions {
+		if f, ok := def.(*ast.FragmentDefinition); ok {
+			c.fragments[f.Name.Value] = f
+		}
+	}
+
+	depth, complexity := c.selectionSet(op.SelectionSet, 0)
+
+	if depth > h.maxDepth {
+		return fmt.Errorf("query depth %d exceeds limit %d", depth, h.maxDepth)
+	}
+
+	if complexity > h.maxComplexity {
+		return fmt.Errorf("query complexity %d exceeds limit %d", complexity, h.maxComplexity)
+	}
+
+	return nil
+}
+
+// Fragment cycles are rejected by validation before
+func (c *cost) selectionSet(set *ast.SelectionSet, depth int) (int, int) {
+	maxDepth, complexity := depth, 0
+
+	if set == nil {
+		return maxDepth, complexity
+	}
+
+	for _, selection := range set.Selections {
+		var (
+			d int
+			n int
+		)
+
+		switch s := selection.(type) {
+		case *ast.Field:
+			// Introspection is bounded by the schema
+			if strings.HasPrefix(s.Name.Value, "__") {
+				continue
+			}
+
+			d, n = c.selectionSet(s.SelectionSet, depth+1)
+			n = 1 + c.multiplier(s)*n
+		case *ast.InlineFragment:
+			d, n = c.selectionSet(s.SelectionSet, depth)
+		case *ast.FragmentSpread:
+			f, ok := c.fragments[s.Name.Value]
+
+			if !ok {
+				continue
+			}
+
+			d, n = c.selectionSet(f.SelectionSet, depth)
+		}
+
+		maxDepth = max(maxDepth, d)
+		complexity += n
+	}
+
+	return maxDepth, complexity
+}
+
+// Items of page requested from connection field, 1 for other fields
+func (c *cost) multiplier(field *ast.Field) int {
+	first, ok := connectionFields[field.Name.Value]
+
+	if !ok {
+		return 1
+	}
+
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil {
+				first = n
+			}
+		case *ast.Variable:
+			switch n := c.variables[v.Name.Value].(type) {
+			case float64:
+				first = int(n)
+			case int:
+				first = n
+			}
+		}
+	}
+
+	return max(first, 1)
+}
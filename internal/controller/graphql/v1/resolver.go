@@ -0,0 +1,359 @@
+package v1
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/auth"
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/converter"
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/model"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+// Users in a page when first is not given
+const defaultFirst = 10
+
+const cursorPrefix = "offset:"
+
+type connection struct {
+	Edges    []edge   `json:"edges"`
+	PageInfo pageInfo `json:"pageInfo"`
+}
+
+type edge struct {
+	Cursor string       `json:"cursor"`
+	Node   *domain.User `json:"node"`
+}
+
+type pageInfo struct {
+	HasNextPage     bool    `json:"hasNextPage"`
+	HasPreviousPage bool    `json:"hasPreviousPage"`
+	StartCursor     *string `json:"startCursor"`
+	EndCursor       *string `json:"endCursor"`
+}
+
+// Error shown to clients with its code in extensions
+type resolverError struct {
+	code    string
+	message string
+}
+
+func (e *resolverError) Error() string {
+	return e.message
+}
+
+func (e *resolverError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": e.code}
+}
+
+func (h *Handler) resolveUser(p graphql.ResolveParams) (interface{}, error) {
+	id, _ := p.Args["id"].(int)
+
+	u, err := h.service.GetById(p.Context, id)
+
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return nil, nil
+		}
+
+		return nil, toError(err)
+	}
+
+	return u, nil
+}
+
+// Page of users, cursors are offsets of users so any sort can be paged
+func (h *Handler) resolveUsers(p graphql.ResolveParams) (interface{}, error) {
+	first, _ := p.Args["first"].(int)
+
+	userFilter := &model.UserFilter{
+		Limit: first,
+	}
+
+	if filter, ok := p.Args["filter"].(map[string]interface{}); ok {
+		userFilter.Expr = toFilterExpr(filter)
+	}
+
+	sortArgs, _ := p.Args["sort"].([]interface{})
+
+	for _, s := range sortArgs {
+		s, _ := s.(map[string]interface{})
+		field, _ := s["field"].(string)
+		desc, _ := s["desc"].(bool)
+
+		userFilter.Sort = append(userFilter.Sort, model.SortField{Field: field, Desc: desc})
+	}
+
+	if err := userFilter.Validate(); err != nil {
+		return nil, badInput(err)
+	}
+
+	offset := 0
+
+	if after, ok := p.Args["after"].(string); ok {
+		position, err := decodeCursor(after)
+
+		if err != nil {
+			return nil, badInput(err)
+		}
+
+		offset = position + 1
+	}
+
+	filter := converter.ToUserFilterFromController(userFilter)
+
+	// One more user tells if there is a next page
+	filter.Limit = first + 1
+	filter.Offset = offset
+
+	users, err := h.service.Get(p.Context, filter)
+
+	if err != nil {
+		return nil, toError(err)
+	}
+
+	conn := &connection{
+		Edges: make([]edge, 0, len(users)),
+		PageInfo: pageInfo{
+			HasNextPage:     len(users) > first,
+			HasPreviousPage: offset > 0,
+		},
+	}
+
+	if conn.PageInfo.HasNextPage {
+		users = users[:first]
+	}
+
+	for i := range users {
+		conn.Edges = append(conn.Edges, edge{Cursor: encodeCursor(offset + i), Node: &users[i]})
+	}
+
+	if len(conn.Edges) > 0 {
+		conn.PageInfo.StartCursor = &conn.Edges[0].Cursor
+		conn.PageInfo.EndCursor = &conn.Edges[len(conn.Edges)-1].Cursor
+	}
+
+	return conn, nil
+}
+
+func (h *Handler) resolveCreateUser(p graphql.ResolveParams) (interface{}, error) {
+	if err := h.authorize(p.Context, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	input, _ := p.Args["input"].(map[string]interface{})
+
+	user := model.CreateUser{}
+	user.Name, _ = input["name"].(string)
+	user.Surname, _ = input["surname"].(string)
+	user.Patronymic, _ = input["patronymic"].(string)
+
+	if err := user.Validate(); err != nil {
+		return nil, badInput(err)
+	}
+
+	u := converter.ToCreateUserFromController(&user)
+
+	if err := h.service.Create(p.Context, u); err != nil {
+		return nil, toError(err)
+	}
+
+	return u, nil
+}
+
+// Update given fields of user and return it
+func (h *Handler) resolveUpdateUser(p graphql.ResolveParams) (interface{}, error) {
+	if err := h.authorize(p.Context, auth.RoleEditor); err != nil {
+		return nil, err
+	}
+
+	id, _ := p.Args["id"].(int)
+	input, _ := p.Args["input"].(map[string]interface{})
+
+	updateUser := model.UpdateUser{}
+	updateUser.Name, _ = input["name"].(string)
+	updateUser.Surname, _ = input["surname"].(string)
+	updateUser.Patronymic, _ = input["patronymic"].(string)
+	updateUser.Age, _ = input["age"].(int)
+	updateUser.Gender, _ = input["gender"].(string)
+	updateUser.Nationality, _ = input["nationality"].(string)
+
+	if err := updateUser.Validate(); err != nil {
+		return nil, badInput(err)
+	}
+
+	u, err := h.service.GetById(p.Context, id)
+
+	if err != nil {
+		return nil, toError(err)
+	}
+
+	user := converter.ToUserFromService(u)
+
+	updateUser.Copy(user)
+
+	updated := converter.ToUserFromController(user)
+
+	if err := h.service.Update(p.Context, id, updated); err != nil {
+		return nil, toError(err)
+	}
+
+	return updated, nil
+}
+
+func (h *Handler) resolveDeleteUser(p graphql.ResolveParams) (interface{}, error) {
+	if err := h.authorize(p.Context, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	id, _ := p.Args["id"].(int)
+
+	if err := h.service.Delete(p.Context, id); err != nil {
+		return nil, toError(err)
+	}
+
+	return true, nil
+}
+
+// Check role of principal if roles are checked
+func (h *Handler) authorize(ctx context.Context, role auth.Role) error {
+	if !h.checkRoles {
+		return nil
+	}
+
+	principal := auth.PrincipalFromContext(ctx)
+
+	if principal == nil {
+		return &resolverError{code: "UNAUTHENTICATED", message: "authentication required"}
+	}
+
+	if !principal.Role.Allows(role) {
+		slog.Warn(fmt.Sprintf("graphql: %s is not allowed to act as %s", principal, role))
+		return &resolverError{code: "FORBIDDEN", message: fmt.Sprintf("%s role required", role)}
+	}
+
+	return nil
+}
+
+// Build filter expression from UserFilter input, validated the same way as http one
+func toFilterExpr(input map[string]interface{}) model.FilterExpr {
+	keys := make([]string, 0, len(input))
+
+	for k := range input {
+		keys = append(keys, k)
+	}
+
+	// Map order is random, expression must not be
+	sort.Strings(keys)
+
+	children := make([]model.FilterExpr, 0, len(keys))
+
+	for _, k := range keys {
+		switch k {
+		case "and", "or":
+			filters, _ := input[k].([]interface{})
+			group := model.FilterExpr{Op: model.FilterOp(k), Children: make([]model.FilterExpr, 0, len(filters))}
+
+			for _, f := range filters {
+				f, _ := f.(map[string]interface{})
+				group.Children = append(group.Children, toFilterExpr(f))
+			}
+
+			children = append(children, group)
+		default:
+			conditions, _ := input[k].(map[string]interface{})
+			children = append(children, toConditions(k, conditions)...)
+		}
+	}
+
+	if len(children) == 0 {
+		return model.FilterExpr{}
+	}
+
+	return model.FilterExpr{Op: model.FilterAnd, Children: children}
+}
+
+// Conditions on field by operator
+func toConditions(field string, conditions map[string]interface{}) []model.FilterExpr {
+	ops := make([]string, 0, len(conditions))
+
+	for op := range conditions {
+		ops = append(ops, op)
+	}
+
+	sort.Strings(ops)
+
+	exprs := make([]model.FilterExpr, 0, len(ops))
+
+	for _, op := range ops {
+		expr := model.FilterExpr{Op: model.FilterOp(op), Field: field}
+
+		switch v := conditions[op].(type) {
+		case bool:
+			// null: false matches users with field
+			if !v {
+				expr.Op = model.FilterNotNull
+			}
+		case []interface{}:
+			for _, value := range v {
+				expr.Values = append(expr.Values, fmt.Sprint(value))
+			}
+		default:
+			expr.Values = []string{fmt.Sprint(v)}
+		}
+
+		exprs = append(exprs, expr)
+	}
+
+	return exprs
+}
+
+func encodeCursor(position int) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(position)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	data, err := base64.StdEncoding.DecodeString(cursor)
+
+	if err != nil {
+		return 0, errors.New("invalid cursor")
+	}
+
+	position, err := strconv.Atoi(strings.TrimPrefix(string(data), cursorPrefix))
+
+	if err != nil || !strings.HasPrefix(string(data), cursorPrefix) || position < 0 {
+		return 0, errors.New("invalid cursor")
+	}
+
+	return position, nil
+}
+
+func badInput(err error) error {
+	slog.Error(fmt.Sprintf("graphql: %s", err.Error()))
+
+	return &resolverError{code: "BAD_USER_INPUT", message: err.Error()}
+}
+
+// Map service errors to codes, unexpected errors are not shown to clients
+func toError(err error) error {
+	slog.Error(fmt.Sprintf("graphql: %s", err.Error()))
+
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		return &resolverError{code: "NOT_FOUND", message: domain.ErrUserNotFound.Error()}
+	case errors.Is(err, domain.ErrEnrichmentUnavailable):
+		return &resolverError{code: "UNAVAILABLE", message: domain.ErrEnrichmentUnavailable.Error()}
+	case errors.Is(err, domain.ErrUnknownName):
+		return &resolverError{code: "BAD_USER_INPUT", message: err.Error()}
+	}
+
+	return &resolverError{code: "INTERNAL", message: "internal error"}
+}
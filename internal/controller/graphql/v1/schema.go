@@ -0,0 +1,238 @@
+package v1
+
+import (
+	"github.com/graphql-go/graphql"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+var genderEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "Gender",
+	Values: graphql.EnumValueConfigMap{
+		"MALE":   {Value: "male"},
+		"FEMALE": {Value: "female"},
+	},
+})
+
+var userType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "User",
+	Description: "User with name enriched by age, gender and nationality",
+	Fields: graphql.Fields{
+		"id":      {Type: graphql.NewNonNull(graphql.Int)},
+		"name":    {Type: graphql.NewNonNull(graphql.String)},
+		"surname": {Type: graphql.NewNonNull(graphql.String)},
+		"patronymic": {
+			Type: graphql.String,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				u, _ := p.Source.(*domain.User)
+
+				if u == nil || u.Patronymic == "" {
+					return nil, nil
+				}
+
+				return u.Patronymic, nil
+			},
+		},
+		"age":         {Type: graphql.NewNonNull(graphql.Int)},
+		"gender":      {Type: graphql.NewNonNull(genderEnum)},
+		"nationality": {Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var pageInfoType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "PageInfo",
+	Fields: graphql.Fields{
+		"hasNextPage":     {Type: graphql.NewNonNull(graphql.Boolean)},
+		"hasPreviousPage": {Type: graphql.NewNonNull(graphql.Boolean)},
+		"startCursor":     {Type: graphql.String},
+		"endCursor":       {Type: graphql.String},
+	},
+})
+
+var userEdgeType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "UserEdge",
+	Fields: graphql.Fields{
+		"cursor": {Type: graphql.NewNonNull(graphql.String)},
+		"node":   {Type: graphql.NewNonNull(userType)},
+	},
+})
+
+var userConnectionType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "UserConnection",
+	Fields: graphql.Fields{
+		"edges":    {Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(userEdgeType)))},
+		"pageInfo": {Type: graphql.NewNonNull(pageInfoType)},
+	},
+})
+
+func listOf(t graphql.Type) graphql.Type {
+	return graphql.NewList(graphql.NewNonNull(t))
+}
+
+var stringFilterInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "StringFilter",
+	Description: "Conditions on a text field, null is allowed on patronymic only",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"eq":       {Type: graphql.String},
+		"ne":       {Type: graphql.String},
+		"in":       {Type: listOf(graphql.String)},
+		"nin":      {Type: listOf(graphql.String)},
+		"prefix":   {Type: graphql.String},
+		"contains": {Type: graphql.String},
+		"null":     {Type: graphql.Boolean},
+	},
+})
+
+var intFilterInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "IntFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"eq":  {Type: graphql.Int},
+		"ne":  {Type: graphql.Int},
+		"in":  {Type: listOf(graphql.Int)},
+		"nin": {Type: listOf(graphql.Int)},
+		"gt":  {Type: graphql.Int},
+		"gte": {Type: graphql.Int},
+		"lt":  {Type: graphql.Int},
+		"lte": {Type: graphql.Int},
+	},
+})
+
+var genderFilterInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "GenderFilter",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"eq":  {Type: genderEnum},
+		"ne":  {Type: genderEnum},
+		"in":  {Type: listOf(genderEnum)},
+		"nin": {Type: listOf(genderEnum)},
+	},
+})
+
+var userFilterInput = newUserFilterInput()
+
+// Filter referring to itself in and and or
+func newUserFilterInput() *graphql.InputObject {
+	var input *graphql.InputObject
+
+	input = graphql.NewInputObject(graphql.InputObjectConfig{
+		Name:        "UserFilter",
+		Description: "Conditions joined with AND, and and or take nested filters",
+		Fields: graphql.InputObjectConfigFieldMapThunk(func() graphql.InputObjectConfigFieldMap {
+			return graphql.InputObjectConfigFieldMap{
+				"name":        {Type: stringFilterInput},
+				"surname":     {Type: stringFilterInput},
+				"patronymic":  {Type: stringFilterInput},
+				"age":         {Type: intFilterInput},
+				"gender":      {Type: genderFilterInput},
+				"nationality": {Type: stringFilterInput},
+				"and":         {Type: listOf(input)},
+				"or":          {Type: listOf(input)},
+			}
+		}),
+	})
+
+	return input
+}
+
+var userSortFieldEnum = graphql.NewEnum(graphql.EnumConfig{
+	Name: "UserSortField",
+	Values: graphql.EnumValueConfigMap{
+		"ID":          {Value: "id"},
+		"NAME":        {Value: "name"},
+		"SURNAME":     {Value: "surname"},
+		"PATRONYMIC":  {Value: "patronymic"},
+		"AGE":         {Value: "age"},
+		"GENDER":      {Value: "gender"},
+		"NATIONALITY": {Value: "nationality"},
+	},
+})
+
+var userSortInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "UserSort",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"field": {Type: graphql.NewNonNull(userSortFieldEnum)},
+		"desc":  {Type: graphql.Boolean, DefaultValue: false},
+	},
+})
+
+var createUserInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name: "CreateUserInput",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":       {Type: graphql.NewNonNull(graphql.String)},
+		"surname":    {Type: graphql.NewNonNull(graphql.String)},
+		"patronymic": {Type: graphql.String},
+	},
+})
+
+var updateUserInput = graphql.NewInputObject(graphql.InputObjectConfig{
+	Name:        "UpdateUserInput",
+	Description: "Fields to change, the rest are kept",
+	Fields: graphql.InputObjectConfigFieldMap{
+		"name":        {Type: graphql.String},
+		"surname":     {Type: graphql.String},
+		"patronymic":  {Type: graphql.String},
+		"age":         {Type: graphql.Int},
+		"gender":      {Type: genderEnum},
+		"nationality": {Type: graphql.String},
+	},
+})
+
+func (h *Handler) newSchema() (graphql.Schema, error) {
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": {
+				Type:        userType,
+				Description: "User by id, null if there is none",
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: h.resolveUser,
+			},
+			"users": {
+				Type:        graphql.NewNonNull(userConnectionType),
+				Description: "Page of users matching filter, first is at most 50",
+				Args: graphql.FieldConfigArgument{
+					"filter": {Type: userFilterInput},
+					"sort":   {Type: listOf(userSortInput)},
+					"first":  {Type: graphql.Int, DefaultValue: defaultFirst},
+					"after":  {Type: graphql.String},
+				},
+				Resolve: h.resolveUsers,
+			},
+		},
+	})
+
+	mutation := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"createUser": {
+				Type:        graphql.NewNonNull(userType),
+				Description: "Create user, age, gender and nationality are fetched by name",
+				Args: graphql.FieldConfigArgument{
+					"input": {Type: graphql.NewNonNull(createUserInput)},
+				},
+				Resolve: h.resolveCreateUser,
+			},
+			"updateUser": {
+				Type: graphql.NewNonNull(userType),
+				Args: graphql.FieldConfigArgument{
+					"id":    {Type: graphql.NewNonNull(graphql.Int)},
+					"input": {Type: graphql.NewNonNull(updateUserInput)},
+				},
+				Resolve: h.resolveUpdateUser,
+			},
+			"deleteUser": {
+				Type: graphql.NewNonNull(graphql.Boolean),
+				Args: graphql.FieldConfigArgument{
+					"id": {Type: graphql.NewNonNull(graphql.Int)},
+				},
+				Resolve: h.resolveDeleteUser,
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:    query,
+		Mutation: mutation,
+	})
+}
@@ -0,0 +1,100 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/auth"
+)
+
+func (s *UserServer) unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+
+	ctx, err := s.authorize(ctx, info.FullMethod)
+
+	var resp any
+
+	if err == nil {
+		resp, err = handler(ctx, req)
+	}
+
+	logCall(info.FullMethod, start, err)
+
+	return resp, err
+}
+
+func (s *UserServer) streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+
+	ctx, err := s.authorize(ss.Context(), info.FullMethod)
+
+	if err == nil {
+		err = handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+
+	logCall(info.FullMethod, start, err)
+
+	return err
+}
+
+// Authenticate call by metadata and check role of method, principal is put on context
+func (s *UserServer) authorize(ctx context.Context, method string) (context.Context, error) {
+	if s.authenticator == nil {
+		return ctx, nil
+	}
+
+	// Authenticators read credentials from http headers
+	md, _ := metadata.FromIncomingContext(ctx)
+	header := http.Header{}
+
+	for key, values := range md {
+		for _, v := range values {
+			header.Add(key, v)
+		}
+	}
+
+	p, err := s.authenticator.Authenticate(&http.Request{Header: header})
+
+	if err != nil {
+		if !errors.Is(err, auth.ErrNoCredentials) {
+			slog.Warn(fmt.Sprintf("auth: %s: %s", method, err.Error()))
+		}
+
+		return nil, status.Error(codes.Unauthenticated, "unauthenticated")
+	}
+
+	// Audit log
+	slog.Info(fmt.Sprintf("auth: %s", method), "principal", p.String(), "role", string(p.Role))
+
+	role, ok := methodRoles[method]
+
+	if !ok || !p.Role.Allows(role) {
+		slog.Warn(fmt.Sprintf("auth: %s is not allowed to call %s", p, method))
+		return nil, status.Error(codes.PermissionDenied, "permission denied")
+	}
+
+	return auth.WithPrincipal(ctx, p), nil
+}
+
+func logCall(method string, start time.Time, err error) {
+	slog.Info(fmt.Sprintf("grpc: %s %s in %s", method, status.Code(err), time.Since(start)))
+}
+
+// Stream with context carrying principal
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,287 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/auth"
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/converter"
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/model"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	userv1 "github.com/sletkov/effective-mobile-test-task/pkg/api/user/v1"
+)
+
+type UserService interface {
+	Get(ctx context.Context, userFilter *domain.UserFilter) ([]domain.User, error)
+	Delete(ctx context.Context, id int) error
+	Update(ctx context.Context, id int, u *domain.User) error
+	Create(ctx context.Context, u *domain.User) error
+	GetById(ctx context.Context, id int, fields ...string) (*domain.User, error)
+	Export(ctx context.Context, userFilter *domain.UserFilter, fn func(u *domain.User) error) error
+}
+
+// Users returned by Get without limit
+const defaultLimit = 10
+
+// Roles required by methods when authentication is enabled
+var methodRoles = map[string]auth.Role{
+	userv1.UserService_Get_FullMethodName:     auth.RoleReader,
+	userv1.UserService_GetById_FullMethodName: auth.RoleReader,
+	userv1.UserService_List_FullMethodName:    auth.RoleReader,
+	userv1.UserService_Create_FullMethodName:  auth.RoleEditor,
+	userv1.UserService_Update_FullMethodName:  auth.RoleEditor,
+	userv1.UserService_Delete_FullMethodName:  auth.RoleAdmin,
+}
+
+type UserServer struct {
+	userv1.UnimplementedUserServiceServer
+
+	service       UserService
+	authenticator auth.Authenticator
+}
+
+type Option func(s *UserServer)
+
+// Require authentication for all methods
+func WithAuth(authenticator auth.Authenticator) Option {
+	return func(s *UserServer) {
+		s.authenticator = authenticator
+	}
+}
+
+func New(service UserService, opts ...Option) *UserServer {
+	s := &UserServer{
+		service: service,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Create grpc server with user service registered
+func (s *UserServer) Register(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.unaryInterceptor),
+		grpc.ChainStreamInterceptor(s.streamInterceptor),
+	)
+
+	server := grpc.NewServer(opts...)
+	userv1.RegisterUserServiceServer(server, s)
+
+	return server
+}
+
+// Get users with filters and limit
+func (s *UserServer) Get(ctx context.Context, req *userv1.GetRequest) (*userv1.GetResponse, error) {
+	userFilter, err := toUserFilter(req.GetConditions(), req.GetSort(), req.GetFields(), int(req.GetLimit()))
+
+	if err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	users, err := s.service.Get(ctx, converter.ToUserFilterFromController(userFilter))
+
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	resp := &userv1.GetResponse{
+		Users: make([]*userv1.User, 0, len(users)),
+	}
+
+	for i := range users {
+		resp.Users = append(resp.Users, toUser(&users[i]))
+	}
+
+	return resp, nil
+}
+
+// Get user by id
+func (s *UserServer) GetById(ctx context.Context, req *userv1.GetByIdRequest) (*userv1.User, error) {
+	fields := model.Fields(req.GetFields())
+
+	if err := fields.Validate(); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	u, err := s.service.GetById(ctx, int(req.GetId()), fields...)
+
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toUser(u), nil
+}
+
+// Create user enriched by name
+func (s *UserServer) Create(ctx context.Context, req *userv1.CreateRequest) (*userv1.CreateResponse, error) {
+	user := model.CreateUser{
+		Name:       req.GetName(),
+		Surname:    req.GetSurname(),
+		Patronymic: req.GetPatronymic(),
+	}
+
+	if err := user.Validate(); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	if err := s.service.Create(ctx, converter.ToCreateUserFromController(&user)); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &userv1.CreateResponse{}, nil
+}
+
+// Update given fields of user
+func (s *UserServer) Update(ctx context.Context, req *userv1.UpdateRequest) (*userv1.UpdateResponse, error) {
+	updateUser := model.UpdateUser{
+		Name:        req.GetName(),
+		Surname:     req.GetSurname(),
+		Patronymic:  req.GetPatronymic(),
+		Age:         int(req.GetAge()),
+		Gender:      req.GetGender(),
+		Nationality: req.GetNationality(),
+	}
+
+	if err := updateUser.Validate(); err != nil {
+		return nil, invalidArgument(err)
+	}
+
+	id := int(req.GetId())
+
+	u, err := s.service.GetById(ctx, id)
+
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	user := converter.ToUserFromService(u)
+
+	updateUser.Copy(user)
+
+	if err := s.service.Update(ctx, id, converter.ToUserFromController(user)); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &userv1.UpdateResponse{}, nil
+}
+
+// Delete user by id
+func (s *UserServer) Delete(ctx context.Context, req *userv1.DeleteRequest) (*userv1.DeleteResponse, error) {
+	if err := s.service.Delete(ctx, int(req.GetId())); err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &userv1.DeleteResponse{}, nil
+}
+
+// Stream all users matching filters
+func (s *UserServer) List(req *userv1.ListRequest, stream userv1.UserService_ListServer) error {
+	userFilter, err := toUserFilter(req.GetConditions(), req.GetSort(), req.GetFields(), 0)
+
+	if err != nil {
+		return invalidArgument(err)
+	}
+
+	// List everything
+	userFilter.Limit = 0
+
+	err = s.service.Export(stream.Context(), converter.ToUserFilterFromController(userFilter), func(u *domain.User) error {
+		return stream.Send(toUser(u))
+	})
+
+	if err != nil {
+		return toStatus(err)
+	}
+
+	return nil
+}
+
+// Build filter validated the same way as http one
+func toUserFilter(conditions []*userv1.Condition, sort, fields []string, limit int) (*model.UserFilter, error) {
+	userFilter := &model.UserFilter{
+		Fields: fields,
+		Limit:  limit,
+	}
+
+	if userFilter.Limit <= 0 {
+		userFilter.Limit = defaultLimit
+	}
+
+	children := make([]model.FilterExpr, 0, len(conditions))
+
+	for _, c := range conditions {
+		op := model.FilterOp(c.GetOp())
+
+		if op == "" {
+			op = model.FilterEq
+		}
+
+		children = append(children, model.FilterExpr{Op: op, Field: c.GetField(), Values: c.GetValues()})
+	}
+
+	if len(children) > 0 {
+		userFilter.Expr = model.FilterExpr{Op: model.FilterAnd, Children: children}
+	}
+
+	for _, field := range sort {
+		userFilter.Sort = append(userFilter.Sort, model.SortField{
+			Field: strings.TrimPrefix(field, "-"),
+			Desc:  strings.HasPrefix(field, "-"),
+		})
+	}
+
+	if err := userFilter.Validate(); err != nil {
+		return nil, err
+	}
+
+	return userFilter, nil
+}
+
+func toUser(u *domain.User) *userv1.User {
+	return &userv1.User{
+		Id:          int64(u.Id),
+		Name:        u.Name,
+		Surname:     u.Surname,
+		Patronymic:  u.Patronymic,
+		Age:         int32(u.Age),
+		Gender:      u.Gender,
+		Nationality: u.Nationality,
+	}
+}
+
+func invalidArgument(err error) error {
+	slog.Error(fmt.Sprintf("grpc: %s", err.Error()))
+
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// Map service errors to status codes, unexpected errors are not shown to clients
+func toStatus(err error) error {
+	slog.Error(fmt.Sprintf("grpc: %s", err.Error()))
+
+	switch {
+	case errors.Is(err, domain.ErrUserNotFound):
+		return status.Error(codes.NotFound, domain.ErrUserNotFound.Error())
+	case errors.Is(err, domain.ErrEnrichmentUnavailable):
+		return status.Error(codes.Unavailable, domain.ErrEnrichmentUnavailable.Error())
+	case errors.Is(err, domain.ErrUnknownName):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	return status.Error(codes.Internal, "internal error")
+}
@@ -0,0 +1,256 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/auth"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	mock_service "github.com/sletkov/effective-mobile-test-task/internal/service/mocks"
+	userv1 "github.com/sletkov/effective-mobile-test-task/pkg/api/user/v1"
+)
+
+// Start server on in-memory listener and connect client to it
+func newTestClient(t *testing.T, server *UserServer) userv1.UserServiceClient {
+	listener := bufconn.Listen(1 << 20)
+
+	grpcServer := server.Register()
+
+	go grpcServer.Serve(listener)
+
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { conn.Close() })
+
+	return userv1.NewUserServiceClient(conn)
+}
+
+func TestServerGet(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockUserService, userFilter *domain.UserFilter)
+
+	testCases := []struct {
+		name          string
+		req           *userv1.GetRequest
+		userFilter    *domain.UserFilter
+		mockBehavior  mockBehavior
+		expectedUsers []*userv1.User
+		expectedCode  codes.Code
+	}{
+		{
+			name: "OK",
+			req: &userv1.GetRequest{
+				Conditions: []*userv1.Condition{
+					{Field: "nationality", Op: "in", Values: []string{"RU", "UA"}},
+					{Field: "age", Op: "gte", Values: []string{"20"}},
+				},
+				Sort:   []string{"-age"},
+				Fields: []string{"id", "age"},
+			},
+			userFilter: &domain.UserFilter{
+				Expr: domain.And(
+					domain.Cond("nationality", domain.FilterIn, "RU", "UA"),
+					domain.Cond("age", domain.FilterGte, "20"),
+				),
+				Sort:   []domain.SortField{{Field: "age", Desc: true}},
+				Fields: []string{"id", "age"},
+				Limit:  10,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {
+				s.EXPECT().Get(gomock.Any(), userFilter).Return([]domain.User{{Id: 1, Age: 30}}, nil)
+			},
+			expectedUsers: []*userv1.User{{Id: 1, Age: 30}},
+		},
+
+		{
+			name: "invalid condition",
+			req: &userv1.GetRequest{
+				Conditions: []*userv1.Condition{{Field: "gender", Op: "gt", Values: []string{"male"}}},
+			},
+			mockBehavior: func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {},
+			expectedCode: codes.InvalidArgument,
+		},
+
+		{
+			name:         "invalid limit",
+			req:          &userv1.GetRequest{Limit: 100},
+			mockBehavior: func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {},
+			expectedCode: codes.InvalidArgument,
+		},
+
+		{
+			name:       "service error",
+			req:        &userv1.GetRequest{},
+			userFilter: &domain.UserFilter{Limit: 10},
+			mockBehavior: func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {
+				s.EXPECT().Get(gomock.Any(), userFilter).Return(nil, errors.New("connection refused"))
+			},
+			expectedCode: codes.Internal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			userService := mock_service.NewMockUserService(c)
+			tc.mockBehavior(userService, tc.userFilter)
+
+			client := newTestClient(t, New(userService))
+
+			resp, err := client.Get(context.Background(), tc.req)
+
+			if tc.expectedCode != codes.OK {
+				assert.Equal(t, tc.expectedCode, status.Code(err))
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Len(t, resp.GetUsers(), len(tc.expectedUsers))
+
+			for i, u := range tc.expectedUsers {
+				assert.Equal(t, u.GetId(), resp.GetUsers()[i].GetId())
+				assert.Equal(t, u.GetAge(), resp.GetUsers()[i].GetAge())
+			}
+		})
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	userService := mock_service.NewMockUserService(c)
+	client := newTestClient(t, New(userService))
+
+	ctx := context.Background()
+
+	userService.EXPECT().GetById(gomock.Any(), 2).Return(nil, domain.ErrUserNotFound)
+
+	_, err := client.GetById(ctx, &userv1.GetByIdRequest{Id: 2})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetById(ctx, &userv1.GetByIdRequest{Id: 2, Fields: []string{"password"}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	userService.EXPECT().Create(gomock.Any(), &domain.User{Name: "Ivan", Surname: "Ivanov"}).Return(domain.ErrEnrichmentUnavailable)
+
+	_, err = client.Create(ctx, &userv1.CreateRequest{Name: "Ivan", Surname: "Ivanov"})
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	_, err = client.Create(ctx, &userv1.CreateRequest{Name: "Ivan1"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	userService.EXPECT().GetById(gomock.Any(), 1).Return(&domain.User{Id: 1, Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "male", Nationality: "RU"}, nil)
+	userService.EXPECT().Update(gomock.Any(), 1, &domain.User{Id: 1, Name: "Ivan", Surname: "Ivanov", Age: 21, Gender: "male", Nationality: "RU"}).Return(nil)
+
+	_, err = client.Update(ctx, &userv1.UpdateRequest{Id: 1, Age: 21})
+	assert.NoError(t, err)
+
+	userService.EXPECT().Delete(gomock.Any(), 1).Return(nil)
+
+	_, err = client.Delete(ctx, &userv1.DeleteRequest{Id: 1})
+	assert.NoError(t, err)
+}
+
+func TestServerList(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	users := []domain.User{
+		{Id: 1, Name: "Ivan"},
+		{Id: 2, Name: "Petr"},
+		{Id: 3, Name: "Anna"},
+	}
+
+	userService := mock_service.NewMockUserService(c)
+	userService.EXPECT().Export(gomock.Any(), &domain.UserFilter{Fields: []string{"id", "name"}}, gomock.Any()).DoAndReturn(
+		func(ctx context.Context, userFilter *domain.UserFilter, fn func(u *domain.User) error) error {
+			for i := range users {
+				if err := fn(&users[i]); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+
+	client := newTestClient(t, New(userService))
+
+	stream, err := client.List(context.Background(), &userv1.ListRequest{Fields: []string{"id", "name"}})
+	assert.NoError(t, err)
+
+	var names []string
+
+	for {
+		u, err := stream.Recv()
+
+		if err == io.EOF {
+			break
+		}
+
+		assert.NoError(t, err)
+
+		names = append(names, u.GetName())
+	}
+
+	assert.Equal(t, []string{"Ivan", "Petr", "Anna"}, names)
+}
+
+func TestServerAuth(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	authenticator, err := auth.NewAPIKeyAuthenticator(map[string]string{"reader-key": "reader", "admin-key": "admin"})
+	assert.NoError(t, err)
+
+	userService := mock_service.NewMockUserService(c)
+	client := newTestClient(t, New(userService, WithAuth(authenticator)))
+
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(context.Background(), "x-api-key", key)
+	}
+
+	_, err = client.Delete(context.Background(), &userv1.DeleteRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.Delete(withKey("unknown-key"), &userv1.DeleteRequest{Id: 1})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = client.Delete(withKey("reader-key"), &userv1.DeleteRequest{Id: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	userService.EXPECT().Delete(gomock.Any(), 1).Return(nil)
+
+	_, err = client.Delete(withKey("admin-key"), &userv1.DeleteRequest{Id: 1})
+	assert.NoError(t, err)
+
+	stream, err := client.List(context.Background(), &userv1.ListRequest{})
+	assert.NoError(t, err)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,321 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Encoding of request and response bodies
+type codec struct {
+	contentType string
+	// Other media types of the same encoding
+	aliases   []string
+	marshal   func(v any) ([]byte, error)
+	unmarshal func(data []byte, v any) error
+}
+
+var (
+	jsonCodec = &codec{
+		contentType: "application/json",
+		marshal:     json.Marshal,
+		unmarshal:   json.Unmarshal,
+	}
+
+	xmlCodec = &codec{
+		contentType: "application/xml",
+		aliases:     []string{"text/xml"},
+		marshal:     marshalXML,
+		unmarshal:   xml.Unmarshal,
+	}
+
+	msgpackCodec = &codec{
+		contentType: "application/msgpack",
+		aliases:     []string{"application/x-msgpack", "application/vnd.msgpack"},
+		marshal:     marshalMsgpack,
+		unmarshal:   unmarshalMsgpack,
+	}
+)
+
+// Supported codecs, the first one is used when client accepts anything
+var codecs = []*codec{jsonCodec, xmlCodec, msgpackCodec}
+
+type codecKey struct {
+	request bool
+}
+
+// Codec by media type
+func lookupCodec(mediaType string) *codec {
+	for _, c := range codecs {
+		if c.contentType == mediaType {
+			return c
+		}
+
+		for _, alias := range c.aliases {
+			if alias == mediaType {
+				return c
+			}
+		}
+	}
+
+	return nil
+}
+
+// Media types of codec, the main one first
+func (c *codec) mediaTypes() []string {
+	return append([]string{c.contentType}, c.aliases...)
+}
+
+// How specific media range matches media type, -1 if it doesn't
+func rangeSpecificity(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mediaRange, "*")):
+		return 1
+	}
+
+	return -1
+}
+
+// Index of offer client prefers by Accept header, offers are media types of a
+// single format. Quality of an offer comes from the most specific range matching
+// it, so application/json;q=0 refuses JSON even next to */*. Client order and
+// then offers order break ties.
+func preferredOffer(accept string, offers [][]string) (int, bool) {
+	type acceptedRange struct {
+		mediaRange string
+		q          float64
+	}
+
+	var ranges []acceptedRange
+
+	for _, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(part)
+
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		ranges = append(ranges, acceptedRange{mediaRange: mediaRange, q: q})
+	}
+
+	type acceptedOffer struct {
+		index    int
+		q        float64
+		position int
+	}
+
+	var accepted []acceptedOffer
+
+	for index, mediaTypes := range offers {
+		best := acceptedOffer{index: index}
+		specificity := -1
+
+		for position, a := range ranges {
+			for _, mediaType := range mediaTypes {
+				s := rangeSpecificity(a.mediaRange, mediaType)
+
+				if s > specificity || s >= 0 && s == specificity && a.q > best.q {
+					specificity = s
+					best.q = a.q
+					best.position = position
+				}
+			}
+		}
+
+		if specificity >= 0 && best.q > 0 {
+			accepted = append(accepted, best)
+		}
+	}
+
+	if len(accepted) == 0 {
+		return 0, false
+	}
+
+	sort.SliceStable(accepted, func(i, j int) bool {
+		if accepted[i].q != accepted[j].q {
+			return accepted[i].q > accepted[j].q
+		}
+
+		return accepted[i].position < accepted[j].position
+	})
+
+	return accepted[0].index, true
+}
+
+// Codec client prefers by Accept header, JSON if header is missing or client accepts anything
+func responseCodec(r *http.Request) (*codec, bool) {
+	accept := r.Header.Get("Accept")
+
+	if strings.TrimSpace(accept) == "" {
+		return codecs[0], true
+	}
+
+	offers := make([][]string, len(codecs))
+
+	for i, c := range codecs {
+		offers[i] = c.mediaTypes()
+	}
+
+	i, ok := preferredOffer(accept, offers)
+
+	if !ok {
+		return nil, false
+	}
+
+	return codecs[i], true
+}
+
+// Codec of request body by Content-Type header, JSON if header is missing
+func requestCodec(r *http.Request) (*codec, bool) {
+	contentType := r.Header.Get("Content-Type")
+
+	if contentType == "" {
+		return codecs[0], true
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+
+	if err != nil {
+		return nil, false
+	}
+
+	c := lookupCodec(mediaType)
+
+	return c, c != nil
+}
+
+// Reject requests with unsupported Accept or Content-Type and keep codecs for handlers
+func negotiate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responseCodec(r)
+
+		if !ok {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), codecKey{}, response)
+
+		if r.ContentLength != 0 && r.Method != http.MethodGet {
+			request, ok := requestCodec(r)
+
+			if !ok {
+				w.WriteHeader(http.StatusUnsupportedMediaType)
+				return
+			}
+
+			ctx = context.WithValue(ctx, codecKey{request: true}, request)
+		}
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Codec chosen by negotiate, JSON without it
+func codecFromContext(ctx context.Context, request bool) *codec {
+	if c, ok := ctx.Value(codecKey{request: request}).(*codec); ok {
+		return c
+	}
+
+	return codecs[0]
+}
+
+// Decode request body by its Content-Type
+func decode(r *http.Request, data []byte, v any) error {
+	return codecFromContext(r.Context(), true).unmarshal(data, v)
+}
+
+// Encode v with codec client accepts and write it with status
+func respond(w http.ResponseWriter, r *http.Request, status int, v any) error {
+	c := codecFromContext(r.Context(), false)
+
+	data, err := c.marshal(v)
+
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", c.contentType)
+	w.WriteHeader(status)
+	w.Write(data)
+
+	return nil
+}
+
+// XML has a single root element, list items are wrapped into it
+func marshalXML(v any) ([]byte, error) {
+	var b bytes.Buffer
+
+	b.WriteString(xml.Header)
+
+	encoder := xml.NewEncoder(&b)
+	root := xml.StartElement{Name: xml.Name{Local: "response"}}
+
+	if value := reflect.ValueOf(v); value.Kind() == reflect.Slice {
+		if err := encoder.EncodeToken(root); err != nil {
+			return nil, err
+		}
+
+		item := xml.StartElement{Name: xml.Name{Local: "item"}}
+
+		for i := 0; i < value.Len(); i++ {
+			if err := encoder.EncodeElement(value.Index(i).Interface(), item); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := encoder.EncodeToken(root.End()); err != nil {
+			return nil, err
+		}
+	} else if err := encoder.EncodeElement(v, root); err != nil {
+		return nil, err
+	}
+
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// MessagePack keys are the same as JSON ones
+func marshalMsgpack(v any) ([]byte, error) {
+	var b bytes.Buffer
+
+	encoder := msgpack.NewEncoder(&b)
+	encoder.SetCustomStructTag("json")
+
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+func unmarshalMsgpack(data []byte, v any) error {
+	decoder := msgpack.NewDecoder(bytes.NewReader(data))
+	decoder.SetCustomStructTag("json")
+
+	return decoder.Decode(v)
+}
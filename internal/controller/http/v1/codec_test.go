@@ -0,0 +1,261 @@
+package v1
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	mock_service "github.com/sletkov/effective-mobile-test-task/internal/service/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestResponseCodec(t *testing.T) {
+	testCases := []struct {
+		name                string
+		accept              string
+		expectedContentType string
+	}{
+		{
+			name:                "no accept",
+			expectedContentType: "application/json",
+		},
+
+		{
+			name:                "anything",
+			accept:              "*/*",
+			expectedContentType: "application/json",
+		},
+
+		{
+			name:                "alias",
+			accept:              "text/xml",
+			expectedContentType: "application/xml",
+		},
+
+		{
+			name:                "by quality",
+			accept:              "application/json;q=0.5, application/msgpack",
+			expectedContentType: "application/msgpack",
+		},
+
+		{
+			name:                "unsupported types skipped",
+			accept:              "text/html, application/xhtml+xml, application/xml;q=0.9, */*;q=0.8",
+			expectedContentType: "application/xml",
+		},
+
+		{
+			name:   "refused",
+			accept: "application/json;q=0, text/html",
+		},
+
+		{
+			name:                "refused next to anything",
+			accept:              "application/json;q=0, */*",
+			expectedContentType: "application/xml",
+		},
+
+		{
+			name:                "specific type over its range",
+			accept:              "application/*;q=0, application/msgpack",
+			expectedContentType: "application/msgpack",
+		},
+
+		{
+			name:   "everything refused",
+			accept: "application/json;q=0, application/xml;q=0, application/msgpack;q=0, */*",
+		},
+
+		{
+			name:   "anything refused",
+			accept: "*/*;q=0",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			c, ok := responseCodec(req)
+
+			if tc.expectedContentType == "" {
+				assert.False(t, ok)
+				return
+			}
+
+			assert.True(t, ok)
+			assert.Equal(t, tc.expectedContentType, c.contentType)
+		})
+	}
+}
+
+func TestControllerNegotiation(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockUserService)
+
+	user := &domain.User{Id: 1, Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "male", Nationality: "RU"}
+
+	msgpackUser, err := msgpack.Marshal(map[string]string{"name": "Ivan", "surname": "Ivanov"})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name                 string
+		method               string
+		url                  string
+		accept               string
+		contentType          string
+		body                 []byte
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedContentType  string
+		expectedResponseBody string
+	}{
+		{
+			name:   "json by default",
+			method: http.MethodGet,
+			url:    "/api/v1/users/1?fields=name,id",
+			mockBehavior: func(s *mock_service.MockUserService) {
+				s.EXPECT().GetById(gomock.Any(), 1, "name", "id").Return(user, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedContentType:  "application/json",
+			expectedResponseBody: `{"id":1,"name":"Ivan"}`,
+		},
+
+		{
+			name:   "xml user",
+			method: http.MethodGet,
+			url:    "/api/v1/users/1?fields=name,id",
+			accept: "application/xml",
+			mockBehavior: func(s *mock_service.MockUserService) {
+				s.EXPECT().GetById(gomock.Any(), 1, "name", "id").Return(user, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedContentType:  "application/xml",
+			expectedResponseBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response><id>1</id><name>Ivan</name></response>`,
+		},
+
+		{
+			name:   "xml stats",
+			method: http.MethodGet,
+			url:    "/api/v1/users/stats?group_by=gender",
+			accept: "application/xml",
+			mockBehavior: func(s *mock_service.MockUserService) {
+				s.EXPECT().Stats(gomock.Any(), gomock.Any()).Return([]domain.UserStats{{Gender: "male", Count: 1, AvgAge: 20}}, nil)
+			},
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/xml",
+			expectedResponseBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<response><item><gender>male</gender><count>1</count><avg_age>20</avg_age><age_p50>0</age_p50><age_p90>0</age_p90><age_p99>0</age_p99></item></response>`,
+		},
+
+		{
+			name:   "msgpack user",
+			method: http.MethodGet,
+			url:    "/api/v1/users/1?fields=name,id",
+			accept: "application/msgpack",
+			mockBehavior: func(s *mock_service.MockUserService) {
+				s.EXPECT().GetById(gomock.Any(), 1, "name", "id").Return(user, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedContentType:  "application/msgpack",
+			expectedResponseBody: "\x82\xa2id\x01\xa4name\xa4Ivan",
+		},
+
+		{
+			name:               "not acceptable",
+			method:             http.MethodGet,
+			url:                "/api/v1/users/1",
+			accept:             "text/html",
+			mockBehavior:       func(s *mock_service.MockUserService) {},
+			expectedStatusCode: http.StatusNotAcceptable,
+		},
+
+		{
+			name:               "everything refused",
+			method:             http.MethodGet,
+			url:                "/api/v1/users/1",
+			accept:             "application/json;q=0, application/xml;q=0, application/msgpack;q=0, */*",
+			mockBehavior:       func(s *mock_service.MockUserService) {},
+			expectedStatusCode: http.StatusNotAcceptable,
+		},
+
+		{
+			name:        "xml body",
+			method:      http.MethodPost,
+			url:         "/api/v1/users",
+			contentType: "application/xml; charset=utf-8",
+			body:        []byte(`<user><name>Ivan</name><surname>Ivanov</surname></user>`),
+			mockBehavior: func(s *mock_service.MockUserService) {
+				s.EXPECT().Create(gomock.Any(), &domain.User{Name: "Ivan", Surname: "Ivanov"}).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+
+		{
+			name:        "msgpack body",
+			method:      http.MethodPost,
+			url:         "/api/v1/users",
+			contentType: "application/x-msgpack",
+			body:        msgpackUser,
+			mockBehavior: func(s *mock_service.MockUserService) {
+				s.EXPECT().Create(gomock.Any(), &domain.User{Name: "Ivan", Surname: "Ivanov"}).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+
+		{
+			name:               "unsupported body",
+			method:             http.MethodPost,
+			url:                "/api/v1/users",
+			contentType:        "text/plain",
+			body:               []byte("Ivan Ivanov"),
+			mockBehavior:       func(s *mock_service.MockUserService) {},
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			userService := mock_service.NewMockUserService(c)
+			tc.mockBehavior(userService)
+
+			// Routes with middlewares
+			r := New(userService).InitRoutes()
+
+			// Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.url, bytes.NewReader(tc.body))
+
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+
+			// Perform request
+			r.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+			assert.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+
+			if tc.expectedResponseBody != "" {
+				assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -2,18 +2,21 @@ package v1
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
+	"github.com/sletkov/effective-mobile-test-task/internal/auth"
 	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/converter"
 	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/model"
 	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	"github.com/sletkov/effective-mobile-test-task/internal/ratelimit"
 )
 
 //go:generate mockgen -source=controller.go -destination=../../../service/mocks/mock.go
@@ -23,60 +26,175 @@ type UserService interface {
 	Delete(ctx context.Context, id int) error
 	Update(ctx context.Context, id int, u *domain.User) error
 	Create(ctx context.Context, u *domain.User) error
-	GetById(ctx context.Context, id int) (*domain.User, error)
+	GetById(ctx context.Context, id int, fields ...string) (*domain.User, error)
+	Search(ctx context.Context, userSearch *domain.UserSearch) ([]domain.UserMatch, error)
+	Stats(ctx context.Context, statsRequest *domain.UserStatsRequest) ([]domain.UserStats, error)
+	Export(ctx context.Context, userFilter *domain.UserFilter, fn func(u *domain.User) error) error
+	Import(ctx context.Context, rows []domain.ImportRow, opts domain.ImportOptions) (*domain.ImportReport, error)
 }
 
 type UserController struct {
-	service UserService
+	service       UserService
+	authenticator auth.Authenticator
+	limiter       *ratelimit.Limiter
+	graphql       http.Handler
+	debugVars     http.Handler
+	webhooks      WebhookService
+	events        EventBroker
+	heartbeat     time.Duration
 }
 
-func New(service UserService) *UserController {
-	return &UserController{
+type Option func(c *UserController)
+
+// Require authentication for all api routes
+func WithAuth(authenticator auth.Authenticator) Option {
+	return func(c *UserController) {
+		c.authenticator = authenticator
+	}
+}
+
+// Limit requests rate per client
+func WithRateLimit(limiter *ratelimit.Limiter) Option {
+	return func(c *UserController) {
+		c.limiter = limiter
+	}
+}
+
+// Serve graphql endpoint behind the same authentication and rate limit
+func WithGraphQL(handler http.Handler) Option {
+	return func(c *UserController) {
+		c.graphql = handler
+	}
+}
+
+// Serve runtime stats at /debug/vars to admins, they expose command line and internals
+func WithDebugVars(handler http.Handler) Option {
+	return func(c *UserController) {
+		c.debugVars = handler
+	}
+}
+
+func New(service UserService, opts ...Option) *UserController {
+	c := &UserController{
 		service: service,
 	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
 }
 
 // Initialize routes and return router
-func (c *UserController) InitRoutes(ctx context.Context) chi.Router {
+func (c *UserController) InitRoutes() chi.Router {
 	r := chi.NewRouter()
 	r.Use(middleware.Logger)
 
+	if c.graphql != nil {
+		r.Group(func(r chi.Router) {
+			c.protect(r)
+
+			r.With(c.requireRole(auth.RoleReader)).Handle("/graphql", c.graphql)
+		})
+	}
+
+	if c.debugVars != nil {
+		r.Group(func(r chi.Router) {
+			c.protect(r)
+
+			r.With(c.requireRole(auth.RoleAdmin)).Handle("/debug/vars", c.debugVars)
+		})
+	}
+
 	r.Route("/api", func(r chi.Router) {
+		c.protect(r)
+
 		r.Route("/v1", func(r chi.Router) {
 			r.Route("/users", func(r chi.Router) {
 
-				r.Get("/", c.handleGetUsers(ctx, r))
-				r.Post("/", c.handleCreateUser(ctx))
+				// Export and import have formats of their own
+				r.With(c.requireRole(auth.RoleReader)).Get("/export", c.handleExportUsers())
+				r.With(c.requireRole(auth.RoleEditor)).Post("/import", c.handleImportUsers())
+
+				if c.events != nil {
+					r.With(c.requireRole(auth.RoleReader)).Get("/events", c.handleUserEvents())
+				}
+
+				r.Group(func(r chi.Router) {
+					r.Use(negotiate)
 
-				r.Route("/{id}", func(r chi.Router) {
-					r.Delete("/", c.handleDeleteUser(ctx))
-					r.Patch("/", c.handleUpdateUser(ctx))
+					r.With(c.requireRole(auth.RoleReader)).Get("/", c.handleGetUsers())
+					r.With(c.requireRole(auth.RoleEditor)).Post("/", c.handleCreateUser())
+					r.With(c.requireRole(auth.RoleReader)).Get("/search", c.handleSearchUsers())
+					r.With(c.requireRole(auth.RoleReader)).Get("/stats", c.handleGetStats())
+
+					r.Route("/{id}", func(r chi.Router) {
+						r.With(c.requireRole(auth.RoleReader)).Get("/", c.handleGetUser())
+						r.With(c.requireRole(auth.RoleAdmin)).Delete("/", c.handleDeleteUser())
+						r.With(c.requireRole(auth.RoleEditor)).Patch("/", c.handleUpdateUser())
+					})
 				})
 			})
+
+			if c.webhooks != nil {
+				r.With(c.requireRole(auth.RoleAdmin)).Route("/webhooks", c.webhookRoutes)
+			}
 		})
 	})
 
 	return r
 }
 
+// Authenticate and rate limit requests if enabled, failed authentications
+// are limited per ip before credentials are checked
+func (c *UserController) protect(r chi.Router) {
+	if c.authenticator != nil {
+		if c.limiter != nil {
+			r.Use(c.limiter.FailedAuthMiddleware)
+		}
+
+		r.Use(auth.Middleware(c.authenticator))
+	}
+
+	if c.limiter != nil {
+		r.Use(c.limiter.Middleware)
+	}
+}
+
+// Enforce role if authentication is enabled
+func (c *UserController) requireRole(role auth.Role) func(http.Handler) http.Handler {
+	if c.authenticator == nil {
+		return func(next http.Handler) http.Handler {
+			return next
+		}
+	}
+
+	return auth.RequireRole(role)
+}
+
 // @Summary GetUsers
 // @Tags users
 // @Description get all users with filters and limit
 // @ID get-users
-// @Produce json
-// @Param name query string false "name filter"
+// @Produce json,xml,application/msgpack
+// @Param name query string false "name filter, name[op]=value for other operators"
 // @Param surname query string false "surname filter"
-// @Param patronymic query string false "patronymic filter"
+// @Param patronymic query string false "patronymic filter, patronymic[null]=true for users without it"
+// @Param age query integer false "age filter, age[gte]=20&age[lte]=30 for range"
 // @Param age_from query integer false "min age filter"
 // @Param age_to query integer false "max age filter"
 // @Param gender query string false "gender filter"
-// @Param nationality query string false "nationality filter"
+// @Param nationality query string false "nationality filter, comma separated list for any of them"
+// @Param sort query string false "comma separated fields to sort by, minus for descending order"
+// @Param fields query string false "comma separated fields to return"
 // @Param limit query integer false "limit"
 // @Success 200
 // @Failure 400
+// @Failure 406
 // @Failure 500
 // @Router /api/v1/users [get]
-func (c *UserController) handleGetUsers(ctx context.Context, r chi.Router) http.HandlerFunc {
+func (c *UserController) handleGetUsers() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		users := make([]model.User, 0)
 
@@ -86,7 +204,7 @@ func (c *UserController) handleGetUsers(ctx context.Context, r chi.Router) http.
 
 		if err != nil {
 			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
-			w.WriteHeader(http.StatusInternalServerError)
+			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
 
@@ -99,7 +217,7 @@ func (c *UserController) handleGetUsers(ctx context.Context, r chi.Router) http.
 			return
 		}
 
-		serviceUsers, err := c.service.Get(ctx, converter.ToUserFilterFromController(userFilter))
+		serviceUsers, err := c.service.Get(r.Context(), converter.ToUserFilterFromController(userFilter))
 
 		if err != nil {
 			slog.Error(err.Error())
@@ -111,16 +229,163 @@ func (c *UserController) handleGetUsers(ctx context.Context, r chi.Router) http.
 			users = append(users, *converter.ToUserFromService(&u))
 		}
 
-		data, err := json.Marshal(users)
+		if err := respond(w, r, http.StatusOK, userFilter.Fields.SelectList(users)); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+// @Summary GetUser
+// @Tags users
+// @Description get user by id
+// @ID get-user
+// @Produce json,xml,application/msgpack
+// @Param id path integer true "user id"
+// @Param fields query string false "comma separated fields to return"
+// @Success 200
+// @Failure 400
+// @Failure 406
+// @Failure 404
+// @Failure 500
+// @Router /api/v1/users/{id} [get]
+func (c *UserController) handleGetUser() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 
 		if err != nil {
 			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		fields := model.ParseFields(r.URL.Query())
+
+		if err := fields.Validate(); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		u, err := c.service.GetById(r.Context(), id, fields...)
+
+		if err != nil {
+			slog.Error(err.Error())
+
+			if errors.Is(err, domain.ErrUserNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
 
-		w.WriteHeader(http.StatusOK)
-		w.Write(data)
+		if err := respond(w, r, http.StatusOK, fields.Select(converter.ToUserFromService(u))); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+// @Summary SearchUsers
+// @Tags users
+// @Description search users by name, surname and patronymic ignoring case and diacritics, best matches first
+// @ID search-users
+// @Produce json,xml,application/msgpack
+// @Param q query string true "search query"
+// @Param threshold query number false "min similarity of fuzzy matches, 0..1, 0.3 by default"
+// @Param limit query integer false "limit"
+// @Success 200
+// @Failure 400
+// @Failure 406
+// @Failure 500
+// @Router /api/v1/users/search [get]
+func (c *UserController) handleSearchUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		matches := make([]model.UserMatch, 0)
+
+		userSearch := &model.UserSearch{}
+
+		if err := userSearch.FillSearch(r.URL.Query()); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Validate struct
+		if err := userSearch.Validate(); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		serviceMatches, err := c.service.Search(r.Context(), converter.ToUserSearchFromController(userSearch))
+
+		if err != nil {
+			slog.Error(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		for _, m := range serviceMatches {
+			matches = append(matches, *converter.ToUserMatchFromService(&m))
+		}
+
+		if err := respond(w, r, http.StatusOK, matches); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+// @Summary GetStats
+// @Tags users
+// @Description count users and their ages by groups, filters are the same as for users list
+// @ID get-stats
+// @Produce json,xml,application/msgpack
+// @Param group_by query string false "comma separated groups: gender, nationality, age_bucket"
+// @Param age_bucket query integer false "width of age buckets, 10 by default"
+// @Success 200
+// @Failure 400
+// @Failure 406
+// @Failure 500
+// @Router /api/v1/users/stats [get]
+func (c *UserController) handleGetStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats := make([]model.UserStats, 0)
+
+		statsRequest := &model.UserStatsRequest{}
+
+		if err := statsRequest.FillStats(r.URL.Query()); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Validate struct
+		if err := statsRequest.Validate(); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		serviceStats, err := c.service.Stats(r.Context(), converter.ToUserStatsRequestFromController(statsRequest))
+
+		if err != nil {
+			slog.Error(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		for _, s := range serviceStats {
+			stats = append(stats, *converter.ToUserStatsFromService(&s))
+		}
+
+		if err := respond(w, r, http.StatusOK, stats); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
 	}
 }
 
@@ -131,9 +396,10 @@ func (c *UserController) handleGetUsers(ctx context.Context, r chi.Router) http.
 // @Param id path integer true "user id"
 // @Success 200
 // @Failure 400
+// @Failure 404
 // @Failure 500
 // @Router /api/v1/users/{id} [delete]
-func (c *UserController) handleDeleteUser(ctx context.Context) http.HandlerFunc {
+func (c *UserController) handleDeleteUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 
@@ -143,8 +409,14 @@ func (c *UserController) handleDeleteUser(ctx context.Context) http.HandlerFunc
 			return
 		}
 
-		if err := c.service.Delete(ctx, id); err != nil {
+		if err := c.service.Delete(r.Context(), id); err != nil {
 			slog.Error(err.Error())
+
+			if errors.Is(err, domain.ErrUserNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -157,7 +429,7 @@ func (c *UserController) handleDeleteUser(ctx context.Context) http.HandlerFunc
 // @Tags users
 // @Description update user
 // @ID update-user
-// @Accept json
+// @Accept json,xml,application/msgpack
 // @Param id path integer true "user id"
 // @Param name body string false "user name"
 // @Param surname body string false "user surname"
@@ -167,9 +439,11 @@ func (c *UserController) handleDeleteUser(ctx context.Context) http.HandlerFunc
 // @Param nationality body string false "user nationality"
 // @Success 200
 // @Failure 400
+// @Failure 404
+// @Failure 415
 // @Failure 500
 // @Router /api/v1/users/{id} [patch]
-func (c *UserController) handleUpdateUser(ctx context.Context) http.HandlerFunc {
+func (c *UserController) handleUpdateUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
 		var updateUser = model.UpdateUser{}
@@ -190,7 +464,7 @@ func (c *UserController) handleUpdateUser(ctx context.Context) http.HandlerFunc
 			return
 		}
 
-		err = json.Unmarshal(data, &updateUser)
+		err = decode(r, data, &updateUser)
 
 		slog.Debug(fmt.Sprintf("controller: got structure: %v", updateUser))
 
@@ -209,10 +483,16 @@ func (c *UserController) handleUpdateUser(ctx context.Context) http.HandlerFunc
 			return
 		}
 
-		u, err := c.service.GetById(ctx, id)
+		u, err := c.service.GetById(r.Context(), id)
 
 		if err != nil {
 			slog.Error(err.Error())
+
+			if errors.Is(err, domain.ErrUserNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -228,8 +508,15 @@ func (c *UserController) handleUpdateUser(ctx context.Context) http.HandlerFunc
 
 		updateUser.Copy(user)
 
-		if err := c.service.Update(ctx, id, converter.ToUserFromController(user)); err != nil {
+		// User may be deleted since it was read
+		if err := c.service.Update(r.Context(), id, converter.ToUserFromController(user)); err != nil {
 			slog.Error(err.Error())
+
+			if errors.Is(err, domain.ErrUserNotFound) {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -242,15 +529,19 @@ func (c *UserController) handleUpdateUser(ctx context.Context) http.HandlerFunc
 // @Tags users
 // @Description create user
 // @ID create-user
-// @Accept json
+// @Accept json,xml,application/msgpack
 // @Param name body string true "user name"
 // @Param surname body string true "user surname"
 // @Param patronymic body string false "user patronymic"
 // @Success 200
 // @Failure 400
+// @Failure 415
+// @Failure 422 {string} string "name is unknown to enrichment apis"
+// @Failure 429
 // @Failure 500
+// @Failure 503
 // @Router /api/v1/users [post]
-func (c *UserController) handleCreateUser(ctx context.Context) http.HandlerFunc {
+func (c *UserController) handleCreateUser() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var user model.CreateUser
 
@@ -262,7 +553,7 @@ func (c *UserController) handleCreateUser(ctx context.Context) http.HandlerFunc
 			return
 		}
 
-		err = json.Unmarshal(data, &user)
+		err = decode(r, data, &user)
 
 		slog.Debug(fmt.Sprintf("controller: got structure: %v", user))
 
@@ -281,8 +572,20 @@ func (c *UserController) handleCreateUser(ctx context.Context) http.HandlerFunc
 			return
 		}
 
-		if err := c.service.Create(ctx, converter.ToCreateUserFromController(&user)); err != nil {
+		if err := c.service.Create(r.Context(), converter.ToCreateUserFromController(&user)); err != nil {
 			slog.Error(err.Error())
+
+			if errors.Is(err, domain.ErrEnrichmentUnavailable) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			// Name is valid but user can't be saved without its age, gender and nationality
+			if errors.Is(err, domain.ErrUnknownName) {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -3,20 +3,23 @@ package v1
 import (
 	"bytes"
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/go-chi/chi"
 	"github.com/golang/mock/gomock"
+	"github.com/sletkov/effective-mobile-test-task/internal/auth"
 	"github.com/sletkov/effective-mobile-test-task/internal/domain"
 	mock_service "github.com/sletkov/effective-mobile-test-task/internal/service/mocks"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestControllerHandleGetUsers(t *testing.T) {
-	type mockBehavior func(s *mock_service.MockUserService, ctx context.Context, userFilter *domain.UserFilter)
+	type mockBehavior func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter)
 
 	users := []domain.User{
 		{
@@ -55,7 +58,7 @@ func TestControllerHandleGetUsers(t *testing.T) {
 			userFilter: &domain.UserFilter{
 				Limit: 10,
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, userFilter *domain.UserFilter) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
 				s.EXPECT().Get(ctx, userFilter).Return(users, nil)
 			},
 			expectedStatusCode:   http.StatusOK,
@@ -66,10 +69,10 @@ func TestControllerHandleGetUsers(t *testing.T) {
 			name: "filtering by name",
 			url:  "/api/v1/users?name=Ivan",
 			userFilter: &domain.UserFilter{
-				Name:  "Ivan",
+				Expr:  domain.And(domain.Cond("name", domain.FilterEq, "Ivan")),
 				Limit: 10,
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, userFilter *domain.UserFilter) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
 				s.EXPECT().Get(ctx, userFilter).Return(users[0:1], nil)
 			},
 			expectedStatusCode:   http.StatusOK,
@@ -80,10 +83,10 @@ func TestControllerHandleGetUsers(t *testing.T) {
 			name: "filtering by surname",
 			url:  "/api/v1/users?surname=Petrova",
 			userFilter: &domain.UserFilter{
-				Surname: "Petrova",
-				Limit:   10,
+				Expr:  domain.And(domain.Cond("surname", domain.FilterEq, "Petrova")),
+				Limit: 10,
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, userFilter *domain.UserFilter) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
 				s.EXPECT().Get(ctx, userFilter).Return(users[1:], nil)
 			},
 			expectedStatusCode:   http.StatusOK,
@@ -94,10 +97,10 @@ func TestControllerHandleGetUsers(t *testing.T) {
 			name: "filtering by patronymic",
 			url:  "/api/v1/users?patronymic=Ivanovich",
 			userFilter: &domain.UserFilter{
-				Patronymic: "Ivanovich",
-				Limit:      10,
+				Expr:  domain.And(domain.Cond("patronymic", domain.FilterEq, "Ivanovich")),
+				Limit: 10,
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, userFilter *domain.UserFilter) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
 				s.EXPECT().Get(ctx, userFilter).Return(users[0:1], nil)
 			},
 			expectedStatusCode:   http.StatusOK,
@@ -108,10 +111,10 @@ func TestControllerHandleGetUsers(t *testing.T) {
 			name: "filtering by age_from",
 			url:  "/api/v1/users?age_from=30",
 			userFilter: &domain.UserFilter{
-				AgeFrom: 30,
-				Limit:   10,
+				Expr:  domain.And(domain.Cond("age", domain.FilterGte, "30")),
+				Limit: 10,
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, userFilter *domain.UserFilter) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
 				s.EXPECT().Get(ctx, userFilter).Return(users[1:], nil)
 			},
 			expectedStatusCode:   http.StatusOK,
@@ -122,10 +125,10 @@ func TestControllerHandleGetUsers(t *testing.T) {
 			name: "filtering by age_to",
 			url:  "/api/v1/users?age_to=30",
 			userFilter: &domain.UserFilter{
-				AgeTo: 30,
+				Expr:  domain.And(domain.Cond("age", domain.FilterLte, "30")),
 				Limit: 10,
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, userFilter *domain.UserFilter) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
 				s.EXPECT().Get(ctx, userFilter).Return(users[0:1], nil)
 			},
 			expectedStatusCode:   http.StatusOK,
@@ -136,10 +139,10 @@ func TestControllerHandleGetUsers(t *testing.T) {
 			name: "filtering by gender",
 			url:  "/api/v1/users?gender=male",
 			userFilter: &domain.UserFilter{
-				Gender: "male",
-				Limit:  10,
+				Expr:  domain.And(domain.Cond("gender", domain.FilterEq, "male")),
+				Limit: 10,
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, userFilter *domain.UserFilter) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
 				s.EXPECT().Get(ctx, userFilter).Return(users[0:1], nil)
 			},
 			expectedStatusCode:   http.StatusOK,
@@ -150,10 +153,10 @@ func TestControllerHandleGetUsers(t *testing.T) {
 			name: "filtering by nationality",
 			url:  "/api/v1/users?nationality=US",
 			userFilter: &domain.UserFilter{
-				Nationality: "US",
-				Limit:       10,
+				Expr:  domain.And(domain.Cond("nationality", domain.FilterEq, "US")),
+				Limit: 10,
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, userFilter *domain.UserFilter) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
 				s.EXPECT().Get(ctx, userFilter).Return(users[1:], nil)
 			},
 			expectedStatusCode:   http.StatusOK,
@@ -166,12 +169,136 @@ func TestControllerHandleGetUsers(t *testing.T) {
 			userFilter: &domain.UserFilter{
 				Limit: 1,
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, userFilter *domain.UserFilter) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
 				s.EXPECT().Get(ctx, userFilter).Return(users[0:1], nil)
 			},
 			expectedStatusCode:   http.StatusOK,
 			expectedResponseBody: `[{"id":1,"name":"Ivan","surname":"Ivanov","patronymic":"Ivanovich","age":20,"gender":"male","nationality":"RU"}]`,
 		},
+
+		{
+			name: "filtering by nationality list",
+			url:  "/api/v1/users?nationality=RU,UA",
+			userFilter: &domain.UserFilter{
+				Expr:  domain.And(domain.Cond("nationality", domain.FilterIn, "RU", "UA")),
+				Limit: 10,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
+				s.EXPECT().Get(ctx, userFilter).Return(users[0:1], nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"id":1,"name":"Ivan","surname":"Ivanov","patronymic":"Ivanovich","age":20,"gender":"male","nationality":"RU"}]`,
+		},
+
+		{
+			name: "filtering with operators",
+			url:  "/api/v1/users?surname[prefix]=Iva&gender[ne]=female&patronymic[null]=false&age[gte]=18&age[lt]=65",
+			userFilter: &domain.UserFilter{
+				Expr: domain.And(
+					domain.Cond("age", domain.FilterGte, "18"),
+					domain.Cond("age", domain.FilterLt, "65"),
+					domain.Cond("gender", domain.FilterNe, "female"),
+					domain.Cond("patronymic", domain.FilterNotNull),
+					domain.Cond("surname", domain.FilterPrefix, "Iva"),
+				),
+				Limit: 10,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
+				s.EXPECT().Get(ctx, userFilter).Return(users[0:1], nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"id":1,"name":"Ivan","surname":"Ivanov","patronymic":"Ivanovich","age":20,"gender":"male","nationality":"RU"}]`,
+		},
+
+		{
+			name: "filtering with or groups",
+			url:  "/api/v1/users?gender=male&or[a][name]=Ivan&or[a][surname][contains]=van&or[b][nationality][nin]=US,DE&or[b][patronymic][null]=true",
+			userFilter: &domain.UserFilter{
+				Expr: domain.And(
+					domain.Cond("gender", domain.FilterEq, "male"),
+					domain.Or(
+						domain.Cond("name", domain.FilterEq, "Ivan"),
+						domain.Cond("surname", domain.FilterContains, "van"),
+					),
+					domain.Or(
+						domain.Cond("nationality", domain.FilterNotIn, "US", "DE"),
+						domain.Cond("patronymic", domain.FilterNull),
+					),
+				),
+				Limit: 10,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
+				s.EXPECT().Get(ctx, userFilter).Return(users[0:1], nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"id":1,"name":"Ivan","surname":"Ivanov","patronymic":"Ivanovich","age":20,"gender":"male","nationality":"RU"}]`,
+		},
+
+		{
+			name: "sorting",
+			url:  "/api/v1/users?sort=-age,surname&limit=2",
+			userFilter: &domain.UserFilter{
+				Sort:  []domain.SortField{{Field: "age", Desc: true}, {Field: "surname"}},
+				Limit: 2,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
+				s.EXPECT().Get(ctx, userFilter).Return([]domain.User{users[1], users[0]}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"id":2,"name":"Galina","surname":"Petrova","patronymic":"Petrovna","age":40,"gender":"female","nationality":"US"},{"id":1,"name":"Ivan","surname":"Ivanov","patronymic":"Ivanovich","age":20,"gender":"male","nationality":"RU"}]`,
+		},
+
+		{
+			name: "sparse fieldset",
+			url:  "/api/v1/users?fields=nationality,id,name",
+			userFilter: &domain.UserFilter{
+				Fields: []string{"nationality", "id", "name"},
+				Limit:  10,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {
+				s.EXPECT().Get(ctx, userFilter).Return([]domain.User{
+					{Id: 1, Name: "Ivan", Nationality: "RU"},
+					{Id: 2, Name: "Galina", Nationality: "US"},
+				}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"id":1,"name":"Ivan","nationality":"RU"},{"id":2,"name":"Galina","nationality":"US"}]`,
+		},
+
+		{
+			name:               "unknown field in fieldset",
+			url:                "/api/v1/users?fields=id,email",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "unknown sort field",
+			url:                "/api/v1/users?sort=created",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "unknown field",
+			url:                "/api/v1/users?email[eq]=ivan@example.com",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "operator not allowed for field",
+			url:                "/api/v1/users?gender[prefix]=ma",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "sql in value",
+			url:                "/api/v1/users?name=Ivan%27%20OR%20%271%27=%271",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, userFilter *domain.UserFilter) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -181,13 +308,13 @@ func TestControllerHandleGetUsers(t *testing.T) {
 			defer c.Finish()
 
 			userService := mock_service.NewMockUserService(c)
-			tc.mockBehavior(userService, context.Background(), tc.userFilter)
+			tc.mockBehavior(userService, gomock.Any(), tc.userFilter)
 
 			controller := New(userService)
 
 			// Test router
 			r := chi.NewRouter()
-			r.Get("/api/v1/users", controller.handleGetUsers(context.Background(), r))
+			r.Get("/api/v1/users", controller.handleGetUsers())
 
 			// Test request
 			w := httptest.NewRecorder()
@@ -203,8 +330,186 @@ func TestControllerHandleGetUsers(t *testing.T) {
 	}
 }
 
+func TestControllerHandleGetUser(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockUserService, ctx interface{}, id int)
+
+	testCases := []struct {
+		name                 string
+		url                  string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "all fields",
+			url:  "/api/v1/users/1",
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, id int) {
+				s.EXPECT().GetById(ctx, id).Return(&domain.User{
+					Id:          1,
+					Name:        "Ivan",
+					Surname:     "Ivanov",
+					Patronymic:  "Ivanovich",
+					Age:         20,
+					Gender:      "male",
+					Nationality: "RU",
+				}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":1,"name":"Ivan","surname":"Ivanov","patronymic":"Ivanovich","age":20,"gender":"male","nationality":"RU"}`,
+		},
+
+		{
+			name: "sparse fieldset",
+			url:  "/api/v1/users/1?fields=nationality,id",
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, id int) {
+				s.EXPECT().GetById(ctx, id, "nationality", "id").Return(&domain.User{Id: 1, Nationality: "RU"}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"id":1,"nationality":"RU"}`,
+		},
+
+		{
+			name: "not found",
+			url:  "/api/v1/users/1",
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, id int) {
+				s.EXPECT().GetById(ctx, id).Return(nil, domain.ErrUserNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+
+		{
+			name:               "unknown field",
+			url:                "/api/v1/users/1?fields=password",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, id int) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "invalid id",
+			url:                "/api/v1/users/id",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, id int) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			userService := mock_service.NewMockUserService(c)
+			tc.mockBehavior(userService, gomock.Any(), 1)
+
+			controller := New(userService)
+
+			// Test router
+			r := chi.NewRouter()
+			r.Get("/api/v1/users/{id}", controller.handleGetUser())
+
+			// Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+
+			// Perform request
+			r.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+
+			if tc.expectedResponseBody != "" {
+				assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestControllerRequestContext(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	authenticator, err := auth.NewAPIKeyAuthenticator(map[string]string{"key": "reader"})
+	assert.NoError(t, err)
+
+	// Service gets context of the request with principal on it
+	userService := mock_service.NewMockUserService(c)
+	userService.EXPECT().GetById(gomock.Any(), 1).DoAndReturn(func(ctx context.Context, id int, fields ...string) (*domain.User, error) {
+		assert.NotNil(t, auth.PrincipalFromContext(ctx))
+		return &domain.User{Id: id}, nil
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/users/1", nil)
+	req.Header.Set(auth.APIKeyHeader, "key")
+
+	New(userService, WithAuth(authenticator)).InitRoutes().ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestControllerDebugVars(t *testing.T) {
+	authenticator, err := auth.NewAPIKeyAuthenticator(map[string]string{"reader-key": "reader", "admin-key": "admin"})
+	assert.NoError(t, err)
+
+	vars := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"cmdline": []}`))
+	})
+
+	testCases := []struct {
+		name               string
+		opts               []Option
+		key                string
+		expectedStatusCode int
+	}{
+		{
+			name:               "disabled",
+			opts:               []Option{WithAuth(authenticator)},
+			key:                "admin-key",
+			expectedStatusCode: http.StatusNotFound,
+		},
+
+		{
+			name:               "unauthenticated",
+			opts:               []Option{WithAuth(authenticator), WithDebugVars(vars)},
+			expectedStatusCode: http.StatusUnauthorized,
+		},
+
+		{
+			name:               "not admin",
+			opts:               []Option{WithAuth(authenticator), WithDebugVars(vars)},
+			key:                "reader-key",
+			expectedStatusCode: http.StatusForbidden,
+		},
+
+		{
+			name:               "admin",
+			opts:               []Option{WithAuth(authenticator), WithDebugVars(vars)},
+			key:                "admin-key",
+			expectedStatusCode: http.StatusOK,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/debug/vars", nil)
+
+			if tc.key != "" {
+				req.Header.Set(auth.APIKeyHeader, tc.key)
+			}
+
+			New(mock_service.NewMockUserService(c), tc.opts...).InitRoutes().ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+		})
+	}
+}
+
 func TestControllerHandleDeleteUser(t *testing.T) {
-	type mockBehavior func(s *mock_service.MockUserService, ctx context.Context, id int)
+	type mockBehavior func(s *mock_service.MockUserService, ctx interface{}, id int)
 
 	testCases := []struct {
 		name               string
@@ -216,16 +521,25 @@ func TestControllerHandleDeleteUser(t *testing.T) {
 		{
 			name: "valid id",
 			id:   "7",
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, id int) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, id int) {
 				s.EXPECT().Delete(ctx, id)
 			},
 			expectedStatusCode: http.StatusOK,
 		},
 
+		{
+			name: "missing user",
+			id:   "8",
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, id int) {
+				s.EXPECT().Delete(ctx, id).Return(domain.ErrUserNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+
 		{
 			name:               "invalid id",
 			id:                 "id",
-			mockBehavior:       func(s *mock_service.MockUserService, ctx context.Context, id int) {},
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, id int) {},
 			expectedStatusCode: http.StatusBadRequest,
 		},
 	}
@@ -240,13 +554,13 @@ func TestControllerHandleDeleteUser(t *testing.T) {
 
 			id, _ := strconv.Atoi(tc.id)
 
-			tc.mockBehavior(userService, context.Background(), id)
+			tc.mockBehavior(userService, gomock.Any(), id)
 
 			controller := New(userService)
 
 			// Test router
 			r := chi.NewRouter()
-			r.Delete("/api/v1/users/{id}", controller.handleDeleteUser(context.Background()))
+			r.Delete("/api/v1/users/{id}", controller.handleDeleteUser())
 
 			// Test request
 			w := httptest.NewRecorder()
@@ -262,7 +576,7 @@ func TestControllerHandleDeleteUser(t *testing.T) {
 }
 
 func TestControllerHandleUpdateUser(t *testing.T) {
-	type mockBehavior func(s *mock_service.MockUserService, ctx context.Context, id int, user *domain.User)
+	type mockBehavior func(s *mock_service.MockUserService, ctx interface{}, id int, user *domain.User)
 
 	testCases := []struct {
 		name               string
@@ -280,7 +594,7 @@ func TestControllerHandleUpdateUser(t *testing.T) {
 			user: &domain.User{
 				Name: "Ivan",
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, id int, user *domain.User) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, id int, user *domain.User) {
 				s.EXPECT().GetById(ctx, id).Return(&domain.User{}, nil)
 				s.EXPECT().Update(ctx, id, user)
 			},
@@ -298,13 +612,13 @@ func TestControllerHandleUpdateUser(t *testing.T) {
 
 			id, _ := strconv.Atoi(tc.id)
 
-			tc.mockBehavior(userService, context.Background(), id, tc.user)
+			tc.mockBehavior(userService, gomock.Any(), id, tc.user)
 
 			controller := New(userService)
 
 			// Test router
 			r := chi.NewRouter()
-			r.Patch("/api/v1/users/{id}", controller.handleUpdateUser(context.Background()))
+			r.Patch("/api/v1/users/{id}", controller.handleUpdateUser())
 
 			// Test request
 			w := httptest.NewRecorder()
@@ -320,7 +634,7 @@ func TestControllerHandleUpdateUser(t *testing.T) {
 }
 
 func TestControllerHandleCreateUser(t *testing.T) {
-	type mockBehavior func(s *mock_service.MockUserService, ctx context.Context, user *domain.User)
+	type mockBehavior func(s *mock_service.MockUserService, ctx interface{}, user *domain.User)
 
 	testCases := []struct {
 		name               string
@@ -338,7 +652,7 @@ func TestControllerHandleCreateUser(t *testing.T) {
 				Surname:    "Ivanov",
 				Patronymic: "Ivanovich",
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, user *domain.User) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, user *domain.User) {
 				s.EXPECT().Create(ctx, user).Return(nil)
 			},
 			expectedStatusCode: http.StatusOK,
@@ -351,19 +665,32 @@ func TestControllerHandleCreateUser(t *testing.T) {
 				Name:    "Ivan",
 				Surname: "Ivanov",
 			},
-			mockBehavior: func(s *mock_service.MockUserService, ctx context.Context, user *domain.User) {
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, user *domain.User) {
 				s.EXPECT().Create(ctx, user).Return(nil)
 			},
 			expectedStatusCode: http.StatusOK,
 		},
 
+		{
+			name:       "unknown name",
+			requstBody: `{"name":"Qwzx","surname":"Ivanov"}`,
+			user: &domain.User{
+				Name:    "Qwzx",
+				Surname: "Ivanov",
+			},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, user *domain.User) {
+				s.EXPECT().Create(ctx, user).Return(domain.ErrUnknownName)
+			},
+			expectedStatusCode: http.StatusUnprocessableEntity,
+		},
+
 		{
 			name:       "blank name",
 			requstBody: `{"surname":"Ivanov"}`,
 			user: &domain.User{
 				Surname: "Ivanov",
 			},
-			mockBehavior:       func(s *mock_service.MockUserService, ctx context.Context, user *domain.User) {},
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, user *domain.User) {},
 			expectedStatusCode: http.StatusBadRequest,
 		},
 
@@ -373,7 +700,7 @@ func TestControllerHandleCreateUser(t *testing.T) {
 			user: &domain.User{
 				Name: "Ivan",
 			},
-			mockBehavior:       func(s *mock_service.MockUserService, ctx context.Context, user *domain.User) {},
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, user *domain.User) {},
 			expectedStatusCode: http.StatusBadRequest,
 		},
 	}
@@ -385,13 +712,13 @@ func TestControllerHandleCreateUser(t *testing.T) {
 			defer c.Finish()
 
 			userService := mock_service.NewMockUserService(c)
-			tc.mockBehavior(userService, context.Background(), tc.user)
+			tc.mockBehavior(userService, gomock.Any(), tc.user)
 
 			controller := New(userService)
 
 			// Test router
 			r := chi.NewRouter()
-			r.Post("/api/v1/users", controller.handleCreateUser(context.Background()))
+			r.Post("/api/v1/users", controller.handleCreateUser())
 
 			// Test request
 			w := httptest.NewRecorder()
@@ -405,3 +732,522 @@ func TestControllerHandleCreateUser(t *testing.T) {
 		})
 	}
 }
+
+func TestControllerHandleSearchUsers(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockUserService, ctx interface{}, userSearch *domain.UserSearch)
+
+	matches := []domain.UserMatch{
+		{
+			User: domain.User{
+				Id:          1,
+				Name:        "Ivan",
+				Surname:     "Ivanov",
+				Patronymic:  "Ivanovich",
+				Age:         20,
+				Gender:      "male",
+				Nationality: "RU",
+			},
+			Rank:         0.6,
+			MatchedField: "name",
+			Highlight:    "<mark>Ivan</mark>",
+		},
+	}
+
+	testCases := []struct {
+		name                 string
+		url                  string
+		userSearch           *domain.UserSearch
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name: "defaults",
+			url:  "/api/v1/users/search?q=ivan",
+			userSearch: &domain.UserSearch{
+				Query:     "ivan",
+				Threshold: 0.3,
+				Limit:     10,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userSearch *domain.UserSearch) {
+				s.EXPECT().Search(ctx, userSearch).Return(matches, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"id":1,"name":"Ivan","surname":"Ivanov","patronymic":"Ivanovich","age":20,"gender":"male","nationality":"RU","rank":0.6,"matched_field":"name","highlight":"\u003cmark\u003eIvan\u003c/mark\u003e"}]`,
+		},
+
+		{
+			name: "threshold and limit",
+			url:  "/api/v1/users/search?q=Iv%C3%A1n&threshold=0.5&limit=5",
+			userSearch: &domain.UserSearch{
+				Query:     "Iván",
+				Threshold: 0.5,
+				Limit:     5,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, userSearch *domain.UserSearch) {
+				s.EXPECT().Search(ctx, userSearch).Return([]domain.UserMatch{}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[]`,
+		},
+
+		{
+			name:               "no query",
+			url:                "/api/v1/users/search",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, userSearch *domain.UserSearch) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "query without words",
+			url:                "/api/v1/users/search?q=%27%26%21",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, userSearch *domain.UserSearch) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "invalid threshold",
+			url:                "/api/v1/users/search?q=ivan&threshold=2",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, userSearch *domain.UserSearch) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			userService := mock_service.NewMockUserService(c)
+			tc.mockBehavior(userService, gomock.Any(), tc.userSearch)
+
+			controller := New(userService)
+
+			// Test router
+			r := chi.NewRouter()
+			r.Get("/api/v1/users/search", controller.handleSearchUsers())
+
+			// Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+
+			// Perform request
+			r.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+
+			if tc.expectedResponseBody != "" {
+				assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestControllerHandleGetStats(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockUserService, ctx interface{}, statsRequest *domain.UserStatsRequest)
+
+	testCases := []struct {
+		name                 string
+		url                  string
+		statsRequest         *domain.UserStatsRequest
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:         "all users",
+			url:          "/api/v1/users/stats",
+			statsRequest: &domain.UserStatsRequest{AgeBucket: 10},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, statsRequest *domain.UserStatsRequest) {
+				s.EXPECT().Stats(ctx, statsRequest).Return([]domain.UserStats{
+					{Count: 2, AvgAge: 30, AgeP50: 30, AgeP90: 38, AgeP99: 39.8},
+				}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"count":2,"avg_age":30,"age_p50":30,"age_p90":38,"age_p99":39.8}]`,
+		},
+
+		{
+			name: "grouped and filtered",
+			url:  "/api/v1/users/stats?group_by=gender,age_bucket&age_bucket=20&nationality=RU,UA",
+			statsRequest: &domain.UserStatsRequest{
+				Expr:      domain.And(domain.Cond("nationality", domain.FilterIn, "RU", "UA")),
+				GroupBy:   []string{"gender", "age_bucket"},
+				AgeBucket: 20,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, statsRequest *domain.UserStatsRequest) {
+				s.EXPECT().Stats(ctx, statsRequest).Return([]domain.UserStats{
+					{Gender: "male", AgeBucket: &domain.AgeBucket{From: 20, To: 39}, Count: 1, AvgAge: 20, AgeP50: 20, AgeP90: 20, AgeP99: 20},
+				}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"gender":"male","age_bucket":{"from":20,"to":39},"count":1,"avg_age":20,"age_p50":20,"age_p90":20,"age_p99":20}]`,
+		},
+
+		{
+			name:         "empty params are ignored",
+			url:          "/api/v1/users/stats?group_by=&age_bucket=&limit=&name=",
+			statsRequest: &domain.UserStatsRequest{AgeBucket: 10},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, statsRequest *domain.UserStatsRequest) {
+				s.EXPECT().Stats(ctx, statsRequest).Return([]domain.UserStats{}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+
+		{
+			name: "empty groups are dropped",
+			url:  "/api/v1/users/stats?group_by=gender,,",
+			statsRequest: &domain.UserStatsRequest{
+				GroupBy:   []string{"gender"},
+				AgeBucket: 10,
+			},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, statsRequest *domain.UserStatsRequest) {
+				s.EXPECT().Stats(ctx, statsRequest).Return([]domain.UserStats{}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+
+		{
+			name:               "unknown group",
+			url:                "/api/v1/users/stats?group_by=surname",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, statsRequest *domain.UserStatsRequest) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "invalid filter",
+			url:                "/api/v1/users/stats?gender=email",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, statsRequest *domain.UserStatsRequest) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "invalid age bucket",
+			url:                "/api/v1/users/stats?group_by=age_bucket&age_bucket=0",
+			mockBehavior:       func(s *mock_service.MockUserService, ctx interface{}, statsRequest *domain.UserStatsRequest) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			userService := mock_service.NewMockUserService(c)
+			tc.mockBehavior(userService, gomock.Any(), tc.statsRequest)
+
+			controller := New(userService)
+
+			// Test router
+			r := chi.NewRouter()
+			r.Get("/api/v1/users/stats", controller.handleGetStats())
+
+			// Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+
+			// Perform request
+			r.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+
+			if tc.expectedResponseBody != "" {
+				assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestControllerHandleExportUsers(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockUserService, userFilter *domain.UserFilter)
+
+	users := []domain.User{
+		{Id: 1, Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "male", Nationality: "RU"},
+		{Id: 2, Name: "Anna", Surname: "Petrova, Jr", Patronymic: "Ivanovna", Age: 30, Gender: "female", Nationality: "UA"},
+	}
+
+	exportUsers := func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {
+		s.EXPECT().Export(gomock.Any(), userFilter, gomock.Any()).DoAndReturn(
+			func(ctx context.Context, userFilter *domain.UserFilter, fn func(u *domain.User) error) error {
+				for i := range users {
+					if err := fn(&users[i]); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+	}
+
+	testCases := []struct {
+		name                 string
+		url                  string
+		accept               string
+		userFilter           *domain.UserFilter
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedContentType  string
+		expectedResponseBody string
+	}{
+		{
+			name:                "ndjson by default",
+			url:                 "/api/v1/users/export?fields=name,id",
+			userFilter:          &domain.UserFilter{Fields: []string{"name", "id"}},
+			mockBehavior:        exportUsers,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "application/x-ndjson",
+			expectedResponseBody: `{"id":1,"name":"Ivan"}` + "\n" +
+				`{"id":2,"name":"Anna"}` + "\n",
+		},
+
+		{
+			name:                "csv",
+			url:                 "/api/v1/users/export?age[gte]=20&fields=surname,id,patronymic",
+			accept:              "text/html, text/csv;q=0.9",
+			userFilter:          &domain.UserFilter{Expr: domain.And(domain.Cond("age", domain.FilterGte, "20")), Fields: []string{"surname", "id", "patronymic"}},
+			mockBehavior:        exportUsers,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv",
+			expectedResponseBody: "id,surname,patronymic\n" +
+				"1,Ivanov,\n" +
+				"2,\"Petrova, Jr\",Ivanovna\n",
+		},
+
+		{
+			name:       "empty csv has header",
+			url:        "/api/v1/users/export?name=Nobody",
+			accept:     "text/csv",
+			userFilter: &domain.UserFilter{Expr: domain.And(domain.Cond("name", domain.FilterEq, "Nobody"))},
+			mockBehavior: func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {
+				s.EXPECT().Export(gomock.Any(), userFilter, gomock.Any()).Return(nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedContentType:  "text/csv",
+			expectedResponseBody: "id,name,surname,patronymic,age,gender,nationality\n",
+		},
+
+		{
+			name:       "failed before first user",
+			url:        "/api/v1/users/export",
+			userFilter: &domain.UserFilter{},
+			mockBehavior: func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {
+				s.EXPECT().Export(gomock.Any(), userFilter, gomock.Any()).Return(errors.New("connection refused"))
+			},
+			expectedStatusCode: http.StatusInternalServerError,
+		},
+
+		{
+			name:                "ndjson refused",
+			url:                 "/api/v1/users/export?fields=id",
+			accept:              "application/x-ndjson;q=0, */*",
+			userFilter:          &domain.UserFilter{Fields: []string{"id"}},
+			mockBehavior:        exportUsers,
+			expectedStatusCode:  http.StatusOK,
+			expectedContentType: "text/csv",
+			expectedResponseBody: "id\n" +
+				"1\n" +
+				"2\n",
+		},
+
+		{
+			name:               "unsupported format",
+			url:                "/api/v1/users/export",
+			accept:             "application/xml",
+			mockBehavior:       func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {},
+			expectedStatusCode: http.StatusNotAcceptable,
+		},
+
+		{
+			name:               "limit",
+			url:                "/api/v1/users/export?limit=10",
+			mockBehavior:       func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "invalid filter",
+			url:                "/api/v1/users/export?gender=email",
+			mockBehavior:       func(s *mock_service.MockUserService, userFilter *domain.UserFilter) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			userService := mock_service.NewMockUserService(c)
+			tc.mockBehavior(userService, tc.userFilter)
+
+			controller := New(userService)
+
+			// Test router
+			r := chi.NewRouter()
+			r.Get("/api/v1/users/export", controller.handleExportUsers())
+
+			// Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tc.url, nil)
+
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+
+			// Perform request
+			r.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+
+			if tc.expectedStatusCode == http.StatusOK {
+				assert.Equal(t, tc.expectedContentType, w.Header().Get("Content-Type"))
+				assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestControllerHandleImportUsers(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockUserService, ctx interface{}, rows []domain.ImportRow, opts domain.ImportOptions)
+
+	noImport := func(s *mock_service.MockUserService, ctx interface{}, rows []domain.ImportRow, opts domain.ImportOptions) {
+	}
+
+	testCases := []struct {
+		name                 string
+		url                  string
+		contentType          string
+		body                 string
+		rows                 []domain.ImportRow
+		opts                 domain.ImportOptions
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:        "csv with invalid rows",
+			url:         "/api/v1/users/import?enrich=true",
+			contentType: "text/csv; charset=utf-8",
+			body:        "name,surname\nIvan,Ivanov\nIvan1,Ivanov\nQwzx,Petrov\n",
+			rows: []domain.ImportRow{
+				{Line: 2, User: domain.User{Name: "Ivan", Surname: "Ivanov"}},
+				{Line: 4, User: domain.User{Name: "Qwzx", Surname: "Petrov"}},
+			},
+			opts: domain.ImportOptions{Enrich: true},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, rows []domain.ImportRow, opts domain.ImportOptions) {
+				s.EXPECT().Import(ctx, rows, opts).Return(&domain.ImportReport{
+					Total:    2,
+					Valid:    1,
+					Imported: 1,
+					Errors:   []domain.ImportError{{Line: 4, Error: "nationality is unknown"}},
+				}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"total":3,"valid":1,"unverified":0,"imported":1,"dry_run":false,"errors":[{"line":3,"error":"name: must contain English letters only."},{"line":4,"error":"nationality is unknown"}]}`,
+		},
+
+		{
+			name:        "ndjson dry run",
+			url:         "/api/v1/users/import?dry_run=true",
+			contentType: "application/x-ndjson",
+			body:        `{"name":"Anna","surname":"Petrova","age":30,"gender":"female","nationality":"UA"}` + "\n",
+			rows: []domain.ImportRow{
+				{Line: 1, User: domain.User{Name: "Anna", Surname: "Petrova", Age: 30, Gender: "female", Nationality: "UA"}},
+			},
+			opts: domain.ImportOptions{DryRun: true},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, rows []domain.ImportRow, opts domain.ImportOptions) {
+				s.EXPECT().Import(ctx, rows, opts).Return(&domain.ImportReport{Total: 1, Valid: 1, DryRun: true, Errors: []domain.ImportError{}}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `{"total":1,"valid":1,"unverified":0,"imported":0,"dry_run":true,"errors":[]}`,
+		},
+
+		{
+			name:        "enrichment unavailable",
+			url:         "/api/v1/users/import?enrich=1",
+			contentType: "text/csv",
+			body:        "name,surname\nIvan,Ivanov\n",
+			rows:        []domain.ImportRow{{Line: 2, User: domain.User{Name: "Ivan", Surname: "Ivanov"}}},
+			opts:        domain.ImportOptions{Enrich: true},
+			mockBehavior: func(s *mock_service.MockUserService, ctx interface{}, rows []domain.ImportRow, opts domain.ImportOptions) {
+				s.EXPECT().Import(ctx, rows, opts).Return(nil, domain.ErrEnrichmentUnavailable)
+			},
+			expectedStatusCode: http.StatusServiceUnavailable,
+		},
+
+		{
+			name:               "unsupported content type",
+			url:                "/api/v1/users/import",
+			contentType:        "application/json",
+			body:               `[{"name":"Ivan","surname":"Ivanov"}]`,
+			mockBehavior:       noImport,
+			expectedStatusCode: http.StatusUnsupportedMediaType,
+		},
+
+		{
+			name:               "unknown column",
+			url:                "/api/v1/users/import",
+			contentType:        "text/csv",
+			body:               "name,surname,email\n",
+			mockBehavior:       noImport,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "invalid dry run",
+			url:                "/api/v1/users/import?dry_run=maybe",
+			contentType:        "text/csv",
+			body:               "name,surname\n",
+			mockBehavior:       noImport,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:               "too many rows",
+			url:                "/api/v1/users/import",
+			contentType:        "text/csv",
+			body:               "name,surname\n" + strings.Repeat("Ivan,Ivanov\n", importMaxRows+1),
+			mockBehavior:       noImport,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			userService := mock_service.NewMockUserService(c)
+			tc.mockBehavior(userService, gomock.Any(), tc.rows, tc.opts)
+
+			controller := New(userService)
+
+			// Test router
+			r := chi.NewRouter()
+			r.Post("/api/v1/users/import", controller.handleImportUsers())
+
+			// Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, tc.url, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+
+			// Perform request
+			r.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+
+			if tc.expectedResponseBody != "" {
+				assert.Equal(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -38,16 +38,31 @@ func ToUserFromController(user *model.User) *domain.User {
 }
 
 func ToUserFilterFromController(userFilter *model.UserFilter) *domain.UserFilter {
-	return &domain.UserFilter{
-		Name:        userFilter.Name,
-		Surname:     userFilter.Surname,
-		Patronymic:  userFilter.Patronymic,
-		AgeFrom:     userFilter.AgeFrom,
-		AgeTo:       userFilter.AgeTo,
-		Gender:      userFilter.Gender,
-		Nationality: userFilter.Nationality,
-		Limit:       userFilter.Limit,
+	filter := &domain.UserFilter{
+		Expr:   ToFilterExprFromController(userFilter.Expr),
+		Fields: userFilter.Fields,
+		Limit:  userFilter.Limit,
 	}
+
+	for _, f := range userFilter.Sort {
+		filter.Sort = append(filter.Sort, domain.SortField{Field: f.Field, Desc: f.Desc})
+	}
+
+	return filter
+}
+
+func ToFilterExprFromController(e model.FilterExpr) domain.FilterExpr {
+	expr := domain.FilterExpr{
+		Op:     domain.FilterOp(e.Op),
+		Field:  e.Field,
+		Values: e.Values,
+	}
+
+	for _, child := range e.Children {
+		expr.Children = append(expr.Children, ToFilterExprFromController(child))
+	}
+
+	return expr
 }
 
 func ToUserFromService(user *domain.User) *model.User {
@@ -61,3 +76,77 @@ func ToUserFromService(user *domain.User) *model.User {
 		Nationality: user.Nationality,
 	}
 }
+
+func ToUserSearchFromController(userSearch *model.UserSearch) *domain.UserSearch {
+	return &domain.UserSearch{
+		Query:     userSearch.Query,
+		Threshold: userSearch.Threshold,
+		Limit:     userSearch.Limit,
+	}
+}
+
+func ToUserMatchFromService(match *domain.UserMatch) *model.UserMatch {
+	return &model.UserMatch{
+		User:         *ToUserFromService(&match.User),
+		Rank:         match.Rank,
+		MatchedField: match.MatchedField,
+		Highlight:    match.Highlight,
+	}
+}
+
+func ToUserStatsRequestFromController(statsRequest *model.UserStatsRequest) *domain.UserStatsRequest {
+	return &domain.UserStatsRequest{
+		Expr:      ToFilterExprFromController(statsRequest.Filter.Expr),
+		GroupBy:   statsRequest.GroupBy,
+		AgeBucket: statsRequest.AgeBucket,
+	}
+}
+
+func ToUserStatsFromService(stats *domain.UserStats) *model.UserStats {
+	s := &model.UserStats{
+		Gender:      stats.Gender,
+		Nationality: stats.Nationality,
+		Count:       stats.Count,
+		AvgAge:      stats.AvgAge,
+		AgeP50:      stats.AgeP50,
+		AgeP90:      stats.AgeP90,
+		AgeP99:      stats.AgeP99,
+	}
+
+	if stats.AgeBucket != nil {
+		s.AgeBucket = &model.AgeBucket{From: stats.AgeBucket.From, To: stats.AgeBucket.To}
+	}
+
+	return s
+}
+
+func ToImportRowFromController(row *model.ImportRow) *domain.ImportRow {
+	return &domain.ImportRow{
+		Line: row.Line,
+		User: domain.User{
+			Name:        row.User.Name,
+			Surname:     row.User.Surname,
+			Patronymic:  row.User.Patronymic,
+			Age:         row.User.Age,
+			Gender:      row.User.Gender,
+			Nationality: row.User.Nationality,
+		},
+	}
+}
+
+func ToImportReportFromService(report *domain.ImportReport) *model.ImportReport {
+	r := &model.ImportReport{
+		Total:      report.Total,
+		Valid:      report.Valid,
+		Unverified: report.Unverified,
+		Imported:   report.Imported,
+		DryRun:     report.DryRun,
+		Errors:     make([]model.ImportError, 0, len(report.Errors)),
+	}
+
+	for _, e := range report.Errors {
+		r.Errors = append(r.Errors, model.ImportError{Line: e.Line, Error: e.Error})
+	}
+
+	return r
+}
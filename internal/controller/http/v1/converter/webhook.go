@@ -0,0 +1,62 @@
+package converter
+
+import (
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/model"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+func ToCreateSubscriptionFromController(s *model.CreateSubscription) *domain.Subscription {
+	return &domain.Subscription{
+		URL:    s.URL,
+		Events: s.Events,
+		Secret: s.Secret,
+	}
+}
+
+// Secret is left out, it is shown only when subscription is created
+func ToSubscriptionFromService(s *domain.Subscription) *model.Subscription {
+	return &model.Subscription{
+		Id:        s.Id,
+		URL:       s.URL,
+		Events:    s.Events,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+func ToSubscriptionFromController(s *model.Subscription) *domain.Subscription {
+	return &domain.Subscription{
+		Id:     s.Id,
+		URL:    s.URL,
+		Events: s.Events,
+		Secret: s.Secret,
+	}
+}
+
+func ToDeliveryFilterFromController(subscriptionId int, filter *model.DeliveryFilter) *domain.DeliveryFilter {
+	return &domain.DeliveryFilter{
+		SubscriptionId: subscriptionId,
+		Status:         filter.Status,
+		Limit:          filter.Limit,
+	}
+}
+
+func ToDeliveryFromService(d *domain.Delivery) *model.Delivery {
+	delivery := &model.Delivery{
+		Id:             d.Id,
+		EventType:      d.EventType,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		ResponseStatus: d.ResponseStatus,
+		Error:          d.Error,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+
+	// Only pending deliveries are attempted again
+	if d.Status == domain.DeliveryPending {
+		delivery.NextAttemptAt = &d.NextAttemptAt
+	}
+
+	return delivery
+}
@@ -0,0 +1,146 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/converter"
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/model"
+	"github.com/sletkov/effective-mobile-test-task/internal/events"
+)
+
+type EventBroker interface {
+	Subscribe(lastEventId string) (*events.Subscription, []*events.Event, bool)
+}
+
+// Reconnection delay suggested to clients
+const eventsRetry = 3 * time.Second
+
+// Stream changes of users from broker at /api/v1/users/events,
+// a comment is sent every heartbeat so proxies keep idle streams open
+func WithEvents(broker EventBroker, heartbeat time.Duration) Option {
+	return func(c *UserController) {
+		c.events = broker
+		c.heartbeat = heartbeat
+	}
+}
+
+// @Summary UserEvents
+// @Tags users
+// @Description stream of created, updated and deleted users as server-sent events, filters are the same as for users list.
+// @Description Reconnecting clients resume after Last-Event-ID, reset event tells they missed changes and should reload users.
+// @ID user-events
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "id of the last event got"
+// @Param last_event_id query string false "id of the last event got, if header can't be set"
+// @Success 200
+// @Failure 400
+// @Router /api/v1/users/events [get]
+func (c *UserController) handleUserEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		eventsRequest := &model.UserEventsRequest{}
+
+		if err := eventsRequest.FillEvents(r.URL.Query(), r.Header.Get("Last-Event-ID")); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Validate struct
+		if err := eventsRequest.Validate(); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		filter, err := events.Compile(converter.ToUserFilterFromController(&eventsRequest.Filter).Expr)
+
+		if err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		rc := http.NewResponseController(w)
+
+		// Stream outlives server write timeout
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+		}
+
+		subscription, backlog, resumed := c.events.Subscribe(eventsRequest.LastEventId)
+		defer subscription.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(w, "retry: %d\n\n", eventsRetry.Milliseconds())
+
+		if !resumed {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+
+		for _, e := range backlog {
+			if err := writeEvent(w, e, filter); err != nil {
+				slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+				return
+			}
+		}
+
+		if err := rc.Flush(); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			return
+		}
+
+		heartbeat := time.NewTicker(c.heartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-subscription.C:
+				// Dropped, client reconnects and resumes
+				if !ok {
+					return
+				}
+
+				if err := writeEvent(w, e, filter); err != nil {
+					slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+					return
+				}
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// Write event if user matches filter. Only id of other events is written,
+// it moves client position without dispatching an event, so it resumes after it.
+func writeEvent(w http.ResponseWriter, e *events.Event, filter events.Filter) error {
+	if !filter(&e.User) {
+		_, err := fmt.Fprintf(w, "id: %d\n\n", e.Id)
+		return err
+	}
+
+	data, err := json.Marshal(e.Event)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Type, data)
+
+	return err
+}
@@ -0,0 +1,154 @@
+package v1
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	"github.com/sletkov/effective-mobile-test-task/internal/events"
+	mock_service "github.com/sletkov/effective-mobile-test-task/internal/service/mocks"
+)
+
+func newUserEvent(id int64, eventType string, u domain.User) *events.Event {
+	data, _ := json.Marshal(u)
+
+	return &events.Event{
+		Event: &domain.Event{
+			Id:         id,
+			Type:       eventType,
+			UserId:     u.Id,
+			Data:       data,
+			OccurredAt: time.Date(2024, 2, 20, 10, 0, 0, 0, time.UTC),
+		},
+		User: u,
+	}
+}
+
+// Read stream until n events or ids are read, blank lines are left out
+func readEvents(t *testing.T, scanner *bufio.Scanner, n int) []string {
+	var lines []string
+
+	for n > 0 && scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			n--
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	assert.NoError(t, scanner.Err())
+
+	return lines
+}
+
+func TestControllerHandleUserEvents(t *testing.T) {
+	ivan := domain.User{Id: 1, Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "male", Nationality: "RU"}
+	galina := domain.User{Id: 2, Name: "Galina", Surname: "Petrova", Age: 40, Gender: "female", Nationality: "US"}
+
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	broker := events.NewBroker()
+	broker.Publish(newUserEvent(1, domain.EventUserCreated, ivan))
+	broker.Publish(newUserEvent(2, domain.EventUserCreated, galina))
+	broker.Publish(newUserEvent(3, domain.EventUserUpdated, ivan))
+
+	controller := New(mock_service.NewMockUserService(c), WithEvents(broker, time.Minute))
+
+	server := httptest.NewServer(controller.InitRoutes())
+	defer server.Close()
+
+	t.Run("resume with filter", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/users/events?nationality=RU", nil)
+		req.Header.Set("Last-Event-ID", "1")
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+		scanner := bufio.NewScanner(resp.Body)
+
+		// Retry and backlog
+		assert.Equal(t, []string{
+			"retry: 3000",
+			"id: 2",
+			"id: 3",
+			"event: user.updated",
+			`data: {"id":3,"type":"user.updated","user_id":1,"data":{"id":1,"name":"Ivan","surname":"Ivanov","age":20,"gender":"male","nationality":"RU"},"occurred_at":"2024-02-20T10:00:00Z"}`,
+		}, readEvents(t, scanner, 3))
+
+		broker.Publish(newUserEvent(4, domain.EventUserDeleted, ivan))
+
+		assert.Equal(t, []string{
+			"id: 4",
+			"event: user.deleted",
+			`data: {"id":4,"type":"user.deleted","user_id":1,"data":{"id":1,"name":"Ivan","surname":"Ivanov","age":20,"gender":"male","nationality":"RU"},"occurred_at":"2024-02-20T10:00:00Z"}`,
+		}, readEvents(t, scanner, 1))
+	})
+
+	t.Run("missed events", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/v1/users/events?last_event_id=100", nil)
+
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, []string{"retry: 3000", "event: reset", "data: {}"}, readEvents(t, bufio.NewScanner(resp.Body), 2))
+	})
+
+	t.Run("unsupported parameter", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/api/v1/users/events?limit=5")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("invalid filter", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/api/v1/users/events?age[gt]=20,30")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	})
+
+	t.Run("closed on shutdown", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/api/v1/users/events")
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		readEvents(t, scanner, 1)
+
+		broker.Close()
+
+		var rest strings.Builder
+
+		for scanner.Scan() {
+			rest.WriteString(scanner.Text())
+		}
+
+		assert.Empty(t, rest.String())
+	})
+}
@@ -0,0 +1,191 @@
+package v1
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/converter"
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/model"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+const (
+	contentTypeCSV    = "text/csv"
+	contentTypeNDJSON = "application/x-ndjson"
+
+	// Rows written between flushes of export response
+	exportFlushRows = 1000
+)
+
+// Export content types, the first one is used when client accepts anything
+var exportContentTypes = []string{contentTypeNDJSON, contentTypeCSV}
+
+// Export content type by Accept header, NDJSON if header is missing
+func exportContentType(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return contentTypeNDJSON, true
+	}
+
+	offers := make([][]string, len(exportContentTypes))
+
+	for i, contentType := range exportContentTypes {
+		offers[i] = []string{contentType}
+	}
+
+	i, ok := preferredOffer(accept, offers)
+
+	if !ok {
+		return "", false
+	}
+
+	return exportContentTypes[i], true
+}
+
+// @Summary ExportUsers
+// @Tags users
+// @Description stream all users matching filters as CSV or NDJSON, filters are the same as for users list
+// @ID export-users
+// @Produce text/csv
+// @Produce application/x-ndjson
+// @Param fields query string false "comma separated fields to export"
+// @Success 200
+// @Failure 400
+// @Failure 406
+// @Failure 500
+// @Router /api/v1/users/export [get]
+func (c *UserController) handleExportUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		contentType, ok := exportContentType(r.Header.Get("Accept"))
+
+		if !ok {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+
+		values := r.URL.Query()
+
+		if values.Has("limit") {
+			slog.Error("controller: limit is not supported by export")
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		userFilter := &model.UserFilter{}
+
+		if err := userFilter.FillFilters(values); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Validate struct
+		if err := userFilter.Validate(); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Export everything
+		userFilter.Limit = 0
+
+		rc := http.NewResponseController(w)
+
+		// Export may take longer than server write timeout
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			slog.Debug(fmt.Sprintf("controller: clearing write deadline: %s", err.Error()))
+		}
+
+		fields := userFilter.Fields
+		names := fields.Names()
+		csvWriter := csv.NewWriter(w)
+		rows := 0
+
+		flush := func() {
+			csvWriter.Flush()
+			rc.Flush()
+		}
+
+		// Headers are sent with the first user, so errors before it still get a status
+		start := func() error {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="users.%s"`, exportExtension(contentType)))
+			w.WriteHeader(http.StatusOK)
+
+			if contentType == contentTypeCSV {
+				return csvWriter.Write(names)
+			}
+
+			return nil
+		}
+
+		// Export is streamed, so it stops with the request
+		err := c.service.Export(r.Context(), converter.ToUserFilterFromController(userFilter), func(u *domain.User) error {
+			if rows == 0 {
+				if err := start(); err != nil {
+					return err
+				}
+			}
+
+			user := converter.ToUserFromService(u)
+
+			if contentType == contentTypeCSV {
+				if err := csvWriter.Write(user.Values(names)); err != nil {
+					return err
+				}
+			} else {
+				data, err := json.Marshal(fields.Select(user))
+
+				if err != nil {
+					return err
+				}
+
+				if _, err := w.Write(append(data, '\n')); err != nil {
+					return err
+				}
+			}
+
+			rows++
+
+			if rows%exportFlushRows == 0 {
+				flush()
+			}
+
+			return nil
+		})
+
+		if err != nil {
+			slog.Error(err.Error())
+
+			if rows == 0 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			// Break connection so client doesn't take truncated export for a full one
+			panic(http.ErrAbortHandler)
+		}
+
+		if rows == 0 {
+			if err := start(); err != nil {
+				slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			}
+		}
+
+		flush()
+
+		slog.Info(fmt.Sprintf("controller: %d users were exported", rows))
+	}
+}
+
+func exportExtension(contentType string) string {
+	if contentType == contentTypeCSV {
+		return "csv"
+	}
+
+	return "ndjson"
+}
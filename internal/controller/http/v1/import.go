@@ -0,0 +1,151 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/converter"
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/model"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+const (
+	// Limits of import upload
+	importMaxBytes = 10 << 20
+	importMaxRows  = 10000
+)
+
+// @Summary ImportUsers
+// @Tags users
+// @Description import users from CSV with header or NDJSON, rows that can't be imported are reported with their lines
+// @ID import-users
+// @Accept text/csv
+// @Accept application/x-ndjson
+// @Produce json,xml,application/msgpack
+// @Param dry_run query boolean false "validate rows without writing them"
+// @Param enrich query boolean false "fetch age, gender and nationality missing in rows"
+// @Success 200
+// @Failure 400
+// @Failure 406
+// @Failure 413
+// @Failure 415
+// @Failure 500
+// @Failure 503
+// @Router /api/v1/users/import [post]
+func (c *UserController) handleImportUsers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		response, ok := responseCodec(r)
+
+		if !ok {
+			w.WriteHeader(http.StatusNotAcceptable)
+			return
+		}
+
+		var opts domain.ImportOptions
+
+		for name, value := range map[string]*bool{"dry_run": &opts.DryRun, "enrich": &opts.Enrich} {
+			if !r.URL.Query().Has(name) {
+				continue
+			}
+
+			parsed, err := strconv.ParseBool(r.URL.Query().Get(name))
+
+			if err != nil {
+				slog.Error(fmt.Sprintf("controller: %s: %s", name, err.Error()))
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			*value = parsed
+		}
+
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+		if err != nil {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		body := http.MaxBytesReader(w, r.Body, importMaxBytes)
+
+		var (
+			rows       []model.ImportRow
+			importErrs []model.ImportError
+		)
+
+		switch mediaType {
+		case contentTypeCSV:
+			rows, importErrs, err = model.ReadCSV(body, importMaxRows)
+		case contentTypeNDJSON:
+			rows, importErrs, err = model.ReadNDJSON(body, importMaxRows)
+		default:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		if err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+
+			var maxBytesErr *http.MaxBytesError
+
+			if errors.As(err, &maxBytesErr) || errors.Is(err, model.ErrTooManyRows) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		total := len(rows) + len(importErrs)
+		validRows := make([]domain.ImportRow, 0, len(rows))
+
+		// Validate rows
+		for i := range rows {
+			if err := rows[i].User.Validate(); err != nil {
+				importErrs = append(importErrs, model.ImportError{Line: rows[i].Line, Error: err.Error()})
+				continue
+			}
+
+			validRows = append(validRows, *converter.ToImportRowFromController(&rows[i]))
+		}
+
+		serviceReport, err := c.service.Import(r.Context(), validRows, opts)
+
+		if err != nil {
+			slog.Error(err.Error())
+
+			if errors.Is(err, domain.ErrEnrichmentUnavailable) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		report := converter.ToImportReportFromService(serviceReport)
+		report.Total = total
+		report.Errors = append(report.Errors, importErrs...)
+
+		sort.SliceStable(report.Errors, func(i, j int) bool {
+			return report.Errors[i].Line < report.Errors[j].Line
+		})
+
+		slog.Info(fmt.Sprintf("controller: %d of %d users were imported", report.Imported, report.Total))
+
+		// Report is encoded as client accepts
+		r = r.WithContext(context.WithValue(r.Context(), codecKey{}, response))
+
+		if err := respond(w, r, http.StatusOK, report); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
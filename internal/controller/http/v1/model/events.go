@@ -0,0 +1,46 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+)
+
+type UserEventsRequest struct {
+	Filter UserFilter
+	// Id of the last event client got, stream resumes after it
+	LastEventId string
+}
+
+// Parse last_event_id, the rest are the same filters as for users list.
+// Last-Event-ID header sent by reconnecting clients takes precedence over the parameter.
+// Empty values are the same as absent ones.
+func (u *UserEventsRequest) FillEvents(values url.Values, lastEventId string) error {
+	filters := url.Values{}
+
+	u.LastEventId = lastEventId
+
+	for k, v := range values {
+		v = nonEmpty(v)
+
+		if len(v) == 0 {
+			continue
+		}
+
+		switch k {
+		case "last_event_id":
+			if u.LastEventId == "" {
+				u.LastEventId = v[0]
+			}
+		case "limit", "sort", "fields":
+			return fmt.Errorf("%s is not supported by events", k)
+		default:
+			filters[k] = v
+		}
+	}
+
+	return u.Filter.FillFilters(filters)
+}
+
+func (u *UserEventsRequest) Validate() error {
+	return u.Filter.Validate()
+}
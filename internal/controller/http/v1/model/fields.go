@@ -0,0 +1,181 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Fields of User in output order
+var userFields = []string{"id", "name", "surname", "patronymic", "age", "gender", "nationality"}
+
+// Requested subset of User fields, all fields if empty
+type Fields []string
+
+// Parse comma separated fields parameter
+func ParseFields(values url.Values) Fields {
+	fields := values.Get("fields")
+
+	if fields == "" {
+		return nil
+	}
+
+	return strings.Split(fields, ",")
+}
+
+func (f Fields) Validate() error {
+	allowed := make([]interface{}, len(userFields))
+
+	for i, field := range userFields {
+		allowed[i] = field
+	}
+
+	for _, field := range f {
+		if err := validation.Validate(field, validation.Required, validation.In(allowed...)); err != nil {
+			return fmt.Errorf("%q: %w", field, err)
+		}
+	}
+
+	return nil
+}
+
+// Requested fields in the usual order
+func (f Fields) Names() []string {
+	if len(f) == 0 {
+		return userFields
+	}
+
+	requested := make(map[string]bool, len(f))
+
+	for _, field := range f {
+		requested[field] = true
+	}
+
+	names := make([]string, 0, len(f))
+
+	for _, field := range userFields {
+		if requested[field] {
+			names = append(names, field)
+		}
+	}
+
+	return names
+}
+
+// User with requested fields only, encoded in the usual order
+type PartialUser struct {
+	fields []string
+	user   *User
+}
+
+// Select requested fields of user
+func (f Fields) Select(u *User) *PartialUser {
+	return &PartialUser{fields: f.Names(), user: u}
+}
+
+// Select requested fields of users
+func (f Fields) SelectList(users []User) []*PartialUser {
+	partial := make([]*PartialUser, 0, len(users))
+
+	for i := range users {
+		partial = append(partial, f.Select(&users[i]))
+	}
+
+	return partial
+}
+
+func (p *PartialUser) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+
+	b.WriteByte('{')
+
+	for i, field := range p.fields {
+		value, err := json.Marshal(p.user.Field(field))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(`"` + field + `":`)
+		b.Write(value)
+	}
+
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
+
+func (p *PartialUser) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	for _, field := range p.fields {
+		if err := e.EncodeElement(p.user.Field(field), xml.StartElement{Name: xml.Name{Local: field}}); err != nil {
+			return err
+		}
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+func (p *PartialUser) EncodeMsgpack(e *msgpack.Encoder) error {
+	if err := e.EncodeMapLen(len(p.fields)); err != nil {
+		return err
+	}
+
+	for _, field := range p.fields {
+		if err := e.EncodeString(field); err != nil {
+			return err
+		}
+
+		if err := e.Encode(p.user.Field(field)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Value of field by its name
+func (u *User) Field(name string) any {
+	switch name {
+	case "id":
+		return u.Id
+	case "name":
+		return u.Name
+	case "surname":
+		return u.Surname
+	case "patronymic":
+		return u.Patronymic
+	case "age":
+		return u.Age
+	case "gender":
+		return u.Gender
+	case "nationality":
+		return u.Nationality
+	}
+
+	return nil
+}
+
+// Values of given fields as text
+func (u *User) Values(fields []string) []string {
+	values := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		values = append(values, fmt.Sprint(u.Field(field)))
+	}
+
+	return values
+}
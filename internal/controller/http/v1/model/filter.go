@@ -0,0 +1,363 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+)
+
+type FilterOp string
+
+const (
+	FilterAnd FilterOp = "and"
+	FilterOr  FilterOp = "or"
+
+	FilterEq       FilterOp = "eq"
+	FilterNe       FilterOp = "ne"
+	FilterIn       FilterOp = "in"
+	FilterNotIn    FilterOp = "nin"
+	FilterPrefix   FilterOp = "prefix"
+	FilterContains FilterOp = "contains"
+	FilterGt       FilterOp = "gt"
+	FilterGte      FilterOp = "gte"
+	FilterLt       FilterOp = "lt"
+	FilterLte      FilterOp = "lte"
+	FilterNull     FilterOp = "null"
+	FilterNotNull  FilterOp = "notnull"
+)
+
+const (
+	maxFilterConditions = 20
+	maxFilterValues     = 50
+	maxSortFields       = 3
+)
+
+// Node of filter expression tree
+type FilterExpr struct {
+	Op       FilterOp
+	Field    string
+	Values   []string
+	Children []FilterExpr
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+type UserFilter struct {
+	Expr   FilterExpr
+	Sort   []SortField
+	Fields Fields
+	Limit  int
+}
+
+var (
+	textOps   = []FilterOp{FilterEq, FilterNe, FilterIn, FilterNotIn, FilterPrefix, FilterContains}
+	numberOps = []FilterOp{FilterEq, FilterNe, FilterIn, FilterNotIn, FilterGt, FilterGte, FilterLt, FilterLte}
+	enumOps   = []FilterOp{FilterEq, FilterNe, FilterIn, FilterNotIn}
+
+	// Operators allowed for filtered fields
+	filterOps = map[string][]FilterOp{
+		"name":        textOps,
+		"surname":     textOps,
+		"patronymic":  append(textOps, FilterNull),
+		"age":         numberOps,
+		"gender":      enumOps,
+		"nationality": enumOps,
+	}
+
+	// Rules for values of filtered fields
+	filterRules = map[string][]validation.Rule{
+		"name":        {validation.Required, validation.Length(1, 255), is.Alpha},
+		"surname":     {validation.Required, validation.Length(1, 255), is.Alpha},
+		"patronymic":  {validation.Required, validation.Length(1, 255), is.Alpha},
+		"age":         {validation.Required, validation.By(isAge)},
+		"gender":      {validation.Required, validation.In("male", "female")},
+		"nationality": {validation.Required, validation.Length(2, 2), is.Alpha},
+	}
+
+	// Fields users can be sorted by
+	sortFields = []interface{}{"id", "name", "surname", "patronymic", "age", "gender", "nationality"}
+
+	// field, field[op], or[group][field] and or[group][field][op]
+	filterKey = regexp.MustCompile(`^(?:or\[(\w+)\]\[(\w+)\]|(\w+))(?:\[(\w+)\])?$`)
+)
+
+// Parse query like nationality=RU,UA&surname[prefix]=Iva&gender[ne]=male&patronymic[null]=true.
+// Conditions are joined with AND, conditions of the same or[group] are joined with OR.
+// Empty values are the same as absent ones. Plain parameters other than filtered
+// fields are ignored, so clients may send their own ones like page=1.
+func (u *UserFilter) FillFilters(filters url.Values) error {
+	defaultLimit := 10
+
+	keys := make([]string, 0, len(filters))
+
+	for k := range filters {
+		keys = append(keys, k)
+	}
+
+	// Map order is random, expression must not be
+	sort.Strings(keys)
+
+	var conditions []FilterExpr
+
+	groups := make(map[string][]FilterExpr)
+	groupNames := make([]string, 0)
+
+	for _, k := range keys {
+		v := nonEmpty(filters[k])
+
+		if len(v) == 0 {
+			continue
+		}
+
+		switch k {
+		case "limit":
+			if value, err := strconv.Atoi(v[0]); err == nil {
+				u.Limit = value
+			}
+
+			continue
+		// Comma separated fields, minus for descending order
+		case "sort":
+			for _, field := range splitValues(v[:1]) {
+				desc := strings.HasPrefix(field, "-")
+				u.Sort = append(u.Sort, SortField{Field: strings.TrimPrefix(field, "-"), Desc: desc})
+			}
+
+			continue
+		case "fields":
+			u.Fields = ParseFields(filters)
+			continue
+		// Age range from before filter expressions
+		case "age_from":
+			conditions = append(conditions, FilterExpr{Op: FilterGte, Field: "age", Values: v[:1]})
+			continue
+		case "age_to":
+			conditions = append(conditions, FilterExpr{Op: FilterLte, Field: "age", Values: v[:1]})
+			continue
+		}
+
+		// Keys with brackets are always filters
+		if _, ok := filterOps[k]; !ok && !strings.Contains(k, "[") {
+			continue
+		}
+
+		m := filterKey.FindStringSubmatch(k)
+
+		if m == nil {
+			return fmt.Errorf("invalid filter %q", k)
+		}
+
+		group, field, op := m[1], m[2]+m[3], FilterOp(m[4])
+
+		if _, ok := filterOps[field]; !ok {
+			return fmt.Errorf("unknown filter field %q", field)
+		}
+
+		condition, err := parseCondition(field, op, v)
+
+		if err != nil {
+			return fmt.Errorf("filter %q: %w", k, err)
+		}
+
+		if group == "" {
+			conditions = append(conditions, condition)
+			continue
+		}
+
+		if _, ok := groups[group]; !ok {
+			groupNames = append(groupNames, group)
+		}
+
+		groups[group] = append(groups[group], condition)
+	}
+
+	for _, group := range groupNames {
+		conditions = append(conditions, FilterExpr{Op: FilterOr, Children: groups[group]})
+	}
+
+	if len(conditions) > 0 {
+		u.Expr = FilterExpr{Op: FilterAnd, Children: conditions}
+	}
+
+	if u.Limit <= 0 {
+		u.Limit = defaultLimit
+	}
+
+	return nil
+}
+
+func parseCondition(field string, op FilterOp, values []string) (FilterExpr, error) {
+	switch op {
+	// Plain field=a,b is a list
+	case "":
+		values = splitValues(values)
+		op = FilterEq
+
+		if len(values) > 1 {
+			op = FilterIn
+		}
+	case FilterIn, FilterNotIn:
+		values = splitValues(values)
+	case FilterNull:
+		switch values[0] {
+		case "true":
+			return FilterExpr{Op: FilterNull, Field: field}, nil
+		case "false":
+			return FilterExpr{Op: FilterNotNull, Field: field}, nil
+		default:
+			return FilterExpr{}, errors.New("must be true or false")
+		}
+	case FilterNotNull:
+		return FilterExpr{}, fmt.Errorf("unknown operator %q", op)
+	default:
+		values = values[:1]
+	}
+
+	return FilterExpr{Op: op, Field: field, Values: values}, nil
+}
+
+func splitValues(values []string) []string {
+	split := make([]string, 0, len(values))
+
+	for _, v := range values {
+		split = append(split, nonEmpty(strings.Split(v, ","))...)
+	}
+
+	return split
+}
+
+func nonEmpty(values []string) []string {
+	filtered := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if v != "" {
+			filtered = append(filtered, v)
+		}
+	}
+
+	return filtered
+}
+
+func (u *UserFilter) Validate() error {
+	conditions := 0
+
+	if err := validateFilterExpr(u.Expr, &conditions); err != nil {
+		return err
+	}
+
+	if conditions > maxFilterConditions {
+		return fmt.Errorf("too many filter conditions, at most %d are allowed", maxFilterConditions)
+	}
+
+	return validation.ValidateStruct(u,
+		validation.Field(&u.Sort, validation.Length(0, maxSortFields), validation.By(uniqueSortFields)),
+		validation.Field(&u.Fields),
+		validation.Field(&u.Limit, validation.Min(1), validation.Max(50)),
+	)
+}
+
+func (s SortField) Validate() error {
+	return validation.ValidateStruct(&s,
+		validation.Field(&s.Field, validation.Required, validation.In(sortFields...)),
+	)
+}
+
+func uniqueSortFields(value interface{}) error {
+	fields, _ := value.([]SortField)
+	seen := make(map[string]bool, len(fields))
+
+	for _, f := range fields {
+		if seen[f.Field] {
+			return fmt.Errorf("%s is sorted by twice", f.Field)
+		}
+
+		seen[f.Field] = true
+	}
+
+	return nil
+}
+
+func validateFilterExpr(e FilterExpr, conditions *int) error {
+	switch e.Op {
+	case "":
+		return nil
+	case FilterAnd, FilterOr:
+		if len(e.Children) == 0 {
+			return fmt.Errorf("empty %s group", e.Op)
+		}
+
+		for _, child := range e.Children {
+			if err := validateFilterExpr(child, conditions); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	*conditions++
+
+	ops, ok := filterOps[e.Field]
+
+	if !ok {
+		return fmt.Errorf("unknown filter field %q", e.Field)
+	}
+
+	if !hasOp(ops, e.Op) && !(e.Op == FilterNotNull && hasOp(ops, FilterNull)) {
+		return fmt.Errorf("%s: operator %q is not allowed", e.Field, e.Op)
+	}
+
+	switch e.Op {
+	case FilterNull, FilterNotNull:
+		if len(e.Values) != 0 {
+			return fmt.Errorf("%s[%s]: takes no values", e.Field, e.Op)
+		}
+	case FilterIn, FilterNotIn:
+		if len(e.Values) == 0 || len(e.Values) > maxFilterValues {
+			return fmt.Errorf("%s[%s]: from 1 to %d values are allowed", e.Field, e.Op, maxFilterValues)
+		}
+	default:
+		if len(e.Values) != 1 {
+			return fmt.Errorf("%s[%s]: exactly one value is allowed", e.Field, e.Op)
+		}
+	}
+
+	for _, v := range e.Values {
+		if err := validation.Validate(v, filterRules[e.Field]...); err != nil {
+			return fmt.Errorf("%s[%s]: %w", e.Field, e.Op, err)
+		}
+	}
+
+	return nil
+}
+
+func hasOp(ops []FilterOp, op FilterOp) bool {
+	for _, o := range ops {
+		if o == op {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isAge(value interface{}) error {
+	s, _ := value.(string)
+	age, err := strconv.Atoi(s)
+
+	if err != nil {
+		return errors.New("must be an integer")
+	}
+
+	return validation.Validate(age, validation.Min(1), validation.Max(100))
+}
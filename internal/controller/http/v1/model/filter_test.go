@@ -0,0 +1,202 @@
+package model
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserFilterFillFilters(t *testing.T) {
+	testCases := []struct {
+		name         string
+		query        string
+		expectedExpr FilterExpr
+		isValid      bool
+	}{
+		{
+			name:    "no filters",
+			query:   "",
+			isValid: true,
+		},
+
+		{
+			name:  "equality and list",
+			query: "name=Ivan&nationality=RU,UA",
+			expectedExpr: FilterExpr{Op: FilterAnd, Children: []FilterExpr{
+				{Op: FilterEq, Field: "name", Values: []string{"Ivan"}},
+				{Op: FilterIn, Field: "nationality", Values: []string{"RU", "UA"}},
+			}},
+			isValid: true,
+		},
+
+		{
+			name:  "unknown parameters are ignored",
+			query: "page=1&name=Ivan",
+			expectedExpr: FilterExpr{Op: FilterAnd, Children: []FilterExpr{
+				{Op: FilterEq, Field: "name", Values: []string{"Ivan"}},
+			}},
+			isValid: true,
+		},
+
+		{
+			name:  "empty values are absent",
+			query: "name=&age_from=&nationality=RU,&sort=",
+			expectedExpr: FilterExpr{Op: FilterAnd, Children: []FilterExpr{
+				{Op: FilterEq, Field: "nationality", Values: []string{"RU"}},
+			}},
+			isValid: true,
+		},
+
+		{
+			name:  "repeated parameter is a list",
+			query: "gender[nin]=male&gender[nin]=female",
+			expectedExpr: FilterExpr{Op: FilterAnd, Children: []FilterExpr{
+				{Op: FilterNotIn, Field: "gender", Values: []string{"male", "female"}},
+			}},
+			isValid: true,
+		},
+
+		{
+			name:  "age range",
+			query: "age_from=20&age_to=30",
+			expectedExpr: FilterExpr{Op: FilterAnd, Children: []FilterExpr{
+				{Op: FilterGte, Field: "age", Values: []string{"20"}},
+				{Op: FilterLte, Field: "age", Values: []string{"30"}},
+			}},
+			isValid: true,
+		},
+
+		{
+			name:  "or group",
+			query: "or[1][patronymic][null]=true&or[1][patronymic][prefix]=Iv",
+			expectedExpr: FilterExpr{Op: FilterAnd, Children: []FilterExpr{
+				{Op: FilterOr, Children: []FilterExpr{
+					{Op: FilterNull, Field: "patronymic"},
+					{Op: FilterPrefix, Field: "patronymic", Values: []string{"Iv"}},
+				}},
+			}},
+			isValid: true,
+		},
+
+		{
+			name:  "invalid name",
+			query: "name=Иван",
+		},
+
+		{
+			name:  "unknown sort field",
+			query: "sort=-email",
+		},
+
+		{
+			name:  "sort field twice",
+			query: "sort=age,-age",
+		},
+
+		{
+			name:  "too many sort fields",
+			query: "sort=age,name,surname,nationality",
+		},
+
+		{
+			name:  "invalid surname prefix",
+			query: "surname[prefix]=Iv%25",
+		},
+
+		{
+			name:  "invalid patronymic",
+			query: "or[1][patronymic]=Иванович",
+		},
+
+		{
+			name:  "invalid age",
+			query: "age[gt]=101",
+		},
+
+		{
+			name:  "invalid age_from",
+			query: "age_from=-1",
+		},
+
+		{
+			name:  "invalid gender",
+			query: "gender=email",
+		},
+
+		{
+			name:  "invalid nationality",
+			query: "nationality=RU,Russia",
+		},
+
+		{
+			name:  "invalid limit",
+			query: "limit=100",
+		},
+
+		{
+			name:  "invalid null check",
+			query: "patronymic[null]=maybe",
+		},
+
+		{
+			name:  "null check on required field",
+			query: "name[null]=true",
+		},
+
+		{
+			name:  "unknown operator",
+			query: "name[like]=Iv",
+		},
+
+		{
+			name:  "unknown field",
+			query: "email[eq]=ivan",
+		},
+
+		{
+			name:  "unknown field in or group",
+			query: "or[1][email]=ivan",
+		},
+
+		{
+			name:  "malformed key",
+			query: "or[1]=ivan",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			values, err := url.ParseQuery(tc.query)
+			assert.NoError(t, err)
+
+			u := &UserFilter{}
+
+			err = u.FillFilters(values)
+
+			if err == nil {
+				err = u.Validate()
+			}
+
+			if !tc.isValid {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedExpr, u.Expr)
+			assert.Equal(t, 10, u.Limit)
+		})
+	}
+}
+
+func TestUserFilterSort(t *testing.T) {
+	values, err := url.ParseQuery("sort=-age,surname")
+	assert.NoError(t, err)
+
+	u := &UserFilter{}
+
+	assert.NoError(t, u.FillFilters(values))
+	assert.NoError(t, u.Validate())
+	assert.Equal(t, []SortField{{Field: "age", Desc: true}, {Field: "surname"}}, u.Sort)
+}
@@ -0,0 +1,238 @@
+package model
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+)
+
+var ErrTooManyRows = errors.New("too many rows")
+
+// Columns import upload may have, name and surname are required
+var importColumns = map[string]bool{
+	"name":        true,
+	"surname":     true,
+	"patronymic":  true,
+	"age":         true,
+	"gender":      true,
+	"nationality": true,
+}
+
+// User to import, enrichment fills fields left empty
+type ImportUser struct {
+	Name        string `json:"name"`
+	Surname     string `json:"surname"`
+	Patronymic  string `json:"patronymic,omitempty"`
+	Age         int    `json:"age,omitempty"`
+	Gender      string `json:"gender,omitempty"`
+	Nationality string `json:"nationality,omitempty"`
+}
+
+// User read from import upload with its line number
+type ImportRow struct {
+	Line int
+	User ImportUser
+}
+
+type ImportError struct {
+	Line  int    `json:"line" xml:"line"`
+	Error string `json:"error" xml:"error"`
+}
+
+type ImportReport struct {
+	Total      int           `json:"total" xml:"total"`
+	Valid      int           `json:"valid" xml:"valid"`
+	Unverified int           `json:"unverified" xml:"unverified"`
+	Imported   int           `json:"imported" xml:"imported"`
+	DryRun     bool          `json:"dry_run" xml:"dry_run"`
+	Errors     []ImportError `json:"errors" xml:"errors>error"`
+}
+
+// Validate names as for created users and the rest if given
+func (u *ImportUser) Validate() error {
+	errs := validation.Errors{}
+
+	createUser := CreateUser{Name: u.Name, Surname: u.Surname, Patronymic: u.Patronymic}
+
+	if err := createUser.Validate(); err != nil {
+		var createErrs validation.Errors
+
+		if !errors.As(err, &createErrs) {
+			return err
+		}
+
+		for field, err := range createErrs {
+			errs[field] = err
+		}
+	}
+
+	if err := validation.ValidateStruct(u,
+		validation.Field(&u.Age, validation.Min(1), validation.Max(100)),
+		validation.Field(&u.Gender, validation.In("male", "female")),
+		validation.Field(&u.Nationality, validation.Length(2, 2), is.Alpha),
+	); err != nil {
+		var restErrs validation.Errors
+
+		if !errors.As(err, &restErrs) {
+			return err
+		}
+
+		for field, err := range restErrs {
+			errs[field] = err
+		}
+	}
+
+	return errs.Filter()
+}
+
+// Read CSV upload with header, malformed rows are returned as errors
+func ReadCSV(r io.Reader, maxRows int) ([]ImportRow, []ImportError, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, nil, errors.New("header is missing")
+		}
+
+		return nil, nil, err
+	}
+
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+
+		if !importColumns[column] {
+			return nil, nil, fmt.Errorf("unknown column %q", column)
+		}
+
+		header[i] = column
+	}
+
+	for _, column := range []string{"name", "surname"} {
+		if !slices.Contains(header, column) {
+			return nil, nil, fmt.Errorf("column %q is missing", column)
+		}
+	}
+
+	rows := make([]ImportRow, 0)
+	importErrs := make([]ImportError, 0)
+
+	for {
+		record, err := reader.Read()
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		var parseErr *csv.ParseError
+
+		if err != nil && !errors.As(err, &parseErr) {
+			return nil, nil, err
+		}
+
+		if len(rows)+len(importErrs) == maxRows {
+			return nil, nil, ErrTooManyRows
+		}
+
+		if parseErr != nil {
+			importErrs = append(importErrs, ImportError{Line: parseErr.StartLine, Error: parseErr.Err.Error()})
+			continue
+		}
+
+		line, _ := reader.FieldPos(0)
+		user := ImportUser{}
+
+		if err := user.fill(header, record); err != nil {
+			importErrs = append(importErrs, ImportError{Line: line, Error: err.Error()})
+			continue
+		}
+
+		rows = append(rows, ImportRow{Line: line, User: user})
+	}
+
+	return rows, importErrs, nil
+}
+
+// Read NDJSON upload, one user per line, malformed lines are returned as errors
+func ReadNDJSON(r io.Reader, maxRows int) ([]ImportRow, []ImportError, error) {
+	scanner := bufio.NewScanner(r)
+
+	rows := make([]ImportRow, 0)
+	importErrs := make([]ImportError, 0)
+
+	for line := 1; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+
+		if len(data) == 0 {
+			continue
+		}
+
+		if len(rows)+len(importErrs) == maxRows {
+			return nil, nil, ErrTooManyRows
+		}
+
+		user := ImportUser{}
+
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+
+		if err := decoder.Decode(&user); err != nil {
+			importErrs = append(importErrs, ImportError{Line: line, Error: err.Error()})
+			continue
+		}
+
+		rows = append(rows, ImportRow{Line: line, User: user})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return rows, importErrs, nil
+}
+
+// Fill user from CSV record by header columns
+func (u *ImportUser) fill(header, record []string) error {
+	for i, column := range header {
+		value := strings.TrimSpace(record[i])
+
+		switch column {
+		case "name":
+			u.Name = value
+		case "surname":
+			u.Surname = value
+		case "patronymic":
+			u.Patronymic = value
+		case "age":
+			if value == "" {
+				continue
+			}
+
+			age, err := strconv.Atoi(value)
+
+			if err != nil {
+				return fmt.Errorf("age: must be an integer")
+			}
+
+			u.Age = age
+		case "gender":
+			u.Gender = value
+		case "nationality":
+			u.Nationality = value
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadCSV(t *testing.T) {
+	testCases := []struct {
+		name               string
+		data               string
+		maxRows            int
+		expectedRows       []ImportRow
+		expectedImportErrs []ImportError
+		isValid            bool
+	}{
+		{
+			name: "rows with errors",
+			data: "Surname, Name,age\n" +
+				"Ivanov,Ivan,20\n" +
+				"Petrov,Petr,old\n" +
+				"Sidorov\n" +
+				"Petrova, Anna ,\n",
+			maxRows: 10,
+			expectedRows: []ImportRow{
+				{Line: 2, User: ImportUser{Name: "Ivan", Surname: "Ivanov", Age: 20}},
+				{Line: 5, User: ImportUser{Name: "Anna", Surname: "Petrova"}},
+			},
+			expectedImportErrs: []ImportError{
+				{Line: 3, Error: "age: must be an integer"},
+				{Line: 4, Error: "wrong number of fields"},
+			},
+			isValid: true,
+		},
+
+		{
+			name: "no header",
+		},
+
+		{
+			name: "unknown column",
+			data: "name,surname,email\n",
+		},
+
+		{
+			name: "missing surname",
+			data: "name,patronymic\n",
+		},
+
+		{
+			name:    "too many rows",
+			data:    "name,surname\nIvan,Ivanov\nPetr,Petrov\n",
+			maxRows: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			rows, importErrs, err := ReadCSV(strings.NewReader(tc.data), tc.maxRows)
+
+			if !tc.isValid {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedRows, rows)
+			assert.Equal(t, tc.expectedImportErrs, importErrs)
+		})
+	}
+}
+
+func TestReadNDJSON(t *testing.T) {
+	data := `{"name":"Ivan","surname":"Ivanov","gender":"male"}` + "\n" +
+		"\n" +
+		`{"name":"Petr","email":"petr@example.com"}` + "\n" +
+		`{"name":` + "\n"
+
+	rows, importErrs, err := ReadNDJSON(strings.NewReader(data), 10)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ImportRow{{Line: 1, User: ImportUser{Name: "Ivan", Surname: "Ivanov", Gender: "male"}}}, rows)
+	assert.Len(t, importErrs, 2)
+	assert.Equal(t, 3, importErrs[0].Line)
+	assert.Equal(t, 4, importErrs[1].Line)
+
+	_, _, err = ReadNDJSON(strings.NewReader(data), 2)
+	assert.ErrorIs(t, err, ErrTooManyRows)
+}
+
+func TestImportUserValidate(t *testing.T) {
+	testCases := []struct {
+		name    string
+		user    ImportUser
+		isValid bool
+	}{
+		{
+			name:    "names only",
+			user:    ImportUser{Name: "Ivan", Surname: "Ivanov"},
+			isValid: true,
+		},
+
+		{
+			name:    "all fields",
+			user:    ImportUser{Name: "Ivan", Surname: "Ivanov", Patronymic: "Ivanovich", Age: 20, Gender: "male", Nationality: "RU"},
+			isValid: true,
+		},
+
+		{
+			name: "no surname",
+			user: ImportUser{Name: "Ivan"},
+		},
+
+		{
+			name: "invalid gender",
+			user: ImportUser{Name: "Ivan", Surname: "Ivanov", Gender: "unknown"},
+		},
+
+		{
+			name: "invalid age",
+			user: ImportUser{Name: "Ivan", Surname: "Ivanov", Age: 101},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.user.Validate()
+
+			if !tc.isValid {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
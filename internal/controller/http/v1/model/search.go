@@ -0,0 +1,78 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"unicode"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+const (
+	defaultSearchThreshold = 0.3
+	defaultSearchLimit     = 10
+)
+
+type UserSearch struct {
+	Query     string
+	Threshold float64
+	Limit     int
+}
+
+type UserMatch struct {
+	User
+	Rank         float64 `json:"rank" xml:"rank"`
+	MatchedField string  `json:"matched_field" xml:"matched_field"`
+	Highlight    string  `json:"highlight" xml:"highlight"`
+}
+
+func (u *UserSearch) FillSearch(values url.Values) error {
+	u.Query = values.Get("q")
+	u.Threshold = defaultSearchThreshold
+	u.Limit = defaultSearchLimit
+
+	if threshold := values.Get("threshold"); threshold != "" {
+		value, err := strconv.ParseFloat(threshold, 64)
+
+		if err != nil {
+			return fmt.Errorf("parsing threshold: %w", err)
+		}
+
+		u.Threshold = value
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return fmt.Errorf("parsing limit: %w", err)
+		}
+
+		u.Limit = value
+	}
+
+	return nil
+}
+
+func (u *UserSearch) Validate() error {
+	return validation.ValidateStruct(u,
+		validation.Field(&u.Query, validation.Required, validation.Length(1, 255), validation.By(hasWord)),
+		validation.Field(&u.Threshold, validation.Min(0.0), validation.Max(1.0)),
+		validation.Field(&u.Limit, validation.Min(1), validation.Max(50)),
+	)
+}
+
+// Query must contain something to search for
+func hasWord(value interface{}) error {
+	s, _ := value.(string)
+
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return nil
+		}
+	}
+
+	return errors.New("must contain letters or digits")
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+)
+
+const defaultAgeBucket = 10
+
+type UserStatsRequest struct {
+	Filter    UserFilter
+	GroupBy   []string
+	AgeBucket int
+}
+
+type AgeBucket struct {
+	From int `json:"from" xml:"from"`
+	To   int `json:"to" xml:"to"`
+}
+
+type UserStats struct {
+	Gender      string     `json:"gender,omitempty" xml:"gender,omitempty"`
+	Nationality string     `json:"nationality,omitempty" xml:"nationality,omitempty"`
+	AgeBucket   *AgeBucket `json:"age_bucket,omitempty" xml:"age_bucket,omitempty"`
+	Count       int        `json:"count" xml:"count"`
+	AvgAge      float64    `json:"avg_age" xml:"avg_age"`
+	AgeP50      float64    `json:"age_p50" xml:"age_p50"`
+	AgeP90      float64    `json:"age_p90" xml:"age_p90"`
+	AgeP99      float64    `json:"age_p99" xml:"age_p99"`
+}
+
+// Parse group_by and age_bucket, the rest are the same filters as for users list.
+// Empty values are the same as absent ones.
+func (u *UserStatsRequest) FillStats(values url.Values) error {
+	filters := url.Values{}
+
+	u.AgeBucket = defaultAgeBucket
+
+	for k, v := range values {
+		v = nonEmpty(v)
+
+		if len(v) == 0 {
+			continue
+		}
+
+		switch k {
+		case "group_by":
+			u.GroupBy = splitValues(v[:1])
+		case "age_bucket":
+			value, err := strconv.Atoi(v[0])
+
+			if err != nil {
+				return fmt.Errorf("parsing age_bucket: %w", err)
+			}
+
+			u.AgeBucket = value
+		case "limit", "sort", "fields":
+			return fmt.Errorf("%s is not supported by stats", k)
+		default:
+			filters[k] = v
+		}
+	}
+
+	return u.Filter.FillFilters(filters)
+}
+
+func (u *UserStatsRequest) Validate() error {
+	if err := u.Filter.Validate(); err != nil {
+		return err
+	}
+
+	return validation.ValidateStruct(u,
+		validation.Field(&u.GroupBy, validation.Length(0, 3), validation.By(validGroups)),
+		validation.Field(&u.AgeBucket, validation.Required, validation.Min(1), validation.Max(100)),
+	)
+}
+
+func validGroups(value interface{}) error {
+	groups, _ := value.([]string)
+	seen := make(map[string]bool, len(groups))
+
+	for _, group := range groups {
+		if err := validation.Validate(group, validation.Required, validation.In("gender", "nationality", "age_bucket")); err != nil {
+			return fmt.Errorf("%q: %w", group, err)
+		}
+
+		if seen[group] {
+			return fmt.Errorf("%s is grouped by twice", group)
+		}
+
+		seen[group] = true
+	}
+
+	return nil
+}
@@ -242,85 +242,3 @@ func TestUpdateUserValidate(t *testing.T) {
 		})
 	}
 }
-
-func TestUserFilterValidate(t *testing.T) {
-	testCases := []struct {
-		name    string
-		u       UserFilter
-		isValid bool
-	}{
-		{
-			name: "invalid name",
-			u: UserFilter{
-				Name: "Иван",
-			},
-			isValid: false,
-		},
-
-		{
-			name: "invalid surname",
-			u: UserFilter{
-				Surname: "Иванов",
-			},
-			isValid: false,
-		},
-
-		{
-			name: "invalid patronymic",
-			u: UserFilter{
-				Name: "Иванович",
-			},
-			isValid: false,
-		},
-
-		{
-			name: "invalid ageFrom",
-			u: UserFilter{
-				AgeFrom: 101,
-			},
-			isValid: false,
-		},
-
-		{
-			name: "invalid ageTo",
-			u: UserFilter{
-				AgeFrom: -1,
-			},
-			isValid: false,
-		},
-
-		{
-			name: "invalid gender",
-			u: UserFilter{
-				Gender: "email",
-			},
-			isValid: false,
-		},
-
-		{
-			name: "invalid nationality",
-			u: UserFilter{
-				Nationality: "Russia",
-			},
-			isValid: false,
-		},
-
-		{
-			name: "invalid limit",
-			u: UserFilter{
-				Limit: -1,
-			},
-			isValid: false,
-		},
-	}
-
-	for _, tc := range testCases {
-		t.Run(tc.name, func(t *testing.T) {
-			if tc.isValid {
-				assert.NoError(t, tc.u.Validate())
-			} else {
-				assert.Error(t, tc.u.Validate())
-			}
-		})
-	}
-}
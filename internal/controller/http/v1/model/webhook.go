@@ -0,0 +1,133 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+)
+
+const (
+	defaultDeliveriesLimit = 50
+	maxDeliveriesLimit     = 500
+)
+
+// Events subscriptions are notified of
+var webhookEvents = []interface{}{"user.created", "user.updated", "user.deleted"}
+
+type Subscription struct {
+	Id     int      `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Shown only when subscription is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type CreateSubscription struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Generated if empty
+	Secret string `json:"secret,omitempty"`
+}
+
+type UpdateSubscription struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+type Delivery struct {
+	Id             int64           `json:"id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+}
+
+type DeliveryFilter struct {
+	Status string
+	Limit  int
+}
+
+func (u *UpdateSubscription) Copy(s *Subscription) {
+	if u.URL != "" {
+		s.URL = u.URL
+	}
+
+	if len(u.Events) > 0 {
+		s.Events = u.Events
+	}
+
+	if u.Secret != "" {
+		s.Secret = u.Secret
+	}
+}
+
+func (s *CreateSubscription) Validate() error {
+	return validation.ValidateStruct(s,
+		validation.Field(&s.URL, validation.Required, validation.Length(1, 2048), is.URL, validation.By(httpURL)),
+		validation.Field(&s.Events, validation.Required, validation.Each(validation.In(webhookEvents...))),
+		validation.Field(&s.Secret, validation.Length(16, 255)),
+	)
+}
+
+func (s *UpdateSubscription) Validate() error {
+	return validation.ValidateStruct(s,
+		validation.Field(&s.URL, validation.Length(1, 2048), is.URL, validation.By(httpURL)),
+		validation.Field(&s.Events, validation.Each(validation.In(webhookEvents...))),
+		validation.Field(&s.Secret, validation.Length(16, 255)),
+	)
+}
+
+// Parse status and limit of delivery log
+func (f *DeliveryFilter) FillFilter(values url.Values) error {
+	f.Status = values.Get("status")
+	f.Limit = defaultDeliveriesLimit
+
+	if limit := values.Get("limit"); limit != "" {
+		value, err := strconv.Atoi(limit)
+
+		if err != nil {
+			return fmt.Errorf("parsing limit: %w", err)
+		}
+
+		f.Limit = value
+	}
+
+	return nil
+}
+
+func (f *DeliveryFilter) Validate() error {
+	return validation.ValidateStruct(f,
+		validation.Field(&f.Status, validation.In("pending", "succeeded", "failed")),
+		validation.Field(&f.Limit, validation.Required, validation.Min(1), validation.Max(maxDeliveriesLimit)),
+	)
+}
+
+// Webhooks are called over http or https only
+func httpURL(value interface{}) error {
+	s, _ := value.(string)
+
+	if s == "" {
+		return nil
+	}
+
+	u, err := url.Parse(s)
+
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("must be an http or https url")
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateSubscriptionValidate(t *testing.T) {
+	testCases := []struct {
+		name         string
+		subscription CreateSubscription
+		isValid      bool
+	}{
+		{
+			name:         "valid",
+			subscription: CreateSubscription{URL: "https://partner.example.com/hooks", Events: []string{"user.created", "user.deleted"}},
+			isValid:      true,
+		},
+
+		{
+			name:         "no events",
+			subscription: CreateSubscription{URL: "https://partner.example.com/hooks"},
+		},
+
+		{
+			name:         "unknown event",
+			subscription: CreateSubscription{URL: "https://partner.example.com/hooks", Events: []string{"user.viewed"}},
+		},
+
+		{
+			name:         "not http url",
+			subscription: CreateSubscription{URL: "ftp://partner.example.com/hooks", Events: []string{"user.created"}},
+		},
+
+		{
+			name:         "short secret",
+			subscription: CreateSubscription{URL: "https://partner.example.com/hooks", Events: []string{"user.created"}, Secret: "secret"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.subscription.Validate()
+
+			if tc.isValid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
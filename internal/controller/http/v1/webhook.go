@@ -0,0 +1,385 @@
+package v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/converter"
+	"github.com/sletkov/effective-mobile-test-task/internal/controller/http/v1/model"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+//go:generate mockgen -source=webhook.go -destination=../../../service/mocks/webhook_mock.go -package=mock_v1
+
+type WebhookService interface {
+	CreateSubscription(ctx context.Context, s *domain.Subscription) error
+	GetSubscriptions(ctx context.Context) ([]domain.Subscription, error)
+	GetSubscriptionById(ctx context.Context, id int) (*domain.Subscription, error)
+	UpdateSubscription(ctx context.Context, id int, s *domain.Subscription) error
+	DeleteSubscription(ctx context.Context, id int) error
+	GetDeliveries(ctx context.Context, filter *domain.DeliveryFilter) ([]domain.Delivery, error)
+	Redeliver(ctx context.Context, subscriptionId int, id int64) (int64, error)
+}
+
+// Manage webhook subscriptions under /api/v1/webhooks, admin role is required
+func WithWebhooks(service WebhookService) Option {
+	return func(c *UserController) {
+		c.webhooks = service
+	}
+}
+
+// Subscriptions and their delivery logs, json only
+func (c *UserController) webhookRoutes(r chi.Router) {
+	r.Get("/", c.handleGetSubscriptions())
+	r.Post("/", c.handleCreateSubscription())
+
+	r.Route("/{id}", func(r chi.Router) {
+		r.Get("/", c.handleGetSubscription())
+		r.Patch("/", c.handleUpdateSubscription())
+		r.Delete("/", c.handleDeleteSubscription())
+		r.Get("/deliveries", c.handleGetDeliveries())
+		r.Post("/deliveries/{deliveryId}/redeliver", c.handleRedeliver())
+	})
+}
+
+// @Summary GetSubscriptions
+// @Tags webhooks
+// @Description get all webhook subscriptions
+// @ID get-subscriptions
+// @Produce json
+// @Success 200
+// @Failure 500
+// @Router /api/v1/webhooks [get]
+func (c *UserController) handleGetSubscriptions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceSubscriptions, err := c.webhooks.GetSubscriptions(r.Context())
+
+		if err != nil {
+			slog.Error(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		subscriptions := make([]model.Subscription, 0, len(serviceSubscriptions))
+
+		for _, s := range serviceSubscriptions {
+			subscriptions = append(subscriptions, *converter.ToSubscriptionFromService(&s))
+		}
+
+		if err := respond(w, r, http.StatusOK, subscriptions); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+// @Summary CreateSubscription
+// @Tags webhooks
+// @Description subscribe url to changes of users, the secret signing deliveries is returned only here
+// @ID create-subscription
+// @Accept json
+// @Produce json
+// @Param url body string true "url called with POST"
+// @Param events body []string true "user.created, user.updated and/or user.deleted"
+// @Param secret body string false "HMAC-SHA256 key, at least 16 characters, generated if empty"
+// @Success 201
+// @Failure 400
+// @Failure 500
+// @Router /api/v1/webhooks [post]
+func (c *UserController) handleCreateSubscription() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var subscription model.CreateSubscription
+
+		data, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err := decode(r, data, &subscription); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Validate struct
+		if err := subscription.Validate(); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s := converter.ToCreateSubscriptionFromController(&subscription)
+
+		if err := c.webhooks.CreateSubscription(r.Context(), s); err != nil {
+			slog.Error(err.Error())
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		created := converter.ToSubscriptionFromService(s)
+		created.Secret = s.Secret
+
+		if err := respond(w, r, http.StatusCreated, created); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+// @Summary GetSubscription
+// @Tags webhooks
+// @Description get webhook subscription by id
+// @ID get-subscription
+// @Produce json
+// @Param id path integer true "subscription id"
+// @Success 200
+// @Failure 400
+// @Failure 404
+// @Failure 500
+// @Router /api/v1/webhooks/{id} [get]
+func (c *UserController) handleGetSubscription() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s, err := c.webhooks.GetSubscriptionById(r.Context(), id)
+
+		if err != nil {
+			slog.Error(err.Error())
+			w.WriteHeader(webhookErrorStatus(err))
+			return
+		}
+
+		if err := respond(w, r, http.StatusOK, converter.ToSubscriptionFromService(s)); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+// @Summary UpdateSubscription
+// @Tags webhooks
+// @Description update url, events or secret of webhook subscription
+// @ID update-subscription
+// @Accept json
+// @Param id path integer true "subscription id"
+// @Param url body string false "url called with POST"
+// @Param events body []string false "user.created, user.updated and/or user.deleted"
+// @Param secret body string false "HMAC-SHA256 key, at least 16 characters"
+// @Success 200
+// @Failure 400
+// @Failure 404
+// @Failure 500
+// @Router /api/v1/webhooks/{id} [patch]
+func (c *UserController) handleUpdateSubscription() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var updateSubscription model.UpdateSubscription
+
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		data, err := io.ReadAll(r.Body)
+
+		if err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if err := decode(r, data, &updateSubscription); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Validate struct
+		if err := updateSubscription.Validate(); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		s, err := c.webhooks.GetSubscriptionById(r.Context(), id)
+
+		if err != nil {
+			slog.Error(err.Error())
+			w.WriteHeader(webhookErrorStatus(err))
+			return
+		}
+
+		subscription := converter.ToSubscriptionFromService(s)
+		subscription.Secret = s.Secret
+
+		updateSubscription.Copy(subscription)
+
+		if err := c.webhooks.UpdateSubscription(r.Context(), id, converter.ToSubscriptionFromController(subscription)); err != nil {
+			slog.Error(err.Error())
+			w.WriteHeader(webhookErrorStatus(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// @Summary DeleteSubscription
+// @Tags webhooks
+// @Description delete webhook subscription with its delivery log
+// @ID delete-subscription
+// @Param id path integer true "subscription id"
+// @Success 200
+// @Failure 400
+// @Failure 404
+// @Failure 500
+// @Router /api/v1/webhooks/{id} [delete]
+func (c *UserController) handleDeleteSubscription() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if err := c.webhooks.DeleteSubscription(r.Context(), id); err != nil {
+			slog.Error(err.Error())
+			w.WriteHeader(webhookErrorStatus(err))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// @Summary GetDeliveries
+// @Tags webhooks
+// @Description get delivery log of webhook subscription, newest first
+// @ID get-deliveries
+// @Produce json
+// @Param id path integer true "subscription id"
+// @Param status query string false "pending, succeeded or failed"
+// @Param limit query integer false "limit, 50 by default"
+// @Success 200
+// @Failure 400
+// @Failure 404
+// @Failure 500
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+func (c *UserController) handleGetDeliveries() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		filter := &model.DeliveryFilter{}
+
+		if err := filter.FillFilter(r.URL.Query()); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// Validate struct
+		if err := filter.Validate(); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		serviceDeliveries, err := c.webhooks.GetDeliveries(r.Context(), converter.ToDeliveryFilterFromController(id, filter))
+
+		if err != nil {
+			slog.Error(err.Error())
+			w.WriteHeader(webhookErrorStatus(err))
+			return
+		}
+
+		deliveries := make([]model.Delivery, 0, len(serviceDeliveries))
+
+		for _, d := range serviceDeliveries {
+			deliveries = append(deliveries, *converter.ToDeliveryFromService(&d))
+		}
+
+		if err := respond(w, r, http.StatusOK, deliveries); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+// @Summary Redeliver
+// @Tags webhooks
+// @Description send delivery again as a new delivery with the same payload, the original stays in the log
+// @ID redeliver
+// @Produce json
+// @Param id path integer true "subscription id"
+// @Param deliveryId path integer true "delivery id"
+// @Success 202
+// @Failure 400
+// @Failure 404
+// @Failure 500
+// @Router /api/v1/webhooks/{id}/deliveries/{deliveryId}/redeliver [post]
+func (c *UserController) handleRedeliver() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+
+		if err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		deliveryId, err := strconv.ParseInt(chi.URLParam(r, "deliveryId"), 10, 64)
+
+		if err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		redeliveryId, err := c.webhooks.Redeliver(r.Context(), id, deliveryId)
+
+		if err != nil {
+			slog.Error(err.Error())
+			w.WriteHeader(webhookErrorStatus(err))
+			return
+		}
+
+		if err := respond(w, r, http.StatusAccepted, map[string]int64{"id": redeliveryId}); err != nil {
+			slog.Error(fmt.Sprintf("controller: %s", err.Error()))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}
+}
+
+func webhookErrorStatus(err error) int {
+	if errors.Is(err, domain.ErrSubscriptionNotFound) || errors.Is(err, domain.ErrDeliveryNotFound) {
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,192 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	mock_service "github.com/sletkov/effective-mobile-test-task/internal/service/mocks"
+)
+
+func TestControllerWebhooks(t *testing.T) {
+	type mockBehavior func(s *mock_service.MockWebhookService)
+
+	createdAt := time.Date(2024, 2, 15, 10, 0, 0, 0, time.UTC)
+
+	subscription := domain.Subscription{
+		Id:        1,
+		URL:       "https://partner.example.com/hooks",
+		Events:    []string{domain.EventUserCreated},
+		Secret:    "0123456789abcdef",
+		CreatedAt: createdAt,
+	}
+
+	testCases := []struct {
+		name                 string
+		method               string
+		url                  string
+		body                 string
+		mockBehavior         mockBehavior
+		expectedStatusCode   int
+		expectedResponseBody string
+	}{
+		{
+			name:   "create",
+			method: http.MethodPost,
+			url:    "/api/v1/webhooks",
+			body:   `{"url":"https://partner.example.com/hooks","events":["user.created"]}`,
+			mockBehavior: func(s *mock_service.MockWebhookService) {
+				s.EXPECT().CreateSubscription(gomock.Any(), &domain.Subscription{
+					URL:    "https://partner.example.com/hooks",
+					Events: []string{domain.EventUserCreated},
+				}).DoAndReturn(func(ctx context.Context, s *domain.Subscription) error {
+					*s = subscription
+					return nil
+				})
+			},
+			expectedStatusCode:   http.StatusCreated,
+			expectedResponseBody: `{"id":1,"url":"https://partner.example.com/hooks","events":["user.created"],"secret":"0123456789abcdef","created_at":"2024-02-15T10:00:00Z"}`,
+		},
+
+		{
+			name:               "create with unknown event",
+			method:             http.MethodPost,
+			url:                "/api/v1/webhooks",
+			body:               `{"url":"https://partner.example.com/hooks","events":["user.viewed"]}`,
+			mockBehavior:       func(s *mock_service.MockWebhookService) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:   "list hides secrets",
+			method: http.MethodGet,
+			url:    "/api/v1/webhooks",
+			mockBehavior: func(s *mock_service.MockWebhookService) {
+				s.EXPECT().GetSubscriptions(gomock.Any()).Return([]domain.Subscription{subscription}, nil)
+			},
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseBody: `[{"id":1,"url":"https://partner.example.com/hooks","events":["user.created"],"created_at":"2024-02-15T10:00:00Z"}]`,
+		},
+
+		{
+			name:   "update keeps secret",
+			method: http.MethodPatch,
+			url:    "/api/v1/webhooks/1",
+			body:   `{"events":["user.created","user.deleted"]}`,
+			mockBehavior: func(s *mock_service.MockWebhookService) {
+				s.EXPECT().GetSubscriptionById(gomock.Any(), 1).Return(&subscription, nil)
+				s.EXPECT().UpdateSubscription(gomock.Any(), 1, &domain.Subscription{
+					Id:     1,
+					URL:    "https://partner.example.com/hooks",
+					Events: []string{domain.EventUserCreated, domain.EventUserDeleted},
+					Secret: "0123456789abcdef",
+				}).Return(nil)
+			},
+			expectedStatusCode: http.StatusOK,
+		},
+
+		{
+			name:   "delete unknown",
+			method: http.MethodDelete,
+			url:    "/api/v1/webhooks/2",
+			mockBehavior: func(s *mock_service.MockWebhookService) {
+				s.EXPECT().DeleteSubscription(gomock.Any(), 2).Return(domain.ErrSubscriptionNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+
+		{
+			name:   "deliveries",
+			method: http.MethodGet,
+			url:    "/api/v1/webhooks/1/deliveries?status=pending&limit=10",
+			mockBehavior: func(s *mock_service.MockWebhookService) {
+				s.EXPECT().GetDeliveries(gomock.Any(), &domain.DeliveryFilter{SubscriptionId: 1, Status: domain.DeliveryPending, Limit: 10}).Return([]domain.Delivery{
+					{
+						Id:             5,
+						SubscriptionId: 1,
+						EventType:      domain.EventUserCreated,
+						Payload:        json.RawMessage(`{"id":"e1"}`),
+						Status:         domain.DeliveryPending,
+						Attempts:       1,
+						ResponseStatus: http.StatusBadGateway,
+						Error:          "webhook responded with status 502",
+						NextAttemptAt:  createdAt.Add(time.Minute),
+						CreatedAt:      createdAt,
+						UpdatedAt:      createdAt,
+					},
+				}, nil)
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedResponseBody: `[{"id":5,"event_type":"user.created","payload":{"id":"e1"},"status":"pending","attempts":1,` +
+				`"response_status":502,"error":"webhook responded with status 502","next_attempt_at":"2024-02-15T10:01:00Z",` +
+				`"created_at":"2024-02-15T10:00:00Z","updated_at":"2024-02-15T10:00:00Z"}]`,
+		},
+
+		{
+			name:               "deliveries with unknown status",
+			method:             http.MethodGet,
+			url:                "/api/v1/webhooks/1/deliveries?status=lost",
+			mockBehavior:       func(s *mock_service.MockWebhookService) {},
+			expectedStatusCode: http.StatusBadRequest,
+		},
+
+		{
+			name:   "redeliver",
+			method: http.MethodPost,
+			url:    "/api/v1/webhooks/1/deliveries/5/redeliver",
+			mockBehavior: func(s *mock_service.MockWebhookService) {
+				s.EXPECT().Redeliver(gomock.Any(), 1, int64(5)).Return(int64(6), nil)
+			},
+			expectedStatusCode:   http.StatusAccepted,
+			expectedResponseBody: `{"id":6}`,
+		},
+
+		{
+			name:   "redeliver unknown",
+			method: http.MethodPost,
+			url:    "/api/v1/webhooks/1/deliveries/9/redeliver",
+			mockBehavior: func(s *mock_service.MockWebhookService) {
+				s.EXPECT().Redeliver(gomock.Any(), 1, int64(9)).Return(int64(0), domain.ErrDeliveryNotFound)
+			},
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Init deps
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			webhookService := mock_service.NewMockWebhookService(c)
+			tc.mockBehavior(webhookService)
+
+			controller := New(mock_service.NewMockUserService(c), WithWebhooks(webhookService))
+
+			// Test router
+			r := controller.InitRoutes()
+
+			// Test request
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tc.method, tc.url, bytes.NewBufferString(tc.body))
+
+			// Perform request
+			r.ServeHTTP(w, req)
+
+			// Assert
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+
+			if tc.expectedResponseBody != "" {
+				assert.JSONEq(t, tc.expectedResponseBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package converter
+
+import (
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	repoModel "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+func ToEventFromRepo(event *repoModel.Event) *domain.Event {
+	return &domain.Event{
+		Id:         event.Id,
+		Type:       event.Type,
+		UserId:     event.UserId,
+		Data:       event.Payload,
+		OccurredAt: event.CreatedAt,
+	}
+}
@@ -17,20 +17,37 @@ func ToUserFromService(user *domain.User) *repoModel.User {
 }
 
 func ToUserFilterFromService(userFilter *domain.UserFilter) *repoModel.UserFilter {
-	return &repoModel.UserFilter{
-		Name:        userFilter.Name,
-		Surname:     userFilter.Surname,
-		Patronymic:  userFilter.Patronymic,
-		AgeFrom:     userFilter.AgeFrom,
-		AgeTo:       userFilter.AgeTo,
-		Gender:      userFilter.Gender,
-		Nationality: userFilter.Nationality,
-		Limit:       userFilter.Limit,
+	filter := &repoModel.UserFilter{
+		Expr:   ToFilterExprFromService(userFilter.Expr),
+		Fields: userFilter.Fields,
+		Limit:  userFilter.Limit,
+		Offset: userFilter.Offset,
 	}
+
+	for _, f := range userFilter.Sort {
+		filter.Sort = append(filter.Sort, repoModel.SortField{Field: f.Field, Desc: f.Desc})
+	}
+
+	return filter
+}
+
+func ToFilterExprFromService(e domain.FilterExpr) repoModel.FilterExpr {
+	expr := repoModel.FilterExpr{
+		Op:     repoModel.FilterOp(e.Op),
+		Field:  e.Field,
+		Values: e.Values,
+	}
+
+	for _, child := range e.Children {
+		expr.Children = append(expr.Children, ToFilterExprFromService(child))
+	}
+
+	return expr
 }
 
 func ToUserFromRepo(user *repoModel.User) *domain.User {
 	return &domain.User{
+		Id:          user.Id,
 		Name:        user.Name,
 		Surname:     user.Surname,
 		Patronymic:  user.Patronymic,
@@ -39,3 +56,54 @@ func ToUserFromRepo(user *repoModel.User) *domain.User {
 		Nationality: user.Nationality,
 	}
 }
+
+func ToUserSearchFromService(userSearch *domain.UserSearch) *repoModel.UserSearch {
+	return &repoModel.UserSearch{
+		Query:     userSearch.Query,
+		Threshold: userSearch.Threshold,
+		Limit:     userSearch.Limit,
+	}
+}
+
+func ToUserMatchFromRepo(match *repoModel.UserMatch) *domain.UserMatch {
+	return &domain.UserMatch{
+		User: domain.User{
+			Id:          match.Id,
+			Name:        match.Name,
+			Surname:     match.Surname,
+			Patronymic:  match.Patronymic,
+			Age:         match.Age,
+			Gender:      match.Gender,
+			Nationality: match.Nationality,
+		},
+		Rank:         match.Rank,
+		MatchedField: match.MatchedField,
+		Highlight:    match.Highlight,
+	}
+}
+
+func ToUserStatsRequestFromService(statsRequest *domain.UserStatsRequest) *repoModel.UserStatsRequest {
+	return &repoModel.UserStatsRequest{
+		Expr:      ToFilterExprFromService(statsRequest.Expr),
+		GroupBy:   statsRequest.GroupBy,
+		AgeBucket: statsRequest.AgeBucket,
+	}
+}
+
+func ToUserStatsFromRepo(stats *repoModel.UserStats) *domain.UserStats {
+	s := &domain.UserStats{
+		Gender:      stats.Gender,
+		Nationality: stats.Nationality,
+		Count:       stats.Count,
+		AvgAge:      stats.AvgAge,
+		AgeP50:      stats.AgeP50,
+		AgeP90:      stats.AgeP90,
+		AgeP99:      stats.AgeP99,
+	}
+
+	if stats.AgeBucket != nil {
+		s.AgeBucket = &domain.AgeBucket{From: stats.AgeBucket.From, To: stats.AgeBucket.To}
+	}
+
+	return s
+}
@@ -0,0 +1,48 @@
+package converter
+
+import (
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	repoModel "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+func ToSubscriptionFromService(s *domain.Subscription) *repoModel.Subscription {
+	return &repoModel.Subscription{
+		URL:    s.URL,
+		Events: s.Events,
+		Secret: s.Secret,
+	}
+}
+
+func ToSubscriptionFromRepo(s *repoModel.Subscription) *domain.Subscription {
+	return &domain.Subscription{
+		Id:        s.Id,
+		URL:       s.URL,
+		Events:    s.Events,
+		Secret:    s.Secret,
+		CreatedAt: s.CreatedAt,
+	}
+}
+
+func ToDeliveryFilterFromService(filter *domain.DeliveryFilter) *repoModel.DeliveryFilter {
+	return &repoModel.DeliveryFilter{
+		SubscriptionId: filter.SubscriptionId,
+		Status:         filter.Status,
+		Limit:          filter.Limit,
+	}
+}
+
+func ToDeliveryFromRepo(d *repoModel.Delivery) *domain.Delivery {
+	return &domain.Delivery{
+		Id:             d.Id,
+		SubscriptionId: d.SubscriptionId,
+		EventType:      d.EventType,
+		Payload:        d.Payload,
+		Status:         d.Status,
+		Attempts:       d.Attempts,
+		ResponseStatus: d.ResponseStatus,
+		Error:          d.Error,
+		NextAttemptAt:  d.NextAttemptAt,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+	}
+}
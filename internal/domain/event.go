@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Types of changes of users
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+)
+
+// Change of user published to downstream systems. Events may be delivered more
+// than once, consumers deduplicate them by id.
+type Event struct {
+	Id     int64  `json:"id"`
+	Type   string `json:"type"`
+	UserId int    `json:"user_id"`
+	// User after the change, the last state for deleted users
+	Data       json.RawMessage `json:"data"`
+	OccurredAt time.Time       `json:"occurred_at"`
+}
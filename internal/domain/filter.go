@@ -0,0 +1,49 @@
+package domain
+
+type FilterOp string
+
+const (
+	// Groups of expressions
+	FilterAnd FilterOp = "and"
+	FilterOr  FilterOp = "or"
+
+	// Conditions on a field
+	FilterEq       FilterOp = "eq"
+	FilterNe       FilterOp = "ne"
+	FilterIn       FilterOp = "in"
+	FilterNotIn    FilterOp = "nin"
+	FilterPrefix   FilterOp = "prefix"
+	FilterContains FilterOp = "contains"
+	FilterGt       FilterOp = "gt"
+	FilterGte      FilterOp = "gte"
+	FilterLt       FilterOp = "lt"
+	FilterLte      FilterOp = "lte"
+	FilterNull     FilterOp = "null"
+	FilterNotNull  FilterOp = "notnull"
+)
+
+// Node of filter expression tree. Groups join their children,
+// conditions compare field with values. Zero expression matches everything.
+type FilterExpr struct {
+	Op       FilterOp
+	Field    string
+	Values   []string
+	Children []FilterExpr
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+func And(children ...FilterExpr) FilterExpr {
+	return FilterExpr{Op: FilterAnd, Children: children}
+}
+
+func Or(children ...FilterExpr) FilterExpr {
+	return FilterExpr{Op: FilterOr, Children: children}
+}
+
+func Cond(field string, op FilterOp, values ...string) FilterExpr {
+	return FilterExpr{Op: op, Field: field, Values: values}
+}
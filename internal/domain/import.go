@@ -0,0 +1,29 @@
+package domain
+
+// User read from import upload with its line number
+type ImportRow struct {
+	Line int
+	User User
+}
+
+type ImportOptions struct {
+	// Validate rows without writing them
+	DryRun bool
+	// Fetch age, gender and nationality missing in rows
+	Enrich bool
+}
+
+type ImportError struct {
+	Line  int
+	Error string
+}
+
+type ImportReport struct {
+	Total int
+	Valid int
+	// Rows that need enrichment, dry run can't tell if they are valid
+	Unverified int
+	Imported   int
+	DryRun     bool
+	Errors     []ImportError
+}
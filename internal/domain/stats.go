@@ -0,0 +1,32 @@
+package domain
+
+// Fields users can be grouped by in stats
+const (
+	GroupByGender      = "gender"
+	GroupByNationality = "nationality"
+	GroupByAgeBucket   = "age_bucket"
+)
+
+type UserStatsRequest struct {
+	Expr    FilterExpr
+	GroupBy []string
+	// Width of age buckets in years
+	AgeBucket int
+}
+
+type AgeBucket struct {
+	From int
+	To   int
+}
+
+// Stats of a group of users, group fields not grouped by are empty
+type UserStats struct {
+	Gender      string
+	Nationality string
+	AgeBucket   *AgeBucket
+	Count       int
+	AvgAge      float64
+	AgeP50      float64
+	AgeP90      float64
+	AgeP99      float64
+}
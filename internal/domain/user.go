@@ -6,6 +6,10 @@ import (
 
 var (
 	ErrUserNotFound = errors.New("user not found")
+	// 3rd-party enrichment apis can't be called right now
+	ErrEnrichmentUnavailable = errors.New("enrichment unavailable")
+	// 3rd-party enrichment apis don't know age, gender or nationality of name
+	ErrUnknownName = errors.New("name is unknown to enrichment apis")
 )
 
 type User struct {
@@ -19,14 +23,27 @@ type User struct {
 }
 
 type UserFilter struct {
-	Name        string
-	Surname     string
-	Patronymic  string
-	AgeFrom     int
-	AgeTo       int
-	Gender      string
-	Nationality string
-	Limit       int
+	Expr FilterExpr
+	Sort []SortField
+	// Fields to return, all if empty
+	Fields []string
+	Limit  int
+	// Users to skip before the first one
+	Offset int
+}
+
+type UserSearch struct {
+	Query     string
+	Threshold float64
+	Limit     int
+}
+
+// User found by search with the field matching query best
+type UserMatch struct {
+	User
+	Rank         float64
+	MatchedField string
+	Highlight    string
 }
 
 type UpdateUser struct {
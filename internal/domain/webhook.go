@@ -0,0 +1,61 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrDeliveryNotFound     = errors.New("delivery not found")
+)
+
+// Statuses of webhook deliveries
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	// Retries are exhausted
+	DeliveryFailed = "failed"
+)
+
+// Partner url called on changes of users of given event types
+type Subscription struct {
+	Id     int
+	URL    string
+	Events []string
+	// Key of HMAC-SHA256 signatures of deliveries
+	Secret    string
+	CreatedAt time.Time
+}
+
+type Delivery struct {
+	Id             int64
+	SubscriptionId int
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int
+	// Status of the last response, zero if there was none
+	ResponseStatus int
+	Error          string
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+type DeliveryFilter struct {
+	SubscriptionId int
+	// Deliveries of any status if empty
+	Status string
+	Limit  int
+}
+
+// Body of webhook requests. Redelivered events keep their id,
+// so receivers deduplicate them by it.
+type WebhookEvent struct {
+	Id         string    `json:"id"`
+	Type       string    `json:"type"`
+	User       *User     `json:"user"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
@@ -0,0 +1,188 @@
+package events
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+const (
+	defaultBufferSize       = 1000
+	defaultSubscriberBuffer = 64
+)
+
+// Change of user with the user parsed for filtering
+type Event struct {
+	*domain.Event
+	User domain.User
+}
+
+// Broker keeps recent changes of users and fans new ones out to subscribers.
+// Subscribers falling behind are dropped, they resume from the buffer.
+type Broker struct {
+	mu sync.Mutex
+
+	// Ring of recent events in order they were notified
+	buffer []*Event
+	start  int
+	count  int
+
+	subscribers      map[*Subscription]struct{}
+	subscriberBuffer int
+	closed           bool
+}
+
+type Option func(b *Broker)
+
+// Keep size recent events to resume from
+func WithBufferSize(size int) Option {
+	return func(b *Broker) {
+		b.buffer = make([]*Event, size)
+	}
+}
+
+// Queue up to size events for a subscriber before it is dropped
+func WithSubscriberBuffer(size int) Option {
+	return func(b *Broker) {
+		b.subscriberBuffer = size
+	}
+}
+
+func NewBroker(opts ...Option) *Broker {
+	b := &Broker{
+		buffer:           make([]*Event, defaultBufferSize),
+		subscribers:      make(map[*Subscription]struct{}),
+		subscriberBuffer: defaultSubscriberBuffer,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+type Subscription struct {
+	// Closed when subscriber is dropped or broker is reset
+	C      <-chan *Event
+	events chan *Event
+	broker *Broker
+}
+
+// Stop receiving events
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	s.broker.drop(s)
+}
+
+// Subscribe to events after the one with lastEventId, empty id subscribes to new events only.
+// Returns buffered events after it and false if it is not buffered anymore,
+// then subscriber may have missed events.
+func (b *Broker) Subscribe(lastEventId string) (*Subscription, []*Event, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events := make(chan *Event, b.subscriberBuffer)
+
+	s := &Subscription{
+		C:      events,
+		events: events,
+		broker: b,
+	}
+
+	// Stream ends right away
+	if b.closed {
+		close(events)
+		return s, nil, true
+	}
+
+	b.subscribers[s] = struct{}{}
+
+	if lastEventId == "" {
+		return s, nil, true
+	}
+
+	for i := b.count - 1; i >= 0; i-- {
+		if strconv.FormatInt(b.at(i).Id, 10) == lastEventId {
+			backlog := make([]*Event, 0, b.count-i-1)
+
+			for j := i + 1; j < b.count; j++ {
+				backlog = append(backlog, b.at(j))
+			}
+
+			return s, backlog, true
+		}
+	}
+
+	return s, nil, false
+}
+
+// Buffer event and send it to subscribers
+func (b *Broker) Publish(e *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.buffer) > 0 {
+		if b.count == len(b.buffer) {
+			b.start = (b.start + 1) % len(b.buffer)
+			b.count--
+		}
+
+		b.buffer[(b.start+b.count)%len(b.buffer)] = e
+		b.count++
+	}
+
+	for s := range b.subscribers {
+		select {
+		case s.events <- e:
+		default:
+			b.drop(s)
+		}
+	}
+}
+
+// Forget buffered events and drop subscribers after events may have been missed,
+// reconnecting subscribers can't resume and start over
+func (b *Broker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i := range b.buffer {
+		b.buffer[i] = nil
+	}
+
+	b.start, b.count = 0, 0
+
+	for s := range b.subscribers {
+		b.drop(s)
+	}
+}
+
+// Drop subscribers and end new subscriptions right away, so streams don't hold server shutdown
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+
+	for s := range b.subscribers {
+		b.drop(s)
+	}
+}
+
+// Event at position i of buffer, oldest first
+func (b *Broker) at(i int) *Event {
+	return b.buffer[(b.start+i)%len(b.buffer)]
+}
+
+func (b *Broker) drop(s *Subscription) {
+	if _, ok := b.subscribers[s]; !ok {
+		return
+	}
+
+	delete(b.subscribers, s)
+	close(s.events)
+}
@@ -0,0 +1,153 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+func newEvent(id int64) *Event {
+	return &Event{Event: &domain.Event{Id: id, Type: domain.EventUserUpdated}}
+}
+
+// Ids of events received so far
+func received(s *Subscription) []int64 {
+	var ids []int64
+
+	for {
+		select {
+		case e, ok := <-s.C:
+			if !ok {
+				return ids
+			}
+
+			ids = append(ids, e.Id)
+		default:
+			return ids
+		}
+	}
+}
+
+func ids(events []*Event) []int64 {
+	var ids []int64
+
+	for _, e := range events {
+		ids = append(ids, e.Id)
+	}
+
+	return ids
+}
+
+func TestBrokerSubscribe(t *testing.T) {
+	b := NewBroker(WithBufferSize(3))
+
+	for id := int64(1); id <= 5; id++ {
+		b.Publish(newEvent(id))
+	}
+
+	testCases := []struct {
+		name            string
+		lastEventId     string
+		expectedBacklog []int64
+		resumed         bool
+	}{
+		{
+			name:    "new events only",
+			resumed: true,
+		},
+
+		{
+			name:            "buffered",
+			lastEventId:     "3",
+			expectedBacklog: []int64{4, 5},
+			resumed:         true,
+		},
+
+		{
+			name:        "latest",
+			lastEventId: "5",
+			resumed:     true,
+		},
+
+		{
+			name:        "evicted",
+			lastEventId: "2",
+		},
+
+		{
+			name:        "unknown",
+			lastEventId: "event",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, backlog, resumed := b.Subscribe(tc.lastEventId)
+			defer s.Close()
+
+			assert.Equal(t, tc.resumed, resumed)
+			assert.Equal(t, tc.expectedBacklog, ids(backlog))
+		})
+	}
+}
+
+func TestBrokerPublish(t *testing.T) {
+	b := NewBroker(WithSubscriberBuffer(2))
+
+	fast, _, _ := b.Subscribe("")
+	slow, _, _ := b.Subscribe("")
+
+	b.Publish(newEvent(1))
+	b.Publish(newEvent(2))
+	assert.Equal(t, []int64{1, 2}, received(fast))
+
+	// Slow subscriber doesn't block the rest and is dropped
+	b.Publish(newEvent(3))
+	assert.Equal(t, []int64{3}, received(fast))
+	assert.Equal(t, []int64{1, 2}, received(slow))
+
+	_, ok := <-slow.C
+	assert.False(t, ok)
+
+	// It resumes from the buffer
+	resumed, backlog, ok := b.Subscribe("2")
+	assert.True(t, ok)
+	assert.Equal(t, []int64{3}, ids(backlog))
+
+	// Changes may have been missed
+	b.Reset()
+
+	_, ok = <-fast.C
+	assert.False(t, ok)
+
+	_, ok = <-resumed.C
+	assert.False(t, ok)
+
+	_, _, ok = b.Subscribe("3")
+	assert.False(t, ok)
+
+	b.Close()
+
+	closed, _, _ := b.Subscribe("")
+
+	_, ok = <-closed.C
+	assert.False(t, ok)
+
+	fast.Close()
+}
+
+func TestParse(t *testing.T) {
+	e, err := Parse([]byte(`{"id": 7, "type": "user.deleted", "user_id": 5, ` +
+		`"data": {"id": 5, "name": "Ivan", "surname": "Ivanov", "patronymic": null, "age": 20, "gender": "male", "nationality": "RU"}, ` +
+		`"occurred_at": "2024-02-20T10:00:00.123456+00:00"}`))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), e.Id)
+	assert.Equal(t, domain.EventUserDeleted, e.Type)
+	assert.Equal(t, domain.User{Id: 5, Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "male", Nationality: "RU"}, e.User)
+
+	_, err = Parse([]byte(`{"id": 7, "data": []}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,24 @@
+package events
+
+import (
+	"github.com/sletkov/effective-mobile-test-task/internal/converter"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/emulate"
+)
+
+// Check if changed user matches filter of a stream
+type Filter func(u *domain.User) bool
+
+// Compile filter expression the same way users query does, so streams
+// get the same errors and the same users as the list
+func Compile(e domain.FilterExpr) (Filter, error) {
+	match, err := emulate.CompileFilter(converter.ToFilterExprFromService(e))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return func(u *domain.User) bool {
+		return match == nil || match(converter.ToUserFromService(u))
+	}, nil
+}
@@ -0,0 +1,124 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+func TestCompile(t *testing.T) {
+	ivan := &domain.User{Id: 1, Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "male", Nationality: "RU"}
+
+	testCases := []struct {
+		name    string
+		expr    domain.FilterExpr
+		matches bool
+		isValid bool
+	}{
+		{
+			name:    "no filter",
+			matches: true,
+			isValid: true,
+		},
+
+		{
+			name:    "eq",
+			expr:    domain.And(domain.Cond("name", domain.FilterEq, "Ivan")),
+			matches: true,
+			isValid: true,
+		},
+
+		{
+			name:    "eq is case sensitive",
+			expr:    domain.And(domain.Cond("name", domain.FilterEq, "ivan")),
+			isValid: true,
+		},
+
+		{
+			name:    "prefix ignores case",
+			expr:    domain.And(domain.Cond("surname", domain.FilterPrefix, "iva")),
+			matches: true,
+			isValid: true,
+		},
+
+		{
+			name:    "age range",
+			expr:    domain.And(domain.Cond("age", domain.FilterGte, "18"), domain.Cond("age", domain.FilterLt, "30")),
+			matches: true,
+			isValid: true,
+		},
+
+		{
+			name: "age compared as number",
+			expr: domain.And(domain.Cond("age", domain.FilterGt, "3")),
+			// "20" > "3" would be false as strings
+			matches: true,
+			isValid: true,
+		},
+
+		{
+			name:    "not in",
+			expr:    domain.And(domain.Cond("nationality", domain.FilterNotIn, "RU", "UA")),
+			isValid: true,
+		},
+
+		{
+			name:    "gender compared in enum order",
+			expr:    domain.And(domain.Cond("gender", domain.FilterLt, "female")),
+			matches: true,
+			isValid: true,
+		},
+
+		{
+			name: "unknown field",
+			expr: domain.And(domain.Cond("email", domain.FilterEq, "ivan@example.com")),
+		},
+
+		{
+			name: "age is not a number",
+			expr: domain.And(domain.Cond("age", domain.FilterGt, "old")),
+		},
+
+		{
+			name: "too many values",
+			expr: domain.And(domain.Cond("age", domain.FilterGt, "18", "30")),
+		},
+
+		{
+			name:    "without patronymic",
+			expr:    domain.And(domain.Cond("patronymic", domain.FilterNull)),
+			matches: true,
+			isValid: true,
+		},
+
+		{
+			name:    "null patronymic is unequal",
+			expr:    domain.And(domain.Cond("patronymic", domain.FilterNe, "Ivanovich")),
+			matches: true,
+			isValid: true,
+		},
+
+		{
+			name:    "or group",
+			expr:    domain.And(domain.Cond("gender", domain.FilterEq, "male"), domain.Or(domain.Cond("age", domain.FilterGt, "60"), domain.Cond("nationality", domain.FilterEq, "RU"))),
+			matches: true,
+			isValid: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			match, err := Compile(tc.expr)
+
+			if !tc.isValid {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.matches, match(ivan))
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+// Postgres channel users changes are notified to
+const Channel = "users_events"
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+
+	// Connection is checked while there are no notifications
+	pingInterval = 90 * time.Second
+)
+
+// Source of postgres notifications, pq.Listener
+type notifier interface {
+	NotificationChannel() <-chan *pq.Notification
+	Ping() error
+}
+
+// Publish changes of users notified by postgres to broker until ctx is done.
+// Notifications are not stored, so ones sent while connection is broken can't be
+// reloaded. Broker is reset then and resumes from before the gap are refused.
+func Listen(ctx context.Context, dsn string, broker *Broker) error {
+	listener := pq.NewListener(dsn, minReconnectInterval, maxReconnectInterval, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error(fmt.Sprintf("events: listener: %s", err.Error()))
+		}
+
+		// Streams resuming until reconnect would miss changes too
+		if event == pq.ListenerEventDisconnected {
+			slog.Warn("events: listener disconnected, changes may be missed")
+			broker.Reset()
+		}
+	})
+
+	defer listener.Close()
+
+	if err := listener.Listen(Channel); err != nil {
+		return fmt.Errorf("events: listening %s: %w", Channel, err)
+	}
+
+	relay(ctx, listener, broker, pingInterval)
+
+	return nil
+}
+
+// Publish notifications to broker until ctx is done, connection is pinged while there are none
+func relay(ctx context.Context, listener notifier, broker *Broker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.NotificationChannel():
+			// Connection was reestablished, changes made since disconnect were not notified
+			if n == nil {
+				slog.Warn("events: listener reconnected, changes may have been missed")
+				broker.Reset()
+				continue
+			}
+
+			e, err := Parse([]byte(n.Extra))
+
+			if err != nil {
+				slog.Error(fmt.Sprintf("events: %s", err.Error()))
+				continue
+			}
+
+			broker.Publish(e)
+		case <-ticker.C:
+			go listener.Ping()
+		}
+	}
+}
+
+// Parse notification payload
+func Parse(payload []byte) (*Event, error) {
+	e := &Event{Event: &domain.Event{}}
+
+	if err := json.Unmarshal(payload, e.Event); err != nil {
+		return nil, fmt.Errorf("parsing notification: %w", err)
+	}
+
+	if err := json.Unmarshal(e.Data, &e.User); err != nil {
+		return nil, fmt.Errorf("parsing user of event %d: %w", e.Id, err)
+	}
+
+	return e, nil
+}
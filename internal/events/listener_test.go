@@ -0,0 +1,74 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNotifier struct {
+	notify chan *pq.Notification
+}
+
+func (n *fakeNotifier) NotificationChannel() <-chan *pq.Notification {
+	return n.notify
+}
+
+func (n *fakeNotifier) Ping() error {
+	return nil
+}
+
+func notification(id int64) *pq.Notification {
+	return &pq.Notification{
+		Channel: Channel,
+		Extra:   fmt.Sprintf(`{"id": %d, "type": "user.updated", "user_id": 5, "data": {"id": 5, "name": "Ivan"}, "occurred_at": "2024-02-20T10:00:00Z"}`, id),
+	}
+}
+
+func TestRelayReconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	listener := &fakeNotifier{notify: make(chan *pq.Notification)}
+	b := NewBroker()
+
+	s, _, _ := b.Subscribe("")
+
+	done := make(chan struct{})
+
+	go func() {
+		relay(ctx, listener, b, time.Hour)
+		close(done)
+	}()
+
+	listener.notify <- notification(1)
+	listener.notify <- notification(2)
+
+	assert.Equal(t, int64(1), (<-s.C).Id)
+	assert.Equal(t, "Ivan", (<-s.C).User.Name)
+
+	_, backlog, ok := b.Subscribe("1")
+	assert.True(t, ok)
+	assert.Equal(t, []int64{2}, ids(backlog))
+
+	// Changes made while connection was broken are lost
+	listener.notify <- nil
+
+	_, ok = <-s.C
+	assert.False(t, ok)
+
+	_, _, ok = b.Subscribe("2")
+	assert.False(t, ok)
+
+	// Streams started over get changes after the gap
+	s, _, _ = b.Subscribe("")
+
+	listener.notify <- notification(3)
+	assert.Equal(t, int64(3), (<-s.C).Id)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,282 @@
+// Package fakeenrich is a local stand-in for agify, genderize and nationalize
+// apis, answering from a deterministic seed with optional faults.
+package fakeenrich
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed seed.json
+var defaultSeed []byte
+
+type Country struct {
+	CountryId   string  `json:"country_id"`
+	Probability float64 `json:"probability"`
+}
+
+type Person struct {
+	Count       int       `json:"count"`
+	Age         int       `json:"age"`
+	Gender      string    `json:"gender"`
+	Probability float64   `json:"probability"`
+	Countries   []Country `json:"countries"`
+}
+
+// Known names and countries for generated answers to unknown names
+type Seed struct {
+	Names     map[string]Person `json:"names"`
+	Countries []string          `json:"countries"`
+}
+
+// Load seed from json file, built in seed is used if path is empty
+func LoadSeed(path string) (*Seed, error) {
+	data := defaultSeed
+
+	if path != "" {
+		var err error
+
+		data, err = os.ReadFile(path)
+
+		if err != nil {
+			return nil, fmt.Errorf("fakeenrich: reading seed: %w", err)
+		}
+	}
+
+	seed := &Seed{}
+
+	if err := json.Unmarshal(data, seed); err != nil {
+		return nil, fmt.Errorf("fakeenrich: parsing seed: %w", err)
+	}
+
+	if len(seed.Countries) == 0 {
+		seed.Countries = []string{"RU"}
+	}
+
+	return seed, nil
+}
+
+// Faults injected into responses
+type Faults struct {
+	// Delay before every response
+	Latency time.Duration
+	// Share of requests failing with 500
+	ErrorRate float64
+	// Share of requests failing with 429
+	TooManyRequestsRate float64
+	// Names answered by each api per day before its requests fail with 429, zero is unlimited.
+	// Quotas are renewed at midnight UTC like the real ones.
+	DailyLimit int
+	// Random source seed, the same seed gives the same faults sequence
+	RandomSeed int64
+}
+
+type Server struct {
+	seed   *Seed
+	faults Faults
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+	// Names answered today by api
+	used     map[string]int
+	dayStart time.Time
+	now      func() time.Time
+}
+
+// Create handler serving apis under /agify/, /genderize/ and /nationalize/
+func New(seed *Seed, faults Faults) *Server {
+	return &Server{
+		seed:   seed,
+		faults: faults,
+		rnd:    rand.New(rand.NewSource(faults.RandomSeed)),
+		used:   make(map[string]int),
+		now:    time.Now,
+	}
+}
+
+// Renew daily quotas of all apis as if a new day has started
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.used = make(map[string]int)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	api := strings.Trim(r.URL.Path, "/")
+
+	if api != "agify" && api != "genderize" && api != "nationalize" {
+		http.NotFound(w, r)
+		return
+	}
+
+	query := r.URL.Query()
+	names, batch := query["name[]"]
+
+	if !batch {
+		names = query["name"]
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	if len(names) == 0 || names[0] == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Missing 'name' parameter"})
+		return
+	}
+
+	if batch && len(names) > 10 {
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]string{"error": "Invalid 'name' parameter"})
+		return
+	}
+
+	if s.faults.Latency > 0 {
+		select {
+		case <-time.After(s.faults.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	status, remaining := s.take(api, len(names))
+
+	if s.faults.DailyLimit > 0 {
+		w.Header().Set("X-Rate-Limit-Limit", strconv.Itoa(s.faults.DailyLimit))
+		w.Header().Set("X-Rate-Limit-Remaining", strconv.Itoa(remaining))
+		w.Header().Set("X-Rate-Limit-Reset", strconv.Itoa(secondsToMidnight(s.now())))
+	}
+
+	switch status {
+	case http.StatusTooManyRequests:
+		writeJSON(w, status, map[string]string{"error": "Request limit reached"})
+		return
+	case http.StatusInternalServerError:
+		writeJSON(w, status, map[string]string{"error": "Internal server error"})
+		return
+	}
+
+	answers := make([]interface{}, 0, len(names))
+
+	for _, name := range names {
+		answers = append(answers, s.answer(api, name))
+	}
+
+	if batch {
+		writeJSON(w, http.StatusOK, answers)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, answers[0])
+}
+
+// Count names against daily limit of api and roll injected faults
+func (s *Server) take(api string, n int) (int, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if day := s.now().UTC().Truncate(24 * time.Hour); day.After(s.dayStart) {
+		s.dayStart = day
+		s.used = make(map[string]int)
+	}
+
+	used := s.used[api]
+
+	if s.faults.DailyLimit > 0 && used+n > s.faults.DailyLimit {
+		return http.StatusTooManyRequests, 0
+	}
+
+	roll := s.rnd.Float64()
+
+	switch {
+	case roll < s.faults.TooManyRequestsRate:
+		return http.StatusTooManyRequests, s.faults.DailyLimit - used
+	case roll < s.faults.TooManyRequestsRate+s.faults.ErrorRate:
+		return http.StatusInternalServerError, s.faults.DailyLimit - used
+	}
+
+	s.used[api] = used + n
+
+	return http.StatusOK, s.faults.DailyLimit - s.used[api]
+}
+
+// Answer in the format of given api
+func (s *Server) answer(api, name string) interface{} {
+	p := s.person(name)
+
+	switch api {
+	case "agify":
+		return struct {
+			Count int    `json:"count"`
+			Name  string `json:"name"`
+			Age   int    `json:"age"`
+		}{p.Count, name, p.Age}
+	case "genderize":
+		return struct {
+			Count       int     `json:"count"`
+			Name        string  `json:"name"`
+			Gender      string  `json:"gender"`
+			Probability float64 `json:"probability"`
+		}{p.Count, name, p.Gender, p.Probability}
+	default:
+		return struct {
+			Count   int       `json:"count"`
+			Name    string    `json:"name"`
+			Country []Country `json:"country"`
+		}{p.Count, name, p.Countries}
+	}
+}
+
+// Person from seed, or one derived from name hash for unknown names
+func (s *Server) person(name string) Person {
+	if p, ok := s.seed.Names[strings.ToLower(name)]; ok {
+		return p
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(strings.ToLower(name)))
+	sum := h.Sum64()
+
+	gender := "male"
+
+	if sum%2 == 1 {
+		gender = "female"
+	}
+
+	return Person{
+		Count:       int(sum%10000) + 1,
+		Age:         int(sum%63) + 18,
+		Gender:      gender,
+		Probability: 0.9,
+		Countries: []Country{
+			{CountryId: s.seed.Countries[sum%uint64(len(s.seed.Countries))], Probability: 0.5},
+		},
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		slog.Error(fmt.Sprintf("fakeenrich: %s", err.Error()))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+func secondsToMidnight(now time.Time) int {
+	midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+
+	return int(midnight.Sub(now).Seconds())
+}
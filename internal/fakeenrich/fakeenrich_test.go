@@ -0,0 +1,176 @@
+package fakeenrich
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func get(t *testing.T, handler http.Handler, url string) (*httptest.ResponseRecorder, interface{}) {
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+
+	var body interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+
+	return w, body
+}
+
+func TestServer(t *testing.T) {
+	seed, err := LoadSeed("")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name               string
+		url                string
+		expectedStatusCode int
+		expectedBody       string
+	}{
+		{
+			name:               "agify",
+			url:                "/agify/?name=Ivan",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"count":298219,"name":"Ivan","age":51}`,
+		},
+
+		{
+			name:               "genderize",
+			url:                "/genderize/?name=Galina",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"count":44193,"name":"Galina","gender":"female","probability":1}`,
+		},
+
+		{
+			name:               "nationalize",
+			url:                "/nationalize/?name=Petr",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `{"count":20214,"name":"Petr","country":[{"country_id":"CZ","probability":0.5801},{"country_id":"RU","probability":0.1215}]}`,
+		},
+
+		{
+			name:               "batch",
+			url:                "/agify/?name[]=Ivan&name[]=Olga",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       `[{"count":298219,"name":"Ivan","age":51},{"count":223316,"name":"Olga","age":54}]`,
+		},
+
+		{
+			name:               "missing name",
+			url:                "/agify/",
+			expectedStatusCode: http.StatusUnprocessableEntity,
+			expectedBody:       `{"error":"Missing 'name' parameter"}`,
+		},
+
+		{
+			name:               "unknown api",
+			url:                "/ageify/?name=Ivan",
+			expectedStatusCode: http.StatusNotFound,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			New(seed, Faults{}).ServeHTTP(w, httptest.NewRequest(http.MethodGet, tc.url, nil))
+
+			assert.Equal(t, tc.expectedStatusCode, w.Code)
+
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestServerUnknownNameIsDeterministic(t *testing.T) {
+	seed, err := LoadSeed("")
+	assert.NoError(t, err)
+
+	_, first := get(t, New(seed, Faults{}), "/nationalize/?name=Qwzx")
+	_, second := get(t, New(seed, Faults{}), "/nationalize/?name=Qwzx")
+
+	assert.Equal(t, first, second)
+	assert.NotEmpty(t, first.(map[string]interface{})["country"])
+}
+
+func TestServerDailyLimit(t *testing.T) {
+	seed, err := LoadSeed("")
+	assert.NoError(t, err)
+
+	s := New(seed, Faults{DailyLimit: 3})
+
+	w, _ := get(t, s, "/agify/?name[]=Ivan&name[]=Olga")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Rate-Limit-Remaining"))
+
+	w, _ = get(t, s, "/agify/?name[]=Ivan&name[]=Olga")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	w, _ = get(t, s, "/agify/?name=Ivan")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-Rate-Limit-Remaining"))
+
+	// Every api has a quota of its own
+	w, _ = get(t, s, "/genderize/?name=Ivan")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Rate-Limit-Remaining"))
+
+	// Quotas are renewed
+	s.Reset()
+
+	w, _ = get(t, s, "/agify/?name=Ivan")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("X-Rate-Limit-Remaining"))
+}
+
+func TestServerDailyLimitRenewedAtMidnight(t *testing.T) {
+	seed, err := LoadSeed("")
+	assert.NoError(t, err)
+
+	now := time.Date(2024, 3, 1, 23, 59, 0, 0, time.UTC)
+
+	s := New(seed, Faults{DailyLimit: 1})
+	s.now = func() time.Time { return now }
+
+	w, _ := get(t, s, "/agify/?name=Ivan")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "60", w.Header().Get("X-Rate-Limit-Reset"))
+
+	w, _ = get(t, s, "/agify/?name=Ivan")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+
+	now = now.Add(time.Minute)
+
+	w, _ = get(t, s, "/agify/?name=Ivan")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestServerFaults(t *testing.T) {
+	seed, err := LoadSeed("")
+	assert.NoError(t, err)
+
+	statuses := func() map[int]int {
+		s := New(seed, Faults{ErrorRate: 0.2, TooManyRequestsRate: 0.3, RandomSeed: 42})
+		counts := make(map[int]int)
+
+		for i := 0; i < 1000; i++ {
+			w, _ := get(t, s, "/genderize/?name=Ivan")
+			counts[w.Code]++
+		}
+
+		return counts
+	}
+
+	counts := statuses()
+
+	assert.InDelta(t, 200, counts[http.StatusInternalServerError], 50)
+	assert.InDelta(t, 300, counts[http.StatusTooManyRequests], 50)
+	assert.InDelta(t, 500, counts[http.StatusOK], 50)
+
+	// The same random seed gives the same faults
+	assert.Equal(t, counts, statuses())
+}
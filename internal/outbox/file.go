@@ -0,0 +1,49 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+// Sink appending events to a file as json lines, for tests and local runs
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+
+	if err != nil {
+		return nil, fmt.Errorf("outbox: opening file sink: %w", err)
+	}
+
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Publish(ctx context.Context, e *domain.Event) error {
+	data, err := json.Marshal(e)
+
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+
+	// Event is delivered once it is on disk
+	return s.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
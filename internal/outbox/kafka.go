@@ -0,0 +1,50 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+// Sink writing events to topic keyed by user id, so events of a user share a partition
+type KafkaSink struct {
+	writer *kafka.Writer
+}
+
+func NewKafkaSink(brokers []string, topic string) *KafkaSink {
+	return &KafkaSink{
+		writer: &kafka.Writer{
+			Addr:         kafka.TCP(brokers...),
+			Topic:        topic,
+			Balancer:     &kafka.Hash{},
+			RequiredAcks: kafka.RequireAll,
+			// Events are written one by one, don't wait for a batch
+			BatchSize: 1,
+		},
+	}
+}
+
+func (s *KafkaSink) Publish(ctx context.Context, e *domain.Event) error {
+	data, err := json.Marshal(e)
+
+	if err != nil {
+		return err
+	}
+
+	return s.writer.WriteMessages(ctx, kafka.Message{
+		Key:   []byte(strconv.Itoa(e.UserId)),
+		Value: data,
+		Headers: []kafka.Header{
+			{Key: "event-id", Value: []byte(strconv.FormatInt(e.Id, 10))},
+			{Key: "event-type", Value: []byte(e.Type)},
+		},
+	})
+}
+
+func (s *KafkaSink) Close() error {
+	return s.writer.Close()
+}
@@ -0,0 +1,57 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+// Sink publishing events to JetStream, the stream deduplicates redelivered events by id
+type NATSSink struct {
+	conn    *nats.Conn
+	js      nats.JetStreamContext
+	subject string
+}
+
+func NewNATSSink(url, subject string) (*NATSSink, error) {
+	conn, err := nats.Connect(url, nats.Name("users-outbox"))
+
+	if err != nil {
+		return nil, fmt.Errorf("outbox: connecting to nats: %w", err)
+	}
+
+	js, err := conn.JetStream()
+
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("outbox: connecting to jetstream: %w", err)
+	}
+
+	return &NATSSink{conn: conn, js: js, subject: subject}, nil
+}
+
+func (s *NATSSink) Publish(ctx context.Context, e *domain.Event) error {
+	data, err := json.Marshal(e)
+
+	if err != nil {
+		return err
+	}
+
+	msg := nats.NewMsg(s.subject)
+	msg.Data = data
+	msg.Header.Set("Event-Type", e.Type)
+
+	// Acknowledged once stored by the stream
+	_, err = s.js.PublishMsg(msg, nats.Context(ctx), nats.MsgId(strconv.FormatInt(e.Id, 10)))
+
+	return err
+}
+
+func (s *NATSSink) Close() error {
+	return s.conn.Drain()
+}
@@ -0,0 +1,120 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/converter"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	repoModel "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+type Store interface {
+	Publish(ctx context.Context, limit int, publish func(events []repoModel.Event) []int64) (int, error)
+}
+
+// Destination of events, an event is delivered once Publish returns nil
+type Sink interface {
+	Publish(ctx context.Context, e *domain.Event) error
+	Close() error
+}
+
+const (
+	defaultInterval  = time.Second
+	defaultBatchSize = 100
+)
+
+// Relay publishes outbox events to sink at least once, events of every user in order
+type Relay struct {
+	store     Store
+	sink      Sink
+	interval  time.Duration
+	batchSize int
+}
+
+type Option func(r *Relay)
+
+// Poll outbox every interval while it is drained
+func WithInterval(interval time.Duration) Option {
+	return func(r *Relay) {
+		r.interval = interval
+	}
+}
+
+// Publish up to size events in a transaction
+func WithBatchSize(size int) Option {
+	return func(r *Relay) {
+		r.batchSize = size
+	}
+}
+
+func NewRelay(store Store, sink Sink, opts ...Option) *Relay {
+	r := &Relay{
+		store:     store,
+		sink:      sink,
+		interval:  defaultInterval,
+		batchSize: defaultBatchSize,
+	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Publish events until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := r.Flush(ctx)
+
+		switch {
+		case errors.Is(err, repoModel.ErrOutboxLocked):
+			slog.Debug("outbox: another relay is publishing")
+		case err != nil && ctx.Err() == nil:
+			slog.Error(fmt.Sprintf("outbox: %s", err.Error()))
+		case n == r.batchSize:
+			// More events are waiting
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Publish a batch of events in order. Once an event of a user fails to publish,
+// the rest of events of the user wait for the next batch.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	return r.store.Publish(ctx, r.batchSize, func(events []repoModel.Event) []int64 {
+		failed := make(map[int]bool)
+		published := make([]int64, 0, len(events))
+
+		for i := range events {
+			e := converter.ToEventFromRepo(&events[i])
+
+			if failed[e.UserId] {
+				continue
+			}
+
+			if err := r.sink.Publish(ctx, e); err != nil {
+				slog.Error(fmt.Sprintf("outbox: publishing event %d: %s", e.Id, err.Error()))
+				failed[e.UserId] = true
+				continue
+			}
+
+			published = append(published, e.Id)
+		}
+
+		return published
+	})
+}
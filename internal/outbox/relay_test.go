@@ -0,0 +1,151 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	repoModel "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// Outbox in memory
+type memoryStore struct {
+	mu     sync.Mutex
+	events []repoModel.Event
+	locked bool
+}
+
+func (s *memoryStore) Publish(ctx context.Context, limit int, publish func(events []repoModel.Event) []int64) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.locked {
+		return 0, repoModel.ErrOutboxLocked
+	}
+
+	batch := s.events[:min(limit, len(s.events))]
+
+	published := make(map[int64]bool)
+
+	for _, id := range publish(batch) {
+		published[id] = true
+	}
+
+	pending := make([]repoModel.Event, 0, len(s.events))
+
+	for _, e := range s.events {
+		if !published[e.Id] {
+			pending = append(pending, e)
+		}
+	}
+
+	s.events = pending
+
+	return len(published), nil
+}
+
+// Sink failing events of given users
+type memorySink struct {
+	mu        sync.Mutex
+	published []int64
+	failing   map[int]bool
+}
+
+func (s *memorySink) Publish(ctx context.Context, e *domain.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failing[e.UserId] {
+		return errors.New("connection refused")
+	}
+
+	s.published = append(s.published, e.Id)
+
+	return nil
+}
+
+func (s *memorySink) Close() error {
+	return nil
+}
+
+func (s *memorySink) Published() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]int64(nil), s.published...)
+}
+
+func testEvents(userIds ...int) []repoModel.Event {
+	events := make([]repoModel.Event, 0, len(userIds))
+
+	for i, userId := range userIds {
+		events = append(events, repoModel.Event{
+			Id:      int64(i + 1),
+			Type:    repoModel.EventUserUpdated,
+			UserId:  userId,
+			Payload: json.RawMessage(`{}`),
+		})
+	}
+
+	return events
+}
+
+func TestRelayFlush(t *testing.T) {
+	store := &memoryStore{events: testEvents(1, 2, 1, 3, 2)}
+	sink := &memorySink{failing: map[int]bool{2: true}}
+
+	relay := NewRelay(store, sink, WithBatchSize(4))
+
+	n, err := relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	// Events of user 2 stay in order for the next batch
+	assert.Equal(t, []int64{1, 3, 4}, sink.Published())
+	assert.Equal(t, int64(2), store.events[0].Id)
+	assert.Equal(t, int64(5), store.events[1].Id)
+
+	sink.failing = nil
+
+	n, err = relay.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []int64{1, 3, 4, 2, 5}, sink.Published())
+	assert.Empty(t, store.events)
+
+	store.locked = true
+
+	_, err = relay.Flush(context.Background())
+	assert.ErrorIs(t, err, repoModel.ErrOutboxLocked)
+}
+
+func TestRelayRun(t *testing.T) {
+	store := &memoryStore{events: testEvents(1, 2, 3, 4, 5)}
+	sink := &memorySink{}
+
+	relay := NewRelay(store, sink, WithBatchSize(2), WithInterval(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		relay.Run(ctx)
+	}()
+
+	// Full batches are published without waiting for interval
+	assert.Eventually(t, func() bool {
+		return len(sink.Published()) == 5
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+
+	assert.Equal(t, []int64{1, 2, 3, 4, 5}, sink.Published())
+}
@@ -0,0 +1,23 @@
+package outbox
+
+import (
+	"fmt"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/config"
+)
+
+// Create sink configured for outbox
+func NewSink(cfg config.Outbox) (Sink, error) {
+	switch cfg.Sink {
+	case "file":
+		return NewFileSink(cfg.FilePath)
+	case "webhook":
+		return NewWebhookSink(cfg.WebhookURL, cfg.WebhookTimeout), nil
+	case "nats":
+		return NewNATSSink(cfg.NATSURL, cfg.NATSSubject)
+	case "kafka":
+		return NewKafkaSink(cfg.KafkaBrokers, cfg.KafkaTopic), nil
+	default:
+		return nil, fmt.Errorf("outbox: unknown sink %q", cfg.Sink)
+	}
+}
@@ -0,0 +1,70 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+var testEvent = &domain.Event{
+	Id:         7,
+	Type:       domain.EventUserCreated,
+	UserId:     3,
+	Data:       json.RawMessage(`{"id":3,"name":"Ivan"}`),
+	OccurredAt: time.Date(2024, 2, 10, 10, 0, 0, 0, time.UTC),
+}
+
+const testEventJSON = `{"id":7,"type":"user.created","user_id":3,"data":{"id":3,"name":"Ivan"},"occurred_at":"2024-02-10T10:00:00Z"}`
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+
+	sink, err := NewFileSink(path)
+	assert.NoError(t, err)
+
+	assert.NoError(t, sink.Publish(context.Background(), testEvent))
+	assert.NoError(t, sink.Publish(context.Background(), testEvent))
+	assert.NoError(t, sink.Close())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat(testEventJSON+"\n", 2), string(data))
+}
+
+func TestWebhookSink(t *testing.T) {
+	status := http.StatusNoContent
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "7", r.Header.Get("X-Event-Id"))
+		assert.Equal(t, "user.created", r.Header.Get("X-Event-Type"))
+		assert.JSONEq(t, testEventJSON, string(body))
+
+		w.WriteHeader(status)
+	}))
+
+	defer server.Close()
+
+	sink := NewWebhookSink(server.URL, time.Second)
+	defer sink.Close()
+
+	assert.NoError(t, sink.Publish(context.Background(), testEvent))
+
+	status = http.StatusServiceUnavailable
+
+	assert.Error(t, sink.Publish(context.Background(), testEvent))
+}
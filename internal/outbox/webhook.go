@@ -0,0 +1,68 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+// Sink posting every event as json to url, any 2xx response acknowledges it
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, timeout time.Duration) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, e *domain.Event) error {
+	data, err := json.Marshal(e)
+
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(data))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Id", strconv.FormatInt(e.Id, 10))
+	req.Header.Set("X-Event-Type", e.Type)
+
+	resp, err := s.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	// Drain body so connection is reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (s *WebhookSink) Close() error {
+	s.client.CloseIdleConnections()
+
+	return nil
+}
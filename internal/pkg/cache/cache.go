@@ -0,0 +1,97 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU cache with per entry expiration, safe for concurrent use
+type Cache[K comparable, V any] struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	size  int
+	ll    *list.List
+	items map[K]*list.Element
+	now   func() time.Time
+}
+
+type entry[K comparable, V any] struct {
+	key       K
+	value     V
+	expiresAt time.Time
+}
+
+// Create cache holding at most size entries for ttl each
+func New[K comparable, V any](size int, ttl time.Duration) *Cache[K, V] {
+	return &Cache[K, V]{
+		ttl:   ttl,
+		size:  size,
+		ll:    list.New(),
+		items: make(map[K]*list.Element),
+		now:   time.Now,
+	}
+}
+
+// Get value if it is present and not expired
+func (c *Cache[K, V]) Get(key K) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+
+	el, ok := c.items[key]
+
+	if !ok {
+		return zero, false
+	}
+
+	e := el.Value.(*entry[K, V])
+
+	if c.now().After(e.expiresAt) {
+		c.remove(el)
+		return zero, false
+	}
+
+	c.ll.MoveToFront(el)
+
+	return e.value, true
+}
+
+// Set value, evicting the least recently used entry if cache is full
+func (c *Cache[K, V]) Set(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := c.now().Add(c.ttl)
+
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*entry[K, V])
+		e.value = value
+		e.expiresAt = expiresAt
+		c.ll.MoveToFront(el)
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&entry[K, V]{
+		key:       key,
+		value:     value,
+		expiresAt: expiresAt,
+	})
+
+	if c.ll.Len() > c.size {
+		c.remove(c.ll.Back())
+	}
+}
+
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ll.Len()
+}
+
+func (c *Cache[K, V]) remove(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry[K, V]).key)
+}
@@ -0,0 +1,35 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	now := time.Now()
+
+	c := New[string, int](2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	// Touch a, so b is the least recently used one
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	c.Set("c", 3)
+
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, c.Len())
+
+	now = now.Add(2 * time.Minute)
+
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, c.Len())
+}
@@ -2,26 +2,21 @@ package utils
 
 import (
 	"encoding/json"
-	"io"
-	"net/http"
+	"errors"
 
 	"github.com/sletkov/effective-mobile-test-task/internal/domain"
 )
 
+var ErrUnknownNationality = errors.New("nationality is unknown")
+
 // Add age to user by data from 3rd-party api response
-func Agify(ageResponse *http.Response, u *domain.User) error {
+func Agify(data []byte, u *domain.User) error {
 	var ageInfo = struct {
 		Count int    `json:"count"`
 		Name  string `json:"name"`
 		Age   int    `json:"age"`
 	}{}
 
-	data, err := io.ReadAll(ageResponse.Body)
-
-	if err != nil {
-		return err
-	}
-
 	if err := json.Unmarshal(data, &ageInfo); err != nil {
 		return err
 	}
@@ -32,7 +27,7 @@ func Agify(ageResponse *http.Response, u *domain.User) error {
 }
 
 // Add gender to user by data from 3rd-party api response
-func Genderize(genderResponse *http.Response, u *domain.User) error {
+func Genderize(data []byte, u *domain.User) error {
 	var genderInfo = struct {
 		Count       int     `json:"count"`
 		Name        string  `json:"name"`
@@ -40,12 +35,6 @@ func Genderize(genderResponse *http.Response, u *domain.User) error {
 		Probability float32 `json:"probability"`
 	}{}
 
-	data, err := io.ReadAll(genderResponse.Body)
-
-	if err != nil {
-		return err
-	}
-
 	if err := json.Unmarshal(data, &genderInfo); err != nil {
 		return err
 	}
@@ -56,7 +45,7 @@ func Genderize(genderResponse *http.Response, u *domain.User) error {
 }
 
 // Add nationality to user by data from 3rd-party api response
-func Nationalize(genderResponse *http.Response, u *domain.User) error {
+func Nationalize(data []byte, u *domain.User) error {
 	var nationalityInfo = struct {
 		Count   int    `json:"count"`
 		Name    string `json:"name"`
@@ -66,14 +55,12 @@ func Nationalize(genderResponse *http.Response, u *domain.User) error {
 		}
 	}{}
 
-	data, err := io.ReadAll(genderResponse.Body)
-
-	if err != nil {
+	if err := json.Unmarshal(data, &nationalityInfo); err != nil {
 		return err
 	}
 
-	if err := json.Unmarshal(data, &nationalityInfo); err != nil {
-		return err
+	if len(nationalityInfo.Country) == 0 {
+		return ErrUnknownNationality
 	}
 
 	// The first element always has the most probability
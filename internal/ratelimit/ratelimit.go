@@ -0,0 +1,178 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/middleware"
+	"golang.org/x/time/rate"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/auth"
+)
+
+// Buckets idle for longer than this are dropped
+const idleTimeout = 10 * time.Minute
+
+// Token bucket rate limiter per client
+type Limiter struct {
+	mu        sync.Mutex
+	rps       rate.Limit
+	burst     int
+	clients   map[string]*client
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type client struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+func New(rps float64, burst int) *Limiter {
+	return &Limiter{
+		rps:     rate.Limit(rps),
+		burst:   burst,
+		clients: make(map[string]*client),
+		now:     time.Now,
+	}
+}
+
+// Take a token for client, returns false and time to wait if bucket is empty
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	if now.Sub(l.lastSweep) > idleTimeout {
+		l.sweep(now)
+	}
+
+	c, ok := l.clients[key]
+
+	if !ok {
+		c = &client{limiter: rate.NewLimiter(l.rps, l.burst)}
+		l.clients[key] = c
+	}
+
+	c.lastSeen = now
+
+	r := c.limiter.ReserveN(now, 1)
+
+	if !r.OK() {
+		return false, 0
+	}
+
+	if delay := r.DelayFrom(now); delay > 0 {
+		// Don't consume the token the client isn't going to wait for
+		r.CancelAt(now)
+		return false, delay
+	}
+
+	return true, 0
+}
+
+// Check if client has a token without taking it, returns time to wait if it has none
+func (l *Limiter) Peek(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.clients[key]
+
+	if !ok {
+		return true, 0
+	}
+
+	tokens := c.limiter.TokensAt(l.now())
+
+	if tokens >= 1 {
+		return true, 0
+	}
+
+	if l.rps <= 0 {
+		return false, 0
+	}
+
+	return false, time.Duration((1 - tokens) / float64(l.rps) * float64(time.Second))
+}
+
+func (l *Limiter) sweep(now time.Time) {
+	for key, c := range l.clients {
+		if now.Sub(c.lastSeen) > idleTimeout {
+			delete(l.clients, key)
+		}
+	}
+
+	l.lastSweep = now
+}
+
+// Limit requests per authenticated principal, or per client ip for anonymous requests
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := clientKey(r)
+
+		ok, retryAfter := l.Allow(key)
+
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(l.burst))
+
+		if !ok {
+			slog.Warn(fmt.Sprintf("ratelimit: %s exceeded rate limit", key))
+			tooManyRequests(w, retryAfter)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Limit failed authentications per client ip, it goes before authentication.
+// Only responses with 401 status take tokens, so guessing credentials is throttled
+// while authenticated clients sharing an ip are limited by their own buckets.
+func (l *Limiter) FailedAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := ipKey(r)
+
+		if ok, retryAfter := l.Peek(key); !ok {
+			slog.Warn(fmt.Sprintf("ratelimit: %s exceeded failed authentications limit", key))
+			tooManyRequests(w, retryAfter)
+			return
+		}
+
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		if ww.Status() == http.StatusUnauthorized {
+			l.Allow(key)
+		}
+	})
+}
+
+func tooManyRequests(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	w.WriteHeader(http.StatusTooManyRequests)
+}
+
+func clientKey(r *http.Request) string {
+	if p := auth.PrincipalFromContext(r.Context()); p != nil {
+		return p.String()
+	}
+
+	return ipKey(r)
+}
+
+func ipKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return "ip:" + r.RemoteAddr
+	}
+
+	return "ip:" + host
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/auth"
+)
+
+func TestLimiterMiddleware(t *testing.T) {
+	now := time.Now()
+
+	l := New(1, 2)
+	l.now = func() time.Time { return now }
+
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	do := func(remoteAddr string, p *auth.Principal) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/users", nil)
+		req.RemoteAddr = remoteAddr
+
+		if p != nil {
+			req = req.WithContext(auth.WithPrincipal(req.Context(), p))
+		}
+
+		handler.ServeHTTP(w, req)
+
+		return w
+	}
+
+	// Burst is allowed, then bucket is empty
+	assert.Equal(t, http.StatusOK, do("10.0.0.1:1000", nil).Code)
+	assert.Equal(t, http.StatusOK, do("10.0.0.1:1001", nil).Code)
+
+	w := do("10.0.0.1:1002", nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Other clients have their own buckets
+	assert.Equal(t, http.StatusOK, do("10.0.0.2:1000", nil).Code)
+	assert.Equal(t, http.StatusOK, do("10.0.0.1:1003", &auth.Principal{Subject: "key", Method: "apikey"}).Code)
+
+	// Bucket is refilled
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, do("10.0.0.1:1004", nil).Code)
+}
+
+func TestLimiterFailedAuthMiddleware(t *testing.T) {
+	now := time.Now()
+
+	l := New(1, 2)
+	l.now = func() time.Time { return now }
+
+	// Authenticates requests with "valid" key only
+	handler := l.FailedAuthMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(auth.APIKeyHeader) != "valid" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+
+	do := func(key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/users", nil)
+		req.RemoteAddr = "10.0.0.1:1000"
+		req.Header.Set(auth.APIKeyHeader, key)
+
+		handler.ServeHTTP(w, req)
+
+		return w
+	}
+
+	// Authenticated requests take no tokens
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, do("valid").Code)
+	}
+
+	// Failures take tokens until bucket is empty
+	assert.Equal(t, http.StatusUnauthorized, do("guess1").Code)
+	assert.Equal(t, http.StatusUnauthorized, do("guess2").Code)
+
+	w := do("guess3")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Even valid credentials are not checked until bucket is refilled
+	assert.Equal(t, http.StatusTooManyRequests, do("valid").Code)
+
+	now = now.Add(time.Second)
+	assert.Equal(t, http.StatusOK, do("valid").Code)
+}
@@ -0,0 +1,265 @@
+// Package emulate evaluates users queries in Go the way postgres does, for backends
+// and consumers that can't run them in postgres.
+package emulate
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// Predicate on users compiled from filter expression, nil matches every user
+type Predicate func(u *model.User) bool
+
+// Filtered fields, the same as postgres repository ones
+var filterFields = map[string]bool{
+	"name":        true,
+	"surname":     true,
+	"patronymic":  true,
+	"age":         true,
+	"gender":      true,
+	"nationality": true,
+}
+
+// Gender is an enum in postgres, it sorts in declaration order
+var genderOrder = map[string]int{
+	"male":   0,
+	"female": 1,
+}
+
+// Check if gender is a value of postgres enum
+func IsGender(gender string) bool {
+	_, ok := genderOrder[gender]
+	return ok
+}
+
+// Compile filter the way postgres repository does, with the same errors.
+// Empty patronymic is NULL, so it matches only null and negative conditions.
+func CompileFilter(e model.FilterExpr) (Predicate, error) {
+	switch e.Op {
+	case "":
+		return nil, nil
+	case model.FilterAnd, model.FilterOr:
+		parts := make([]Predicate, 0, len(e.Children))
+
+		for _, child := range e.Children {
+			part, err := CompileFilter(child)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if part != nil {
+				parts = append(parts, part)
+			}
+		}
+
+		if len(parts) == 0 {
+			return nil, nil
+		}
+
+		if e.Op == model.FilterAnd {
+			return func(u *model.User) bool {
+				for _, part := range parts {
+					if !part(u) {
+						return false
+					}
+				}
+
+				return true
+			}, nil
+		}
+
+		return func(u *model.User) bool {
+			for _, part := range parts {
+				if part(u) {
+					return true
+				}
+			}
+
+			return false
+		}, nil
+	}
+
+	if !filterFields[e.Field] {
+		return nil, fmt.Errorf("filter: unknown field %q", e.Field)
+	}
+
+	if e.Field == "age" {
+		for _, v := range e.Values {
+			if _, err := strconv.Atoi(v); err != nil {
+				return nil, fmt.Errorf("filter: age: %w", err)
+			}
+		}
+	}
+
+	field, values := e.Field, e.Values
+
+	switch e.Op {
+	case model.FilterNull:
+		return func(u *model.User) bool {
+			_, ok := fieldValue(field, u)
+			return !ok
+		}, nil
+	case model.FilterNotNull:
+		return func(u *model.User) bool {
+			_, ok := fieldValue(field, u)
+			return ok
+		}, nil
+	case model.FilterIn:
+		return func(u *model.User) bool {
+			return in(field, u, values)
+		}, nil
+	case model.FilterNotIn:
+		return func(u *model.User) bool {
+			return !in(field, u, values)
+		}, nil
+	}
+
+	if len(values) != 1 {
+		return nil, fmt.Errorf("filter: %s[%s] takes one value", field, e.Op)
+	}
+
+	value := values[0]
+
+	var matches func(v string) bool
+
+	switch e.Op {
+	case model.FilterEq:
+		matches = func(v string) bool { return Compare(field, v, value) == 0 }
+	case model.FilterNe:
+		return func(u *model.User) bool {
+			v, ok := fieldValue(field, u)
+			return !ok || Compare(field, v, value) != 0
+		}, nil
+	case model.FilterGt:
+		matches = func(v string) bool { return Compare(field, v, value) > 0 }
+	case model.FilterGte:
+		matches = func(v string) bool { return Compare(field, v, value) >= 0 }
+	case model.FilterLt:
+		matches = func(v string) bool { return Compare(field, v, value) < 0 }
+	case model.FilterLte:
+		matches = func(v string) bool { return Compare(field, v, value) <= 0 }
+	case model.FilterPrefix:
+		matches = func(v string) bool { return strings.HasPrefix(strings.ToLower(v), strings.ToLower(value)) }
+	case model.FilterContains:
+		matches = func(v string) bool { return strings.Contains(strings.ToLower(v), strings.ToLower(value)) }
+	default:
+		return nil, fmt.Errorf("filter: unknown operator %q", e.Op)
+	}
+
+	return func(u *model.User) bool {
+		v, ok := fieldValue(field, u)
+		return ok && matches(v)
+	}, nil
+}
+
+// Compile sort fields to a less function tiebroken on id, as postgres orders users
+func CompileSort(sort []model.SortField) (func(a, b *model.User) bool, error) {
+	desc := false
+
+	for _, f := range sort {
+		if !filterFields[f.Field] && f.Field != "id" {
+			return nil, fmt.Errorf("filter: unknown sort field %q", f.Field)
+		}
+
+		desc = f.Desc
+
+		if f.Field == "id" {
+			break
+		}
+	}
+
+	return func(a, b *model.User) bool {
+		for _, f := range sort {
+			if f.Field == "id" {
+				return (a.Id < b.Id) != f.Desc
+			}
+
+			if c := compareUsers(f.Field, a, b); c != 0 {
+				return (c < 0) != f.Desc
+			}
+		}
+
+		return (a.Id < b.Id) != desc
+	}, nil
+}
+
+// Compare users by field, NULLs are greater than any value. Gender sorts as text.
+func compareUsers(field string, a, b *model.User) int {
+	va, okA := fieldValue(field, a)
+	vb, okB := fieldValue(field, b)
+
+	switch {
+	case !okA && !okB:
+		return 0
+	case !okA:
+		return 1
+	case !okB:
+		return -1
+	}
+
+	if field == "gender" {
+		return strings.Compare(va, vb)
+	}
+
+	return Compare(field, va, vb)
+}
+
+// Value of filtered field, false if it is NULL
+func fieldValue(field string, u *model.User) (string, bool) {
+	switch field {
+	case "name":
+		return u.Name, true
+	case "surname":
+		return u.Surname, true
+	case "patronymic":
+		return u.Patronymic, u.Patronymic != ""
+	case "age":
+		return strconv.Itoa(u.Age), true
+	case "gender":
+		return u.Gender, true
+	case "nationality":
+		return u.Nationality, true
+	}
+
+	return "", false
+}
+
+// Compare ages as numbers, genders in enum order and the rest as strings
+func Compare(field, value, other string) int {
+	switch field {
+	case "age":
+		a, _ := strconv.Atoi(value)
+		b, _ := strconv.Atoi(other)
+
+		return a - b
+	case "gender":
+		a, okA := genderOrder[value]
+		b, okB := genderOrder[other]
+
+		if okA && okB {
+			return a - b
+		}
+	}
+
+	return strings.Compare(value, other)
+}
+
+func in(field string, u *model.User, values []string) bool {
+	v, ok := fieldValue(field, u)
+
+	if !ok {
+		return false
+	}
+
+	for _, value := range values {
+		if Compare(field, v, value) == 0 {
+			return true
+		}
+	}
+
+	return false
+}
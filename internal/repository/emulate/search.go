@@ -0,0 +1,157 @@
+package emulate
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// Rank users the way postgres search does approximately, for databases without
+// trigram and full-text search. User matches if every term is a prefix of a name word
+// or the query is similar enough to names by trigrams, rank is word similarity.
+// Best matches go first up to limit.
+func Rank(s *model.UserSearch, users []model.User) []model.UserMatch {
+	terms := s.Terms()
+	query := trigrams(model.Fold(s.Query))
+
+	matches := make([]model.UserMatch, 0)
+
+	for _, u := range users {
+		text := model.Fold(u.Name + " " + u.Surname + " " + u.Patronymic)
+		rank := wordSimilarity(query, text)
+
+		if !prefixesWords(terms, words(text)) && rank < s.Threshold {
+			continue
+		}
+
+		// The field most similar to the query is highlighted
+		fields := [3]struct{ name, value string }{
+			{"name", u.Name},
+			{"surname", u.Surname},
+			{"patronymic", u.Patronymic},
+		}
+
+		best, bestSimilarity := 0, -1.0
+
+		for i, field := range fields {
+			if similarity := wordSimilarity(query, model.Fold(field.value)); similarity > bestSimilarity {
+				best, bestSimilarity = i, similarity
+			}
+		}
+
+		matches = append(matches, model.UserMatch{
+			User:         u,
+			Rank:         rank,
+			MatchedField: fields[best].name,
+			Highlight:    model.Highlight(fields[best].value, terms),
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Rank != matches[j].Rank {
+			return matches[i].Rank > matches[j].Rank
+		}
+
+		return matches[i].Id < matches[j].Id
+	})
+
+	if len(matches) > s.Limit {
+		matches = matches[:s.Limit]
+	}
+
+	return matches
+}
+
+// Words of folded text, split the same way as search terms
+func words(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Check if every term is a prefix of some word, no terms match nothing
+func prefixesWords(terms, words []string) bool {
+	if len(terms) == 0 {
+		return false
+	}
+
+	for _, term := range terms {
+		found := false
+
+		for _, w := range words {
+			if strings.HasPrefix(w, term) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Trigrams of words padded like pg_trgm does
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+
+	for _, w := range words(s) {
+		runes := []rune("  " + w + " ")
+
+		for i := 0; i+3 <= len(runes); i++ {
+			set[string(runes[i:i+3])] = struct{}{}
+		}
+	}
+
+	return set
+}
+
+// Share of query trigrams found in text, as pg_trgm word_similarity
+// for the extent of text matching the query best
+func wordSimilarity(query map[string]struct{}, text string) float64 {
+	if len(query) == 0 {
+		return 0
+	}
+
+	common := 0
+
+	for t := range trigrams(text) {
+		if _, ok := query[t]; ok {
+			common++
+		}
+	}
+
+	return float64(common) / float64(len(query))
+}
+
+// Patterns of LIKE matching text of folded names, each surrounded with spaces,
+// if a word of the names has a trigram of the query. Sum of their matches is the number
+// of common trigrams, so databases can rank users the same way without trigram search.
+func TrigramPatterns(query string) []string {
+	set := trigrams(model.Fold(query))
+	patterns := make([]string, 0, len(set))
+
+	for t := range set {
+		// Word start is padded with a single space in the text
+		patterns = append(patterns, "%"+strings.Replace(t, "  ", " ", 1)+"%")
+	}
+
+	sort.Strings(patterns)
+
+	return patterns
+}
+
+// Patterns of LIKE matching the same text if term is a prefix of some word
+func PrefixPatterns(terms []string) []string {
+	patterns := make([]string, 0, len(terms))
+
+	for _, term := range terms {
+		patterns = append(patterns, "% "+term+"%")
+	}
+
+	return patterns
+}
@@ -0,0 +1,57 @@
+package emulate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+func TestRank(t *testing.T) {
+	users := []model.User{
+		{Id: 1, Name: "Ivan", Surname: "Ivanov"},
+		{Id: 2, Name: "Petr", Surname: "Petrov", Patronymic: "Ivanovich"},
+		{Id: 3, Name: "Anna", Surname: "Smirnova"},
+	}
+
+	matches := Rank(&model.UserSearch{Query: "ivan", Threshold: 0.3, Limit: 10}, users)
+
+	if assert.Len(t, matches, 2) {
+		assert.Equal(t, 1, matches[0].Id)
+		assert.Equal(t, "name", matches[0].MatchedField)
+		assert.Equal(t, "<mark>Ivan</mark>", matches[0].Highlight)
+		assert.Equal(t, 2, matches[1].Id)
+		assert.Equal(t, "patronymic", matches[1].MatchedField)
+	}
+
+	// Approximate match
+	matches = Rank(&model.UserSearch{Query: "Smirnva", Threshold: 0.3, Limit: 10}, users)
+
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, 3, matches[0].Id)
+	}
+}
+
+func TestTrigramPatterns(t *testing.T) {
+	assert.Equal(t, []string{"% i%", "% iv%", "%an %", "%iva%", "%van%"}, TrigramPatterns("Ivan"))
+	assert.Equal(t, []string{"% iv%", "% ivo%"}, PrefixPatterns([]string{"iv", "ivo"}))
+
+	// Matched patterns are common trigrams
+	for _, query := range []string{"ivan", "ivanov petr", "a"} {
+		for _, names := range []string{"Ivan Ivanov", "Petr Petrov Ivanovich", "Anna A"} {
+			text := " " + strings.Join(strings.Fields(model.Fold(names)), " ") + " "
+			common := 0
+
+			for _, pattern := range TrigramPatterns(query) {
+				if strings.Contains(text, strings.Trim(pattern, "%")) {
+					common++
+				}
+			}
+
+			q := trigrams(model.Fold(query))
+			assert.InDelta(t, wordSimilarity(q, model.Fold(names)), float64(common)/float64(len(q)), 1e-9, "%s in %s", query, names)
+		}
+	}
+}
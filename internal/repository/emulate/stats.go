@@ -0,0 +1,45 @@
+package emulate
+
+import (
+	"math"
+	"sort"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// Count, average and percentiles of ages the way stats query computes them,
+// for databases without percentile_cont. Zeros for no ages.
+func AggregateAges(ages []int) model.UserStats {
+	s := model.UserStats{Count: len(ages)}
+
+	if len(ages) == 0 {
+		return s
+	}
+
+	sort.Ints(ages)
+
+	sum := 0
+
+	for _, age := range ages {
+		sum += age
+	}
+
+	s.AvgAge = float64(sum) / float64(len(ages))
+	s.AgeP50 = percentile(ages, 0.5)
+	s.AgeP90 = percentile(ages, 0.9)
+	s.AgeP99 = percentile(ages, 0.99)
+
+	return s
+}
+
+// Continuous percentile of sorted values interpolated between
+// the nearest ones, as postgres percentile_cont
+func percentile(sorted []int, p float64) float64 {
+	position := p * float64(len(sorted)-1)
+	lower := math.Floor(position)
+	upper := math.Ceil(position)
+
+	value := float64(sorted[int(lower)])
+
+	return value + (position-lower)*(float64(sorted[int(upper)])-value)
+}
@@ -0,0 +1,45 @@
+package emulate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+func TestAggregateAges(t *testing.T) {
+	testCases := []struct {
+		name     string
+		ages     []int
+		expected model.UserStats
+	}{
+		{
+			name: "no ages",
+		},
+
+		{
+			name:     "single age",
+			ages:     []int{30},
+			expected: model.UserStats{Count: 1, AvgAge: 30, AgeP50: 30, AgeP90: 30, AgeP99: 30},
+		},
+
+		{
+			name:     "interpolated",
+			ages:     []int{40, 20},
+			expected: model.UserStats{Count: 2, AvgAge: 30, AgeP50: 30, AgeP90: 38, AgeP99: 39.8},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := AggregateAges(tc.ages)
+
+			assert.Equal(t, tc.expected.Count, s.Count)
+			assert.InDelta(t, tc.expected.AvgAge, s.AvgAge, 1e-9)
+			assert.InDelta(t, tc.expected.AgeP50, s.AgeP50, 1e-9)
+			assert.InDelta(t, tc.expected.AgeP90, s.AgeP90, 1e-9)
+			assert.InDelta(t, tc.expected.AgeP99, s.AgeP99, 1e-9)
+		})
+	}
+}
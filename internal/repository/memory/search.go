@@ -0,0 +1,21 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/emulate"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// Search users by names, see emulate.Rank for how they are matched
+func (r *UserRepository) Search(ctx context.Context, userSearch *model.UserSearch) ([]model.UserMatch, error) {
+	slog.Info("memory: searching users")
+
+	matches := emulate.Rank(userSearch, r.filter(nil))
+
+	slog.Info(fmt.Sprintf("memory: %d users were found", len(matches)))
+
+	return matches, nil
+}
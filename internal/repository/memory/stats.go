@@ -0,0 +1,108 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/emulate"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// Values users are grouped by, unused ones are zero
+type statsGroup struct {
+	gender      string
+	nationality string
+	ageBucket   int
+}
+
+// Count users and their ages by groups, groups are ordered as postgres orders them
+func (r *UserRepository) Stats(ctx context.Context, statsRequest *model.UserStatsRequest) ([]model.UserStats, error) {
+	slog.Info("memory: getting users stats")
+
+	match, err := emulate.CompileFilter(statsRequest.Expr)
+
+	if err != nil {
+		return nil, fmt.Errorf("memory: getting users stats: %w", err)
+	}
+
+	for _, group := range statsRequest.GroupBy {
+		switch group {
+		case "gender", "nationality":
+		case "age_bucket":
+			if statsRequest.AgeBucket <= 0 {
+				return nil, fmt.Errorf("memory: getting users stats: stats: invalid age bucket %d", statsRequest.AgeBucket)
+			}
+		default:
+			return nil, fmt.Errorf("memory: getting users stats: stats: unknown group %q", group)
+		}
+	}
+
+	groups := make(map[statsGroup][]int)
+
+	for _, u := range r.filter(match) {
+		var group statsGroup
+
+		for _, by := range statsRequest.GroupBy {
+			switch by {
+			case "gender":
+				group.gender = u.Gender
+			case "nationality":
+				group.nationality = u.Nationality
+			case "age_bucket":
+				group.ageBucket = u.Age / statsRequest.AgeBucket * statsRequest.AgeBucket
+			}
+		}
+
+		groups[group] = append(groups[group], u.Age)
+	}
+
+	// Aggregate without groups is a single row even for no users
+	if len(statsRequest.GroupBy) == 0 && len(groups) == 0 {
+		groups[statsGroup{}] = nil
+	}
+
+	stats := make([]model.UserStats, 0, len(groups))
+
+	for group, ages := range groups {
+		s := emulate.AggregateAges(ages)
+		s.Gender, s.Nationality = group.gender, group.nationality
+
+		for _, by := range statsRequest.GroupBy {
+			if by == "age_bucket" {
+				s.AgeBucket = &model.AgeBucket{
+					From: group.ageBucket,
+					To:   group.ageBucket + statsRequest.AgeBucket - 1,
+				}
+			}
+		}
+
+		stats = append(stats, s)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		for _, group := range statsRequest.GroupBy {
+			var c int
+
+			switch group {
+			case "gender":
+				c = emulate.Compare("gender", stats[i].Gender, stats[j].Gender)
+			case "nationality":
+				c = emulate.Compare("nationality", stats[i].Nationality, stats[j].Nationality)
+			case "age_bucket":
+				c = stats[i].AgeBucket.From - stats[j].AgeBucket.From
+			}
+
+			if c != 0 {
+				return c < 0
+			}
+		}
+
+		return false
+	})
+
+	slog.Info("memory: users stats were got successfully")
+
+	return stats, nil
+}
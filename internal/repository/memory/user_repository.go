@@ -0,0 +1,306 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/emulate"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// Database driver value selecting in-memory repository
+const Driver = "memory"
+
+var (
+	lettersRegexp     = regexp.MustCompile(`^[A-Za-z]+$`)
+	nationalityRegexp = regexp.MustCompile(`^[A-Za-z]{2}$`)
+)
+
+// UserRepository keeps users in memory, they are lost on exit. It behaves
+// like postgres repository including constraints of users table, except that
+// text is compared byte-wise and search is an approximation of postgres one.
+type UserRepository struct {
+	mu     sync.RWMutex
+	users  map[int]model.User
+	nextId int
+}
+
+func New() *UserRepository {
+	return &UserRepository{
+		users:  make(map[int]model.User),
+		nextId: 1,
+	}
+}
+
+// Get all users with filters and limit
+func (r *UserRepository) Get(ctx context.Context, userFilter *model.UserFilter) ([]model.User, error) {
+	slog.Info("memory: getting users")
+
+	selected, err := r.selectUsers(userFilter)
+
+	if err != nil {
+		return nil, fmt.Errorf("memory: getting users: %w", err)
+	}
+
+	// Nil without users as postgres repository returns
+	var users []model.User
+
+	users = append(users, selected...)
+
+	slog.Info("memory: users were got successfully")
+
+	return users, nil
+}
+
+// Call fn for every user matching filter, fn stops the export by returning an error.
+// Users are exported from a snapshot, changes made meanwhile are not seen.
+func (r *UserRepository) Export(ctx context.Context, userFilter *model.UserFilter, fn func(u *model.User) error) error {
+	slog.Info("memory: exporting users")
+
+	users, err := r.selectUsers(userFilter)
+
+	if err != nil {
+		return fmt.Errorf("memory: exporting users: %w", err)
+	}
+
+	for i := range users {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("memory: exporting users: %w", err)
+		}
+
+		if err := fn(&users[i]); err != nil {
+			return fmt.Errorf("memory: exporting users: %w", err)
+		}
+	}
+
+	slog.Info(fmt.Sprintf("memory: %d users were exported successfully", len(users)))
+
+	return nil
+}
+
+// Delete user by id
+func (r *UserRepository) Delete(ctx context.Context, id int) error {
+	slog.Info(fmt.Sprintf("memory: deleting user %d", id))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return fmt.Errorf("memory: deleting user %d: %w", id, model.ErrUserNotFound)
+	}
+
+	delete(r.users, id)
+
+	slog.Info(fmt.Sprintf("memory: user %d was deleted successfully", id))
+
+	return nil
+}
+
+// Update user
+func (r *UserRepository) Update(ctx context.Context, id int, u *model.User) error {
+	slog.Info(fmt.Sprintf("memory: updating user %d", id))
+
+	if err := check(u); err != nil {
+		return fmt.Errorf("memory: updating user %d: %w", id, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.users[id]; !ok {
+		return fmt.Errorf("memory: updating user %d: %w", id, model.ErrUserNotFound)
+	}
+
+	user := *u
+	user.Id = id
+	r.users[id] = user
+
+	slog.Info(fmt.Sprintf("memory: user %d was updated successfully", id))
+
+	return nil
+}
+
+// Create new user
+func (r *UserRepository) Create(ctx context.Context, u *model.User) (int, error) {
+	slog.Info("memory: creating user")
+
+	if err := check(u); err != nil {
+		return 0, fmt.Errorf("memory: creating user: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := r.insert(*u)
+
+	slog.Info(fmt.Sprintf("memory: user %d was created successfully", id))
+
+	return id, nil
+}
+
+// Insert users, all of them or none. Returns imported users with their ids.
+func (r *UserRepository) Import(ctx context.Context, users []model.User) ([]model.User, error) {
+	slog.Info(fmt.Sprintf("memory: importing %d users", len(users)))
+
+	for i := range users {
+		if err := check(&users[i]); err != nil {
+			return nil, fmt.Errorf("memory: importing users: %w", err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	imported := make([]model.User, len(users))
+
+	for i, u := range users {
+		u.Id = r.insert(u)
+		imported[i] = u
+	}
+
+	slog.Info(fmt.Sprintf("memory: %d users were imported successfully", len(users)))
+
+	return imported, nil
+}
+
+// Get user by id, only given fields if any
+func (r *UserRepository) GetUserById(ctx context.Context, id int, fields ...string) (*model.User, error) {
+	slog.Info(fmt.Sprintf("memory: getting user %d", id))
+
+	columns, err := model.Columns(fields)
+
+	if err != nil {
+		return nil, fmt.Errorf("memory: getting user %d: %w", id, err)
+	}
+
+	r.mu.RLock()
+	user, ok := r.users[id]
+	r.mu.RUnlock()
+
+	if !ok {
+		return nil, model.ErrUserNotFound
+	}
+
+	user = project(user, columns)
+
+	slog.Debug(fmt.Sprintf("memory: user %d was got successfully", id))
+
+	return &user, nil
+}
+
+// Insert user with next id, lock must be held
+func (r *UserRepository) insert(u model.User) int {
+	u.Id = r.nextId
+	r.users[u.Id] = u
+	r.nextId++
+
+	return u.Id
+}
+
+// Filtered, sorted and paginated users with requested fields only
+func (r *UserRepository) selectUsers(userFilter *model.UserFilter) ([]model.User, error) {
+	match, err := emulate.CompileFilter(userFilter.Expr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	less, err := emulate.CompileSort(userFilter.Sort)
+
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := model.Columns(userFilter.Fields)
+
+	if err != nil {
+		return nil, err
+	}
+
+	users := r.filter(match)
+
+	sort.Slice(users, func(i, j int) bool {
+		return less(&users[i], &users[j])
+	})
+
+	if userFilter.Offset > 0 {
+		users = users[min(userFilter.Offset, len(users)):]
+	}
+
+	if userFilter.Limit > 0 {
+		users = users[:min(userFilter.Limit, len(users))]
+	}
+
+	for i := range users {
+		users[i] = project(users[i], columns)
+	}
+
+	return users, nil
+}
+
+// Copies of users matching predicate, all of them if it is nil
+func (r *UserRepository) filter(match emulate.Predicate) []model.User {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := make([]model.User, 0, len(r.users))
+
+	for _, u := range r.users {
+		if match == nil || match(&u) {
+			users = append(users, u)
+		}
+	}
+
+	return users
+}
+
+// User with only given columns set
+func project(u model.User, columns []string) model.User {
+	var p model.User
+
+	for _, column := range columns {
+		switch column {
+		case "id":
+			p.Id = u.Id
+		case "name":
+			p.Name = u.Name
+		case "surname":
+			p.Surname = u.Surname
+		case "patronymic":
+			p.Patronymic = u.Patronymic
+		case "age":
+			p.Age = u.Age
+		case "gender":
+			p.Gender = u.Gender
+		case "nationality":
+			p.Nationality = u.Nationality
+		}
+	}
+
+	return p
+}
+
+// Check constraints of users table
+func check(u *model.User) error {
+	switch {
+	case !lettersRegexp.MatchString(u.Name):
+		return errors.New("invalid name")
+	case !lettersRegexp.MatchString(u.Surname):
+		return errors.New("invalid surname")
+	case u.Patronymic != "" && !lettersRegexp.MatchString(u.Patronymic):
+		return errors.New("invalid patronymic")
+	case u.Age < 1 || u.Age > 100:
+		return errors.New("invalid age")
+	case !emulate.IsGender(u.Gender):
+		return errors.New("invalid gender")
+	case !nationalityRegexp.MatchString(u.Nationality):
+		return errors.New("invalid nationality")
+	}
+
+	return nil
+}
@@ -0,0 +1,14 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/repotest"
+	"github.com/sletkov/effective-mobile-test-task/internal/service"
+)
+
+func TestUserRepository(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) service.UserRepository {
+		return New()
+	})
+}
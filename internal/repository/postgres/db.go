@@ -0,0 +1,58 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	_ "github.com/lib/pq"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/config"
+)
+
+const (
+	DriverPQ  = "postgres"
+	DriverPGX = "pgx"
+)
+
+// Open database with configured driver and pool settings
+func Open(cfg config.Database) (*sql.DB, error) {
+	var db *sql.DB
+
+	switch cfg.Driver {
+	case DriverPQ, "":
+		var err error
+
+		db, err = sql.Open(DriverPQ, cfg.URL)
+
+		if err != nil {
+			return nil, fmt.Errorf("postgres: opening db: %w", err)
+		}
+	case DriverPGX:
+		connConfig, err := pgx.ParseConfig(cfg.URL)
+
+		if err != nil {
+			return nil, fmt.Errorf("postgres: parsing db url: %w", err)
+		}
+
+		connConfig.StatementCacheCapacity = cfg.StatementCacheCapacity
+
+		// Without statement cache every query is sent with extended protocol
+		// and prepared anonymously
+		if cfg.StatementCacheCapacity == 0 {
+			connConfig.DefaultQueryExecMode = pgx.QueryExecModeExec
+		}
+
+		db = stdlib.OpenDB(*connConfig)
+	default:
+		return nil, fmt.Errorf("postgres: unknown driver %q", cfg.Driver)
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return db, nil
+}
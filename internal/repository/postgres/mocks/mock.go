@@ -64,6 +64,20 @@ func (mr *MockUserRepositoryMockRecorder) Delete(ctx, id interface{}) *gomock.Ca
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserRepository)(nil).Delete), ctx, id)
 }
 
+// Export mocks base method.
+func (m *MockUserRepository) Export(ctx context.Context, userFilter *model.UserFilter, fn func(*model.User) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", ctx, userFilter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockUserRepositoryMockRecorder) Export(ctx, userFilter, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockUserRepository)(nil).Export), ctx, userFilter, fn)
+}
+
 // Get mocks base method.
 func (m *MockUserRepository) Get(ctx context.Context, userFilter *model.UserFilter) ([]model.User, error) {
 	m.ctrl.T.Helper()
@@ -80,18 +94,68 @@ func (mr *MockUserRepositoryMockRecorder) Get(ctx, userFilter interface{}) *gomo
 }
 
 // GetUserById mocks base method.
-func (m *MockUserRepository) GetUserById(ctx context.Context, id int) (*model.User, error) {
+func (m *MockUserRepository) GetUserById(ctx context.Context, id int, fields ...string) (*model.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetUserById", ctx, id)
+	varargs := []interface{}{ctx, id}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetUserById", varargs...)
 	ret0, _ := ret[0].(*model.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetUserById indicates an expected call of GetUserById.
-func (mr *MockUserRepositoryMockRecorder) GetUserById(ctx, id interface{}) *gomock.Call {
+func (mr *MockUserRepositoryMockRecorder) GetUserById(ctx, id interface{}, fields ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, id}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserById", reflect.TypeOf((*MockUserRepository)(nil).GetUserById), varargs...)
+}
+
+// Import mocks base method.
+func (m *MockUserRepository) Import(ctx context.Context, users []model.User) ([]model.User, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", ctx, users)
+	ret0, _ := ret[0].([]model.User)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Import indicates an expected call of Import.
+func (mr *MockUserRepositoryMockRecorder) Import(ctx, users interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockUserRepository)(nil).Import), ctx, users)
+}
+
+// Search mocks base method.
+func (m *MockUserRepository) Search(ctx context.Context, userSearch *model.UserSearch) ([]model.UserMatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, userSearch)
+	ret0, _ := ret[0].([]model.UserMatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockUserRepositoryMockRecorder) Search(ctx, userSearch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockUserRepository)(nil).Search), ctx, userSearch)
+}
+
+// Stats mocks base method.
+func (m *MockUserRepository) Stats(ctx context.Context, statsRequest *model.UserStatsRequest) ([]model.UserStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", ctx, statsRequest)
+	ret0, _ := ret[0].([]model.UserStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockUserRepositoryMockRecorder) Stats(ctx, statsRequest interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserById", reflect.TypeOf((*MockUserRepository)(nil).GetUserById), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockUserRepository)(nil).Stats), ctx, statsRequest)
 }
 
 // Update mocks base method.
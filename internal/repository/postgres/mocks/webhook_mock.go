@@ -0,0 +1,154 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webhook_service.go
+
+// Package mock_postgres is a generated GoMock package.
+package mock_postgres
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	model "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// MockWebhookRepository is a mock of WebhookRepository interface.
+type MockWebhookRepository struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookRepositoryMockRecorder
+}
+
+// MockWebhookRepositoryMockRecorder is the mock recorder for MockWebhookRepository.
+type MockWebhookRepositoryMockRecorder struct {
+	mock *MockWebhookRepository
+}
+
+// NewMockWebhookRepository creates a new mock instance.
+func NewMockWebhookRepository(ctrl *gomock.Controller) *MockWebhookRepository {
+	mock := &MockWebhookRepository{ctrl: ctrl}
+	mock.recorder = &MockWebhookRepositoryMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookRepository) EXPECT() *MockWebhookRepositoryMockRecorder {
+	return m.recorder
+}
+
+// CreateSubscription mocks base method.
+func (m *MockWebhookRepository) CreateSubscription(ctx context.Context, s *model.Subscription) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSubscription", ctx, s)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CreateSubscription indicates an expected call of CreateSubscription.
+func (mr *MockWebhookRepositoryMockRecorder) CreateSubscription(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSubscription", reflect.TypeOf((*MockWebhookRepository)(nil).CreateSubscription), ctx, s)
+}
+
+// DeleteSubscription mocks base method.
+func (m *MockWebhookRepository) DeleteSubscription(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSubscription", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSubscription indicates an expected call of DeleteSubscription.
+func (mr *MockWebhookRepositoryMockRecorder) DeleteSubscription(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSubscription", reflect.TypeOf((*MockWebhookRepository)(nil).DeleteSubscription), ctx, id)
+}
+
+// Enqueue mocks base method.
+func (m *MockWebhookRepository) Enqueue(ctx context.Context, eventType string, payload []byte) (int, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Enqueue", ctx, eventType, payload)
+	ret0, _ := ret[0].(int)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Enqueue indicates an expected call of Enqueue.
+func (mr *MockWebhookRepositoryMockRecorder) Enqueue(ctx, eventType, payload interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Enqueue", reflect.TypeOf((*MockWebhookRepository)(nil).Enqueue), ctx, eventType, payload)
+}
+
+// GetDeliveries mocks base method.
+func (m *MockWebhookRepository) GetDeliveries(ctx context.Context, filter *model.DeliveryFilter) ([]model.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeliveries", ctx, filter)
+	ret0, _ := ret[0].([]model.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeliveries indicates an expected call of GetDeliveries.
+func (mr *MockWebhookRepositoryMockRecorder) GetDeliveries(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeliveries", reflect.TypeOf((*MockWebhookRepository)(nil).GetDeliveries), ctx, filter)
+}
+
+// GetSubscriptionById mocks base method.
+func (m *MockWebhookRepository) GetSubscriptionById(ctx context.Context, id int) (*model.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubscriptionById", ctx, id)
+	ret0, _ := ret[0].(*model.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubscriptionById indicates an expected call of GetSubscriptionById.
+func (mr *MockWebhookRepositoryMockRecorder) GetSubscriptionById(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubscriptionById", reflect.TypeOf((*MockWebhookRepository)(nil).GetSubscriptionById), ctx, id)
+}
+
+// GetSubscriptions mocks base method.
+func (m *MockWebhookRepository) GetSubscriptions(ctx context.Context) ([]model.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubscriptions", ctx)
+	ret0, _ := ret[0].([]model.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubscriptions indicates an expected call of GetSubscriptions.
+func (mr *MockWebhookRepositoryMockRecorder) GetSubscriptions(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubscriptions", reflect.TypeOf((*MockWebhookRepository)(nil).GetSubscriptions), ctx)
+}
+
+// Redeliver mocks base method.
+func (m *MockWebhookRepository) Redeliver(ctx context.Context, subscriptionId int, id int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Redeliver", ctx, subscriptionId, id)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Redeliver indicates an expected call of Redeliver.
+func (mr *MockWebhookRepositoryMockRecorder) Redeliver(ctx, subscriptionId, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Redeliver", reflect.TypeOf((*MockWebhookRepository)(nil).Redeliver), ctx, subscriptionId, id)
+}
+
+// UpdateSubscription mocks base method.
+func (m *MockWebhookRepository) UpdateSubscription(ctx context.Context, id int, s *model.Subscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSubscription", ctx, id, s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSubscription indicates an expected call of UpdateSubscription.
+func (mr *MockWebhookRepositoryMockRecorder) UpdateSubscription(ctx, id, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSubscription", reflect.TypeOf((*MockWebhookRepository)(nil).UpdateSubscription), ctx, id, s)
+}
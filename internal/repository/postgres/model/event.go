@@ -0,0 +1,102 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+// Types of events written to outbox
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+)
+
+// Change of user read from outbox, payload is user after the change
+type Event struct {
+	Id        int64
+	Type      string
+	UserId    int
+	Payload   []byte
+	CreatedAt time.Time
+}
+
+// Columns mutations return for events and payload built of them
+const (
+	eventColumns = "id, name, surname, patronymic, age, gender, nationality"
+	eventPayload = "jsonb_build_object('id', id, 'name', name, 'surname', surname, 'patronymic', patronymic, " +
+		"'age', age, 'gender', gender, 'nationality', nationality)"
+)
+
+// Build mutation of users returning ids of changed users
+func Mutation(mutation sq.Sqlizer) (string, []interface{}, error) {
+	query, args, err := mutation.ToSql()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	query, err = sq.Dollar.ReplacePlaceholders(query + " RETURNING id")
+
+	return query, args, err
+}
+
+// Build mutation of users writing event of every changed user to outbox
+// in the same statement, so both are committed or none. Returns ids of changed users.
+func MutationWithEvent(mutation sq.Sqlizer, eventType string) (string, []interface{}, error) {
+	return mutationWithEvent(mutation, eventType, "id")
+}
+
+func mutationWithEvent(mutation sq.Sqlizer, eventType string, returning string) (string, []interface{}, error) {
+	query, args, err := mutation.ToSql()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	query = "WITH changed AS (" + query + " RETURNING " + eventColumns + "), " +
+		"event AS (INSERT INTO users_outbox (user_id, event_type, payload) SELECT id, ?, " + eventPayload + " FROM changed) " +
+		"SELECT " + returning + " FROM changed"
+
+	query, err = sq.Dollar.ReplacePlaceholders(query)
+
+	return query, append(args, eventType), err
+}
+
+// Temporary table import is copied into, users are inserted from it returning their ids
+const (
+	ImportTable       = "users_import"
+	CreateImportTable = "CREATE TEMP TABLE " + ImportTable + " ON COMMIT DROP AS " +
+		"SELECT name, surname, patronymic, age, gender, nationality FROM users WITH NO DATA"
+)
+
+// Insert users copied into import table
+func InsertImported() sq.InsertBuilder {
+	return sq.
+		Insert("users").
+		Columns(ImportColumns...).
+		Select(sq.Select(ImportColumns...).From(ImportTable))
+}
+
+// Build insert of users copied into import table returning all their columns,
+// writing their events to outbox if enabled
+func ImportMutation(outbox bool) (string, []interface{}, error) {
+	if outbox {
+		return mutationWithEvent(InsertImported(), EventUserCreated, eventColumns)
+	}
+
+	query, args, err := InsertImported().ToSql()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	query, err = sq.Dollar.ReplacePlaceholders(query + " RETURNING " + eventColumns)
+
+	return query, args, err
+}
+
+// Another relay is publishing outbox
+var ErrOutboxLocked = errors.New("outbox is locked")
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"strings"
+	"testing"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMutation(t *testing.T) {
+	update := sq.Update("users").Set("age", 30).Where(sq.Eq{"id": 1})
+
+	query, args, err := Mutation(update)
+	assert.NoError(t, err)
+	assert.Equal(t, "UPDATE users SET age = $1 WHERE id = $2 RETURNING id", query)
+	assert.Equal(t, []interface{}{30, 1}, args)
+
+	query, args, err = MutationWithEvent(update, EventUserUpdated)
+	assert.NoError(t, err)
+	assert.Equal(t, "WITH changed AS (UPDATE users SET age = $1 WHERE id = $2 RETURNING "+eventColumns+"), "+
+		"event AS (INSERT INTO users_outbox (user_id, event_type, payload) SELECT id, $3, "+eventPayload+" FROM changed) "+
+		"SELECT id FROM changed", query)
+	assert.Equal(t, []interface{}{30, 1, EventUserUpdated}, args)
+
+	query, _, err = MutationWithEvent(InsertImported(), EventUserCreated)
+	assert.NoError(t, err)
+	assert.Contains(t, query, "WITH changed AS (INSERT INTO users (name,surname,patronymic,age,gender,nationality) "+
+		"SELECT name, surname, patronymic, age, gender, nationality FROM users_import RETURNING "+eventColumns+")")
+}
+
+func TestImportMutation(t *testing.T) {
+	query, _, err := ImportMutation(false)
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO users (name,surname,patronymic,age,gender,nationality) "+
+		"SELECT name, surname, patronymic, age, gender, nationality FROM users_import RETURNING "+eventColumns, query)
+
+	// Imported users are returned rather than their ids
+	query, args, err := ImportMutation(true)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasSuffix(query, "SELECT "+eventColumns+" FROM changed"), query)
+	assert.Equal(t, []interface{}{EventUserCreated}, args)
+}
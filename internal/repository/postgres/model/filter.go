@@ -0,0 +1,271 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+type FilterOp string
+
+const (
+	FilterAnd FilterOp = "and"
+	FilterOr  FilterOp = "or"
+
+	FilterEq       FilterOp = "eq"
+	FilterNe       FilterOp = "ne"
+	FilterIn       FilterOp = "in"
+	FilterNotIn    FilterOp = "nin"
+	FilterPrefix   FilterOp = "prefix"
+	FilterContains FilterOp = "contains"
+	FilterGt       FilterOp = "gt"
+	FilterGte      FilterOp = "gte"
+	FilterLt       FilterOp = "lt"
+	FilterLte      FilterOp = "lte"
+	FilterNull     FilterOp = "null"
+	FilterNotNull  FilterOp = "notnull"
+)
+
+// Node of filter expression tree
+type FilterExpr struct {
+	Op       FilterOp
+	Field    string
+	Values   []string
+	Children []FilterExpr
+}
+
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+type UserFilter struct {
+	Expr   FilterExpr
+	Sort   []SortField
+	Fields []string
+	Limit  int
+	// Users to skip before the first one
+	Offset int
+}
+
+// Filtered fields and their columns
+var filterColumns = map[string]string{
+	"name":        "name",
+	"surname":     "surname",
+	"patronymic":  "patronymic",
+	"age":         "age",
+	"gender":      "gender",
+	"nationality": "nationality",
+}
+
+// Columns that may be NULL, negative conditions match NULL in them
+var nullableColumns = map[string]bool{
+	"patronymic": true,
+}
+
+// Sortable fields and their columns. Gender enum sorts as text like in databases without enums.
+var sortColumns = map[string]string{
+	"id":          "id",
+	"name":        "name",
+	"surname":     "surname",
+	"patronymic":  "patronymic",
+	"age":         "age",
+	"gender":      "gender::text",
+	"nationality": "nationality",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Compile filter to parameterized WHERE expression, nil if filter matches everything
+func (u *UserFilter) Where() (sq.Sqlizer, error) {
+	return compileFilter(u.Expr)
+}
+
+// Build query selecting filtered users, without limit if it is zero
+func (u *UserFilter) ToSql() (string, []interface{}, error) {
+	where, err := u.Where()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	orderBy, err := u.OrderBy()
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	columns, err := Columns(u.Fields)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	builder := sq.
+		Select(columns...).
+		From("users").
+		OrderBy(orderBy...).
+		PlaceholderFormat(sq.Dollar)
+
+	if where != nil {
+		builder = builder.Where(where)
+	}
+
+	if u.Limit > 0 {
+		builder = builder.Limit(uint64(u.Limit))
+	}
+
+	if u.Offset > 0 {
+		builder = builder.Offset(uint64(u.Offset))
+	}
+
+	return builder.ToSql()
+}
+
+// ORDER BY columns tiebroken on id so order is stable between pages.
+// Tiebreaker follows direction of the last column, so indexes on (column, id)
+// can be scanned either way.
+func (u *UserFilter) OrderBy() ([]string, error) {
+	orderBy := make([]string, 0, len(u.Sort)+1)
+	desc := false
+
+	for _, f := range u.Sort {
+		column, ok := sortColumns[f.Field]
+
+		if !ok {
+			return nil, fmt.Errorf("filter: unknown sort field %q", f.Field)
+		}
+
+		desc = f.Desc
+
+		if desc {
+			column += " DESC"
+		}
+
+		orderBy = append(orderBy, column)
+
+		// Ids are unique, nothing to tiebreak
+		if f.Field == "id" {
+			return orderBy, nil
+		}
+	}
+
+	if desc {
+		return append(orderBy, "id DESC"), nil
+	}
+
+	return append(orderBy, "id"), nil
+}
+
+func compileFilter(e FilterExpr) (sq.Sqlizer, error) {
+	switch e.Op {
+	case "":
+		return nil, nil
+	case FilterAnd, FilterOr:
+		parts := make([]sq.Sqlizer, 0, len(e.Children))
+
+		for _, child := range e.Children {
+			part, err := compileFilter(child)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if part != nil {
+				parts = append(parts, part)
+			}
+		}
+
+		if len(parts) == 0 {
+			return nil, nil
+		}
+
+		if e.Op == FilterAnd {
+			return sq.And(parts), nil
+		}
+
+		return sq.Or(parts), nil
+	}
+
+	column, ok := filterColumns[e.Field]
+
+	if !ok {
+		return nil, fmt.Errorf("filter: unknown field %q", e.Field)
+	}
+
+	values, err := filterValues(e.Field, e.Values)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Op {
+	case FilterNull:
+		return sq.Eq{column: nil}, nil
+	case FilterNotNull:
+		return sq.NotEq{column: nil}, nil
+	case FilterIn:
+		return sq.Eq{column: values}, nil
+	case FilterNotIn:
+		if nullableColumns[column] {
+			return sq.Or{sq.NotEq{column: values}, sq.Eq{column: nil}}, nil
+		}
+
+		return sq.NotEq{column: values}, nil
+	}
+
+	if len(values) != 1 {
+		return nil, fmt.Errorf("filter: %s[%s] takes one value", e.Field, e.Op)
+	}
+
+	value := values[0]
+
+	switch e.Op {
+	case FilterEq:
+		return sq.Eq{column: value}, nil
+	case FilterNe:
+		if nullableColumns[column] {
+			return sq.Expr(column+" IS DISTINCT FROM ?", value), nil
+		}
+
+		return sq.NotEq{column: value}, nil
+	case FilterGt:
+		return sq.Gt{column: value}, nil
+	case FilterGte:
+		return sq.GtOrEq{column: value}, nil
+	case FilterLt:
+		return sq.Lt{column: value}, nil
+	case FilterLte:
+		return sq.LtOrEq{column: value}, nil
+	case FilterPrefix:
+		return sq.ILike{column: likeEscaper.Replace(e.Values[0]) + "%"}, nil
+	case FilterContains:
+		return sq.ILike{column: "%" + likeEscaper.Replace(e.Values[0]) + "%"}, nil
+	default:
+		return nil, fmt.Errorf("filter: unknown operator %q", e.Op)
+	}
+}
+
+// Values typed as their column
+func filterValues(field string, values []string) ([]interface{}, error) {
+	typed := make([]interface{}, 0, len(values))
+
+	for _, v := range values {
+		if field != "age" {
+			typed = append(typed, v)
+			continue
+		}
+
+		age, err := strconv.Atoi(v)
+
+		if err != nil {
+			return nil, fmt.Errorf("filter: age: %w", err)
+		}
+
+		typed = append(typed, age)
+	}
+
+	return typed, nil
+}
@@ -0,0 +1,121 @@
+package model
+
+import (
+	"html"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+type UserSearch struct {
+	Query     string
+	Threshold float64
+	Limit     int
+}
+
+type UserMatch struct {
+	User
+	Rank         float64
+	MatchedField string
+	Highlight    string
+}
+
+// Lower case string without diacritics, the same way search columns are built
+func Fold(s string) string {
+	var b strings.Builder
+
+	for _, r := range norm.NFD.String(s) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+
+	return b.String()
+}
+
+// Folded words of the search query
+func (s *UserSearch) Terms() []string {
+	return strings.FieldsFunc(Fold(s.Query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Full-text query matching all terms by prefix, terms are letters and
+// digits only so they can't break to_tsquery syntax
+func (s *UserSearch) TSQuery() string {
+	terms := s.Terms()
+
+	for i, term := range terms {
+		terms[i] = term + ":*"
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+// Wrap parts of value matching terms in <mark> tags ignoring case and diacritics.
+// Value matched only approximately is marked whole.
+func Highlight(value string, terms []string) string {
+	runes := []rune(value)
+	marked := make([]bool, len(runes))
+	starts := make([]int, len(runes))
+
+	var folded strings.Builder
+
+	for i, r := range runes {
+		starts[i] = folded.Len()
+		folded.WriteString(Fold(string(r)))
+	}
+
+	found := false
+
+	for _, term := range terms {
+		if term == "" {
+			continue
+		}
+
+		for offset := 0; ; {
+			n := strings.Index(folded.String()[offset:], term)
+
+			if n < 0 {
+				break
+			}
+
+			begin, end := offset+n, offset+n+len(term)
+
+			for i := range runes {
+				if starts[i] >= begin && starts[i] < end {
+					marked[i] = true
+					found = true
+				}
+			}
+
+			offset = end
+		}
+	}
+
+	if !found {
+		return "<mark>" + html.EscapeString(value) + "</mark>"
+	}
+
+	var b strings.Builder
+
+	for i := 0; i < len(runes); {
+		j := i
+
+		for j < len(runes) && marked[j] == marked[i] {
+			j++
+		}
+
+		part := html.EscapeString(string(runes[i:j]))
+
+		if marked[i] {
+			part = "<mark>" + part + "</mark>"
+		}
+
+		b.WriteString(part)
+		i = j
+	}
+
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserSearchTSQuery(t *testing.T) {
+	testCases := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "single word",
+			query:    "Ivan",
+			expected: "ivan:*",
+		},
+
+		{
+			name:     "several words with diacritics",
+			query:    "  Iván  Petrović ",
+			expected: "ivan:* & petrovic:*",
+		},
+
+		{
+			name:     "tsquery syntax is dropped",
+			query:    "ivan' | !petr:* & (",
+			expected: "ivan:* & petr:*",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &UserSearch{Query: tc.query}
+
+			assert.Equal(t, tc.expected, s.TSQuery())
+		})
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	testCases := []struct {
+		name     string
+		value    string
+		terms    []string
+		expected string
+	}{
+		{
+			name:     "prefix",
+			value:    "Ivanov",
+			terms:    []string{"ivan"},
+			expected: "<mark>Ivan</mark>ov",
+		},
+
+		{
+			name:     "diacritics",
+			value:    "Petrović",
+			terms:    []string{"petrovic"},
+			expected: "<mark>Petrović</mark>",
+		},
+
+		{
+			name:     "several terms",
+			value:    "Annabella",
+			terms:    []string{"ann", "bel"},
+			expected: "<mark>Ann</mark>a<mark>bel</mark>la",
+		},
+
+		{
+			name:     "approximate match",
+			value:    "Ivanov",
+			terms:    []string{"ivonov"},
+			expected: "<mark>Ivanov</mark>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Highlight(tc.value, tc.terms))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"fmt"
+	"strconv"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+type UserStatsRequest struct {
+	Expr      FilterExpr
+	GroupBy   []string
+	AgeBucket int
+}
+
+type AgeBucket struct {
+	From int
+	To   int
+}
+
+type UserStats struct {
+	Gender      string
+	Nationality string
+	AgeBucket   *AgeBucket
+	Count       int
+	AvgAge      float64
+	AgeP50      float64
+	AgeP90      float64
+	AgeP99      float64
+}
+
+// Build query aggregating filtered users by groups
+func (r *UserStatsRequest) ToSql() (string, []interface{}, error) {
+	where, err := compileFilter(r.Expr)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	builder := sq.Select()
+	groupBy := make([]string, 0, len(r.GroupBy))
+
+	for i, group := range r.GroupBy {
+		switch group {
+		case "gender", "nationality":
+			builder = builder.Column(group)
+		case "age_bucket":
+			if r.AgeBucket <= 0 {
+				return "", nil, fmt.Errorf("stats: invalid age bucket %d", r.AgeBucket)
+			}
+
+			builder = builder.Column(sq.Expr("age / ? * ?", r.AgeBucket, r.AgeBucket))
+		default:
+			return "", nil, fmt.Errorf("stats: unknown group %q", group)
+		}
+
+		// Grouped by select list positions, parameterized expressions can't be repeated
+		groupBy = append(groupBy, strconv.Itoa(i+1))
+	}
+
+	builder = builder.
+		Column("count(*)").
+		Column("coalesce(avg(age), 0)::float8").
+		Column("coalesce(percentile_cont(0.5) WITHIN GROUP (ORDER BY age), 0)").
+		Column("coalesce(percentile_cont(0.9) WITHIN GROUP (ORDER BY age), 0)").
+		Column("coalesce(percentile_cont(0.99) WITHIN GROUP (ORDER BY age), 0)").
+		From("users").
+		PlaceholderFormat(sq.Dollar)
+
+	if where != nil {
+		builder = builder.Where(where)
+	}
+
+	if len(groupBy) > 0 {
+		builder = builder.GroupBy(groupBy...).OrderBy(groupBy...)
+	}
+
+	return builder.ToSql()
+}
+
+// Scan destinations for a row of stats query
+func (s *UserStats) ScanDest(groupBy []string) []interface{} {
+	dest := make([]interface{}, 0, len(groupBy)+5)
+
+	for _, group := range groupBy {
+		switch group {
+		case "gender":
+			dest = append(dest, &s.Gender)
+		case "nationality":
+			dest = append(dest, &s.Nationality)
+		case "age_bucket":
+			s.AgeBucket = &AgeBucket{}
+			dest = append(dest, &s.AgeBucket.From)
+		}
+	}
+
+	return append(dest, &s.Count, &s.AvgAge, &s.AgeP50, &s.AgeP90, &s.AgeP99)
+}
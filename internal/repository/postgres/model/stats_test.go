@@ -0,0 +1,61 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserStatsRequestToSql(t *testing.T) {
+	testCases := []struct {
+		name         string
+		request      UserStatsRequest
+		expectedSql  string
+		expectedArgs []interface{}
+		isValid      bool
+	}{
+		{
+			name:        "all users",
+			request:     UserStatsRequest{},
+			expectedSql: "SELECT count(*), coalesce(avg(age), 0)::float8, coalesce(percentile_cont(0.5) WITHIN GROUP (ORDER BY age), 0), coalesce(percentile_cont(0.9) WITHIN GROUP (ORDER BY age), 0), coalesce(percentile_cont(0.99) WITHIN GROUP (ORDER BY age), 0) FROM users",
+			isValid:     true,
+		},
+
+		{
+			name: "grouped and filtered",
+			request: UserStatsRequest{
+				Expr:      FilterExpr{Op: FilterIn, Field: "nationality", Values: []string{"RU", "UA"}},
+				GroupBy:   []string{"nationality", "age_bucket"},
+				AgeBucket: 10,
+			},
+			expectedSql:  "SELECT nationality, age / $1 * $2, count(*), coalesce(avg(age), 0)::float8, coalesce(percentile_cont(0.5) WITHIN GROUP (ORDER BY age), 0), coalesce(percentile_cont(0.9) WITHIN GROUP (ORDER BY age), 0), coalesce(percentile_cont(0.99) WITHIN GROUP (ORDER BY age), 0) FROM users WHERE nationality IN ($3,$4) GROUP BY 1, 2 ORDER BY 1, 2",
+			expectedArgs: []interface{}{10, 10, "RU", "UA"},
+			isValid:      true,
+		},
+
+		{
+			name:    "unknown group",
+			request: UserStatsRequest{GroupBy: []string{"surname"}},
+		},
+
+		{
+			name:    "empty age bucket",
+			request: UserStatsRequest{GroupBy: []string{"age_bucket"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, args, err := tc.request.ToSql()
+
+			if !tc.isValid {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedSql, query)
+			assert.Equal(t, tc.expectedArgs, args)
+		})
+	}
+}
@@ -1,9 +1,9 @@
 package model
 
 import (
+	"database/sql"
 	"errors"
-	"strconv"
-	"strings"
+	"fmt"
 )
 
 var ErrUserNotFound = errors.New("user not found")
@@ -18,58 +18,74 @@ type User struct {
 	Nationality string `db:"nationality"`
 }
 
-type UserFilter struct {
-	Name        string
-	Surname     string
-	Patronymic  string
-	AgeFrom     int
-	AgeTo       int
-	Gender      string
-	Nationality string
-	Limit       int
-}
+// Columns of User in select order
+var userColumns = []string{"id", "name", "surname", "patronymic", "age", "gender", "nationality"}
 
-func (u *UserFilter) GetFilterRequest() string {
-	slice := make([]string, 0)
+// Columns written by import
+var ImportColumns = []string{"name", "surname", "patronymic", "age", "gender", "nationality"}
 
-	if u.Name != "" {
-		str := "name = " + "'" + u.Name + "'"
-		slice = append(slice, str)
+// Values of ImportColumns, empty patronymic is NULL
+func (u *User) ImportValues() []any {
+	return []any{
+		u.Name,
+		u.Surname,
+		sql.NullString{String: u.Patronymic, Valid: u.Patronymic != ""},
+		u.Age,
+		u.Gender,
+		u.Nationality,
 	}
+}
 
-	if u.Surname != "" {
-		str := "surname = " + "'" + u.Surname + "'"
-		slice = append(slice, str)
+// Columns of requested fields in select order, all columns if no fields requested
+func Columns(fields []string) ([]string, error) {
+	if len(fields) == 0 {
+		return userColumns, nil
 	}
 
-	if u.Patronymic != "" {
-		str := "patronymic = " + "'" + u.Patronymic + "'"
-		slice = append(slice, str)
-	}
+	requested := make(map[string]bool, len(fields))
 
-	if u.AgeFrom != 0 {
-		itoa := strconv.Itoa(u.AgeFrom)
-		str := "age >= " + itoa
-		slice = append(slice, str)
+	for _, f := range fields {
+		requested[f] = true
 	}
 
-	if u.AgeTo != 0 {
-		itoa := strconv.Itoa(u.AgeTo)
-		str := "age <= " + itoa
-		slice = append(slice, str)
-	}
+	columns := make([]string, 0, len(fields))
 
-	if u.Gender != "" {
-		str := "gender = " + "'" + u.Gender + "'"
-		slice = append(slice, str)
+	for _, column := range userColumns {
+		if requested[column] {
+			columns = append(columns, column)
+			delete(requested, column)
+		}
 	}
 
-	if u.Nationality != "" {
-		str := "nationality = " + "'" + u.Nationality + "'"
-		slice = append(slice, str)
+	for f := range requested {
+		return nil, fmt.Errorf("unknown user field %q", f)
 	}
 
-	result := strings.Join(slice, " AND ")
+	return columns, nil
+}
+
+// Scan destinations for columns, nullable patronymic goes to a separate destination
+func (u *User) ScanDest(columns []string, patronymic *sql.NullString) []interface{} {
+	dest := make([]interface{}, 0, len(columns))
+
+	for _, column := range columns {
+		switch column {
+		case "id":
+			dest = append(dest, &u.Id)
+		case "name":
+			dest = append(dest, &u.Name)
+		case "surname":
+			dest = append(dest, &u.Surname)
+		case "patronymic":
+			dest = append(dest, patronymic)
+		case "age":
+			dest = append(dest, &u.Age)
+		case "gender":
+			dest = append(dest, &u.Gender)
+		case "nationality":
+			dest = append(dest, &u.Nationality)
+		}
+	}
 
-	return result
+	return dest
 }
@@ -1,7 +1,202 @@
 package model
 
-import "testing"
+import (
+	"testing"
 
-func TestUserFilter_GetFilterRequest(m *testing.T) {
+	sq "github.com/Masterminds/squirrel"
+	"github.com/stretchr/testify/assert"
+)
 
+func TestUserFilterWhere(t *testing.T) {
+	testCases := []struct {
+		name         string
+		expr         FilterExpr
+		expectedSql  string
+		expectedArgs []interface{}
+		isValid      bool
+	}{
+		{
+			name:    "no filter",
+			isValid: true,
+		},
+
+		{
+			name: "equality and range",
+			expr: FilterExpr{Op: FilterAnd, Children: []FilterExpr{
+				{Op: FilterEq, Field: "name", Values: []string{"Ivan"}},
+				{Op: FilterGte, Field: "age", Values: []string{"30"}},
+			}},
+			expectedSql:  "SELECT id FROM users WHERE (name = $1 AND age >= $2)",
+			expectedArgs: []interface{}{"Ivan", 30},
+			isValid:      true,
+		},
+
+		{
+			name: "lists and negation",
+			expr: FilterExpr{Op: FilterAnd, Children: []FilterExpr{
+				{Op: FilterIn, Field: "nationality", Values: []string{"RU", "UA"}},
+				{Op: FilterNotIn, Field: "age", Values: []string{"20", "21"}},
+				{Op: FilterNe, Field: "gender", Values: []string{"male"}},
+			}},
+			expectedSql:  "SELECT id FROM users WHERE (nationality IN ($1,$2) AND age NOT IN ($3,$4) AND gender <> $5)",
+			expectedArgs: []interface{}{"RU", "UA", 20, 21, "male"},
+			isValid:      true,
+		},
+
+		{
+			name: "prefix, contains and null checks in or group",
+			expr: FilterExpr{Op: FilterAnd, Children: []FilterExpr{
+				{Op: FilterOr, Children: []FilterExpr{
+					{Op: FilterPrefix, Field: "surname", Values: []string{"Iva"}},
+					{Op: FilterContains, Field: "name", Values: []string{"an_%"}},
+				}},
+				{Op: FilterNull, Field: "patronymic"},
+				{Op: FilterOr, Children: []FilterExpr{
+					{Op: FilterNotNull, Field: "patronymic"},
+				}},
+			}},
+			expectedSql:  `SELECT id FROM users WHERE ((surname ILIKE $1 OR name ILIKE $2) AND patronymic IS NULL AND (patronymic IS NOT NULL))`,
+			expectedArgs: []interface{}{"Iva%", `%an\_\%%`},
+			isValid:      true,
+		},
+
+		{
+			name:         "values can't inject sql",
+			expr:         FilterExpr{Op: FilterEq, Field: "name", Values: []string{"' OR 1=1 --"}},
+			expectedSql:  "SELECT id FROM users WHERE name = $1",
+			expectedArgs: []interface{}{"' OR 1=1 --"},
+			isValid:      true,
+		},
+
+		{
+			name: "unknown field",
+			expr: FilterExpr{Op: FilterEq, Field: "name; DROP TABLE users", Values: []string{"Ivan"}},
+		},
+
+		{
+			name: "invalid age",
+			expr: FilterExpr{Op: FilterEq, Field: "age", Values: []string{"old"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := &UserFilter{Expr: tc.expr}
+
+			where, err := u.Where()
+
+			if !tc.isValid {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+
+			if tc.expectedSql == "" {
+				assert.Nil(t, where)
+				return
+			}
+
+			query, args, err := sq.Select("id").From("users").Where(where).PlaceholderFormat(sq.Dollar).ToSql()
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedSql, query)
+			assert.Equal(t, tc.expectedArgs, args)
+		})
+	}
+}
+
+func TestUserFilterOrderBy(t *testing.T) {
+	testCases := []struct {
+		name            string
+		sort            []SortField
+		expectedOrderBy []string
+		isValid         bool
+	}{
+		{
+			name:            "default",
+			expectedOrderBy: []string{"id"},
+			isValid:         true,
+		},
+
+		{
+			name:            "tiebroken on id",
+			sort:            []SortField{{Field: "surname"}, {Field: "name"}},
+			expectedOrderBy: []string{"surname", "name", "id"},
+			isValid:         true,
+		},
+
+		{
+			name:            "tiebreaker follows last field",
+			sort:            []SortField{{Field: "nationality"}, {Field: "age", Desc: true}},
+			expectedOrderBy: []string{"nationality", "age DESC", "id DESC"},
+			isValid:         true,
+		},
+
+		{
+			name:            "by id",
+			sort:            []SortField{{Field: "id", Desc: true}, {Field: "age"}},
+			expectedOrderBy: []string{"id DESC"},
+			isValid:         true,
+		},
+
+		{
+			name: "unknown field",
+			sort: []SortField{{Field: "age; DROP TABLE users"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			u := &UserFilter{Sort: tc.sort}
+
+			orderBy, err := u.OrderBy()
+
+			if !tc.isValid {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedOrderBy, orderBy)
+		})
+	}
+}
+
+func TestColumns(t *testing.T) {
+	columns, err := Columns(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "surname", "patronymic", "age", "gender", "nationality"}, columns)
+
+	columns, err = Columns([]string{"nationality", "id", "name"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"id", "name", "nationality"}, columns)
+
+	_, err = Columns([]string{"id", "password"})
+	assert.Error(t, err)
+}
+
+func TestUserFilterToSql(t *testing.T) {
+	u := &UserFilter{
+		Expr:   FilterExpr{Op: FilterEq, Field: "gender", Values: []string{"male"}},
+		Sort:   []SortField{{Field: "age", Desc: true}},
+		Fields: []string{"name", "id"},
+	}
+
+	query, args, err := u.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id, name FROM users WHERE gender = $1 ORDER BY age DESC, id DESC", query)
+	assert.Equal(t, []interface{}{"male"}, args)
+
+	u.Limit = 5
+
+	query, _, err = u.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id, name FROM users WHERE gender = $1 ORDER BY age DESC, id DESC LIMIT 5", query)
+
+	u.Offset = 10
+
+	query, _, err = u.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id, name FROM users WHERE gender = $1 ORDER BY age DESC, id DESC LIMIT 5 OFFSET 10", query)
 }
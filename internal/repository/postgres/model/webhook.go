@@ -0,0 +1,130 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrSubscriptionNotFound = errors.New("subscription not found")
+	ErrDeliveryNotFound     = errors.New("delivery not found")
+)
+
+// Statuses of deliveries
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+type Subscription struct {
+	Id        int
+	URL       string
+	Events    pq.StringArray
+	Secret    string
+	CreatedAt time.Time
+}
+
+type Delivery struct {
+	Id             int64
+	SubscriptionId int
+	EventType      string
+	Payload        []byte
+	Status         string
+	Attempts       int
+	ResponseStatus int
+	Error          string
+	NextAttemptAt  time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// Columns of Delivery in select order
+var deliveryColumns = []string{
+	"id", "subscription_id", "event_type", "payload", "status", "attempts",
+	"response_status", "error", "next_attempt_at", "created_at", "updated_at",
+}
+
+func (d *Delivery) ScanDest() []interface{} {
+	return []interface{}{
+		&d.Id, &d.SubscriptionId, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.ResponseStatus, &d.Error, &d.NextAttemptAt, &d.CreatedAt, &d.UpdatedAt,
+	}
+}
+
+type DeliveryFilter struct {
+	SubscriptionId int
+	Status         string
+	Limit          int
+}
+
+// Build query selecting deliveries of subscription, newest first
+func (f *DeliveryFilter) ToSql() (string, []interface{}, error) {
+	builder := sq.
+		Select(deliveryColumns...).
+		From("webhook_deliveries").
+		Where(sq.Eq{"subscription_id": f.SubscriptionId}).
+		OrderBy("id DESC").
+		PlaceholderFormat(sq.Dollar)
+
+	if f.Status != "" {
+		builder = builder.Where(sq.Eq{"status": f.Status})
+	}
+
+	if f.Limit > 0 {
+		builder = builder.Limit(uint64(f.Limit))
+	}
+
+	return builder.ToSql()
+}
+
+// Build query adding pending delivery of event to every subscription to its type
+func Enqueue(eventType string, payload []byte) (string, []interface{}, error) {
+	return sq.
+		Insert("webhook_deliveries").
+		Columns("subscription_id", "event_type", "payload").
+		Select(sq.
+			Select("id").
+			Column("?::varchar", eventType).
+			Column("?::jsonb", string(payload)).
+			From("webhook_subscriptions").
+			Where("?::text = ANY(events)", eventType)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+}
+
+// Pending delivery with subscription it is sent to
+type Attempt struct {
+	Delivery
+	URL    string
+	Secret string
+}
+
+// Outcome of an attempt, the delivery is retried at NextAttemptAt while it is pending
+type AttemptResult struct {
+	Id             int64
+	Status         string
+	ResponseStatus int
+	Error          string
+	NextAttemptAt  time.Time
+}
+
+// Build query locking due deliveries, deliveries locked by other dispatchers are skipped
+func DueAttempts(limit int) (string, []interface{}, error) {
+	return sq.
+		Select(
+			"d.id", "d.subscription_id", "d.event_type", "d.payload", "d.attempts", "s.url", "s.secret",
+		).
+		From("webhook_deliveries d").
+		Join("webhook_subscriptions s ON s.id = d.subscription_id").
+		Where(sq.Eq{"d.status": DeliveryPending}).
+		Where("d.next_attempt_at <= now()").
+		OrderBy("d.next_attempt_at", "d.id").
+		Limit(uint64(limit)).
+		Suffix("FOR UPDATE OF d SKIP LOCKED").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+}
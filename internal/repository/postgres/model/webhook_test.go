@@ -0,0 +1,36 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeliveryFilterToSql(t *testing.T) {
+	filter := &DeliveryFilter{SubscriptionId: 3, Status: DeliveryFailed, Limit: 20}
+
+	query, args, err := filter.ToSql()
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT id, subscription_id, event_type, payload, status, attempts, response_status, error, "+
+		"next_attempt_at, created_at, updated_at FROM webhook_deliveries "+
+		"WHERE subscription_id = $1 AND status = $2 ORDER BY id DESC LIMIT 20", query)
+	assert.Equal(t, []interface{}{3, DeliveryFailed}, args)
+}
+
+func TestEnqueue(t *testing.T) {
+	query, args, err := Enqueue(EventUserCreated, []byte(`{"id":"1"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO webhook_deliveries (subscription_id,event_type,payload) "+
+		"SELECT id, $1::varchar, $2::jsonb FROM webhook_subscriptions WHERE $3::text = ANY(events)", query)
+	assert.Equal(t, []interface{}{EventUserCreated, `{"id":"1"}`, EventUserCreated}, args)
+}
+
+func TestDueAttempts(t *testing.T) {
+	query, args, err := DueAttempts(50)
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT d.id, d.subscription_id, d.event_type, d.payload, d.attempts, s.url, s.secret "+
+		"FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id "+
+		"WHERE d.status = $1 AND d.next_attempt_at <= now() ORDER BY d.next_attempt_at, d.id LIMIT 50 "+
+		"FOR UPDATE OF d SKIP LOCKED", query)
+	assert.Equal(t, []interface{}{DeliveryPending}, args)
+}
@@ -0,0 +1,128 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// Arbitrary key of postgres advisory lock held while outbox is published,
+// a single relay at a time keeps events of every user in order
+const outboxLockKey int64 = 7_240_210_100_000
+
+type OutboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutbox(db *sql.DB) *OutboxRepository {
+	return &OutboxRepository{
+		db: db,
+	}
+}
+
+// Pass up to limit oldest events to publish in order and delete the ones it returns as published.
+// Returns model.ErrOutboxLocked if another relay is publishing.
+func (r *OutboxRepository) Publish(ctx context.Context, limit int, publish func(events []model.Event) []int64) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return 0, fmt.Errorf("postgres: publishing outbox: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	var locked bool
+
+	// Lock is released with the transaction
+	if err := tx.QueryRowContext(ctx, "SELECT pg_try_advisory_xact_lock($1)", outboxLockKey).Scan(&locked); err != nil {
+		return 0, fmt.Errorf("postgres: publishing outbox: %w", err)
+	}
+
+	if !locked {
+		return 0, model.ErrOutboxLocked
+	}
+
+	events, err := r.pending(ctx, tx, limit)
+
+	if err != nil {
+		return 0, fmt.Errorf("postgres: publishing outbox: %w", err)
+	}
+
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	published := publish(events)
+
+	if len(published) == 0 {
+		return 0, nil
+	}
+
+	query, args, err := sq.
+		Delete("users_outbox").
+		Where(sq.Eq{"id": published}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("postgres: publishing outbox: %w", err)
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return 0, fmt.Errorf("postgres: publishing outbox: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("postgres: publishing outbox: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("postgres: %d outbox events were published", len(published)))
+
+	return len(published), nil
+}
+
+// Oldest events first
+func (r *OutboxRepository) pending(ctx context.Context, tx *sql.Tx, limit int) ([]model.Event, error) {
+	query, args, err := sq.
+		Select("id", "user_id", "event_type", "payload", "created_at").
+		From("users_outbox").
+		OrderBy("id").
+		Limit(uint64(limit)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	events := make([]model.Event, 0, limit)
+
+	for rows.Next() {
+		var e model.Event
+
+		if err := rows.Scan(&e.Id, &e.UserId, &e.Type, &e.Payload, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
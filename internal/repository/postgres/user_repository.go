@@ -6,19 +6,42 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"strconv"
 
 	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/lib/pq"
 	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
 )
 
+// Users fetched from export cursor at once
+const exportBatchSize = 1000
+
 type UserRepository struct {
-	db *sql.DB
+	db     *sql.DB
+	outbox bool
+}
+
+type Option func(r *UserRepository)
+
+// Write event of every change of users to outbox in the same transaction
+func WithOutbox() Option {
+	return func(r *UserRepository) {
+		r.outbox = true
+	}
 }
 
-func New(db *sql.DB) *UserRepository {
-	return &UserRepository{
+func New(db *sql.DB, opts ...Option) *UserRepository {
+	r := &UserRepository{
 		db: db,
 	}
+
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
 }
 
 // Get all users with filters and limit
@@ -29,14 +52,13 @@ func (r *UserRepository) Get(ctx context.Context, userFilter *model.UserFilter)
 
 	user := model.User{}
 
-	filters := userFilter.GetFilterRequest()
+	columns, err := model.Columns(userFilter.Fields)
 
-	query, _, err := sq.
-		Select("id, name, surname, patronymic, age, gender, nationality").
-		From("users").
-		Where(filters).
-		Limit(uint64(userFilter.Limit)).
-		ToSql()
+	if err != nil {
+		return nil, fmt.Errorf("postgres: getting users: %w", err)
+	}
+
+	query, args, err := userFilter.ToSql()
 
 	if err != nil {
 		return nil, fmt.Errorf("postgres: getting users: %w", err)
@@ -47,6 +69,7 @@ func (r *UserRepository) Get(ctx context.Context, userFilter *model.UserFilter)
 	rows, err := r.db.QueryContext(
 		ctx,
 		query,
+		args...,
 	)
 
 	if err != nil {
@@ -56,37 +79,127 @@ func (r *UserRepository) Get(ctx context.Context, userFilter *model.UserFilter)
 	defer rows.Close()
 
 	for rows.Next() {
-		err := rows.Scan(
-			&user.Id,
-			&user.Name,
-			&user.Surname,
-			&user.Patronymic,
-			&user.Age,
-			&user.Gender,
-			&user.Nationality,
-		)
+		var patronymic sql.NullString
 
-		if err != nil {
+		if err := rows.Scan(user.ScanDest(columns, &patronymic)...); err != nil {
 			return nil, fmt.Errorf("postgres: getting users: %w", err)
 		}
 
+		user.Patronymic = patronymic.String
+
 		users = append(users, user)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: getting users: %w", err)
+	}
+
 	slog.Info("postgres: user were got successfully")
 
 	return users, nil
 }
 
+// Stream all users matching filter from a server-side cursor, fn is called for
+// every user and stops the export by returning an error
+func (r *UserRepository) Export(ctx context.Context, userFilter *model.UserFilter, fn func(u *model.User) error) error {
+	slog.Info("postgres: exporting users")
+
+	columns, err := model.Columns(userFilter.Fields)
+
+	if err != nil {
+		return fmt.Errorf("postgres: exporting users: %w", err)
+	}
+
+	query, args, err := userFilter.ToSql()
+
+	if err != nil {
+		return fmt.Errorf("postgres: exporting users: %w", err)
+	}
+
+	// Snapshot stays the same while the cursor is read
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+
+	if err != nil {
+		return fmt.Errorf("postgres: exporting users: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	if _, err := tx.ExecContext(ctx, "DECLARE users_export NO SCROLL CURSOR FOR "+query, args...); err != nil {
+		return fmt.Errorf("postgres: exporting users: %w", err)
+	}
+
+	fetch := fmt.Sprintf("FETCH FORWARD %d FROM users_export", exportBatchSize)
+	exported := 0
+
+	for {
+		n, err := r.fetch(ctx, tx, fetch, columns, fn)
+
+		if err != nil {
+			return fmt.Errorf("postgres: exporting users: %w", err)
+		}
+
+		exported += n
+
+		if n < exportBatchSize {
+			break
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("postgres: exporting users: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("postgres: %d users were exported successfully", exported))
+
+	return nil
+}
+
+// Fetch next batch from cursor, returns number of fetched users
+func (r *UserRepository) fetch(ctx context.Context, tx *sql.Tx, fetch string, columns []string, fn func(u *model.User) error) (int, error) {
+	rows, err := tx.QueryContext(ctx, fetch)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer rows.Close()
+
+	n := 0
+
+	for rows.Next() {
+		var (
+			user       model.User
+			patronymic sql.NullString
+		)
+
+		if err := rows.Scan(user.ScanDest(columns, &patronymic)...); err != nil {
+			return n, err
+		}
+
+		user.Patronymic = patronymic.String
+
+		if err := fn(&user); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	return n, rows.Err()
+}
+
 // Delete user by id
 func (r *UserRepository) Delete(ctx context.Context, id int) error {
 	slog.Info(fmt.Sprintf("postgres: deleting user %d", id))
 
-	query, _, err := sq.
+	query, args, err := r.mutation(sq.
 		Delete("users").
-		PlaceholderFormat(sq.Dollar).
-		Where(sq.Eq{"id": id}).
-		ToSql()
+		Where(sq.Eq{"id": id}),
+		model.EventUserDeleted,
+	)
 
 	if err != nil {
 		return fmt.Errorf("postgres: deleting user %d: %w", id, err)
@@ -94,11 +207,11 @@ func (r *UserRepository) Delete(ctx context.Context, id int) error {
 
 	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
 
-	if _, err := r.db.ExecContext(
-		ctx,
-		query,
-		id,
-	); err != nil {
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = model.ErrUserNotFound
+		}
+
 		return fmt.Errorf("postgres: deleting user %d: %w", id, err)
 	}
 
@@ -111,17 +224,18 @@ func (r *UserRepository) Delete(ctx context.Context, id int) error {
 func (r *UserRepository) Update(ctx context.Context, id int, u *model.User) error {
 	slog.Info(fmt.Sprintf("postgres: updating user %d", id))
 
-	query, _, err := sq.
+	query, args, err := r.mutation(sq.
 		Update("users").
 		Set("name", u.Name).
 		Set("surname", u.Surname).
-		Set("patronymic", u.Patronymic).
+		Set("patronymic", nullString(u.Patronymic)).
 		Set("age", u.Age).
 		Set("gender", u.Gender).
 		Set("nationality", u.Nationality).
-		PlaceholderFormat(sq.Dollar).
-		Where(sq.Eq{"id": id}).
-		ToSql()
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": id}),
+		model.EventUserUpdated,
+	)
 
 	if err != nil {
 		return fmt.Errorf("postgres: updating user %d: %w", id, err)
@@ -129,17 +243,11 @@ func (r *UserRepository) Update(ctx context.Context, id int, u *model.User) erro
 
 	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
 
-	if _, err := r.db.ExecContext(
-		ctx,
-		query,
-		u.Name,
-		u.Surname,
-		u.Patronymic,
-		u.Age,
-		u.Gender,
-		u.Nationality,
-		id,
-	); err != nil {
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			err = model.ErrUserNotFound
+		}
+
 		return fmt.Errorf("postgres: updating user %d: %w", id, err)
 	}
 
@@ -154,13 +262,12 @@ func (r *UserRepository) Create(ctx context.Context, u *model.User) (int, error)
 
 	var id int
 
-	query, _, err := sq.
+	query, args, err := r.mutation(sq.
 		Insert("users").
 		Columns("name", "surname", "patronymic", "age", "gender", "nationality").
-		PlaceholderFormat(sq.Dollar).
-		Values(u.Name, u.Surname, u.Patronymic, u.Age, u.Gender, u.Nationality).
-		Suffix("RETURNING id").
-		ToSql()
+		Values(u.Name, u.Surname, nullString(u.Patronymic), u.Age, u.Gender, u.Nationality),
+		model.EventUserCreated,
+	)
 
 	if err != nil {
 		return 0, fmt.Errorf("postgres: creating user %d: %w", id, err)
@@ -168,16 +275,7 @@ func (r *UserRepository) Create(ctx context.Context, u *model.User) (int, error)
 
 	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
 
-	if err := r.db.QueryRowContext(
-		ctx,
-		query,
-		u.Name,
-		u.Surname,
-		u.Patronymic,
-		u.Age,
-		u.Gender,
-		u.Nationality,
-	).Scan(&id); err != nil {
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&id); err != nil {
 		return 0, fmt.Errorf("postgres: creating user %d: %w", id, err)
 	}
 
@@ -186,14 +284,233 @@ func (r *UserRepository) Create(ctx context.Context, u *model.User) (int, error)
 	return id, nil
 }
 
-// Get user by id
-func (r *UserRepository) GetUserById(ctx context.Context, id int) (*model.User, error) {
+// Build mutation returning ids of changed users, writing their events if outbox is enabled
+func (r *UserRepository) mutation(mutation sq.Sqlizer, eventType string) (string, []interface{}, error) {
+	if r.outbox {
+		return model.MutationWithEvent(mutation, eventType)
+	}
+
+	return model.Mutation(mutation)
+}
+
+// Insert users with COPY, all of them or none. Returns imported users with their ids.
+func (r *UserRepository) Import(ctx context.Context, users []model.User) ([]model.User, error) {
+	slog.Info(fmt.Sprintf("postgres: importing %d users", len(users)))
+
+	var (
+		imported []model.User
+		err      error
+	)
+
+	switch r.db.Driver().(type) {
+	case *stdlib.Driver:
+		imported, err = r.copyPGX(ctx, users)
+	default:
+		imported, err = r.copyPQ(ctx, users)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: importing users: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("postgres: %d users were imported successfully", len(imported)))
+
+	return imported, nil
+}
+
+// Copy users through lib/pq COPY statement
+func (r *UserRepository) copyPQ(ctx context.Context, users []model.User) ([]model.User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer tx.Rollback()
+
+	// Users are inserted from a copy to get their ids
+	if _, err := tx.ExecContext(ctx, model.CreateImportTable); err != nil {
+		return nil, err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(model.ImportTable, model.ImportColumns...))
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	for i := range users {
+		if _, err := stmt.ExecContext(ctx, users[i].ImportValues()...); err != nil {
+			return nil, err
+		}
+	}
+
+	// Flush buffered rows
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := stmt.Close(); err != nil {
+		return nil, err
+	}
+
+	query, args, err := model.ImportMutation(r.outbox)
+
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	imported, err := scanImported(rows, len(users))
+
+	rows.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return imported, nil
+}
+
+// Rows of database/sql or pgx
+type importedRows interface {
+	Next() bool
+	Scan(dest ...any) error
+	Err() error
+}
+
+// Scan users returned by import mutation
+func scanImported(rows importedRows, size int) ([]model.User, error) {
+	imported := make([]model.User, 0, size)
+
+	columns, _ := model.Columns(nil)
+
+	for rows.Next() {
+		var (
+			u          model.User
+			patronymic sql.NullString
+		)
+
+		if err := rows.Scan(u.ScanDest(columns, &patronymic)...); err != nil {
+			return nil, err
+		}
+
+		u.Patronymic = patronymic.String
+
+		imported = append(imported, u)
+	}
+
+	return imported, rows.Err()
+}
+
+// Copy users through pgx connection under database/sql
+func (r *UserRepository) copyPGX(ctx context.Context, users []model.User) ([]model.User, error) {
+	conn, err := r.db.Conn(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	var imported []model.User
+
+	err = conn.Raw(func(driverConn any) error {
+		pgxConn := driverConn.(*stdlib.Conn).Conn()
+
+		// COPY uses binary format, it needs to know the enum
+		if _, ok := pgxConn.TypeMap().TypeForName("user_gender"); !ok {
+			genderType, err := pgxConn.LoadType(ctx, "user_gender")
+
+			if err != nil {
+				return err
+			}
+
+			pgxConn.TypeMap().RegisterType(genderType)
+		}
+
+		tx, err := pgxConn.Begin(ctx)
+
+		if err != nil {
+			return err
+		}
+
+		defer tx.Rollback(ctx)
+
+		// Users are inserted from a copy to get their ids
+		if _, err := tx.Exec(ctx, model.CreateImportTable); err != nil {
+			return err
+		}
+
+		_, err = tx.CopyFrom(ctx, pgx.Identifier{model.ImportTable}, model.ImportColumns, pgx.CopyFromSlice(len(users), func(i int) ([]any, error) {
+			return users[i].ImportValues(), nil
+		}))
+
+		if err != nil {
+			return err
+		}
+
+		query, args, err := model.ImportMutation(r.outbox)
+
+		if err != nil {
+			return err
+		}
+
+		slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+		rows, err := tx.Query(ctx, query, args...)
+
+		if err != nil {
+			return err
+		}
+
+		imported, err = scanImported(rows, len(users))
+
+		rows.Close()
+
+		if err != nil {
+			return err
+		}
+
+		return tx.Commit(ctx)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return imported, nil
+}
+
+// Get user by id, only given fields if any
+func (r *UserRepository) GetUserById(ctx context.Context, id int, fields ...string) (*model.User, error) {
 	slog.Info(fmt.Sprintf("postgres: getting user %d", id))
 
 	user := &model.User{}
 
+	var patronymic sql.NullString
+
+	columns, err := model.Columns(fields)
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: getting user %d: %w", id, err)
+	}
+
 	query, _, err := sq.
-		Select("id", "name", "surname", "patronymic", "age", "gender", "nationality").
+		Select(columns...).
 		From("users").
 		PlaceholderFormat(sq.Dollar).
 		Where(sq.Eq{"id": id}).
@@ -209,22 +526,185 @@ func (r *UserRepository) GetUserById(ctx context.Context, id int) (*model.User,
 		ctx,
 		query,
 		id,
-	).Scan(
-		&user.Id,
-		&user.Name,
-		&user.Surname,
-		&user.Patronymic,
-		&user.Age,
-		&user.Gender,
-		&user.Nationality,
-	); err != nil {
+	).Scan(user.ScanDest(columns, &patronymic)...); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, model.ErrUserNotFound
 		}
 		return nil, fmt.Errorf("postgres: getting user %d: %w", id, err)
 	}
 
+	user.Patronymic = patronymic.String
+
 	slog.Debug(fmt.Sprintf("postgres: user %d was got successfully", id))
 
 	return user, nil
 }
+
+// Search users by names with full-text and trigram similarity, best matches first
+func (r *UserRepository) Search(ctx context.Context, userSearch *model.UserSearch) ([]model.UserMatch, error) {
+	slog.Info("postgres: searching users")
+
+	folded := model.Fold(userSearch.Query)
+
+	query, args, err := sq.
+		Select("id", "name", "surname", "patronymic", "age", "gender", "nationality").
+		Column(sq.Expr(
+			"greatest(ts_rank(search_vector, to_tsquery('simple', ?)), word_similarity(?, search_text)) AS rank",
+			userSearch.TSQuery(), folded,
+		)).
+		Column(sq.Expr("word_similarity(?, immutable_unaccent(lower(name)))", folded)).
+		Column(sq.Expr("word_similarity(?, immutable_unaccent(lower(surname)))", folded)).
+		Column(sq.Expr("word_similarity(?, immutable_unaccent(lower(coalesce(patronymic, ''))))", folded)).
+		From("users").
+		Where(sq.Or{
+			sq.Expr("search_vector @@ to_tsquery('simple', ?)", userSearch.TSQuery()),
+			sq.Expr("? <% search_text", folded),
+		}).
+		OrderBy("rank DESC", "id").
+		Limit(uint64(userSearch.Limit)).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: searching users: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: searching users: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	// Threshold of <% operator is a setting, set it for this transaction only
+	if _, err := tx.ExecContext(
+		ctx,
+		"SELECT set_config('pg_trgm.word_similarity_threshold', $1, true)",
+		strconv.FormatFloat(userSearch.Threshold, 'f', -1, 64),
+	); err != nil {
+		return nil, fmt.Errorf("postgres: searching users: %w", err)
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: searching users: %w", err)
+	}
+
+	defer rows.Close()
+
+	matches := make([]model.UserMatch, 0)
+	terms := userSearch.Terms()
+
+	for rows.Next() {
+		var (
+			match      model.UserMatch
+			patronymic sql.NullString
+			similarity [3]float64
+		)
+
+		err := rows.Scan(
+			&match.Id,
+			&match.Name,
+			&match.Surname,
+			&patronymic,
+			&match.Age,
+			&match.Gender,
+			&match.Nationality,
+			&match.Rank,
+			&similarity[0],
+			&similarity[1],
+			&similarity[2],
+		)
+
+		if err != nil {
+			return nil, fmt.Errorf("postgres: searching users: %w", err)
+		}
+
+		match.Patronymic = patronymic.String
+
+		// The field most similar to the query is highlighted
+		fields := [3]struct{ name, value string }{
+			{"name", match.Name},
+			{"surname", match.Surname},
+			{"patronymic", match.Patronymic},
+		}
+
+		best := 0
+
+		for i := range similarity {
+			if similarity[i] > similarity[best] {
+				best = i
+			}
+		}
+
+		match.MatchedField = fields[best].name
+		match.Highlight = model.Highlight(fields[best].value, terms)
+
+		matches = append(matches, match)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: searching users: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("postgres: %d users were found", len(matches)))
+
+	return matches, nil
+}
+
+// Count users and their ages by groups
+func (r *UserRepository) Stats(ctx context.Context, statsRequest *model.UserStatsRequest) ([]model.UserStats, error) {
+	slog.Info("postgres: getting users stats")
+
+	query, args, err := statsRequest.ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: getting users stats: %w", err)
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: getting users stats: %w", err)
+	}
+
+	defer rows.Close()
+
+	stats := make([]model.UserStats, 0)
+
+	for rows.Next() {
+		var s model.UserStats
+
+		if err := rows.Scan(s.ScanDest(statsRequest.GroupBy)...); err != nil {
+			return nil, fmt.Errorf("postgres: getting users stats: %w", err)
+		}
+
+		if s.AgeBucket != nil {
+			s.AgeBucket.To = s.AgeBucket.From + statsRequest.AgeBucket - 1
+		}
+
+		stats = append(stats, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: getting users stats: %w", err)
+	}
+
+	slog.Info("postgres: users stats were got successfully")
+
+	return stats, nil
+}
+
+// Store empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{
+		String: s,
+		Valid:  s != "",
+	}
+}
@@ -0,0 +1,90 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// Benchmarks need a migrated database:
+//
+//	TEST_DB_URL="host=localhost user=user password=password dbname=database sslmode=disable" \
+//	go test -run=^$ -bench=. ./internal/repository/postgres/
+func benchRepository(b *testing.B, driver string) *UserRepository {
+	return New(openTestDB(b, driver))
+}
+
+func BenchmarkUserRepositoryCreate(b *testing.B) {
+	for _, driver := range []string{DriverPQ, DriverPGX} {
+		b.Run(driver, func(b *testing.B) {
+			repo := benchRepository(b, driver)
+
+			user := &model.User{
+				Name:        "Ivan",
+				Surname:     "Ivanov",
+				Patronymic:  "Ivanovich",
+				Age:         20,
+				Gender:      "male",
+				Nationality: "RU",
+			}
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.Create(context.Background(), user); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUserRepositoryImport(b *testing.B) {
+	for _, driver := range []string{DriverPQ, DriverPGX} {
+		b.Run(driver, func(b *testing.B) {
+			repo := benchRepository(b, driver)
+
+			users := make([]model.User, 1000)
+
+			for i := range users {
+				users[i] = model.User{
+					Name:        "Ivan",
+					Surname:     "Ivanov",
+					Age:         20,
+					Gender:      "male",
+					Nationality: "RU",
+				}
+			}
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.Import(context.Background(), users); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkUserRepositoryGet(b *testing.B) {
+	for _, driver := range []string{DriverPQ, DriverPGX} {
+		b.Run(driver, func(b *testing.B) {
+			repo := benchRepository(b, driver)
+
+			filter := &model.UserFilter{
+				Expr:  model.FilterExpr{Op: model.FilterEq, Field: "gender", Values: []string{"male"}},
+				Limit: 50,
+			}
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				if _, err := repo.Get(context.Background(), filter); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
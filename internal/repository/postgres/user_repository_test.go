@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/config"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/repotest"
+	"github.com/sletkov/effective-mobile-test-task/internal/service"
+	"github.com/sletkov/effective-mobile-test-task/migrations"
+)
+
+// Open database at TEST_DB_URL with driver and migrate it, skip if it is not set
+func openTestDB(tb testing.TB, driver string) *sql.DB {
+	url := os.Getenv("TEST_DB_URL")
+
+	if url == "" {
+		tb.Skip("TEST_DB_URL is not set")
+	}
+
+	db, err := Open(config.Database{
+		URL:                    url,
+		Driver:                 driver,
+		MaxOpenConns:           10,
+		MaxIdleConns:           10,
+		StatementCacheCapacity: 512,
+	})
+
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	tb.Cleanup(func() { db.Close() })
+
+	goose.SetBaseFS(migrations.FS)
+
+	if err := goose.Up(db, "."); err != nil {
+		tb.Fatal(err)
+	}
+
+	return db
+}
+
+// Users the baseline stored without enrichment data are moved aside by migrations
+func TestMigrateBaselineUsers(t *testing.T) {
+	db := openTestDB(t, DriverPQ)
+
+	if err := goose.DownTo(db, ".", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { goose.Up(db, ".") })
+
+	if err := goose.UpTo(db, ".", 20240118153339); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := db.Exec(`INSERT INTO users (name, surname, patronymic, age, gender, nationality) VALUES
+		('Ivan', 'Ivanov', '', 20, 'male', 'RU'),
+		('Qwzx', 'Unknown', NULL, 0, '', ''),
+		('Zyx', 'Unknown', 'Zyxovich', 30, 'male', '')`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := goose.Up(db, "."); err != nil {
+		t.Fatal(err)
+	}
+
+	var users, unenriched int
+
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM users WHERE patronymic IS NULL").Scan(&users))
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM users_unenriched").Scan(&unenriched))
+	assert.Equal(t, 1, users)
+	assert.Equal(t, 2, unenriched)
+
+	// Moved users are restored on rollback
+	if err := goose.DownTo(db, ".", 20240118153339); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, db.QueryRow("SELECT count(*) FROM users").Scan(&users))
+	assert.Equal(t, 3, users)
+}
+
+// Conformance suite truncates users before every test, so it needs a disposable database:
+//
+//	TEST_DB_URL="host=localhost user=user password=password dbname=test sslmode=disable" \
+//	go test ./internal/repository/postgres/
+func TestUserRepository(t *testing.T) {
+	for _, driver := range []string{DriverPQ, DriverPGX} {
+		t.Run(driver, func(t *testing.T) {
+			db := openTestDB(t, driver)
+
+			repotest.Run(t, func(t *testing.T) service.UserRepository {
+				if _, err := db.Exec("TRUNCATE users RESTART IDENTITY CASCADE"); err != nil {
+					t.Fatal(err)
+				}
+
+				return New(db)
+			})
+		})
+	}
+}
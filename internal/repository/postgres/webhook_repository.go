@@ -0,0 +1,401 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhook(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{
+		db: db,
+	}
+}
+
+// Columns of Subscription in select order
+var subscriptionColumns = []string{"id", "url", "events", "secret", "created_at"}
+
+// Create new subscription
+func (r *WebhookRepository) CreateSubscription(ctx context.Context, s *model.Subscription) (int, error) {
+	slog.Info("postgres: creating subscription")
+
+	var id int
+
+	query, args, err := sq.
+		Insert("webhook_subscriptions").
+		Columns("url", "events", "secret").
+		Values(s.URL, s.Events, s.Secret).
+		Suffix("RETURNING id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("postgres: creating subscription: %w", err)
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&id); err != nil {
+		return 0, fmt.Errorf("postgres: creating subscription: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("postgres: subscription %d was created successfully", id))
+
+	return id, nil
+}
+
+// Get all subscriptions, oldest first
+func (r *WebhookRepository) GetSubscriptions(ctx context.Context) ([]model.Subscription, error) {
+	slog.Info("postgres: getting subscriptions")
+
+	query, args, err := sq.
+		Select(subscriptionColumns...).
+		From("webhook_subscriptions").
+		OrderBy("id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: getting subscriptions: %w", err)
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: getting subscriptions: %w", err)
+	}
+
+	defer rows.Close()
+
+	subscriptions := make([]model.Subscription, 0)
+
+	for rows.Next() {
+		var s model.Subscription
+
+		if err := rows.Scan(&s.Id, &s.URL, &s.Events, &s.Secret, &s.CreatedAt); err != nil {
+			return nil, fmt.Errorf("postgres: getting subscriptions: %w", err)
+		}
+
+		subscriptions = append(subscriptions, s)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: getting subscriptions: %w", err)
+	}
+
+	slog.Info("postgres: subscriptions were got successfully")
+
+	return subscriptions, nil
+}
+
+// Get subscription by id
+func (r *WebhookRepository) GetSubscriptionById(ctx context.Context, id int) (*model.Subscription, error) {
+	slog.Info(fmt.Sprintf("postgres: getting subscription %d", id))
+
+	s := &model.Subscription{}
+
+	query, args, err := sq.
+		Select(subscriptionColumns...).
+		From("webhook_subscriptions").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: getting subscription %d: %w", id, err)
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&s.Id, &s.URL, &s.Events, &s.Secret, &s.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrSubscriptionNotFound
+		}
+
+		return nil, fmt.Errorf("postgres: getting subscription %d: %w", id, err)
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: subscription %d was got successfully", id))
+
+	return s, nil
+}
+
+// Update url, events and secret of subscription
+func (r *WebhookRepository) UpdateSubscription(ctx context.Context, id int, s *model.Subscription) error {
+	slog.Info(fmt.Sprintf("postgres: updating subscription %d", id))
+
+	query, args, err := sq.
+		Update("webhook_subscriptions").
+		Set("url", s.URL).
+		Set("events", s.Events).
+		Set("secret", s.Secret).
+		Set("updated_at", sq.Expr("now()")).
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("postgres: updating subscription %d: %w", id, err)
+	}
+
+	if err := r.exec(ctx, query, args); err != nil {
+		return fmt.Errorf("postgres: updating subscription %d: %w", id, err)
+	}
+
+	slog.Info(fmt.Sprintf("postgres: subscription %d was updated successfully", id))
+
+	return nil
+}
+
+// Delete subscription with its deliveries
+func (r *WebhookRepository) DeleteSubscription(ctx context.Context, id int) error {
+	slog.Info(fmt.Sprintf("postgres: deleting subscription %d", id))
+
+	query, args, err := sq.
+		Delete("webhook_subscriptions").
+		Where(sq.Eq{"id": id}).
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("postgres: deleting subscription %d: %w", id, err)
+	}
+
+	if err := r.exec(ctx, query, args); err != nil {
+		return fmt.Errorf("postgres: deleting subscription %d: %w", id, err)
+	}
+
+	slog.Info(fmt.Sprintf("postgres: subscription %d was deleted successfully", id))
+
+	return nil
+}
+
+// Execute statement changing a subscription, ErrSubscriptionNotFound if there is none
+func (r *WebhookRepository) exec(ctx context.Context, query string, args []interface{}) error {
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return model.ErrSubscriptionNotFound
+	}
+
+	return nil
+}
+
+// Add pending delivery of event to every subscription to its type, returns number of deliveries
+func (r *WebhookRepository) Enqueue(ctx context.Context, eventType string, payload []byte) (int, error) {
+	slog.Info(fmt.Sprintf("postgres: enqueuing %s deliveries", eventType))
+
+	query, args, err := model.Enqueue(eventType, payload)
+
+	if err != nil {
+		return 0, fmt.Errorf("postgres: enqueuing deliveries: %w", err)
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	result, err := r.db.ExecContext(ctx, query, args...)
+
+	if err != nil {
+		return 0, fmt.Errorf("postgres: enqueuing deliveries: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+
+	if err != nil {
+		return 0, fmt.Errorf("postgres: enqueuing deliveries: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("postgres: %d %s deliveries were enqueued", n, eventType))
+
+	return int(n), nil
+}
+
+// Get deliveries of subscription, newest first
+func (r *WebhookRepository) GetDeliveries(ctx context.Context, filter *model.DeliveryFilter) ([]model.Delivery, error) {
+	slog.Info(fmt.Sprintf("postgres: getting deliveries of subscription %d", filter.SubscriptionId))
+
+	query, args, err := filter.ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: getting deliveries: %w", err)
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("postgres: getting deliveries: %w", err)
+	}
+
+	defer rows.Close()
+
+	deliveries := make([]model.Delivery, 0)
+
+	for rows.Next() {
+		var d model.Delivery
+
+		if err := rows.Scan(d.ScanDest()...); err != nil {
+			return nil, fmt.Errorf("postgres: getting deliveries: %w", err)
+		}
+
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres: getting deliveries: %w", err)
+	}
+
+	slog.Info("postgres: deliveries were got successfully")
+
+	return deliveries, nil
+}
+
+// Add pending copy of delivery of subscription, the original stays in the log as it is
+func (r *WebhookRepository) Redeliver(ctx context.Context, subscriptionId int, id int64) (int64, error) {
+	slog.Info(fmt.Sprintf("postgres: redelivering delivery %d", id))
+
+	query, args, err := sq.
+		Insert("webhook_deliveries").
+		Columns("subscription_id", "event_type", "payload").
+		Select(sq.
+			Select("subscription_id", "event_type", "payload").
+			From("webhook_deliveries").
+			Where(sq.Eq{"id": id, "subscription_id": subscriptionId})).
+		Suffix("RETURNING id").
+		PlaceholderFormat(sq.Dollar).
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("postgres: redelivering delivery %d: %w", id, err)
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	var redeliveryId int64
+
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&redeliveryId); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, model.ErrDeliveryNotFound
+		}
+
+		return 0, fmt.Errorf("postgres: redelivering delivery %d: %w", id, err)
+	}
+
+	slog.Info(fmt.Sprintf("postgres: delivery %d was redelivered as %d", id, redeliveryId))
+
+	return redeliveryId, nil
+}
+
+// Pass up to limit due deliveries to send and save their results. Deliveries are locked
+// until results are saved, so concurrent dispatchers don't send them twice.
+func (r *WebhookRepository) Dispatch(ctx context.Context, limit int, send func(attempts []model.Attempt) []model.AttemptResult) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return 0, fmt.Errorf("postgres: dispatching deliveries: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	attempts, err := r.due(ctx, tx, limit)
+
+	if err != nil {
+		return 0, fmt.Errorf("postgres: dispatching deliveries: %w", err)
+	}
+
+	if len(attempts) == 0 {
+		return 0, nil
+	}
+
+	results := send(attempts)
+
+	for _, result := range results {
+		query, args, err := sq.
+			Update("webhook_deliveries").
+			Set("status", result.Status).
+			Set("attempts", sq.Expr("attempts + 1")).
+			Set("response_status", result.ResponseStatus).
+			Set("error", result.Error).
+			Set("next_attempt_at", result.NextAttemptAt).
+			Set("updated_at", sq.Expr("now()")).
+			Where(sq.Eq{"id": result.Id}).
+			PlaceholderFormat(sq.Dollar).
+			ToSql()
+
+		if err != nil {
+			return 0, fmt.Errorf("postgres: dispatching deliveries: %w", err)
+		}
+
+		slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+		if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+			return 0, fmt.Errorf("postgres: dispatching deliveries: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("postgres: dispatching deliveries: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("postgres: %d deliveries were attempted", len(results)))
+
+	return len(results), nil
+}
+
+// Lock due deliveries, oldest first
+func (r *WebhookRepository) due(ctx context.Context, tx *sql.Tx, limit int) ([]model.Attempt, error) {
+	query, args, err := model.DueAttempts(limit)
+
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Debug(fmt.Sprintf("postgres: making db query: %s", query))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	attempts := make([]model.Attempt, 0, limit)
+
+	for rows.Next() {
+		var a model.Attempt
+
+		if err := rows.Scan(&a.Id, &a.SubscriptionId, &a.EventType, &a.Payload, &a.Attempts, &a.URL, &a.Secret); err != nil {
+			return nil, err
+		}
+
+		attempts = append(attempts, a)
+	}
+
+	return attempts, rows.Err()
+}
@@ -0,0 +1,565 @@
+// Package repotest is a conformance suite of user repositories, every
+// implementation of service.UserRepository is expected to pass it.
+package repotest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+	"github.com/sletkov/effective-mobile-test-task/internal/service"
+)
+
+// Users every test starts with, names differ in case-insensitive order
+// the same way as byte-wise so collation doesn't matter
+var seed = []model.User{
+	{Name: "Ivan", Surname: "Ivanov", Patronymic: "Ivanovich", Age: 20, Gender: "male", Nationality: "RU"},
+	{Name: "Galina", Surname: "Petrova", Age: 40, Gender: "female", Nationality: "US"},
+	{Name: "Petr", Surname: "Sidorov", Patronymic: "Petrovich", Age: 35, Gender: "male", Nationality: "UA"},
+	{Name: "Anna", Surname: "Ivanova", Age: 28, Gender: "female", Nationality: "RU"},
+	{Name: "Oleg", Surname: "Smirnov", Patronymic: "Olegovich", Age: 9, Gender: "male", Nationality: "RU"},
+}
+
+// Run suite against repositories made by newRepository, it returns an empty one for every test
+func Run(t *testing.T, newRepository func(t *testing.T) service.UserRepository) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo service.UserRepository, ids []int)
+	}{
+		{"create", testCreate},
+		{"get by id", testGetById},
+		{"update", testUpdate},
+		{"delete", testDelete},
+		{"filter", testFilter},
+		{"sort and paginate", testSort},
+		{"fields", testFields},
+		{"search", testSearch},
+		{"stats", testStats},
+		{"export", testExport},
+		{"import", testImport},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := newRepository(t)
+
+			ids := make([]int, len(seed))
+
+			for i := range seed {
+				id, err := repo.Create(context.Background(), &seed[i])
+
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				ids[i] = id
+			}
+
+			tc.test(t, repo, ids)
+		})
+	}
+}
+
+// Names of users in order
+func names(users []model.User) []string {
+	var names []string
+
+	for _, u := range users {
+		names = append(names, u.Name)
+	}
+
+	return names
+}
+
+func testCreate(t *testing.T, repo service.UserRepository, ids []int) {
+	ctx := context.Background()
+
+	seen := make(map[int]bool)
+
+	for _, id := range ids {
+		assert.Positive(t, id)
+		assert.False(t, seen[id], "id %d is not unique", id)
+
+		seen[id] = true
+	}
+
+	invalid := []model.User{
+		{Name: "Ivan1", Surname: "Ivanov", Age: 20, Gender: "male", Nationality: "RU"},
+		{Name: "Ivan", Surname: "Ivanov", Age: 0, Gender: "male", Nationality: "RU"},
+		{Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "unknown", Nationality: "RU"},
+		{Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "male", Nationality: "RUS"},
+	}
+
+	for i := range invalid {
+		_, err := repo.Create(ctx, &invalid[i])
+		assert.Error(t, err, "user %+v", invalid[i])
+	}
+
+	users, err := repo.Get(ctx, &model.UserFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, users, len(seed))
+}
+
+func testGetById(t *testing.T, repo service.UserRepository, ids []int) {
+	ctx := context.Background()
+
+	user, err := repo.GetUserById(ctx, ids[0])
+	assert.NoError(t, err)
+
+	expected := seed[0]
+	expected.Id = ids[0]
+	assert.Equal(t, &expected, user)
+
+	// NULL patronymic is empty
+	user, err = repo.GetUserById(ctx, ids[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "", user.Patronymic)
+
+	user, err = repo.GetUserById(ctx, ids[0], "name", "age")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.User{Name: "Ivan", Age: 20}, user)
+
+	_, err = repo.GetUserById(ctx, ids[0], "password")
+	assert.Error(t, err)
+
+	_, err = repo.GetUserById(ctx, -1)
+	assert.ErrorIs(t, err, model.ErrUserNotFound)
+}
+
+func testUpdate(t *testing.T, repo service.UserRepository, ids []int) {
+	ctx := context.Background()
+
+	updated := model.User{Name: "Ivan", Surname: "Petrov", Age: 21, Gender: "male", Nationality: "KZ"}
+
+	assert.NoError(t, repo.Update(ctx, ids[0], &updated))
+
+	user, err := repo.GetUserById(ctx, ids[0])
+	assert.NoError(t, err)
+
+	updated.Id = ids[0]
+	assert.Equal(t, &updated, user)
+
+	// Nothing to update
+	assert.ErrorIs(t, repo.Update(ctx, -1, &updated), model.ErrUserNotFound)
+
+	assert.Error(t, repo.Update(ctx, ids[0], &model.User{Name: "Ivan", Surname: "Petrov", Age: 200, Gender: "male", Nationality: "KZ"}))
+
+	// Other users are left as they are
+	user, err = repo.GetUserById(ctx, ids[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "Petrova", user.Surname)
+}
+
+func testDelete(t *testing.T, repo service.UserRepository, ids []int) {
+	ctx := context.Background()
+
+	assert.NoError(t, repo.Delete(ctx, ids[0]))
+
+	_, err := repo.GetUserById(ctx, ids[0])
+	assert.ErrorIs(t, err, model.ErrUserNotFound)
+
+	// Nothing to delete
+	assert.ErrorIs(t, repo.Delete(ctx, ids[0]), model.ErrUserNotFound)
+
+	users, err := repo.Get(ctx, &model.UserFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, users, len(seed)-1)
+}
+
+func testFilter(t *testing.T, repo service.UserRepository, ids []int) {
+	cond := func(field string, op model.FilterOp, values ...string) model.FilterExpr {
+		return model.FilterExpr{Op: op, Field: field, Values: values}
+	}
+
+	and := func(children ...model.FilterExpr) model.FilterExpr {
+		return model.FilterExpr{Op: model.FilterAnd, Children: children}
+	}
+
+	or := func(children ...model.FilterExpr) model.FilterExpr {
+		return model.FilterExpr{Op: model.FilterOr, Children: children}
+	}
+
+	testCases := []struct {
+		name          string
+		expr          model.FilterExpr
+		expectedNames []string
+		wantErr       bool
+	}{
+		{
+			name:          "no filter",
+			expectedNames: []string{"Ivan", "Galina", "Petr", "Anna", "Oleg"},
+		},
+
+		{
+			name:          "eq",
+			expr:          and(cond("nationality", model.FilterEq, "RU")),
+			expectedNames: []string{"Ivan", "Anna", "Oleg"},
+		},
+
+		{
+			name:          "eq is case sensitive",
+			expr:          and(cond("name", model.FilterEq, "ivan")),
+			expectedNames: nil,
+		},
+
+		{
+			name:          "ne matches null",
+			expr:          and(cond("patronymic", model.FilterNe, "Ivanovich")),
+			expectedNames: []string{"Galina", "Petr", "Anna", "Oleg"},
+		},
+
+		{
+			name:          "not in matches null",
+			expr:          and(cond("patronymic", model.FilterNotIn, "Ivanovich", "Petrovich")),
+			expectedNames: []string{"Galina", "Anna", "Oleg"},
+		},
+
+		{
+			name:          "in",
+			expr:          and(cond("nationality", model.FilterIn, "US", "UA")),
+			expectedNames: []string{"Galina", "Petr"},
+		},
+
+		{
+			name:          "not in",
+			expr:          and(cond("nationality", model.FilterNotIn, "RU", "UA")),
+			expectedNames: []string{"Galina"},
+		},
+
+		{
+			name:          "prefix ignores case",
+			expr:          and(cond("surname", model.FilterPrefix, "iVAN")),
+			expectedNames: []string{"Ivan", "Anna"},
+		},
+
+		{
+			name:          "contains",
+			expr:          and(cond("surname", model.FilterContains, "ov")),
+			expectedNames: []string{"Ivan", "Galina", "Petr", "Anna", "Oleg"},
+		},
+
+		{
+			name:          "age compared as number",
+			expr:          and(cond("age", model.FilterGte, "20"), cond("age", model.FilterLt, "35")),
+			expectedNames: []string{"Ivan", "Anna"},
+		},
+
+		{
+			name:          "null",
+			expr:          and(cond("patronymic", model.FilterNull)),
+			expectedNames: []string{"Galina", "Anna"},
+		},
+
+		{
+			name:          "not null",
+			expr:          and(cond("patronymic", model.FilterNotNull)),
+			expectedNames: []string{"Ivan", "Petr", "Oleg"},
+		},
+
+		{
+			name:          "or group",
+			expr:          and(cond("gender", model.FilterEq, "male"), or(cond("age", model.FilterLt, "18"), cond("nationality", model.FilterEq, "UA"))),
+			expectedNames: []string{"Petr", "Oleg"},
+		},
+
+		{
+			name:    "unknown field",
+			expr:    and(cond("password", model.FilterEq, "secret")),
+			wantErr: true,
+		},
+
+		{
+			name:    "age is not a number",
+			expr:    and(cond("age", model.FilterEq, "old")),
+			wantErr: true,
+		},
+
+		{
+			name:    "too many values",
+			expr:    and(cond("age", model.FilterGt, "1", "2")),
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			users, err := repo.Get(context.Background(), &model.UserFilter{Expr: tc.expr})
+
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedNames, names(users))
+		})
+	}
+}
+
+func testSort(t *testing.T, repo service.UserRepository, ids []int) {
+	testCases := []struct {
+		name          string
+		filter        model.UserFilter
+		expectedNames []string
+		wantErr       bool
+	}{
+		{
+			name:          "by name",
+			filter:        model.UserFilter{Sort: []model.SortField{{Field: "name"}}},
+			expectedNames: []string{"Anna", "Galina", "Ivan", "Oleg", "Petr"},
+		},
+
+		{
+			name:          "by age desc",
+			filter:        model.UserFilter{Sort: []model.SortField{{Field: "age", Desc: true}}},
+			expectedNames: []string{"Galina", "Petr", "Anna", "Ivan", "Oleg"},
+		},
+
+		{
+			name:          "tiebroken on id in direction of the last field",
+			filter:        model.UserFilter{Sort: []model.SortField{{Field: "nationality", Desc: true}}},
+			expectedNames: []string{"Galina", "Petr", "Oleg", "Anna", "Ivan"},
+		},
+
+		{
+			name:          "gender as text",
+			filter:        model.UserFilter{Sort: []model.SortField{{Field: "gender"}}},
+			expectedNames: []string{"Galina", "Anna", "Ivan", "Petr", "Oleg"},
+		},
+
+		{
+			name:          "page by gender",
+			filter:        model.UserFilter{Sort: []model.SortField{{Field: "gender", Desc: true}}, Limit: 2, Offset: 2},
+			expectedNames: []string{"Ivan", "Anna"},
+		},
+
+		{
+			name:          "nulls last",
+			filter:        model.UserFilter{Sort: []model.SortField{{Field: "patronymic"}}},
+			expectedNames: []string{"Ivan", "Oleg", "Petr", "Galina", "Anna"},
+		},
+
+		{
+			name:          "nulls first descending",
+			filter:        model.UserFilter{Sort: []model.SortField{{Field: "patronymic", Desc: true}}},
+			expectedNames: []string{"Anna", "Galina", "Petr", "Oleg", "Ivan"},
+		},
+
+		{
+			name:          "page",
+			filter:        model.UserFilter{Sort: []model.SortField{{Field: "name"}}, Limit: 2, Offset: 1},
+			expectedNames: []string{"Galina", "Ivan"},
+		},
+
+		{
+			name:          "page past the end",
+			filter:        model.UserFilter{Limit: 2, Offset: 10},
+			expectedNames: nil,
+		},
+
+		{
+			name:    "unknown field",
+			filter:  model.UserFilter{Sort: []model.SortField{{Field: "password"}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			users, err := repo.Get(context.Background(), &tc.filter)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedNames, names(users))
+		})
+	}
+}
+
+func testFields(t *testing.T, repo service.UserRepository, ids []int) {
+	users, err := repo.Get(context.Background(), &model.UserFilter{Fields: []string{"id", "surname"}, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.User{{Id: ids[0], Surname: "Ivanov"}}, users)
+
+	_, err = repo.Get(context.Background(), &model.UserFilter{Fields: []string{"password"}})
+	assert.Error(t, err)
+}
+
+func testSearch(t *testing.T, repo service.UserRepository, ids []int) {
+	ctx := context.Background()
+
+	matches, err := repo.Search(ctx, &model.UserSearch{Query: "Ivan", Threshold: 0.6, Limit: 10})
+	assert.NoError(t, err)
+
+	if assert.NotEmpty(t, matches) {
+		assert.Equal(t, ids[0], matches[0].Id)
+		assert.Equal(t, "name", matches[0].MatchedField)
+		assert.Equal(t, "<mark>Ivan</mark>", matches[0].Highlight)
+	}
+
+	// Prefix of a word
+	matches, err = repo.Search(ctx, &model.UserSearch{Query: "smir", Threshold: 0.6, Limit: 10})
+	assert.NoError(t, err)
+
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, ids[4], matches[0].Id)
+		assert.Equal(t, "surname", matches[0].MatchedField)
+		assert.Equal(t, "<mark>Smir</mark>nov", matches[0].Highlight)
+	}
+
+	// Typo
+	matches, err = repo.Search(ctx, &model.UserSearch{Query: "Sidorv", Threshold: 0.3, Limit: 10})
+	assert.NoError(t, err)
+
+	if assert.NotEmpty(t, matches) {
+		assert.Equal(t, ids[2], matches[0].Id)
+	}
+
+	matches, err = repo.Search(ctx, &model.UserSearch{Query: "Ivan", Threshold: 0.6, Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, matches, 1)
+
+	matches, err = repo.Search(ctx, &model.UserSearch{Query: "xyzzy", Threshold: 0.6, Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func testStats(t *testing.T, repo service.UserRepository, ids []int) {
+	ctx := context.Background()
+
+	stats, err := repo.Stats(ctx, &model.UserStatsRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.UserStats{{Count: 5, AvgAge: 26.4, AgeP50: 28, AgeP90: 38, AgeP99: 39.8}}, round(stats))
+
+	stats, err = repo.Stats(ctx, &model.UserStatsRequest{GroupBy: []string{"gender"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.UserStats{
+		{Gender: "male", Count: 3, AvgAge: 21.33, AgeP50: 20, AgeP90: 32, AgeP99: 34.7},
+		{Gender: "female", Count: 2, AvgAge: 34, AgeP50: 34, AgeP90: 38.8, AgeP99: 39.88},
+	}, round(stats))
+
+	stats, err = repo.Stats(ctx, &model.UserStatsRequest{GroupBy: []string{"nationality", "age_bucket"}, AgeBucket: 20})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.UserStats{
+		{Nationality: "RU", AgeBucket: &model.AgeBucket{From: 0, To: 19}, Count: 1, AvgAge: 9, AgeP50: 9, AgeP90: 9, AgeP99: 9},
+		{Nationality: "RU", AgeBucket: &model.AgeBucket{From: 20, To: 39}, Count: 2, AvgAge: 24, AgeP50: 24, AgeP90: 27.2, AgeP99: 27.92},
+		{Nationality: "UA", AgeBucket: &model.AgeBucket{From: 20, To: 39}, Count: 1, AvgAge: 35, AgeP50: 35, AgeP90: 35, AgeP99: 35},
+		{Nationality: "US", AgeBucket: &model.AgeBucket{From: 40, To: 59}, Count: 1, AvgAge: 40, AgeP50: 40, AgeP90: 40, AgeP99: 40},
+	}, round(stats))
+
+	// No users
+	stats, err = repo.Stats(ctx, &model.UserStatsRequest{Expr: model.FilterExpr{Op: model.FilterEq, Field: "age", Values: []string{"100"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []model.UserStats{{}}, stats)
+
+	stats, err = repo.Stats(ctx, &model.UserStatsRequest{
+		Expr:    model.FilterExpr{Op: model.FilterEq, Field: "age", Values: []string{"100"}},
+		GroupBy: []string{"gender"},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, stats)
+
+	_, err = repo.Stats(ctx, &model.UserStatsRequest{GroupBy: []string{"age_bucket"}})
+	assert.Error(t, err)
+
+	_, err = repo.Stats(ctx, &model.UserStatsRequest{GroupBy: []string{"surname"}})
+	assert.Error(t, err)
+}
+
+// Stats with averages and percentiles rounded to two decimals
+func round(stats []model.UserStats) []model.UserStats {
+	r := func(v float64) float64 {
+		return float64(int(v*100+0.5)) / 100
+	}
+
+	for i := range stats {
+		stats[i].AvgAge = r(stats[i].AvgAge)
+		stats[i].AgeP50 = r(stats[i].AgeP50)
+		stats[i].AgeP90 = r(stats[i].AgeP90)
+		stats[i].AgeP99 = r(stats[i].AgeP99)
+	}
+
+	return stats
+}
+
+func testExport(t *testing.T, repo service.UserRepository, ids []int) {
+	ctx := context.Background()
+
+	filter := &model.UserFilter{
+		Expr:   model.FilterExpr{Op: model.FilterEq, Field: "gender", Values: []string{"male"}},
+		Sort:   []model.SortField{{Field: "age"}},
+		Fields: []string{"name"},
+	}
+
+	var exported []model.User
+
+	err := repo.Export(ctx, filter, func(u *model.User) error {
+		exported = append(exported, *u)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []model.User{{Name: "Oleg"}, {Name: "Ivan"}, {Name: "Petr"}}, exported)
+
+	// Stopped by fn
+	errStop := errors.New("stop")
+	calls := 0
+
+	err = repo.Export(ctx, filter, func(u *model.User) error {
+		calls++
+		return errStop
+	})
+
+	assert.ErrorIs(t, err, errStop)
+	assert.Equal(t, 1, calls)
+}
+
+func testImport(t *testing.T, repo service.UserRepository, ids []int) {
+	ctx := context.Background()
+
+	imported, err := repo.Import(ctx, []model.User{
+		{Name: "Maria", Surname: "Kuznetsova", Age: 31, Gender: "female", Nationality: "BY"},
+		{Name: "Boris", Surname: "Kuznetsov", Patronymic: "Borisovich", Age: 60, Gender: "male", Nationality: "BY"},
+	})
+
+	assert.NoError(t, err)
+
+	if assert.Len(t, imported, 2) {
+		for _, u := range imported {
+			assert.NotZero(t, u.Id)
+
+			stored, err := repo.GetUserById(ctx, u.Id)
+			assert.NoError(t, err)
+			assert.Equal(t, u, *stored)
+		}
+	}
+
+	users, err := repo.Get(ctx, &model.UserFilter{
+		Expr: model.FilterExpr{Op: model.FilterEq, Field: "nationality", Values: []string{"BY"}},
+		Sort: []model.SortField{{Field: "name"}},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Boris", "Maria"}, names(users))
+
+	// All or none
+	_, err = repo.Import(ctx, []model.User{
+		{Name: "Olga", Surname: "Popova", Age: 25, Gender: "female", Nationality: "KZ"},
+		{Name: "Olga", Surname: "Popova", Age: 25, Gender: "female", Nationality: "Kazakhstan"},
+	})
+
+	assert.Error(t, err)
+
+	users, err = repo.Get(ctx, &model.UserFilter{
+		Expr: model.FilterExpr{Op: model.FilterEq, Field: "nationality", Values: []string{"KZ"}},
+	})
+
+	assert.NoError(t, err)
+	assert.Empty(t, users)
+}
@@ -0,0 +1,59 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	// Pure go driver, it builds without cgo
+	_ "modernc.org/sqlite"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/config"
+)
+
+// Database driver value selecting sqlite repository
+const Driver = "sqlite"
+
+// Pragmas of every connection unless url sets them. Writers of the pool wait for
+// each other instead of failing with SQLITE_BUSY and don't block readers.
+var defaultPragmas = []struct{ name, value string }{
+	{"busy_timeout", "5000"},
+	{"journal_mode", "WAL"},
+}
+
+// Open sqlite database with configured pool settings. Url is a file name or
+// file uri, pragmas of every connection are set with _pragma parameters, e.g.
+// "file:users.db?_pragma=busy_timeout(10000)"
+func Open(cfg config.Database) (*sql.DB, error) {
+	db, err := sql.Open(Driver, withDefaultPragmas(cfg.URL))
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: opening db: %w", err)
+	}
+
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+	return db, nil
+}
+
+// Add default pragmas not set by url
+func withDefaultPragmas(url string) string {
+	for _, pragma := range defaultPragmas {
+		if strings.Contains(url, "_pragma="+pragma.name+"(") {
+			continue
+		}
+
+		separator := "&"
+
+		if !strings.Contains(url, "?") {
+			separator = "?"
+		}
+
+		url += separator + "_pragma=" + pragma.name + "(" + pragma.value + ")"
+	}
+
+	return url
+}
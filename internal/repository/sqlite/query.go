@@ -0,0 +1,318 @@
+package sqlite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/emulate"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// Filtered fields and their columns
+var filterColumns = map[string]string{
+	"name":        "name",
+	"surname":     "surname",
+	"patronymic":  "patronymic",
+	"age":         "age",
+	"gender":      "gender",
+	"nationality": "nationality",
+}
+
+// Columns that may be NULL, negative conditions match NULL in them
+var nullableColumns = map[string]bool{
+	"patronymic": true,
+}
+
+// Gender is a text column, stats groups sort in order of postgres enum
+const genderOrder = "CASE gender WHEN 'male' THEN 0 ELSE 1 END"
+
+// Sortable fields and their expressions
+var sortColumns = map[string]string{
+	"id":          "id",
+	"name":        "name",
+	"surname":     "surname",
+	"patronymic":  "patronymic",
+	"age":         "age",
+	"gender":      "gender",
+	"nationality": "nationality",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Build query selecting filtered users, without limit if it is zero
+func usersQuery(userFilter *model.UserFilter, columns []string) (string, []interface{}, error) {
+	where, err := compileFilter(userFilter.Expr)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	orderBy, err := orderBy(userFilter.Sort)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	builder := sq.
+		Select(columns...).
+		From("users").
+		OrderBy(orderBy...)
+
+	if where != nil {
+		builder = builder.Where(where)
+	}
+
+	switch {
+	case userFilter.Limit > 0:
+		builder = builder.Limit(uint64(userFilter.Limit))
+
+		if userFilter.Offset > 0 {
+			builder = builder.Offset(uint64(userFilter.Offset))
+		}
+	case userFilter.Offset > 0:
+		// Offset needs a limit, negative one is none
+		builder = builder.Suffix("LIMIT -1 OFFSET ?", userFilter.Offset)
+	}
+
+	return builder.ToSql()
+}
+
+// ORDER BY expressions tiebroken on id the same way as postgres repository orders users.
+// NULLs are smallest in sqlite and largest in postgres, so their place is explicit.
+func orderBy(sort []model.SortField) ([]string, error) {
+	orderBy := make([]string, 0, len(sort)+1)
+	desc := false
+
+	for _, f := range sort {
+		column, ok := sortColumns[f.Field]
+
+		if !ok {
+			return nil, fmt.Errorf("filter: unknown sort field %q", f.Field)
+		}
+
+		desc = f.Desc
+
+		switch {
+		case desc && f.Field == "patronymic":
+			column += " DESC NULLS FIRST"
+		case desc:
+			column += " DESC"
+		case f.Field == "patronymic":
+			column += " NULLS LAST"
+		}
+
+		orderBy = append(orderBy, column)
+
+		// Ids are unique, nothing to tiebreak
+		if f.Field == "id" {
+			return orderBy, nil
+		}
+	}
+
+	if desc {
+		return append(orderBy, "id DESC"), nil
+	}
+
+	return append(orderBy, "id"), nil
+}
+
+// Build query selecting groups and ages of filtered users ordered by them,
+// sqlite has no percentiles so ages are aggregated by the caller
+func statsQuery(statsRequest *model.UserStatsRequest) (string, []interface{}, error) {
+	where, err := compileFilter(statsRequest.Expr)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	builder := sq.Select()
+	orderBy := make([]string, 0, len(statsRequest.GroupBy)+1)
+
+	for i, group := range statsRequest.GroupBy {
+		switch group {
+		case "gender":
+			builder = builder.Column(group)
+			orderBy = append(orderBy, genderOrder)
+		case "nationality":
+			builder = builder.Column(group)
+			orderBy = append(orderBy, strconv.Itoa(i+1))
+		case "age_bucket":
+			if statsRequest.AgeBucket <= 0 {
+				return "", nil, fmt.Errorf("stats: invalid age bucket %d", statsRequest.AgeBucket)
+			}
+
+			builder = builder.Column(sq.Expr("age / ? * ?", statsRequest.AgeBucket, statsRequest.AgeBucket))
+
+			// Ordered by select list position, parameterized expressions can't be repeated
+			orderBy = append(orderBy, strconv.Itoa(i+1))
+		default:
+			return "", nil, fmt.Errorf("stats: unknown group %q", group)
+		}
+	}
+
+	builder = builder.
+		Column("age").
+		From("users").
+		OrderBy(append(orderBy, "age")...)
+
+	if where != nil {
+		builder = builder.Where(where)
+	}
+
+	return builder.ToSql()
+}
+
+func compileFilter(e model.FilterExpr) (sq.Sqlizer, error) {
+	switch e.Op {
+	case "":
+		return nil, nil
+	case model.FilterAnd, model.FilterOr:
+		parts := make([]sq.Sqlizer, 0, len(e.Children))
+
+		for _, child := range e.Children {
+			part, err := compileFilter(child)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if part != nil {
+				parts = append(parts, part)
+			}
+		}
+
+		if len(parts) == 0 {
+			return nil, nil
+		}
+
+		if e.Op == model.FilterAnd {
+			return sq.And(parts), nil
+		}
+
+		return sq.Or(parts), nil
+	}
+
+	column, ok := filterColumns[e.Field]
+
+	if !ok {
+		return nil, fmt.Errorf("filter: unknown field %q", e.Field)
+	}
+
+	values, err := filterValues(e.Field, e.Values)
+
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.Op {
+	case model.FilterNull:
+		return sq.Eq{column: nil}, nil
+	case model.FilterNotNull:
+		return sq.NotEq{column: nil}, nil
+	case model.FilterIn:
+		return sq.Eq{column: values}, nil
+	case model.FilterNotIn:
+		if nullableColumns[column] {
+			return sq.Or{sq.NotEq{column: values}, sq.Eq{column: nil}}, nil
+		}
+
+		return sq.NotEq{column: values}, nil
+	}
+
+	if len(values) != 1 {
+		return nil, fmt.Errorf("filter: %s[%s] takes one value", e.Field, e.Op)
+	}
+
+	value := values[0]
+
+	// Like ignores case of ascii letters, names are ascii only
+	switch e.Op {
+	case model.FilterEq:
+		return sq.Eq{column: value}, nil
+	case model.FilterNe:
+		if nullableColumns[column] {
+			return sq.Expr(column+" IS DISTINCT FROM ?", value), nil
+		}
+
+		return sq.NotEq{column: value}, nil
+	case model.FilterGt:
+		return sq.Gt{column: value}, nil
+	case model.FilterGte:
+		return sq.GtOrEq{column: value}, nil
+	case model.FilterLt:
+		return sq.Lt{column: value}, nil
+	case model.FilterLte:
+		return sq.LtOrEq{column: value}, nil
+	case model.FilterPrefix:
+		return sq.Expr(column+` LIKE ? ESCAPE '\'`, likeEscaper.Replace(e.Values[0])+"%"), nil
+	case model.FilterContains:
+		return sq.Expr(column+` LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(e.Values[0])+"%"), nil
+	default:
+		return nil, fmt.Errorf("filter: unknown operator %q", e.Op)
+	}
+}
+
+// Values typed as their column
+func filterValues(field string, values []string) ([]interface{}, error) {
+	typed := make([]interface{}, 0, len(values))
+
+	for _, v := range values {
+		if field != "age" {
+			typed = append(typed, v)
+			continue
+		}
+
+		age, err := strconv.Atoi(v)
+
+		if err != nil {
+			return nil, fmt.Errorf("filter: age: %w", err)
+		}
+
+		typed = append(typed, age)
+	}
+
+	return typed, nil
+}
+
+// Folded names surrounded with spaces, names are ASCII letters so lower folds them
+const searchText = "(' ' || lower(name) || ' ' || lower(surname) || ' ' || lower(coalesce(patronymic, '')) || ' ')"
+
+// Build query of users emulate.Rank would match, best matches first up to limit.
+// Number of matching trigram patterns is the number of trigrams in common with query.
+func searchQuery(userSearch *model.UserSearch, columns []string) (string, []interface{}, error) {
+	patterns := emulate.TrigramPatterns(userSearch.Query)
+
+	common := make([]string, 0, len(patterns)+1)
+	commonArgs := make([]interface{}, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		common = append(common, "("+searchText+" LIKE ?)")
+		commonArgs = append(commonArgs, pattern)
+	}
+
+	commonCount := "(" + strings.Join(append(common, "0"), " + ") + ")"
+
+	var where sq.Sqlizer = sq.Expr(commonCount+" >= ?", append(commonArgs, userSearch.Threshold*float64(len(patterns)))...)
+
+	// Every term is a prefix of a word
+	if prefixes := emulate.PrefixPatterns(userSearch.Terms()); len(prefixes) > 0 {
+		prefixed := make(sq.And, 0, len(prefixes))
+
+		for _, pattern := range prefixes {
+			prefixed = append(prefixed, sq.Expr(searchText+" LIKE ?", pattern))
+		}
+
+		where = sq.Or{prefixed, where}
+	}
+
+	return sq.
+		Select(columns...).
+		From("users").
+		Where(where).
+		OrderByClause(commonCount+" DESC, id", commonArgs...).
+		Limit(uint64(userSearch.Limit)).
+		ToSql()
+}
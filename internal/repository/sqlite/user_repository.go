@@ -0,0 +1,501 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	sq "github.com/Masterminds/squirrel"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/emulate"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// UserRepository keeps users in sqlite database migrated with sqlite migrations.
+// It behaves like postgres repository, except that text is compared byte-wise
+// and search is ranked in go, see emulate.Rank.
+type UserRepository struct {
+	db *sql.DB
+}
+
+func New(db *sql.DB) *UserRepository {
+	return &UserRepository{
+		db: db,
+	}
+}
+
+// Get all users with filters and limit
+func (r *UserRepository) Get(ctx context.Context, userFilter *model.UserFilter) ([]model.User, error) {
+	slog.Info("sqlite: getting users")
+
+	var users []model.User
+
+	columns, err := model.Columns(userFilter.Fields)
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: getting users: %w", err)
+	}
+
+	query, args, err := usersQuery(userFilter, columns)
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: getting users: %w", err)
+	}
+
+	slog.Debug(fmt.Sprintf("sqlite: making db query: %s", query))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: getting users: %w", err)
+	}
+
+	defer rows.Close()
+
+	err = scanUsers(rows, columns, func(u *model.User) error {
+		users = append(users, *u)
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: getting users: %w", err)
+	}
+
+	slog.Info("sqlite: users were got successfully")
+
+	return users, nil
+}
+
+// Call fn for every user matching filter, fn stops the export by returning an error.
+// Users are read in a transaction, so changes made meanwhile are not seen.
+func (r *UserRepository) Export(ctx context.Context, userFilter *model.UserFilter, fn func(u *model.User) error) error {
+	slog.Info("sqlite: exporting users")
+
+	columns, err := model.Columns(userFilter.Fields)
+
+	if err != nil {
+		return fmt.Errorf("sqlite: exporting users: %w", err)
+	}
+
+	query, args, err := usersQuery(userFilter, columns)
+
+	if err != nil {
+		return fmt.Errorf("sqlite: exporting users: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+
+	if err != nil {
+		return fmt.Errorf("sqlite: exporting users: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	slog.Debug(fmt.Sprintf("sqlite: making db query: %s", query))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return fmt.Errorf("sqlite: exporting users: %w", err)
+	}
+
+	defer rows.Close()
+
+	exported := 0
+
+	err = scanUsers(rows, columns, func(u *model.User) error {
+		exported++
+		return fn(u)
+	})
+
+	if err != nil {
+		return fmt.Errorf("sqlite: exporting users: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("sqlite: %d users were exported successfully", exported))
+
+	return nil
+}
+
+// Delete user by id
+func (r *UserRepository) Delete(ctx context.Context, id int) error {
+	slog.Info(fmt.Sprintf("sqlite: deleting user %d", id))
+
+	query, args, err := sq.
+		Delete("users").
+		Where(sq.Eq{"id": id}).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("sqlite: deleting user %d: %w", id, err)
+	}
+
+	slog.Debug(fmt.Sprintf("sqlite: making db query: %s", query))
+
+	if err := r.exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("sqlite: deleting user %d: %w", id, err)
+	}
+
+	slog.Info(fmt.Sprintf("sqlite: user %d was deleted successfully", id))
+
+	return nil
+}
+
+// Update user
+func (r *UserRepository) Update(ctx context.Context, id int, u *model.User) error {
+	slog.Info(fmt.Sprintf("sqlite: updating user %d", id))
+
+	query, args, err := sq.
+		Update("users").
+		Set("name", u.Name).
+		Set("surname", u.Surname).
+		Set("patronymic", nullString(u.Patronymic)).
+		Set("age", u.Age).
+		Set("gender", u.Gender).
+		Set("nationality", u.Nationality).
+		Set("updated_at", sq.Expr("CURRENT_TIMESTAMP")).
+		Where(sq.Eq{"id": id}).
+		ToSql()
+
+	if err != nil {
+		return fmt.Errorf("sqlite: updating user %d: %w", id, err)
+	}
+
+	slog.Debug(fmt.Sprintf("sqlite: making db query: %s", query))
+
+	if err := r.exec(ctx, query, args...); err != nil {
+		return fmt.Errorf("sqlite: updating user %d: %w", id, err)
+	}
+
+	slog.Info(fmt.Sprintf("sqlite: user %d was updated successfully", id))
+
+	return nil
+}
+
+// Change a single user, model.ErrUserNotFound if there is none
+func (r *UserRepository) exec(ctx context.Context, query string, args ...interface{}) error {
+	result, err := r.db.ExecContext(ctx, query, args...)
+
+	if err != nil {
+		return err
+	}
+
+	changed, err := result.RowsAffected()
+
+	if err != nil {
+		return err
+	}
+
+	if changed == 0 {
+		return model.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Create new user
+func (r *UserRepository) Create(ctx context.Context, u *model.User) (int, error) {
+	slog.Info("sqlite: creating user")
+
+	var id int
+
+	query, args, err := sq.
+		Insert("users").
+		Columns(model.ImportColumns...).
+		Values(u.ImportValues()...).
+		Suffix("RETURNING id").
+		ToSql()
+
+	if err != nil {
+		return 0, fmt.Errorf("sqlite: creating user: %w", err)
+	}
+
+	slog.Debug(fmt.Sprintf("sqlite: making db query: %s", query))
+
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(&id); err != nil {
+		return 0, fmt.Errorf("sqlite: creating user: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("sqlite: user %d was created successfully", id))
+
+	return id, nil
+}
+
+// Insert users in a transaction, all of them or none. Returns imported users with their ids.
+func (r *UserRepository) Import(ctx context.Context, users []model.User) ([]model.User, error) {
+	slog.Info(fmt.Sprintf("sqlite: importing %d users", len(users)))
+
+	tx, err := r.db.BeginTx(ctx, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: importing users: %w", err)
+	}
+
+	defer tx.Rollback()
+
+	query, _, err := sq.
+		Insert("users").
+		Columns(model.ImportColumns...).
+		Values(make([]interface{}, len(model.ImportColumns))...).
+		Suffix("RETURNING id").
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: importing users: %w", err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, query)
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: importing users: %w", err)
+	}
+
+	defer stmt.Close()
+
+	imported := make([]model.User, len(users))
+
+	for i := range users {
+		imported[i] = users[i]
+
+		if err := stmt.QueryRowContext(ctx, users[i].ImportValues()...).Scan(&imported[i].Id); err != nil {
+			return nil, fmt.Errorf("sqlite: importing users: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("sqlite: importing users: %w", err)
+	}
+
+	slog.Info(fmt.Sprintf("sqlite: %d users were imported successfully", len(users)))
+
+	return imported, nil
+}
+
+// Get user by id, only given fields if any
+func (r *UserRepository) GetUserById(ctx context.Context, id int, fields ...string) (*model.User, error) {
+	slog.Info(fmt.Sprintf("sqlite: getting user %d", id))
+
+	user := &model.User{}
+
+	var patronymic sql.NullString
+
+	columns, err := model.Columns(fields)
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: getting user %d: %w", id, err)
+	}
+
+	query, args, err := sq.
+		Select(columns...).
+		From("users").
+		Where(sq.Eq{"id": id}).
+		ToSql()
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: getting user %d: %w", id, err)
+	}
+
+	slog.Debug(fmt.Sprintf("sqlite: making db query: %s", query))
+
+	if err := r.db.QueryRowContext(ctx, query, args...).Scan(user.ScanDest(columns, &patronymic)...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, model.ErrUserNotFound
+		}
+		return nil, fmt.Errorf("sqlite: getting user %d: %w", id, err)
+	}
+
+	user.Patronymic = patronymic.String
+
+	slog.Debug(fmt.Sprintf("sqlite: user %d was got successfully", id))
+
+	return user, nil
+}
+
+// Search users by names, sqlite has no trigram search so candidates are found
+// and ordered by LIKE patterns of query trigrams, then ranked in go
+func (r *UserRepository) Search(ctx context.Context, userSearch *model.UserSearch) ([]model.UserMatch, error) {
+	slog.Info("sqlite: searching users")
+
+	var users []model.User
+
+	columns, err := model.Columns(nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: searching users: %w", err)
+	}
+
+	query, args, err := searchQuery(userSearch, columns)
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: searching users: %w", err)
+	}
+
+	slog.Debug(fmt.Sprintf("sqlite: making db query: %s", query))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: searching users: %w", err)
+	}
+
+	defer rows.Close()
+
+	err = scanUsers(rows, columns, func(u *model.User) error {
+		users = append(users, *u)
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: searching users: %w", err)
+	}
+
+	matches := emulate.Rank(userSearch, users)
+
+	slog.Info(fmt.Sprintf("sqlite: %d users were found", len(matches)))
+
+	return matches, nil
+}
+
+// Count users and their ages by groups, ages are aggregated in go since sqlite has no percentiles
+func (r *UserRepository) Stats(ctx context.Context, statsRequest *model.UserStatsRequest) ([]model.UserStats, error) {
+	slog.Info("sqlite: getting users stats")
+
+	query, args, err := statsQuery(statsRequest)
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: getting users stats: %w", err)
+	}
+
+	slog.Debug(fmt.Sprintf("sqlite: making db query: %s", query))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, fmt.Errorf("sqlite: getting users stats: %w", err)
+	}
+
+	defer rows.Close()
+
+	var (
+		stats []model.UserStats
+		group *model.UserStats
+		ages  []int
+	)
+
+	// Rows are ordered by groups, a group ends where the next one starts
+	flush := func() {
+		if group == nil {
+			return
+		}
+
+		s := emulate.AggregateAges(ages)
+		s.Gender, s.Nationality, s.AgeBucket = group.Gender, group.Nationality, group.AgeBucket
+
+		stats = append(stats, s)
+	}
+
+	for rows.Next() {
+		var (
+			row model.UserStats
+			age int
+		)
+
+		if err := rows.Scan(append(groupDest(&row, statsRequest), &age)...); err != nil {
+			return nil, fmt.Errorf("sqlite: getting users stats: %w", err)
+		}
+
+		if row.AgeBucket != nil {
+			row.AgeBucket.To = row.AgeBucket.From + statsRequest.AgeBucket - 1
+		}
+
+		if group == nil || !sameGroup(group, &row) {
+			flush()
+
+			group, ages = &row, nil
+		}
+
+		ages = append(ages, age)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("sqlite: getting users stats: %w", err)
+	}
+
+	flush()
+
+	// Aggregate without groups is a single row even for no users
+	if len(statsRequest.GroupBy) == 0 && len(stats) == 0 {
+		stats = append(stats, emulate.AggregateAges(nil))
+	}
+
+	if stats == nil {
+		stats = make([]model.UserStats, 0)
+	}
+
+	slog.Info("sqlite: users stats were got successfully")
+
+	return stats, nil
+}
+
+// Scan destinations of group values in a row of stats query
+func groupDest(s *model.UserStats, statsRequest *model.UserStatsRequest) []interface{} {
+	dest := make([]interface{}, 0, len(statsRequest.GroupBy)+1)
+
+	for _, group := range statsRequest.GroupBy {
+		switch group {
+		case "gender":
+			dest = append(dest, &s.Gender)
+		case "nationality":
+			dest = append(dest, &s.Nationality)
+		case "age_bucket":
+			s.AgeBucket = &model.AgeBucket{}
+			dest = append(dest, &s.AgeBucket.From)
+		}
+	}
+
+	return dest
+}
+
+func sameGroup(a, b *model.UserStats) bool {
+	if a.Gender != b.Gender || a.Nationality != b.Nationality {
+		return false
+	}
+
+	if a.AgeBucket == nil || b.AgeBucket == nil {
+		return a.AgeBucket == b.AgeBucket
+	}
+
+	return *a.AgeBucket == *b.AgeBucket
+}
+
+// Scan rows of columns calling fn for every user
+func scanUsers(rows *sql.Rows, columns []string, fn func(u *model.User) error) error {
+	for rows.Next() {
+		var (
+			user       model.User
+			patronymic sql.NullString
+		)
+
+		if err := rows.Scan(user.ScanDest(columns, &patronymic)...); err != nil {
+			return err
+		}
+
+		user.Patronymic = patronymic.String
+
+		if err := fn(&user); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// Store empty strings as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{
+		String: s,
+		Valid:  s != "",
+	}
+}
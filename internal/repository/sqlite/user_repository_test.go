@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/config"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/repotest"
+	"github.com/sletkov/effective-mobile-test-task/internal/service"
+	"github.com/sletkov/effective-mobile-test-task/migrations"
+)
+
+func TestUserRepository(t *testing.T) {
+	goose.SetBaseFS(migrations.FS)
+
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.Run(t, func(t *testing.T) service.UserRepository {
+		db, err := Open(config.Database{
+			URL:          "file:" + filepath.Join(t.TempDir(), "users.db"),
+			MaxOpenConns: 4,
+			MaxIdleConns: 4,
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() { db.Close() })
+
+		if err := goose.Up(db, migrations.SQLiteDir); err != nil {
+			t.Fatal(err)
+		}
+
+		return New(db)
+	})
+}
+
+func TestOpenPragmas(t *testing.T) {
+	assert.Equal(t, "users.db?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", withDefaultPragmas("users.db"))
+	assert.Equal(t, "file:users.db?_pragma=busy_timeout(100)&_pragma=journal_mode(WAL)", withDefaultPragmas("file:users.db?_pragma=busy_timeout(100)"))
+
+	goose.SetBaseFS(migrations.FS)
+
+	if err := goose.SetDialect("sqlite3"); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(config.Database{
+		URL:          filepath.Join(t.TempDir(), "users.db"),
+		MaxOpenConns: 8,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if err := goose.Up(db, migrations.SQLiteDir); err != nil {
+		t.Fatal(err)
+	}
+
+	var journalMode string
+
+	assert.NoError(t, db.QueryRow("PRAGMA journal_mode").Scan(&journalMode))
+	assert.Equal(t, "wal", journalMode)
+
+	// Concurrent writers wait for each other
+	repo := New(db)
+
+	var wg sync.WaitGroup
+
+	errs := make(chan error, 32)
+
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			_, err := repo.Import(context.Background(), []model.User{
+				{Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "male", Nationality: "RU"},
+				{Name: "Anna", Surname: "Ivanova", Age: 30, Gender: "female", Nationality: "RU"},
+			})
+
+			errs <- err
+		}()
+	}
+
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+}
@@ -1,8 +1,8 @@
 // Code generated by MockGen. DO NOT EDIT.
 // Source: controller.go
 
-// Package mock_service is a generated GoMock package.
-package mock_service
+// Package mock_v1 is a generated GoMock package.
+package mock_v1
 
 import (
 	context "context"
@@ -63,6 +63,20 @@ func (mr *MockUserServiceMockRecorder) Delete(ctx, id interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockUserService)(nil).Delete), ctx, id)
 }
 
+// Export mocks base method.
+func (m *MockUserService) Export(ctx context.Context, userFilter *domain.UserFilter, fn func(*domain.User) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Export", ctx, userFilter, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Export indicates an expected call of Export.
+func (mr *MockUserServiceMockRecorder) Export(ctx, userFilter, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Export", reflect.TypeOf((*MockUserService)(nil).Export), ctx, userFilter, fn)
+}
+
 // Get mocks base method.
 func (m *MockUserService) Get(ctx context.Context, userFilter *domain.UserFilter) ([]domain.User, error) {
 	m.ctrl.T.Helper()
@@ -79,18 +93,68 @@ func (mr *MockUserServiceMockRecorder) Get(ctx, userFilter interface{}) *gomock.
 }
 
 // GetById mocks base method.
-func (m *MockUserService) GetById(ctx context.Context, id int) (*domain.User, error) {
+func (m *MockUserService) GetById(ctx context.Context, id int, fields ...string) (*domain.User, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetById", ctx, id)
+	varargs := []interface{}{ctx, id}
+	for _, a := range fields {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "GetById", varargs...)
 	ret0, _ := ret[0].(*domain.User)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // GetById indicates an expected call of GetById.
-func (mr *MockUserServiceMockRecorder) GetById(ctx, id interface{}) *gomock.Call {
+func (mr *MockUserServiceMockRecorder) GetById(ctx, id interface{}, fields ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, id}, fields...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetById", reflect.TypeOf((*MockUserService)(nil).GetById), varargs...)
+}
+
+// Import mocks base method.
+func (m *MockUserService) Import(ctx context.Context, rows []domain.ImportRow, opts domain.ImportOptions) (*domain.ImportReport, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Import", ctx, rows, opts)
+	ret0, _ := ret[0].(*domain.ImportReport)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Import indicates an expected call of Import.
+func (mr *MockUserServiceMockRecorder) Import(ctx, rows, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Import", reflect.TypeOf((*MockUserService)(nil).Import), ctx, rows, opts)
+}
+
+// Search mocks base method.
+func (m *MockUserService) Search(ctx context.Context, userSearch *domain.UserSearch) ([]domain.UserMatch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, userSearch)
+	ret0, _ := ret[0].([]domain.UserMatch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockUserServiceMockRecorder) Search(ctx, userSearch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockUserService)(nil).Search), ctx, userSearch)
+}
+
+// Stats mocks base method.
+func (m *MockUserService) Stats(ctx context.Context, statsRequest *domain.UserStatsRequest) ([]domain.UserStats, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats", ctx, statsRequest)
+	ret0, _ := ret[0].([]domain.UserStats)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockUserServiceMockRecorder) Stats(ctx, statsRequest interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetById", reflect.TypeOf((*MockUserService)(nil).GetById), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockUserService)(nil).Stats), ctx, statsRequest)
 }
 
 // Update mocks base method.
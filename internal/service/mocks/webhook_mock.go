@@ -0,0 +1,138 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: webhook.go
+
+// Package mock_v1 is a generated GoMock package.
+package mock_v1
+
+import (
+	context "context"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+	domain "github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+// MockWebhookService is a mock of WebhookService interface.
+type MockWebhookService struct {
+	ctrl     *gomock.Controller
+	recorder *MockWebhookServiceMockRecorder
+}
+
+// MockWebhookServiceMockRecorder is the mock recorder for MockWebhookService.
+type MockWebhookServiceMockRecorder struct {
+	mock *MockWebhookService
+}
+
+// NewMockWebhookService creates a new mock instance.
+func NewMockWebhookService(ctrl *gomock.Controller) *MockWebhookService {
+	mock := &MockWebhookService{ctrl: ctrl}
+	mock.recorder = &MockWebhookServiceMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockWebhookService) EXPECT() *MockWebhookServiceMockRecorder {
+	return m.recorder
+}
+
+// CreateSubscription mocks base method.
+func (m *MockWebhookService) CreateSubscription(ctx context.Context, s *domain.Subscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CreateSubscription", ctx, s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CreateSubscription indicates an expected call of CreateSubscription.
+func (mr *MockWebhookServiceMockRecorder) CreateSubscription(ctx, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateSubscription", reflect.TypeOf((*MockWebhookService)(nil).CreateSubscription), ctx, s)
+}
+
+// DeleteSubscription mocks base method.
+func (m *MockWebhookService) DeleteSubscription(ctx context.Context, id int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteSubscription", ctx, id)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteSubscription indicates an expected call of DeleteSubscription.
+func (mr *MockWebhookServiceMockRecorder) DeleteSubscription(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteSubscription", reflect.TypeOf((*MockWebhookService)(nil).DeleteSubscription), ctx, id)
+}
+
+// GetDeliveries mocks base method.
+func (m *MockWebhookService) GetDeliveries(ctx context.Context, filter *domain.DeliveryFilter) ([]domain.Delivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDeliveries", ctx, filter)
+	ret0, _ := ret[0].([]domain.Delivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDeliveries indicates an expected call of GetDeliveries.
+func (mr *MockWebhookServiceMockRecorder) GetDeliveries(ctx, filter interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDeliveries", reflect.TypeOf((*MockWebhookService)(nil).GetDeliveries), ctx, filter)
+}
+
+// GetSubscriptionById mocks base method.
+func (m *MockWebhookService) GetSubscriptionById(ctx context.Context, id int) (*domain.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubscriptionById", ctx, id)
+	ret0, _ := ret[0].(*domain.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubscriptionById indicates an expected call of GetSubscriptionById.
+func (mr *MockWebhookServiceMockRecorder) GetSubscriptionById(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubscriptionById", reflect.TypeOf((*MockWebhookService)(nil).GetSubscriptionById), ctx, id)
+}
+
+// GetSubscriptions mocks base method.
+func (m *MockWebhookService) GetSubscriptions(ctx context.Context) ([]domain.Subscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetSubscriptions", ctx)
+	ret0, _ := ret[0].([]domain.Subscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetSubscriptions indicates an expected call of GetSubscriptions.
+func (mr *MockWebhookServiceMockRecorder) GetSubscriptions(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetSubscriptions", reflect.TypeOf((*MockWebhookService)(nil).GetSubscriptions), ctx)
+}
+
+// Redeliver mocks base method.
+func (m *MockWebhookService) Redeliver(ctx context.Context, subscriptionId int, id int64) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Redeliver", ctx, subscriptionId, id)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Redeliver indicates an expected call of Redeliver.
+func (mr *MockWebhookServiceMockRecorder) Redeliver(ctx, subscriptionId, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Redeliver", reflect.TypeOf((*MockWebhookService)(nil).Redeliver), ctx, subscriptionId, id)
+}
+
+// UpdateSubscription mocks base method.
+func (m *MockWebhookService) UpdateSubscription(ctx context.Context, id int, s *domain.Subscription) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateSubscription", ctx, id, s)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateSubscription indicates an expected call of UpdateSubscription.
+func (mr *MockWebhookServiceMockRecorder) UpdateSubscription(ctx, id, s interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateSubscription", reflect.TypeOf((*MockWebhookService)(nil).UpdateSubscription), ctx, id, s)
+}
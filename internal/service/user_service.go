@@ -2,12 +2,20 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/sletkov/effective-mobile-test-task/internal/converter"
 	"github.com/sletkov/effective-mobile-test-task/internal/domain"
 	utils "github.com/sletkov/effective-mobile-test-task/internal/pkg"
+	"github.com/sletkov/effective-mobile-test-task/internal/pkg/cache"
 	repoModel "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+	httptransport "github.com/sletkov/effective-mobile-test-task/internal/transport/http"
 )
 
 //go:generate mockgen -source=user_service.go -destination=../repository/postgres/mocks/mock.go
@@ -17,25 +25,81 @@ type UserRepository interface {
 	Delete(ctx context.Context, id int) error
 	Update(ctx context.Context, id int, u *repoModel.User) error
 	Create(ctx context.Context, u *repoModel.User) (int, error)
-	GetUserById(ctx context.Context, id int) (*repoModel.User, error)
+	GetUserById(ctx context.Context, id int, fields ...string) (*repoModel.User, error)
+	Search(ctx context.Context, userSearch *repoModel.UserSearch) ([]repoModel.UserMatch, error)
+	Stats(ctx context.Context, statsRequest *repoModel.UserStatsRequest) ([]repoModel.UserStats, error)
+	Export(ctx context.Context, userFilter *repoModel.UserFilter, fn func(u *repoModel.User) error) error
+	Import(ctx context.Context, users []repoModel.User) ([]repoModel.User, error)
 }
 
 type Transport interface {
-	Get(ctx context.Context, url string) (*http.Response, error)
+	Get(ctx context.Context, url string) (*httptransport.Response, error)
 }
 
+const (
+	defaultAgifyURL       = "https://api.agify.io/"
+	defaultGenderizeURL   = "https://api.genderize.io/"
+	defaultNationalizeURL = "https://api.nationalize.io/"
+)
+
 type UserService struct {
-	repository UserRepository
-	transport  Transport
+	repository     UserRepository
+	transport      Transport
+	agifyURL       string
+	genderizeURL   string
+	nationalizeURL string
+
+	// Recently computed stats by request, nil disables caching
+	statsCache *cache.Cache[string, []domain.UserStats]
+
+	hooks []Hook
+}
+
+// Called after a user is created, updated or deleted with event type and the user,
+// only id of deleted users is known. Imported users are passed one by one after import is committed.
+type Hook func(ctx context.Context, eventType string, u *domain.User)
+
+type Option func(s *UserService)
+
+// Use custom enrichment api base urls
+func WithProviders(agifyURL, genderizeURL, nationalizeURL string) Option {
+	return func(s *UserService) {
+		s.agifyURL = agifyURL
+		s.genderizeURL = genderizeURL
+		s.nationalizeURL = nationalizeURL
+	}
+}
+
+// Cache stats for ttl, they are expensive to compute and may be slightly stale
+func WithStatsCache(size int, ttl time.Duration) Option {
+	return func(s *UserService) {
+		s.statsCache = cache.New[string, []domain.UserStats](size, ttl)
+	}
 }
 
-func New(repository UserRepository, transport Transport) *UserService {
-	return &UserService{
-		repository: repository,
-		transport:  transport,
+// Call hooks after every change of a user
+func WithHooks(hooks ...Hook) Option {
+	return func(s *UserService) {
+		s.hooks = append(s.hooks, hooks...)
 	}
 }
 
+func New(repository UserRepository, transport Transport, opts ...Option) *UserService {
+	s := &UserService{
+		repository:     repository,
+		transport:      transport,
+		agifyURL:       defaultAgifyURL,
+		genderizeURL:   defaultGenderizeURL,
+		nationalizeURL: defaultNationalizeURL,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
 // Get all users with filters and limit
 func (s *UserService) Get(ctx context.Context, userFilter *domain.UserFilter) ([]domain.User, error) {
 	users := make([]domain.User, 0)
@@ -53,14 +117,81 @@ func (s *UserService) Get(ctx context.Context, userFilter *domain.UserFilter) ([
 	return users, nil
 }
 
+// Search users by names
+func (s *UserService) Search(ctx context.Context, userSearch *domain.UserSearch) ([]domain.UserMatch, error) {
+	matches := make([]domain.UserMatch, 0)
+
+	repoMatches, err := s.repository.Search(ctx, converter.ToUserSearchFromService(userSearch))
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, m := range repoMatches {
+		matches = append(matches, *converter.ToUserMatchFromRepo(&m))
+	}
+
+	return matches, nil
+}
+
+// Stream all users matching filter to fn
+func (s *UserService) Export(ctx context.Context, userFilter *domain.UserFilter, fn func(u *domain.User) error) error {
+	return s.repository.Export(ctx, converter.ToUserFilterFromService(userFilter), func(u *repoModel.User) error {
+		return fn(converter.ToUserFromRepo(u))
+	})
+}
+
+// Count users and their ages by groups
+func (s *UserService) Stats(ctx context.Context, statsRequest *domain.UserStatsRequest) ([]domain.UserStats, error) {
+	var key string
+
+	if s.statsCache != nil {
+		data, err := json.Marshal(statsRequest)
+
+		if err != nil {
+			return nil, err
+		}
+
+		key = string(data)
+
+		if stats, ok := s.statsCache.Get(key); ok {
+			return stats, nil
+		}
+	}
+
+	repoStats, err := s.repository.Stats(ctx, converter.ToUserStatsRequestFromService(statsRequest))
+
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]domain.UserStats, 0, len(repoStats))
+
+	for _, st := range repoStats {
+		stats = append(stats, *converter.ToUserStatsFromRepo(&st))
+	}
+
+	if s.statsCache != nil {
+		s.statsCache.Set(key, stats)
+	}
+
+	return stats, nil
+}
+
 // Delete user by id
 func (s *UserService) Delete(ctx context.Context, id int) error {
 	err := s.repository.Delete(ctx, id)
 
 	if err != nil {
+		if errors.Is(err, repoModel.ErrUserNotFound) {
+			return domain.ErrUserNotFound
+		}
+
 		return err
 	}
 
+	s.notify(ctx, domain.EventUserDeleted, &domain.User{Id: id})
+
 	return nil
 }
 
@@ -69,67 +200,255 @@ func (s *UserService) Update(ctx context.Context, id int, u *domain.User) error
 	err := s.repository.Update(ctx, id, converter.ToUserFromService(u))
 
 	if err != nil {
+		if errors.Is(err, repoModel.ErrUserNotFound) {
+			return domain.ErrUserNotFound
+		}
+
 		return err
 	}
 
+	updated := *u
+	updated.Id = id
+
+	s.notify(ctx, domain.EventUserUpdated, &updated)
+
 	return nil
 }
 
 // Create new user
 func (s *UserService) Create(ctx context.Context, u *domain.User) error {
 
+	// Add age, gender and nationality to user
+	if err := s.enrich(ctx, u); err != nil {
+		return err
+	}
+
+	if err := unknownFields(u); err != nil {
+		return err
+	}
+
+	// Save user into db
+	id, err := s.repository.Create(ctx, converter.ToUserFromService(u))
+
+	if err != nil {
+		return err
+	}
+
+	u.Id = id
+
+	s.notify(ctx, domain.EventUserCreated, u)
+
+	return nil
+}
+
+// Pass change of user to hooks
+func (s *UserService) notify(ctx context.Context, eventType string, u *domain.User) {
+	for _, hook := range s.hooks {
+		hook(ctx, eventType, u)
+	}
+}
+
+// Import valid rows, rows that can't be imported are reported with their lines
+func (s *UserService) Import(ctx context.Context, rows []domain.ImportRow, opts domain.ImportOptions) (*domain.ImportReport, error) {
+	report := &domain.ImportReport{
+		Total:  len(rows),
+		DryRun: opts.DryRun,
+		Errors: make([]domain.ImportError, 0),
+	}
+
+	users := make([]repoModel.User, 0, len(rows))
+
+	// Names repeat a lot, so each of them is enriched once
+	enriched := make(map[string]*domain.User)
+
+	for _, row := range rows {
+		u := row.User
+
+		switch {
+		case opts.Enrich && opts.DryRun:
+			// Enrichment would use api quota for nothing, so rows
+			// that need it are neither valid nor invalid
+			if len(missingFields(&u)) > 0 {
+				report.Unverified++
+				continue
+			}
+		case opts.Enrich:
+			if len(missingFields(&u)) == 0 {
+				break
+			}
+
+			known, ok := enriched[u.Name]
+
+			if !ok {
+				known = &domain.User{Name: u.Name}
+
+				if err := s.enrich(ctx, known); err != nil {
+					// Other rows would fail the same way
+					if errors.Is(err, domain.ErrEnrichmentUnavailable) {
+						return nil, err
+					}
+
+					report.Errors = append(report.Errors, domain.ImportError{Line: row.Line, Error: err.Error()})
+					continue
+				}
+
+				enriched[u.Name] = known
+			}
+
+			fillMissing(&u, known)
+
+			if err := unknownFields(&u); err != nil {
+				report.Errors = append(report.Errors, domain.ImportError{Line: row.Line, Error: err.Error()})
+				continue
+			}
+		default:
+			if missing := missingFields(&u); len(missing) > 0 {
+				report.Errors = append(report.Errors, domain.ImportError{
+					Line:  row.Line,
+					Error: fmt.Sprintf("%s required without enrichment", strings.Join(missing, ", ")),
+				})
+				continue
+			}
+		}
+
+		users = append(users, *converter.ToUserFromService(&u))
+	}
+
+	report.Valid = len(users)
+
+	if opts.DryRun || len(users) == 0 {
+		return report, nil
+	}
+
+	imported, err := s.repository.Import(ctx, users)
+
+	if err != nil {
+		return nil, err
+	}
+
+	report.Imported = len(imported)
+
+	for i := range imported {
+		s.notify(ctx, domain.EventUserCreated, converter.ToUserFromRepo(&imported[i]))
+	}
+
+	return report, nil
+}
+
+// Add age, gender and nationality to user by name, the ones apis don't know are left empty
+func (s *UserService) enrich(ctx context.Context, u *domain.User) error {
+
 	// Get response from 3rd-party api
-	ageResponse, err := s.transport.Get(ctx, "https://api.agify.io/?name="+u.Name)
+	ageData, err := s.fetch(ctx, s.agifyURL, u.Name)
 
 	if err != nil {
 		return err
 	}
 
 	// Add age to user
-	if err := utils.Agify(ageResponse, u); err != nil {
+	if err := utils.Agify(ageData, u); err != nil {
 		return err
 	}
 
 	// Get response from 3rd-party api
-	genderResponse, err := s.transport.Get(ctx, "https://api.genderize.io/?name="+u.Name)
+	genderData, err := s.fetch(ctx, s.genderizeURL, u.Name)
 
 	if err != nil {
 		return err
 	}
 
 	// Add gender to user
-	if err := utils.Genderize(genderResponse, u); err != nil {
+	if err := utils.Genderize(genderData, u); err != nil {
 		return err
 	}
 
 	// Get response from 3rd-party api
-	nationalityResponse, err := s.transport.Get(ctx, "https://api.nationalize.io/?name="+u.Name)
+	nationalityData, err := s.fetch(ctx, s.nationalizeURL, u.Name)
 
 	if err != nil {
 		return err
 	}
 
 	// Add nationality to user
-	if err := utils.Nationalize(nationalityResponse, u); err != nil {
+	if err := utils.Nationalize(nationalityData, u); err != nil && !errors.Is(err, utils.ErrUnknownNationality) {
 		return err
 	}
 
-	// Save user into db
-	_, err = s.repository.Create(ctx, converter.ToUserFromService(u))
+	return nil
+}
 
-	if err != nil {
-		return nil
+// Copy enriched fields user doesn't have
+func fillMissing(u *domain.User, enriched *domain.User) {
+	if u.Age == 0 {
+		u.Age = enriched.Age
+	}
+
+	if u.Gender == "" {
+		u.Gender = enriched.Gender
+	}
+
+	if u.Nationality == "" {
+		u.Nationality = enriched.Nationality
+	}
+}
+
+// Fields filled by enrichment user doesn't have
+func missingFields(u *domain.User) []string {
+	var missing []string
+
+	if u.Age == 0 {
+		missing = append(missing, "age")
+	}
+
+	if u.Gender == "" {
+		missing = append(missing, "gender")
+	}
+
+	if u.Nationality == "" {
+		missing = append(missing, "nationality")
+	}
+
+	return missing
+}
+
+// Fail if user has fields enrichment couldn't fill, users table requires them
+func unknownFields(u *domain.User) error {
+	if missing := missingFields(u); len(missing) > 0 {
+		return fmt.Errorf("%w: no %s for %s", domain.ErrUnknownName, strings.Join(missing, ", "), u.Name)
 	}
 
 	return nil
 }
 
-func (s *UserService) GetById(ctx context.Context, id int) (*domain.User, error) {
-	user, err := s.repository.GetUserById(ctx, id)
+// Get response body from 3rd-party api by name
+func (s *UserService) fetch(ctx context.Context, baseURL, name string) ([]byte, error) {
+	response, err := s.transport.Get(ctx, baseURL+"?name="+url.QueryEscape(name))
 
 	if err != nil {
 		return nil, err
 	}
 
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests:
+		return nil, fmt.Errorf("%w: %s quota exceeded", domain.ErrEnrichmentUnavailable, baseURL)
+	case response.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("service: %s responded with status %d", baseURL, response.StatusCode)
+	}
+
+	return response.Body, nil
+}
+
+// Get user by id, only given fields if any
+func (s *UserService) GetById(ctx context.Context, id int, fields ...string) (*domain.User, error) {
+	user, err := s.repository.GetUserById(ctx, id, fields...)
+
+	if err != nil {
+		if errors.Is(err, repoModel.ErrUserNotFound) {
+			return nil, domain.ErrUserNotFound
+		}
+
+		return nil, err
+	}
+
 	return converter.ToUserFromRepo(user), nil
 }
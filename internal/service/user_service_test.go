@@ -2,15 +2,27 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	"github.com/sletkov/effective-mobile-test-task/internal/converter"
 	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	"github.com/sletkov/effective-mobile-test-task/internal/fakeenrich"
+	"github.com/sletkov/effective-mobile-test-task/internal/repository/memory"
 	mock_postgres "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/mocks"
 	repoModel "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+	httptransport "github.com/sletkov/effective-mobile-test-task/internal/transport/http"
 	mock_httptransport "github.com/sletkov/effective-mobile-test-task/internal/transport/http/mocks"
 	"github.com/stretchr/testify/assert"
 )
@@ -136,6 +148,46 @@ func TestServiceDelete(t *testing.T) {
 		})
 	}
 }
+func TestServiceHooks(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	ctx := context.Background()
+
+	repo := mock_postgres.NewMockUserRepository(c)
+	repo.EXPECT().Update(ctx, 3, gomock.Any()).Return(nil)
+	repo.EXPECT().Delete(ctx, 4).Return(nil)
+	repo.EXPECT().Delete(ctx, 5).Return(errors.New("connection refused"))
+	repo.EXPECT().Delete(ctx, 6).Return(fmt.Errorf("postgres: deleting user 6: %w", repoModel.ErrUserNotFound))
+	repo.EXPECT().Update(ctx, 7, gomock.Any()).Return(fmt.Errorf("postgres: updating user 7: %w", repoModel.ErrUserNotFound))
+
+	type change struct {
+		eventType string
+		user      domain.User
+	}
+
+	var changes []change
+
+	service := New(repo, mock_httptransport.NewMockTransport(c), WithHooks(func(ctx context.Context, eventType string, u *domain.User) {
+		changes = append(changes, change{eventType: eventType, user: *u})
+	}))
+
+	assert.NoError(t, service.Update(ctx, 3, &domain.User{Name: "Ivan", Surname: "Ivanov", Age: 20}))
+	assert.NoError(t, service.Delete(ctx, 4))
+
+	// Failed changes are not passed to hooks
+	assert.Error(t, service.Delete(ctx, 5))
+
+	// Neither are changes of missing users
+	assert.ErrorIs(t, service.Delete(ctx, 6), domain.ErrUserNotFound)
+	assert.ErrorIs(t, service.Update(ctx, 7, &domain.User{Name: "Ivan", Surname: "Ivanov", Age: 20}), domain.ErrUserNotFound)
+
+	assert.Equal(t, []change{
+		{eventType: domain.EventUserUpdated, user: domain.User{Id: 3, Name: "Ivan", Surname: "Ivanov", Age: 20}},
+		{eventType: domain.EventUserDeleted, user: domain.User{Id: 4}},
+	}, changes)
+}
+
 func TestServiceUpdate(t *testing.T) {
 	type mockRepoBehavior func(r *mock_postgres.MockUserRepository, ctx context.Context, id int, user *repoModel.User)
 
@@ -224,3 +276,499 @@ func TestServiceGetById(t *testing.T) {
 	}
 
 }
+
+func TestServiceStats(t *testing.T) {
+	statsRequest := &domain.UserStatsRequest{
+		Expr:      domain.Cond("gender", domain.FilterEq, "female"),
+		GroupBy:   []string{domain.GroupByAgeBucket},
+		AgeBucket: 10,
+	}
+
+	repoStats := []repoModel.UserStats{
+		{AgeBucket: &repoModel.AgeBucket{From: 40, To: 49}, Count: 2, AvgAge: 43, AgeP50: 43, AgeP90: 45.4, AgeP99: 45.94},
+	}
+
+	expectedStats := []domain.UserStats{
+		{AgeBucket: &domain.AgeBucket{From: 40, To: 49}, Count: 2, AvgAge: 43, AgeP50: 43, AgeP90: 45.4, AgeP99: 45.94},
+	}
+
+	testCases := []struct {
+		name          string
+		opts          []Option
+		expectedCalls int
+	}{
+		{
+			name:          "without cache",
+			expectedCalls: 2,
+		},
+
+		{
+			name:          "with cache",
+			opts:          []Option{WithStatsCache(10, time.Minute)},
+			expectedCalls: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_postgres.NewMockUserRepository(c)
+			repo.EXPECT().
+				Stats(context.Background(), converter.ToUserStatsRequestFromService(statsRequest)).
+				Return(repoStats, nil).
+				Times(tc.expectedCalls)
+
+			service := New(repo, mock_httptransport.NewMockTransport(c), tc.opts...)
+
+			for i := 0; i < 2; i++ {
+				stats, err := service.Stats(context.Background(), statsRequest)
+
+				assert.NoError(t, err)
+				assert.Equal(t, expectedStats, stats)
+			}
+		})
+	}
+}
+
+var cassetteMode = flag.String("cassette-mode", "replay", "enrichment api cassettes mode: replay (fail on unrecorded requests), auto or record")
+
+// Transport replaying recorded enrichment api exchanges
+func newCassetteTransport(t *testing.T, name string) *httptransport.Recorder {
+	mode, err := httptransport.ParseMode(*cassetteMode)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder, err := httptransport.NewRecorder(
+		httptransport.New(http.DefaultClient),
+		filepath.Join("testdata", "cassettes", name+".json"),
+		mode,
+	)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		if err := recorder.Save(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	return recorder
+}
+
+func TestServiceCreate(t *testing.T) {
+	type mockRepoBehavior func(r *mock_postgres.MockUserRepository, ctx context.Context)
+
+	testCases := []struct {
+		name string
+		mockRepoBehavior
+		user          *domain.User
+		expectedUser  *domain.User
+		expectedError error
+	}{
+		{
+			name: "OK",
+			mockRepoBehavior: func(r *mock_postgres.MockUserRepository, ctx context.Context) {
+				r.EXPECT().Create(ctx, &repoModel.User{
+					Name:        "Ivan",
+					Surname:     "Ivanov",
+					Patronymic:  "Ivanovich",
+					Age:         51,
+					Gender:      "male",
+					Nationality: "HR",
+				}).Return(1, nil)
+			},
+			user: &domain.User{
+				Name:       "Ivan",
+				Surname:    "Ivanov",
+				Patronymic: "Ivanovich",
+			},
+			expectedUser: &domain.User{
+				Id:          1,
+				Name:        "Ivan",
+				Surname:     "Ivanov",
+				Patronymic:  "Ivanovich",
+				Age:         51,
+				Gender:      "male",
+				Nationality: "HR",
+			},
+		},
+
+		{
+			name:             "unknown name",
+			mockRepoBehavior: func(r *mock_postgres.MockUserRepository, ctx context.Context) {},
+			user: &domain.User{
+				Name:    "Qwzx",
+				Surname: "Ivanov",
+			},
+			expectedError: domain.ErrUnknownName,
+		},
+
+		{
+			name:             "upstream quota exceeded",
+			mockRepoBehavior: func(r *mock_postgres.MockUserRepository, ctx context.Context) {},
+			user: &domain.User{
+				Name:    "Galina",
+				Surname: "Petrova",
+			},
+			expectedError: domain.ErrEnrichmentUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_postgres.NewMockUserRepository(c)
+			tc.mockRepoBehavior(repo, context.Background())
+
+			service := New(repo, newCassetteTransport(t, "enrichment"))
+
+			err := service.Create(context.Background(), tc.user)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedUser, tc.user)
+		})
+	}
+}
+
+// Getter sending requests to another host with upstream host as path prefix, so upstream apis are served locally
+type rebasedGetter struct {
+	next *httptransport.Transport
+	base string
+}
+
+func (g *rebasedGetter) Get(ctx context.Context, rawURL string) (*httptransport.Response, error) {
+	u, err := url.Parse(rawURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return g.next.Get(ctx, g.base+"/"+u.Host+u.RequestURI())
+}
+
+// Cassette is the file recorder writes, recording its exchanges again gives the same file
+func TestServiceCassetteRoundTrip(t *testing.T) {
+	path := filepath.Join("testdata", "cassettes", "enrichment.json")
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	var cassette struct {
+		Interactions []httptransport.Interaction `json:"interactions"`
+	}
+
+	assert.NoError(t, json.Unmarshal(data, &cassette))
+	assert.NotEmpty(t, cassette.Interactions)
+
+	byRequest := make(map[string]httptransport.Interaction)
+
+	for _, i := range cassette.Interactions {
+		u, err := url.Parse(i.Request.URL)
+		assert.NoError(t, err)
+
+		byRequest["/"+u.Host+u.RequestURI()] = i
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i, ok := byRequest[r.URL.RequestURI()]
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		for key, values := range i.Response.Header {
+			w.Header()[key] = values
+		}
+
+		w.WriteHeader(i.Response.StatusCode)
+		w.Write([]byte(i.Response.Body))
+	}))
+	defer server.Close()
+
+	recorded := filepath.Join(t.TempDir(), "enrichment.json")
+
+	recorder, err := httptransport.NewRecorder(&rebasedGetter{next: httptransport.New(server.Client()), base: server.URL}, recorded, httptransport.ModeRecord)
+	assert.NoError(t, err)
+
+	for _, i := range cassette.Interactions {
+		_, err := recorder.Get(context.Background(), i.Request.URL)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, recorder.Save())
+
+	rerecorded, err := os.ReadFile(recorded)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), string(rerecorded))
+}
+
+func TestServiceCreateUnrecorded(t *testing.T) {
+	if *cassetteMode != string(httptransport.ModeReplay) {
+		t.Skip("unrecorded requests fail only in replay mode")
+	}
+
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	service := New(mock_postgres.NewMockUserRepository(c), newCassetteTransport(t, "enrichment"))
+
+	err := service.Create(context.Background(), &domain.User{Name: "Petr", Surname: "Petrov"})
+
+	assert.ErrorIs(t, err, httptransport.ErrUnrecordedRequest)
+}
+
+func TestServiceCreateFakeEnrich(t *testing.T) {
+	seed, err := fakeenrich.LoadSeed("")
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name          string
+		faults        fakeenrich.Faults
+		expectedUser  *repoModel.User
+		expectedError error
+	}{
+		{
+			name: "OK",
+			expectedUser: &repoModel.User{
+				Name:        "Sergey",
+				Surname:     "Sergeev",
+				Age:         49,
+				Gender:      "male",
+				Nationality: "RU",
+			},
+		},
+
+		{
+			name:          "upstream is throttling",
+			faults:        fakeenrich.Faults{TooManyRequestsRate: 1},
+			expectedError: domain.ErrEnrichmentUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			server := httptest.NewServer(fakeenrich.New(seed, tc.faults))
+			defer server.Close()
+
+			repo := mock_postgres.NewMockUserRepository(c)
+
+			if tc.expectedUser != nil {
+				repo.EXPECT().Create(gomock.Any(), tc.expectedUser).Return(1, nil)
+			}
+
+			service := New(repo, httptransport.New(server.Client()), WithProviders(
+				server.URL+"/agify/",
+				server.URL+"/genderize/",
+				server.URL+"/nationalize/",
+			))
+
+			err := service.Create(context.Background(), &domain.User{Name: "Sergey", Surname: "Sergeev"})
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestServiceCreateFakeEnrichQuota(t *testing.T) {
+	seed, err := fakeenrich.LoadSeed("")
+	assert.NoError(t, err)
+
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	// Each api answers a single name a day
+	fake := fakeenrich.New(seed, fakeenrich.Faults{DailyLimit: 1})
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	repo := mock_postgres.NewMockUserRepository(c)
+	repo.EXPECT().Create(gomock.Any(), gomock.Any()).Return(1, nil).Times(2)
+
+	service := New(repo, httptransport.New(server.Client()), WithProviders(
+		server.URL+"/agify/",
+		server.URL+"/genderize/",
+		server.URL+"/nationalize/",
+	))
+
+	create := func() error {
+		return service.Create(context.Background(), &domain.User{Name: "Sergey", Surname: "Sergeev"})
+	}
+
+	assert.NoError(t, create())
+
+	// Quota is exhausted
+	assert.ErrorIs(t, create(), domain.ErrEnrichmentUnavailable)
+
+	// and renewed the next day
+	fake.Reset()
+
+	assert.NoError(t, create())
+}
+
+// Changes are checked by their effect on users instead of expected repository calls
+func TestServiceMemoryRepository(t *testing.T) {
+	seed, err := fakeenrich.LoadSeed("")
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(fakeenrich.New(seed, fakeenrich.Faults{}))
+	defer server.Close()
+
+	ctx := context.Background()
+
+	service := New(memory.New(), httptransport.New(server.Client()), WithProviders(
+		server.URL+"/agify/",
+		server.URL+"/genderize/",
+		server.URL+"/nationalize/",
+	))
+
+	assert.NoError(t, service.Create(ctx, &domain.User{Name: "Sergey", Surname: "Sergeev"}))
+
+	user, err := service.GetById(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, &domain.User{Id: 1, Name: "Sergey", Surname: "Sergeev", Age: 49, Gender: "male", Nationality: "RU"}, user)
+
+	user.Age = 50
+	assert.NoError(t, service.Update(ctx, 1, user))
+
+	user, err = service.GetById(ctx, 1, "age")
+	assert.NoError(t, err)
+	assert.Equal(t, 50, user.Age)
+
+	assert.NoError(t, service.Delete(ctx, 1))
+
+	_, err = service.GetById(ctx, 1)
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+}
+
+func TestServiceImport(t *testing.T) {
+	seed, err := fakeenrich.LoadSeed("")
+	assert.NoError(t, err)
+
+	rows := []domain.ImportRow{
+		{Line: 2, User: domain.User{Name: "Sergey", Surname: "Sergeev"}},
+		{Line: 3, User: domain.User{Name: "Sergey", Surname: "Ivanov", Nationality: "BY"}},
+		{Line: 5, User: domain.User{Name: "Anna", Surname: "Petrova", Age: 30, Gender: "female", Nationality: "UA"}},
+	}
+
+	testCases := []struct {
+		name           string
+		opts           domain.ImportOptions
+		expectedUsers  []repoModel.User
+		expectedReport *domain.ImportReport
+		expectedError  error
+	}{
+		{
+			name: "enriched once by name",
+			opts: domain.ImportOptions{Enrich: true},
+			expectedUsers: []repoModel.User{
+				{Name: "Sergey", Surname: "Sergeev", Age: 49, Gender: "male", Nationality: "RU"},
+				{Name: "Sergey", Surname: "Ivanov", Age: 49, Gender: "male", Nationality: "BY"},
+				{Name: "Anna", Surname: "Petrova", Age: 30, Gender: "female", Nationality: "UA"},
+			},
+			expectedReport: &domain.ImportReport{Total: 3, Valid: 3, Imported: 3, Errors: []domain.ImportError{}},
+		},
+
+		{
+			name: "without enrichment",
+			expectedUsers: []repoModel.User{
+				{Name: "Anna", Surname: "Petrova", Age: 30, Gender: "female", Nationality: "UA"},
+			},
+			expectedReport: &domain.ImportReport{Total: 3, Valid: 1, Imported: 1, Errors: []domain.ImportError{
+				{Line: 2, Error: "age, gender, nationality required without enrichment"},
+				{Line: 3, Error: "age, gender required without enrichment"},
+			}},
+		},
+
+		{
+			name:           "dry run",
+			opts:           domain.ImportOptions{Enrich: true, DryRun: true},
+			expectedReport: &domain.ImportReport{Total: 3, Valid: 1, Unverified: 2, DryRun: true, Errors: []domain.ImportError{}},
+		},
+
+		{
+			name:          "enrichment unavailable",
+			opts:          domain.ImportOptions{Enrich: true},
+			expectedError: domain.ErrEnrichmentUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			// Enough quota to enrich a single name
+			server := httptest.NewServer(fakeenrich.New(seed, fakeenrich.Faults{DailyLimit: 1}))
+			defer server.Close()
+
+			// Quota is used up by someone else
+			if tc.expectedError != nil {
+				response, err := server.Client().Get(server.URL + "/agify/?name=Ivan")
+				assert.NoError(t, err)
+				response.Body.Close()
+			}
+
+			repo := mock_postgres.NewMockUserRepository(c)
+
+			var expectedCreated []domain.User
+
+			if tc.expectedUsers != nil {
+				imported := make([]repoModel.User, len(tc.expectedUsers))
+
+				for i, u := range tc.expectedUsers {
+					u.Id = i + 1
+					imported[i] = u
+					expectedCreated = append(expectedCreated, *converter.ToUserFromRepo(&u))
+				}
+
+				repo.EXPECT().Import(gomock.Any(), tc.expectedUsers).Return(imported, nil)
+			}
+
+			var created []domain.User
+
+			service := New(repo, httptransport.New(server.Client()), WithProviders(
+				server.URL+"/agify/",
+				server.URL+"/genderize/",
+				server.URL+"/nationalize/",
+			), WithHooks(func(ctx context.Context, eventType string, u *domain.User) {
+				assert.Equal(t, domain.EventUserCreated, eventType)
+				created = append(created, *u)
+			}))
+
+			report, err := service.Import(context.Background(), rows, tc.opts)
+
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedReport, report)
+			// Imported users are passed to hooks with their ids
+			assert.Equal(t, expectedCreated, created)
+		})
+	}
+}
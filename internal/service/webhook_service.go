@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/converter"
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	repoModel "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+//go:generate mockgen -source=webhook_service.go -destination=../repository/postgres/mocks/webhook_mock.go -package=mock_postgres
+
+type WebhookRepository interface {
+	CreateSubscription(ctx context.Context, s *repoModel.Subscription) (int, error)
+	GetSubscriptions(ctx context.Context) ([]repoModel.Subscription, error)
+	GetSubscriptionById(ctx context.Context, id int) (*repoModel.Subscription, error)
+	UpdateSubscription(ctx context.Context, id int, s *repoModel.Subscription) error
+	DeleteSubscription(ctx context.Context, id int) error
+	Enqueue(ctx context.Context, eventType string, payload []byte) (int, error)
+	GetDeliveries(ctx context.Context, filter *repoModel.DeliveryFilter) ([]repoModel.Delivery, error)
+	Redeliver(ctx context.Context, subscriptionId int, id int64) (int64, error)
+}
+
+// Random bytes of generated secrets
+const secretSize = 32
+
+type WebhookService struct {
+	repository WebhookRepository
+	now        func() time.Time
+}
+
+func NewWebhook(repository WebhookRepository) *WebhookService {
+	return &WebhookService{
+		repository: repository,
+		now:        time.Now,
+	}
+}
+
+// Create subscription, secret is generated if not given
+func (s *WebhookService) CreateSubscription(ctx context.Context, subscription *domain.Subscription) error {
+	if subscription.Secret == "" {
+		secret, err := randomHex(secretSize)
+
+		if err != nil {
+			return err
+		}
+
+		subscription.Secret = secret
+	}
+
+	id, err := s.repository.CreateSubscription(ctx, converter.ToSubscriptionFromService(subscription))
+
+	if err != nil {
+		return err
+	}
+
+	subscription.Id = id
+
+	return nil
+}
+
+// Get all subscriptions
+func (s *WebhookService) GetSubscriptions(ctx context.Context) ([]domain.Subscription, error) {
+	repoSubscriptions, err := s.repository.GetSubscriptions(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	subscriptions := make([]domain.Subscription, 0, len(repoSubscriptions))
+
+	for _, sub := range repoSubscriptions {
+		subscriptions = append(subscriptions, *converter.ToSubscriptionFromRepo(&sub))
+	}
+
+	return subscriptions, nil
+}
+
+// Get subscription by id
+func (s *WebhookService) GetSubscriptionById(ctx context.Context, id int) (*domain.Subscription, error) {
+	subscription, err := s.repository.GetSubscriptionById(ctx, id)
+
+	if err != nil {
+		return nil, toWebhookError(err)
+	}
+
+	return converter.ToSubscriptionFromRepo(subscription), nil
+}
+
+// Update subscription
+func (s *WebhookService) UpdateSubscription(ctx context.Context, id int, subscription *domain.Subscription) error {
+	if err := s.repository.UpdateSubscription(ctx, id, converter.ToSubscriptionFromService(subscription)); err != nil {
+		return toWebhookError(err)
+	}
+
+	return nil
+}
+
+// Delete subscription with its delivery log
+func (s *WebhookService) DeleteSubscription(ctx context.Context, id int) error {
+	if err := s.repository.DeleteSubscription(ctx, id); err != nil {
+		return toWebhookError(err)
+	}
+
+	return nil
+}
+
+// Get delivery log of subscription
+func (s *WebhookService) GetDeliveries(ctx context.Context, filter *domain.DeliveryFilter) ([]domain.Delivery, error) {
+	if _, err := s.GetSubscriptionById(ctx, filter.SubscriptionId); err != nil {
+		return nil, err
+	}
+
+	repoDeliveries, err := s.repository.GetDeliveries(ctx, converter.ToDeliveryFilterFromService(filter))
+
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]domain.Delivery, 0, len(repoDeliveries))
+
+	for _, d := range repoDeliveries {
+		deliveries = append(deliveries, *converter.ToDeliveryFromRepo(&d))
+	}
+
+	return deliveries, nil
+}
+
+// Send delivery of subscription again as a new delivery, returns its id
+func (s *WebhookService) Redeliver(ctx context.Context, subscriptionId int, id int64) (int64, error) {
+	redeliveryId, err := s.repository.Redeliver(ctx, subscriptionId, id)
+
+	if err != nil {
+		return 0, toWebhookError(err)
+	}
+
+	return redeliveryId, nil
+}
+
+// User service hook enqueuing deliveries of the change to subscriptions. The change
+// is already saved, so failures are logged and the change is not delivered.
+func (s *WebhookService) Notify(ctx context.Context, eventType string, u *domain.User) {
+	// Deliveries are enqueued even if client is gone
+	ctx = context.WithoutCancel(ctx)
+
+	id, err := randomHex(16)
+
+	if err != nil {
+		slog.Error(fmt.Sprintf("service: enqueuing %s of user %d: %s", eventType, u.Id, err.Error()))
+		return
+	}
+
+	payload, err := json.Marshal(&domain.WebhookEvent{
+		Id:         id,
+		Type:       eventType,
+		User:       u,
+		OccurredAt: s.now().UTC(),
+	})
+
+	if err != nil {
+		slog.Error(fmt.Sprintf("service: enqueuing %s of user %d: %s", eventType, u.Id, err.Error()))
+		return
+	}
+
+	if _, err := s.repository.Enqueue(ctx, eventType, payload); err != nil {
+		slog.Error(fmt.Sprintf("service: enqueuing %s of user %d: %s", eventType, u.Id, err.Error()))
+	}
+}
+
+func toWebhookError(err error) error {
+	switch {
+	case errors.Is(err, repoModel.ErrSubscriptionNotFound):
+		return domain.ErrSubscriptionNotFound
+	case errors.Is(err, repoModel.ErrDeliveryNotFound):
+		return domain.ErrDeliveryNotFound
+	}
+
+	return err
+}
+
+func randomHex(size int) (string, error) {
+	data := make([]byte, size)
+
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(data), nil
+}
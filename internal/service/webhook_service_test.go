@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	mock_postgres "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/mocks"
+	repoModel "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+func TestWebhookServiceCreateSubscription(t *testing.T) {
+	testCases := []struct {
+		name           string
+		secret         string
+		expectedSecret func(t *testing.T, secret string)
+	}{
+		{
+			name:   "given secret",
+			secret: "0123456789abcdef",
+			expectedSecret: func(t *testing.T, secret string) {
+				assert.Equal(t, "0123456789abcdef", secret)
+			},
+		},
+
+		{
+			name: "generated secret",
+			expectedSecret: func(t *testing.T, secret string) {
+				assert.Len(t, secret, 2*secretSize)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := gomock.NewController(t)
+			defer c.Finish()
+
+			repo := mock_postgres.NewMockWebhookRepository(c)
+			repo.EXPECT().CreateSubscription(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, s *repoModel.Subscription) (int, error) {
+				tc.expectedSecret(t, s.Secret)
+				assert.Equal(t, "https://partner.example.com/hooks", s.URL)
+
+				return 7, nil
+			})
+
+			subscription := &domain.Subscription{
+				URL:    "https://partner.example.com/hooks",
+				Events: []string{domain.EventUserCreated},
+				Secret: tc.secret,
+			}
+
+			assert.NoError(t, NewWebhook(repo).CreateSubscription(context.Background(), subscription))
+			assert.Equal(t, 7, subscription.Id)
+			tc.expectedSecret(t, subscription.Secret)
+		})
+	}
+}
+
+func TestWebhookServiceNotify(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	now := time.Date(2024, 2, 15, 10, 0, 0, 0, time.UTC)
+	user := &domain.User{Id: 3, Name: "Ivan", Surname: "Ivanov", Age: 20, Gender: "male", Nationality: "RU"}
+
+	repo := mock_postgres.NewMockWebhookRepository(c)
+	repo.EXPECT().Enqueue(gomock.Any(), domain.EventUserCreated, gomock.Any()).DoAndReturn(func(ctx context.Context, eventType string, payload []byte) (int, error) {
+		var event domain.WebhookEvent
+
+		assert.NoError(t, json.Unmarshal(payload, &event))
+		assert.Len(t, event.Id, 32)
+		assert.Equal(t, domain.EventUserCreated, event.Type)
+		assert.Equal(t, user, event.User)
+		assert.Equal(t, now, event.OccurredAt)
+
+		return 2, nil
+	})
+
+	// Failure is only logged
+	repo.EXPECT().Enqueue(gomock.Any(), domain.EventUserDeleted, gomock.Any()).Return(0, errors.New("connection refused"))
+
+	service := NewWebhook(repo)
+	service.now = func() time.Time { return now }
+
+	service.Notify(context.Background(), domain.EventUserCreated, user)
+	service.Notify(context.Background(), domain.EventUserDeleted, &domain.User{Id: 3})
+}
+
+func TestWebhookServiceErrors(t *testing.T) {
+	c := gomock.NewController(t)
+	defer c.Finish()
+
+	ctx := context.Background()
+
+	repo := mock_postgres.NewMockWebhookRepository(c)
+	repo.EXPECT().GetSubscriptionById(ctx, 1).Return(nil, repoModel.ErrSubscriptionNotFound)
+	repo.EXPECT().DeleteSubscription(ctx, 1).Return(repoModel.ErrSubscriptionNotFound)
+	repo.EXPECT().Redeliver(ctx, 1, int64(9)).Return(int64(0), repoModel.ErrDeliveryNotFound)
+
+	service := NewWebhook(repo)
+
+	// Log of unknown subscription is not found rather than empty
+	_, err := service.GetDeliveries(ctx, &domain.DeliveryFilter{SubscriptionId: 1, Limit: 10})
+	assert.ErrorIs(t, err, domain.ErrSubscriptionNotFound)
+
+	assert.ErrorIs(t, service.DeleteSubscription(ctx, 1), domain.ErrSubscriptionNotFound)
+
+	_, err = service.Redeliver(ctx, 1, 9)
+	assert.ErrorIs(t, err, domain.ErrDeliveryNotFound)
+}
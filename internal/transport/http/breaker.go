@@ -0,0 +1,149 @@
+package httptransport
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+var ErrUpstreamUnavailable = fmt.Errorf("%w: upstream circuit is open", domain.ErrEnrichmentUnavailable)
+
+type BreakerState int
+
+const (
+	StateClosed BreakerState = iota
+	StateOpen
+	StateHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+type BreakerSettings struct {
+	// Consecutive failures opening the circuit
+	FailureThreshold int
+	// Time circuit stays open before trial requests are let through
+	OpenTimeout time.Duration
+	// Concurrent trial requests in half-open state
+	HalfOpenRequests int
+}
+
+// Request result as seen by circuit breaker
+type outcome int
+
+const (
+	// Request didn't tell anything about upstream, e.g. caller gave up on it
+	outcomeIgnored outcome = iota
+	outcomeSuccess
+	outcomeFailure
+)
+
+// Circuit breaker of a single upstream host
+type breaker struct {
+	mu       sync.Mutex
+	settings BreakerSettings
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	inFlight int
+	now      func() time.Time
+
+	// Incremented on every state change, results of requests
+	// allowed in previous states are stale
+	generation uint64
+}
+
+func newBreaker(settings BreakerSettings, now func() time.Time) *breaker {
+	if settings.HalfOpenRequests <= 0 {
+		settings.HalfOpenRequests = 1
+	}
+
+	return &breaker{
+		settings: settings,
+		now:      now,
+	}
+}
+
+// Check if request may be made, must be followed by done with returned generation if it may
+func (b *breaker) allow() (uint64, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == StateOpen {
+		if b.now().Sub(b.openedAt) < b.settings.OpenTimeout {
+			return 0, false
+		}
+
+		b.setState(StateHalfOpen)
+	}
+
+	if b.state == StateHalfOpen {
+		if b.inFlight >= b.settings.HalfOpenRequests {
+			return 0, false
+		}
+
+		b.inFlight++
+	}
+
+	return b.generation, true
+}
+
+// Record result of request allowed in generation, stale results are dropped
+func (b *breaker) done(generation uint64, result outcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if generation != b.generation {
+		return
+	}
+
+	if b.state == StateHalfOpen {
+		b.inFlight--
+	}
+
+	switch result {
+	case outcomeSuccess:
+		if b.state != StateClosed {
+			b.setState(StateClosed)
+		}
+
+		b.failures = 0
+	case outcomeFailure:
+		b.failures++
+
+		if b.state == StateHalfOpen || b.failures >= b.settings.FailureThreshold {
+			b.setState(StateOpen)
+			b.openedAt = b.now()
+		}
+	}
+}
+
+func (b *breaker) setState(state BreakerState) {
+	b.state = state
+	b.inFlight = 0
+	b.generation++
+}
+
+func (b *breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Report open circuit ready for trial as half-open
+	if b.state == StateOpen && b.now().Sub(b.openedAt) >= b.settings.OpenTimeout {
+		return StateHalfOpen
+	}
+
+	return b.state
+}
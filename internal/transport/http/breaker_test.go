@@ -0,0 +1,128 @@
+package httptransport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportBreaker(t *testing.T) {
+	status := http.StatusInternalServerError
+	calls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	now := time.Now()
+
+	transport := New(server.Client(), WithBreaker(BreakerSettings{
+		FailureThreshold: 2,
+		OpenTimeout:      time.Minute,
+	}))
+	transport.now = func() time.Time { return now }
+
+	host := hostOf(server.URL)
+
+	get := func() error {
+		_, err := transport.Get(context.Background(), server.URL)
+
+		return err
+	}
+
+	// Failures open the circuit
+	assert.NoError(t, get())
+	assert.Equal(t, "closed", transport.BreakerStates()[host])
+	assert.NoError(t, get())
+	assert.Equal(t, "open", transport.BreakerStates()[host])
+
+	// Open circuit fails fast without calling upstream
+	assert.ErrorIs(t, get(), ErrUpstreamUnavailable)
+	assert.Equal(t, 2, calls)
+
+	// Failed trial request opens the circuit again
+	now = now.Add(time.Minute)
+	assert.Equal(t, "half-open", transport.BreakerStates()[host])
+	assert.NoError(t, get())
+	assert.Equal(t, "open", transport.BreakerStates()[host])
+	assert.ErrorIs(t, get(), ErrUpstreamUnavailable)
+
+	// Successful trial request closes it
+	status = http.StatusOK
+	now = now.Add(time.Minute)
+	assert.NoError(t, get())
+	assert.Equal(t, "closed", transport.BreakerStates()[host])
+	assert.Equal(t, 4, calls)
+}
+
+func TestBreakerStaleResults(t *testing.T) {
+	now := time.Now()
+
+	b := newBreaker(BreakerSettings{FailureThreshold: 1, OpenTimeout: time.Minute}, func() time.Time { return now })
+
+	slow, _ := b.allow()
+	failed, _ := b.allow()
+
+	b.done(failed, outcomeFailure)
+	assert.Equal(t, StateOpen, b.State())
+
+	// Request made before circuit opened doesn't close it
+	b.done(slow, outcomeSuccess)
+	assert.Equal(t, StateOpen, b.State())
+
+	// Ignored trial request lets another one through
+	now = now.Add(time.Minute)
+
+	probe, ok := b.allow()
+	assert.True(t, ok)
+
+	_, ok = b.allow()
+	assert.False(t, ok)
+
+	b.done(probe, outcomeIgnored)
+	assert.Equal(t, StateHalfOpen, b.State())
+
+	probe, ok = b.allow()
+	assert.True(t, ok)
+
+	b.done(probe, outcomeSuccess)
+	assert.Equal(t, StateClosed, b.State())
+}
+
+func TestTransportBreakerOutcome(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Write(make([]byte, 2048))
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	transport := New(server.Client(), WithMaxResponseBytes(1024), WithBreaker(BreakerSettings{
+		FailureThreshold: 1,
+		OpenTimeout:      time.Minute,
+	}))
+
+	host := hostOf(server.URL)
+
+	// Upstream answered, the answer is just too large
+	_, err := transport.Get(context.Background(), server.URL+"/large")
+	assert.ErrorIs(t, err, ErrResponseTooLarge)
+	assert.Equal(t, "closed", transport.BreakerStates()[host])
+
+	// Caller gave up on request
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = transport.Get(ctx, server.URL+"/slow")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, "closed", transport.BreakerStates()[host])
+}
@@ -0,0 +1,191 @@
+package httptransport
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+	"github.com/sletkov/effective-mobile-test-task/internal/pkg/cache"
+)
+
+var ErrBudgetExhausted = fmt.Errorf("%w: upstream quota is running low", domain.ErrEnrichmentUnavailable)
+
+type getter interface {
+	Get(ctx context.Context, url string) (*Response, error)
+}
+
+// Quota of a single upstream host
+type hostBudget struct {
+	// Requests made since dayStart
+	used     int
+	dayStart time.Time
+
+	// Quota reported by upstream, valid until resetAt
+	remaining int
+	resetAt   time.Time
+}
+
+// Budget guard protects upstream quota shared by all clients.
+//
+// Every upstream host has a quota of its own, so the guard counts requests
+// to each of them against the daily budget and tracks their
+// X-Rate-Limit-Remaining headers. Once remaining quota of a host drops
+// to the reserve, only cached responses of that host are served.
+type BudgetGuard struct {
+	next    getter
+	cache   *cache.Cache[string, *Response]
+	budget  int
+	reserve int
+
+	mu    sync.Mutex
+	hosts map[string]*hostBudget
+	now   func() time.Time
+}
+
+type BudgetOptions struct {
+	// Upstream requests per day to each host, zero means no local limit
+	DailyBudget int
+	// Remaining quota at which only cached responses are served
+	Reserve int
+	// Cached responses count, zero disables caching
+	CacheSize int
+	CacheTTL  time.Duration
+}
+
+func NewBudgetGuard(next getter, opts BudgetOptions) *BudgetGuard {
+	g := &BudgetGuard{
+		next:    next,
+		budget:  opts.DailyBudget,
+		reserve: opts.Reserve,
+		hosts:   make(map[string]*hostBudget),
+		now:     time.Now,
+	}
+
+	if opts.CacheSize > 0 {
+		g.cache = cache.New[string, *Response](opts.CacheSize, opts.CacheTTL)
+	}
+
+	return g
+}
+
+// Make GET request by url, served from cache if possible
+func (g *BudgetGuard) Get(ctx context.Context, rawURL string) (*Response, error) {
+	if g.cache != nil {
+		if cached, ok := g.cache.Get(rawURL); ok {
+			slog.DebugContext(ctx, fmt.Sprintf("transport: cache hit for %s", rawURL))
+			return cached.clone(), nil
+		}
+	}
+
+	host := hostOf(rawURL)
+
+	if remaining := g.Remaining(host); remaining <= g.reserve {
+		slog.WarnContext(ctx, fmt.Sprintf("transport: %d requests left for %s, serving from cache only", remaining, host))
+		return nil, ErrBudgetExhausted
+	}
+
+	response, err := g.next.Get(ctx, rawURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	g.track(host, response.Header)
+
+	if g.cache != nil && response.StatusCode == http.StatusOK {
+		g.cache.Set(rawURL, response.clone())
+	}
+
+	return response, nil
+}
+
+// Requests left for host: the lower of local daily budget and upstream quota
+func (g *BudgetGuard) Remaining(host string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	h := g.host(host, now)
+
+	remaining := int(^uint(0) >> 1)
+
+	if g.budget > 0 {
+		remaining = g.budget - h.used
+	}
+
+	if now.Before(h.resetAt) && h.remaining < remaining {
+		remaining = h.remaining
+	}
+
+	return remaining
+}
+
+// Count request and remember upstream quota from response headers
+func (g *BudgetGuard) track(host string, header http.Header) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	h := g.host(host, now)
+
+	h.used++
+
+	remaining, err := strconv.Atoi(header.Get("X-Rate-Limit-Remaining"))
+
+	if err != nil {
+		return
+	}
+
+	// Reset header holds seconds until quota is renewed
+	resetAt := now.Add(24 * time.Hour)
+
+	if reset, err := strconv.Atoi(header.Get("X-Rate-Limit-Reset")); err == nil {
+		resetAt = now.Add(time.Duration(reset) * time.Second)
+	}
+
+	h.remaining = remaining
+	h.resetAt = resetAt
+}
+
+// Budget of host, local one is renewed at midnight UTC like the upstream one.
+// Lock must be held.
+func (g *BudgetGuard) host(host string, now time.Time) *hostBudget {
+	h, ok := g.hosts[host]
+
+	if !ok {
+		h = &hostBudget{}
+		g.hosts[host] = h
+	}
+
+	if day := now.UTC().Truncate(24 * time.Hour); day.After(h.dayStart) {
+		h.dayStart = day
+		h.used = 0
+	}
+
+	return h
+}
+
+// Copy response, body is shared as it is never modified
+func (r *Response) clone() *Response {
+	return &Response{
+		StatusCode: r.StatusCode,
+		Header:     r.Header.Clone(),
+		Body:       r.Body,
+	}
+}
+
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+
+	if err != nil {
+		return rawURL
+	}
+
+	return u.Host
+}
@@ -0,0 +1,135 @@
+package httptransport
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sletkov/effective-mobile-test-task/internal/domain"
+)
+
+// Upstream stub answering with decreasing X-Rate-Limit-Remaining
+type upstreamStub struct {
+	calls     int
+	remaining int
+}
+
+func (u *upstreamStub) Get(ctx context.Context, url string) (*Response, error) {
+	u.calls++
+	u.remaining--
+
+	header := http.Header{}
+	header.Set("X-Rate-Limit-Remaining", strconv.Itoa(u.remaining))
+	header.Set("X-Rate-Limit-Reset", "3600")
+
+	return &Response{
+		StatusCode: http.StatusOK,
+		Header:     header,
+		Body:       []byte(`{"count":1,"name":"ivan","age":42}`),
+	}, nil
+}
+
+func TestBudgetGuard(t *testing.T) {
+	testCases := []struct {
+		name           string
+		opts           BudgetOptions
+		upstreamQuota  int
+		urls           []string
+		expectedCalls  int
+		expectedFailed int
+	}{
+		{
+			name:          "upstream quota reaches reserve",
+			opts:          BudgetOptions{Reserve: 2},
+			upstreamQuota: 5,
+			urls:          []string{"https://api.agify.io/?name=a", "https://api.agify.io/?name=b", "https://api.agify.io/?name=c", "https://api.agify.io/?name=d"},
+			// 4, 3, 2 remaining after each call, the fourth request is refused
+			expectedCalls:  3,
+			expectedFailed: 1,
+		},
+
+		{
+			name:           "daily budget",
+			opts:           BudgetOptions{DailyBudget: 3, Reserve: 1},
+			upstreamQuota:  1000,
+			urls:           []string{"https://api.agify.io/?name=a", "https://api.agify.io/?name=b", "https://api.agify.io/?name=c"},
+			expectedCalls:  2,
+			expectedFailed: 1,
+		},
+
+		{
+			name:           "cached responses are served when budget is low",
+			opts:           BudgetOptions{Reserve: 3, CacheSize: 10, CacheTTL: time.Hour},
+			upstreamQuota:  5,
+			urls:           []string{"https://api.agify.io/?name=a", "https://api.agify.io/?name=b", "https://api.agify.io/?name=a", "https://api.agify.io/?name=c"},
+			expectedCalls:  2,
+			expectedFailed: 1,
+		},
+
+		{
+			name:           "hosts have separate daily budgets",
+			opts:           BudgetOptions{DailyBudget: 3, Reserve: 1},
+			upstreamQuota:  1000,
+			urls:           []string{"https://api.agify.io/?name=a", "https://api.genderize.io/?name=a", "https://api.nationalize.io/?name=a", "https://api.agify.io/?name=b"},
+			expectedCalls:  4,
+			expectedFailed: 0,
+		},
+
+		{
+			name:           "hosts have separate quotas",
+			opts:           BudgetOptions{Reserve: 3},
+			upstreamQuota:  5,
+			urls:           []string{"https://api.agify.io/?name=a", "https://api.agify.io/?name=b", "https://api.genderize.io/?name=a"},
+			expectedCalls:  3,
+			expectedFailed: 0,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			upstream := &upstreamStub{remaining: tc.upstreamQuota}
+
+			guard := NewBudgetGuard(upstream, tc.opts)
+
+			failed := 0
+
+			for _, url := range tc.urls {
+				response, err := guard.Get(context.Background(), url)
+
+				if err != nil {
+					assert.ErrorIs(t, err, domain.ErrEnrichmentUnavailable)
+					failed++
+					continue
+				}
+
+				assert.Contains(t, string(response.Body), `"age":42`)
+			}
+
+			assert.Equal(t, tc.expectedCalls, upstream.calls)
+			assert.Equal(t, tc.expectedFailed, failed)
+		})
+	}
+}
+
+func TestBudgetGuardDayReset(t *testing.T) {
+	now := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+
+	guard := NewBudgetGuard(&upstreamStub{remaining: 1000}, BudgetOptions{DailyBudget: 2, Reserve: 1})
+	guard.now = func() time.Time { return now }
+
+	_, err := guard.Get(context.Background(), "https://api.agify.io/?name=a")
+	assert.NoError(t, err)
+
+	_, err = guard.Get(context.Background(), "https://api.agify.io/?name=b")
+	assert.ErrorIs(t, err, ErrBudgetExhausted)
+
+	// Budget is renewed at midnight UTC
+	now = now.Add(time.Hour)
+
+	_, err = guard.Get(context.Background(), "https://api.agify.io/?name=b")
+	assert.NoError(t, err)
+}
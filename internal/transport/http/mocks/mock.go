@@ -6,10 +6,10 @@ package mock_httptransport
 
 import (
 	context "context"
-	http "net/http"
 	reflect "reflect"
 
 	gomock "github.com/golang/mock/gomock"
+	httptransport "github.com/sletkov/effective-mobile-test-task/internal/transport/http"
 )
 
 // MockTransport is a mock of Transport interface.
@@ -36,10 +36,10 @@ func (m *MockTransport) EXPECT() *MockTransportMockRecorder {
 }
 
 // Get mocks base method.
-func (m *MockTransport) Get(ctx context.Context, url string) (*http.Response, error) {
+func (m *MockTransport) Get(ctx context.Context, url string) (*httptransport.Response, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Get", ctx, url)
-	ret0, _ := ret[0].(*http.Response)
+	ret0, _ := ret[0].(*httptransport.Response)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
@@ -0,0 +1,201 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+var ErrUnrecordedRequest = errors.New("request is not recorded in cassette")
+
+type Mode string
+
+const (
+	// Replay recorded exchanges, fail on unrecorded requests
+	ModeReplay Mode = "replay"
+	// Replay recorded exchanges, record unrecorded ones
+	ModeReplayOrRecord Mode = "auto"
+	// Make real requests and record all of them
+	ModeRecord Mode = "record"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch mode := Mode(s); mode {
+	case ModeReplay, ModeReplayOrRecord, ModeRecord:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown cassette mode %q", s)
+	}
+}
+
+// Recorded request and response
+type Interaction struct {
+	Request struct {
+		Method string `json:"method"`
+		URL    string `json:"url"`
+	} `json:"request"`
+	Response struct {
+		StatusCode int         `json:"status_code"`
+		Header     http.Header `json:"header,omitempty"`
+		Body       string      `json:"body"`
+	} `json:"response"`
+}
+
+type cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Recorder is a transport decorator writing exchanges with upstream apis
+// to a cassette file and replaying them offline
+type Recorder struct {
+	next getter
+	path string
+	mode Mode
+
+	mu       sync.Mutex
+	cassette cassette
+	changed  bool
+}
+
+// Create recorder, cassette is loaded from path if it exists.
+// Upstream transport may be nil in replay mode.
+func NewRecorder(next getter, path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{
+		next: next,
+		path: path,
+		mode: mode,
+	}
+
+	if mode == ModeRecord {
+		return r, nil
+	}
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && mode == ModeReplayOrRecord {
+			return r, nil
+		}
+
+		return nil, fmt.Errorf("recorder: reading cassette: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &r.cassette); err != nil {
+		return nil, fmt.Errorf("recorder: parsing cassette %s: %w", path, err)
+	}
+
+	return r, nil
+}
+
+func (r *Recorder) Get(ctx context.Context, url string) (*Response, error) {
+	if r.mode != ModeRecord {
+		if response, ok := r.find(url); ok {
+			return response, nil
+		}
+
+		if r.mode == ModeReplay {
+			return nil, fmt.Errorf("recorder: GET %s: %w", url, ErrUnrecordedRequest)
+		}
+	}
+
+	if r.next == nil {
+		return nil, fmt.Errorf("recorder: GET %s: no upstream transport to record from", url)
+	}
+
+	response, err := r.next.Get(ctx, url)
+
+	if err != nil {
+		return nil, err
+	}
+
+	r.record(url, response)
+
+	return response, nil
+}
+
+// Write cassette file if new exchanges were recorded
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.changed {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+
+	if err != nil {
+		return fmt.Errorf("recorder: encoding cassette: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return fmt.Errorf("recorder: writing cassette: %w", err)
+	}
+
+	if err := os.WriteFile(r.path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("recorder: writing cassette: %w", err)
+	}
+
+	r.changed = false
+
+	return nil
+}
+
+func (r *Recorder) find(url string) (*Response, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, i := range r.cassette.Interactions {
+		if i.Request.Method == http.MethodGet && i.Request.URL == url {
+			return &Response{
+				StatusCode: i.Response.StatusCode,
+				Header:     i.Response.Header.Clone(),
+				Body:       []byte(i.Response.Body),
+			}, true
+		}
+	}
+
+	return nil, false
+}
+
+func (r *Recorder) record(url string, response *Response) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := &Interaction{}
+	i.Request.Method = http.MethodGet
+	i.Request.URL = url
+	i.Response.StatusCode = response.StatusCode
+	i.Response.Header = recordedHeader(response.Header)
+	i.Response.Body = string(response.Body)
+
+	// Newer recording of the same request replaces the old one
+	for n, old := range r.cassette.Interactions {
+		if old.Request.URL == url {
+			r.cassette.Interactions[n] = i
+			r.changed = true
+			return
+		}
+	}
+
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.changed = true
+}
+
+// Keep only headers callers care about, without dates and cookies churning cassettes
+func recordedHeader(header http.Header) http.Header {
+	recorded := http.Header{}
+
+	for _, key := range []string{"Content-Type", "X-Rate-Limit-Limit", "X-Rate-Limit-Remaining", "X-Rate-Limit-Reset"} {
+		if value := header.Get(key); value != "" {
+			recorded.Set(key, value)
+		}
+	}
+
+	return recorded
+}
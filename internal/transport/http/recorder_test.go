@@ -0,0 +1,70 @@
+package httptransport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	calls := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Rate-Limit-Remaining", "999")
+		w.Header().Set("Date", time.Now().String())
+		w.Write([]byte(`{"count":1,"name":"` + r.URL.Query().Get("name") + `","age":42}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassettes", "agify.json")
+	url := server.URL + "/?name=ivan"
+
+	// Record
+	recorder, err := NewRecorder(New(server.Client()), path, ModeRecord)
+	assert.NoError(t, err)
+
+	response, err := recorder.Get(context.Background(), url)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"count":1,"name":"ivan","age":42}`, string(response.Body))
+	assert.NoError(t, recorder.Save())
+	assert.Equal(t, 1, calls)
+
+	// Replay offline
+	replayer, err := NewRecorder(nil, path, ModeReplay)
+	assert.NoError(t, err)
+
+	response, err = replayer.Get(context.Background(), url)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "999", response.Header.Get("X-Rate-Limit-Remaining"))
+	assert.Empty(t, response.Header.Get("Date"))
+	assert.Equal(t, `{"count":1,"name":"ivan","age":42}`, string(response.Body))
+	assert.Equal(t, 1, calls)
+
+	_, err = replayer.Get(context.Background(), server.URL+"/?name=petr")
+	assert.ErrorIs(t, err, ErrUnrecordedRequest)
+
+	// Record only missing exchanges
+	auto, err := NewRecorder(New(server.Client()), path, ModeReplayOrRecord)
+	assert.NoError(t, err)
+
+	_, err = auto.Get(context.Background(), url)
+	assert.NoError(t, err)
+	_, err = auto.Get(context.Background(), server.URL+"/?name=petr")
+	assert.NoError(t, err)
+	assert.NoError(t, auto.Save())
+	assert.Equal(t, 2, calls)
+
+	replayer, err = NewRecorder(nil, path, ModeReplay)
+	assert.NoError(t, err)
+
+	_, err = replayer.Get(context.Background(), server.URL+"/?name=petr")
+	assert.NoError(t, err)
+}
@@ -2,34 +2,258 @@ package httptransport
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
 )
 
+const (
+	DefaultUserAgent        = "effective-mobile-test-task/1.0"
+	DefaultMaxResponseBytes = 1 << 20
+
+	// Leftover bytes read to reuse connection, bigger bodies just close it
+	maxDrainBytes = 64 << 10
+)
+
+var ErrResponseTooLarge = errors.New("response body is too large")
+
+// Response with body read in full. Underlying body is always drained
+// and closed by transport, so callers can't leak connections.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
 type Transport struct {
-	client *http.Client
+	client           *http.Client
+	userAgent        string
+	maxResponseBytes int64
+	apiKey           string
+
+	// Circuit breakers per upstream host, nil settings disable them
+	breakerSettings *BreakerSettings
+	mu              sync.Mutex
+	breakers        map[string]*breaker
+	now             func() time.Time
+}
+
+type Option func(t *Transport)
+
+// Break circuit to upstream hosts failing too often
+func WithBreaker(settings BreakerSettings) Option {
+	return func(t *Transport) {
+		t.breakerSettings = &settings
+	}
+}
+
+// Send custom User-Agent header
+func WithUserAgent(userAgent string) Option {
+	return func(t *Transport) {
+		t.userAgent = userAgent
+	}
+}
+
+// Send api key of paid upstream plan as apikey query parameter.
+// Key is added to requests only, urls in logs and errors don't have it.
+func WithAPIKey(key string) Option {
+	return func(t *Transport) {
+		t.apiKey = key
+	}
+}
+
+// Fail on response bodies bigger than n bytes
+func WithMaxResponseBytes(n int64) Option {
+	return func(t *Transport) {
+		t.maxResponseBytes = n
+	}
 }
 
-func New(client *http.Client) *Transport {
-	return &Transport{
-		client: client,
+type ClientOptions struct {
+	// Whole request timeout including reading body
+	Timeout time.Duration
+	// Proxy url, proxy from HTTP_PROXY/HTTPS_PROXY environment is used if empty
+	Proxy string
+	// Don't request gzip compressed responses
+	DisableCompression bool
+}
+
+// Create http client for upstream apis
+func NewClient(opts ClientOptions) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	transport.DisableCompression = opts.DisableCompression
+
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+
+		if err != nil {
+			return nil, fmt.Errorf("transport: parsing proxy url: %w", err)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return &http.Client{
+		Timeout:   opts.Timeout,
+		Transport: transport,
+	}, nil
+}
+
+func New(client *http.Client, opts ...Option) *Transport {
+	t := &Transport{
+		client:           client,
+		userAgent:        DefaultUserAgent,
+		maxResponseBytes: DefaultMaxResponseBytes,
+		breakers:         make(map[string]*breaker),
+		now:              time.Now,
 	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
 }
 
 // Make GET request by url
-func (t *Transport) Get(ctx context.Context, url string) (*http.Response, error) {
+func (t *Transport) Get(ctx context.Context, url string) (*Response, error) {
 	slog.InfoContext(ctx, fmt.Sprintf("transport: making GET request to %s", url))
 
-	response, err := t.client.Get(url)
+	b := t.breaker(hostOf(url))
+
+	var generation uint64
+
+	if b != nil {
+		var ok bool
+
+		if generation, ok = b.allow(); !ok {
+			slog.WarnContext(ctx, fmt.Sprintf("transport: circuit to %s is open", hostOf(url)))
+			return nil, fmt.Errorf("transport: making get request to %s: %w", url, ErrUpstreamUnavailable)
+		}
+	}
+
+	response, err := t.do(ctx, url)
+
+	if b != nil {
+		b.done(generation, breakerOutcome(ctx, response, err))
+	}
 
 	if err != nil {
 		return nil, fmt.Errorf("transport: making get request to %s: %w", url, err)
 	}
 
-	slog.DebugContext(ctx, fmt.Sprintf("transport: got response %v", response))
+	slog.DebugContext(ctx, fmt.Sprintf("transport: got response %d %s", response.StatusCode, response.Body))
 
 	slog.InfoContext(ctx, "transport: request was made successfully")
 
 	return response, nil
 }
+
+func (t *Transport) do(ctx context.Context, rawURL string) (*Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t.apiKey != "" {
+		query := request.URL.Query()
+		query.Set("apikey", t.apiKey)
+		request.URL.RawQuery = query.Encode()
+	}
+
+	request.Header.Set("User-Agent", t.userAgent)
+	request.Header.Set("Accept", "application/json")
+
+	response, err := t.client.Do(request)
+
+	if err != nil {
+		// Client errors quote request url, keep the key out of them
+		var urlErr *url.Error
+
+		if errors.As(err, &urlErr) {
+			urlErr.URL = rawURL
+		}
+
+		return nil, err
+	}
+
+	defer func() {
+		io.Copy(io.Discard, io.LimitReader(response.Body, maxDrainBytes))
+		response.Body.Close()
+	}()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, t.maxResponseBytes+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(body)) > t.maxResponseBytes {
+		return nil, fmt.Errorf("%w: more than %d bytes", ErrResponseTooLarge, t.maxResponseBytes)
+	}
+
+	return &Response{
+		StatusCode: response.StatusCode,
+		Header:     response.Header,
+		Body:       body,
+	}, nil
+}
+
+// Result of request for circuit breaker. Server errors and throttling count as failures,
+// client errors and too large bodies don't as upstream did answer. Requests given up
+// by callers tell nothing about upstream.
+func breakerOutcome(ctx context.Context, response *Response, err error) outcome {
+	switch {
+	case err == nil:
+		if response.StatusCode >= http.StatusInternalServerError || response.StatusCode == http.StatusTooManyRequests {
+			return outcomeFailure
+		}
+
+		return outcomeSuccess
+	case errors.Is(err, ErrResponseTooLarge):
+		return outcomeSuccess
+	case ctx.Err() != nil && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)):
+		return outcomeIgnored
+	default:
+		return outcomeFailure
+	}
+}
+
+// Circuit states of upstream hosts requested so far
+func (t *Transport) BreakerStates() map[string]string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	states := make(map[string]string, len(t.breakers))
+
+	for host, b := range t.breakers {
+		states[host] = b.State().String()
+	}
+
+	return states
+}
+
+func (t *Transport) breaker(host string) *breaker {
+	if t.breakerSettings == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.breakers[host]
+
+	if !ok {
+		b = newBreaker(*t.breakerSettings, t.now)
+		t.breakers[host] = b
+	}
+
+	return b
+}
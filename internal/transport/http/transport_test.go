@@ -0,0 +1,136 @@
+package httptransport
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransportGet(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/agify":
+			w.Header().Set("X-User-Agent", r.Header.Get("User-Agent"))
+			w.Write([]byte(`{"count":1,"name":"ivan","age":42}`))
+		case "/gzip":
+			if !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			gz.Write([]byte(`{"count":1,"name":"ivan","gender":"male"}`))
+			gz.Close()
+		case "/large":
+			w.Write([]byte(strings.Repeat("a", 2048)))
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	client, err := NewClient(ClientOptions{Timeout: time.Second})
+	assert.NoError(t, err)
+
+	transport := New(client, WithUserAgent("test-agent"), WithMaxResponseBytes(1024))
+
+	t.Run("user agent", func(t *testing.T) {
+		response, err := transport.Get(context.Background(), server.URL+"/agify")
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "test-agent", response.Header.Get("X-User-Agent"))
+		assert.Equal(t, `{"count":1,"name":"ivan","age":42}`, string(response.Body))
+	})
+
+	t.Run("gzip", func(t *testing.T) {
+		response, err := transport.Get(context.Background(), server.URL+"/gzip")
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{"count":1,"name":"ivan","gender":"male"}`, string(response.Body))
+	})
+
+	t.Run("response too large", func(t *testing.T) {
+		_, err := transport.Get(context.Background(), server.URL+"/large")
+
+		assert.ErrorIs(t, err, ErrResponseTooLarge)
+	})
+
+	t.Run("context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		_, err := transport.Get(ctx, server.URL+"/slow")
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("connection is reused after too large body", func(t *testing.T) {
+		transport.Get(context.Background(), server.URL+"/large")
+
+		reused := false
+
+		ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				reused = info.Reused
+			},
+		})
+
+		_, err := transport.Get(ctx, server.URL+"/agify")
+
+		assert.NoError(t, err)
+		assert.True(t, reused)
+	})
+}
+
+func TestTransportProxy(t *testing.T) {
+	var proxiedURL string
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		w.Write([]byte(`{}`))
+	}))
+	defer proxy.Close()
+
+	client, err := NewClient(ClientOptions{Timeout: time.Second, Proxy: proxy.URL})
+	assert.NoError(t, err)
+
+	_, err = New(client).Get(context.Background(), "http://api.agify.io/?name=ivan")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "http://api.agify.io/?name=ivan", proxiedURL)
+}
+
+func TestTransportAPIKey(t *testing.T) {
+	var query string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.Write([]byte(`{}`))
+	}))
+
+	client, err := NewClient(ClientOptions{Timeout: time.Second})
+	assert.NoError(t, err)
+
+	transport := New(client, WithAPIKey("s3cr3t"))
+
+	_, err = transport.Get(context.Background(), server.URL+"/?name=ivan")
+
+	assert.NoError(t, err)
+	assert.Equal(t, "apikey=s3cr3t&name=ivan", query)
+
+	// Errors quote url without the key
+	server.Close()
+
+	_, err = transport.Get(context.Background(), server.URL+"/?name=ivan")
+
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "s3cr3t")
+}
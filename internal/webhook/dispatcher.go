@@ -0,0 +1,215 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	repoModel "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+type Store interface {
+	Dispatch(ctx context.Context, limit int, send func(attempts []repoModel.Attempt) []repoModel.AttemptResult) (int, error)
+}
+
+const (
+	defaultInterval    = time.Second
+	defaultBatchSize   = 50
+	defaultConcurrency = 10
+
+	// Longest error saved to delivery log
+	maxErrorLength = 512
+)
+
+// Delays before retries of failed deliveries, a delivery failing once more is failed for good
+var DefaultSchedule = []time.Duration{
+	time.Minute,
+	5 * time.Minute,
+	30 * time.Minute,
+	2 * time.Hour,
+	12 * time.Hour,
+}
+
+// Dispatcher sends pending deliveries to subscriptions and retries failed ones by schedule
+type Dispatcher struct {
+	store       Store
+	client      *http.Client
+	interval    time.Duration
+	batchSize   int
+	concurrency int
+	schedule    []time.Duration
+	now         func() time.Time
+}
+
+type Option func(d *Dispatcher)
+
+// Poll due deliveries every interval while there are none
+func WithInterval(interval time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.interval = interval
+	}
+}
+
+// Send up to size deliveries in a transaction
+func WithBatchSize(size int) Option {
+	return func(d *Dispatcher) {
+		d.batchSize = size
+	}
+}
+
+// Send up to n deliveries at once
+func WithConcurrency(n int) Option {
+	return func(d *Dispatcher) {
+		d.concurrency = n
+	}
+}
+
+// Retry failed deliveries after delays of schedule
+func WithSchedule(schedule []time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.schedule = schedule
+	}
+}
+
+func NewDispatcher(store Store, client *http.Client, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		store:       store,
+		client:      client,
+		interval:    defaultInterval,
+		batchSize:   defaultBatchSize,
+		concurrency: defaultConcurrency,
+		schedule:    DefaultSchedule,
+		now:         time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// Send deliveries until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := d.Flush(ctx)
+
+		switch {
+		case err != nil && ctx.Err() == nil:
+			slog.Error(fmt.Sprintf("webhook: %s", err.Error()))
+		case n == d.batchSize:
+			// More deliveries are due
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Send a batch of due deliveries, returns number of attempts
+func (d *Dispatcher) Flush(ctx context.Context) (int, error) {
+	return d.store.Dispatch(ctx, d.batchSize, func(attempts []repoModel.Attempt) []repoModel.AttemptResult {
+		results := make([]repoModel.AttemptResult, len(attempts))
+		sem := make(chan struct{}, d.concurrency)
+
+		var wg sync.WaitGroup
+
+		for i := range attempts {
+			wg.Add(1)
+			sem <- struct{}{}
+
+			go func(i int) {
+				defer wg.Done()
+				defer func() { <-sem }()
+
+				results[i] = d.attempt(ctx, &attempts[i])
+			}(i)
+		}
+
+		wg.Wait()
+
+		return results
+	})
+}
+
+// Send delivery once and schedule its retry if it failed
+func (d *Dispatcher) attempt(ctx context.Context, a *repoModel.Attempt) repoModel.AttemptResult {
+	status, err := d.send(ctx, a)
+
+	now := d.now()
+
+	result := repoModel.AttemptResult{
+		Id:             a.Id,
+		Status:         repoModel.DeliverySucceeded,
+		ResponseStatus: status,
+		NextAttemptAt:  now,
+	}
+
+	if err == nil {
+		return result
+	}
+
+	slog.Warn(fmt.Sprintf("webhook: delivery %d to %s failed: %s", a.Id, a.URL, err.Error()))
+
+	result.Error = err.Error()
+
+	if len(result.Error) > maxErrorLength {
+		result.Error = result.Error[:maxErrorLength]
+	}
+
+	// Attempts are counted before this one
+	if a.Attempts >= len(d.schedule) {
+		result.Status = repoModel.DeliveryFailed
+		return result
+	}
+
+	result.Status = repoModel.DeliveryPending
+	result.NextAttemptAt = now.Add(d.schedule[a.Attempts])
+
+	return result
+}
+
+// Post signed payload to subscription url, any 2xx response acknowledges it.
+// Returns response status, zero if there was no response.
+func (d *Dispatcher) send(ctx context.Context, a *repoModel.Attempt) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(a.Payload))
+
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(a.Id, 10))
+	req.Header.Set("X-Webhook-Event", a.EventType)
+	req.Header.Set(SignatureHeader, Sign(a.Secret, d.now(), a.Payload))
+
+	resp, err := d.client.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	// Drain body so connection is reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
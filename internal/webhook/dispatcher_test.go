@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	repoModel "github.com/sletkov/effective-mobile-test-task/internal/repository/postgres/model"
+)
+
+// Due deliveries in memory, results are kept by delivery id
+type memoryStore struct {
+	mu       sync.Mutex
+	attempts []repoModel.Attempt
+	results  map[int64]repoModel.AttemptResult
+}
+
+func (s *memoryStore) Dispatch(ctx context.Context, limit int, send func(attempts []repoModel.Attempt) []repoModel.AttemptResult) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	batch := s.attempts[:min(limit, len(s.attempts))]
+	s.attempts = s.attempts[len(batch):]
+
+	results := send(batch)
+
+	for _, r := range results {
+		s.results[r.Id] = r
+	}
+
+	return len(results), nil
+}
+
+func TestDispatcherFlush(t *testing.T) {
+	now := time.Date(2024, 2, 15, 10, 0, 0, 0, time.UTC)
+	payload := []byte(`{"id":"e1","type":"user.created"}`)
+
+	var (
+		mu       sync.Mutex
+		received = make(map[string]http.Header)
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		assert.NoError(t, Verify("secret", r.Header.Get(SignatureHeader), body, now, time.Minute))
+
+		mu.Lock()
+		received[r.URL.Path] = r.Header
+		mu.Unlock()
+
+		if r.URL.Path == "/failing" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	store := &memoryStore{
+		attempts: []repoModel.Attempt{
+			{Delivery: repoModel.Delivery{Id: 1, EventType: "user.created", Payload: payload}, URL: server.URL + "/ok", Secret: "secret"},
+			{Delivery: repoModel.Delivery{Id: 2, EventType: "user.created", Payload: payload, Attempts: 1}, URL: server.URL + "/failing", Secret: "secret"},
+			{Delivery: repoModel.Delivery{Id: 3, EventType: "user.created", Payload: payload, Attempts: 2}, URL: server.URL + "/failing", Secret: "secret"},
+			{Delivery: repoModel.Delivery{Id: 4, EventType: "user.created", Payload: payload}, URL: "http://127.0.0.1:1/refused", Secret: "secret"},
+		},
+		results: make(map[int64]repoModel.AttemptResult),
+	}
+
+	d := NewDispatcher(store, server.Client(), WithSchedule([]time.Duration{time.Minute, time.Hour}))
+	d.now = func() time.Time { return now }
+
+	n, err := d.Flush(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	assert.Equal(t, repoModel.AttemptResult{Id: 1, Status: repoModel.DeliverySucceeded, ResponseStatus: http.StatusOK, NextAttemptAt: now}, store.results[1])
+
+	// Second attempt is retried after the second delay
+	assert.Equal(t, repoModel.DeliveryPending, store.results[2].Status)
+	assert.Equal(t, http.StatusBadGateway, store.results[2].ResponseStatus)
+	assert.Equal(t, now.Add(time.Hour), store.results[2].NextAttemptAt)
+	assert.Equal(t, "webhook responded with status 502", store.results[2].Error)
+
+	// Schedule is exhausted
+	assert.Equal(t, repoModel.DeliveryFailed, store.results[3].Status)
+
+	// No response
+	assert.Equal(t, repoModel.DeliveryPending, store.results[4].Status)
+	assert.Equal(t, 0, store.results[4].ResponseStatus)
+	assert.Equal(t, now.Add(time.Minute), store.results[4].NextAttemptAt)
+	assert.NotEmpty(t, store.results[4].Error)
+
+	assert.Equal(t, "1", received["/ok"].Get("X-Webhook-Delivery"))
+	assert.Equal(t, "user.created", received["/ok"].Get("X-Webhook-Event"))
+	assert.Equal(t, "application/json", received["/ok"].Get("Content-Type"))
+}
@@ -0,0 +1,68 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header of deliveries with their signature
+const SignatureHeader = "X-Webhook-Signature"
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// Signature of body sent at t: "t=<unix seconds>,v1=<hex HMAC-SHA256 of "<t>.<body>">".
+// Timestamp is signed too, so receivers can reject replayed deliveries.
+func Sign(secret string, t time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(t.Unix(), 10)
+
+	return fmt.Sprintf("t=%s,v1=%s", timestamp, hex.EncodeToString(mac(secret, timestamp, body)))
+}
+
+// Check signature of body made within tolerance of now, zero tolerance accepts any time
+func Verify(secret, signature string, body []byte, now time.Time, tolerance time.Duration) error {
+	var timestamp, v1 string
+
+	for _, part := range strings.Split(signature, ",") {
+		key, value, _ := strings.Cut(part, "=")
+
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			v1 = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if tolerance > 0 && now.Sub(time.Unix(unix, 0)).Abs() > tolerance {
+		return ErrInvalidSignature
+	}
+
+	expected, err := hex.DecodeString(v1)
+
+	if err != nil || !hmac.Equal(expected, mac(secret, timestamp, body)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func mac(secret, timestamp string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write(body)
+
+	return h.Sum(nil)
+}
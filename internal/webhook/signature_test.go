@@ -0,0 +1,90 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	at := time.Unix(1707991200, 0)
+	body := []byte(`{"id":"e1"}`)
+
+	signature := Sign("secret", at, body)
+	assert.Equal(t, "t=1707991200,v1=d0aa491ec3bf46307c0afd6975326ea3249d17c08ed259973b0acf8a2458996b", signature)
+
+	testCases := []struct {
+		name      string
+		secret    string
+		signature string
+		body      string
+		now       time.Time
+		tolerance time.Duration
+		valid     bool
+	}{
+		{
+			name:      "valid",
+			secret:    "secret",
+			signature: signature,
+			body:      `{"id":"e1"}`,
+			now:       at.Add(time.Minute),
+			tolerance: 5 * time.Minute,
+			valid:     true,
+		},
+
+		{
+			name:      "other secret",
+			secret:    "other",
+			signature: signature,
+			body:      `{"id":"e1"}`,
+			now:       at,
+		},
+
+		{
+			name:      "changed body",
+			secret:    "secret",
+			signature: signature,
+			body:      `{"id":"e2"}`,
+			now:       at,
+		},
+
+		{
+			name:      "too old",
+			secret:    "secret",
+			signature: signature,
+			body:      `{"id":"e1"}`,
+			now:       at.Add(time.Hour),
+			tolerance: 5 * time.Minute,
+		},
+
+		{
+			name:      "any time",
+			secret:    "secret",
+			signature: signature,
+			body:      `{"id":"e1"}`,
+			now:       at.Add(time.Hour),
+			valid:     true,
+		},
+
+		{
+			name:      "malformed",
+			secret:    "secret",
+			signature: "v1=zz",
+			body:      `{"id":"e1"}`,
+			now:       at,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Verify(tc.secret, tc.signature, []byte(tc.body), tc.now, tc.tolerance)
+
+			if tc.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidSignature)
+			}
+		})
+	}
+}
@@ -0,0 +1,13 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS users(
+	id serial primary key not null,
+	name varchar not null,
+	surname varchar not null,
+	patronymic varchar,
+	age integer not null,
+	gender varchar not null,
+	nationality varchar not null
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,62 @@
+-- +goose Up
+CREATE TYPE user_gender AS ENUM ('male', 'female');
+
+-- Empty patronymic is stored as NULL
+UPDATE users SET patronymic = NULL WHERE patronymic = '';
+
+-- Users unknown to enrichment apis were stored with age 0, empty gender or nationality.
+-- They can't satisfy the constraints, so they are moved aside to be fixed and created again.
+CREATE TABLE users_unenriched AS
+	SELECT * FROM users
+	WHERE age NOT BETWEEN 1 AND 100 OR gender NOT IN ('male', 'female') OR nationality !~ '^[A-Za-z]{2}$';
+
+DELETE FROM users WHERE id IN (SELECT id FROM users_unenriched);
+
+ALTER TABLE users
+	ALTER COLUMN name TYPE varchar(255),
+	ALTER COLUMN surname TYPE varchar(255),
+	ALTER COLUMN patronymic TYPE varchar(255),
+	ALTER COLUMN nationality TYPE char(2),
+	ALTER COLUMN gender TYPE user_gender USING gender::user_gender,
+	ADD COLUMN created_at timestamptz NOT NULL DEFAULT now(),
+	ADD COLUMN updated_at timestamptz NOT NULL DEFAULT now(),
+	ADD CONSTRAINT users_name_check CHECK (name ~ '^[A-Za-z]+$'),
+	ADD CONSTRAINT users_surname_check CHECK (surname ~ '^[A-Za-z]+$'),
+	ADD CONSTRAINT users_patronymic_check CHECK (patronymic ~ '^[A-Za-z]+$'),
+	ADD CONSTRAINT users_age_check CHECK (age BETWEEN 1 AND 100),
+	ADD CONSTRAINT users_nationality_check CHECK (nationality ~ '^[A-Za-z]{2}$');
+
+-- Indexes for filters produced by UserFilter
+CREATE INDEX users_surname_name_idx ON users (surname, name);
+CREATE INDEX users_name_idx ON users (name);
+CREATE INDEX users_patronymic_idx ON users (patronymic) WHERE patronymic IS NOT NULL;
+CREATE INDEX users_gender_nationality_age_idx ON users (gender, nationality, age);
+CREATE INDEX users_nationality_age_idx ON users (nationality, age);
+CREATE INDEX users_age_idx ON users (age);
+
+-- +goose Down
+DROP INDEX IF EXISTS users_age_idx;
+DROP INDEX IF EXISTS users_nationality_age_idx;
+DROP INDEX IF EXISTS users_gender_nationality_age_idx;
+DROP INDEX IF EXISTS users_patronymic_idx;
+DROP INDEX IF EXISTS users_name_idx;
+DROP INDEX IF EXISTS users_surname_name_idx;
+
+ALTER TABLE users
+	DROP CONSTRAINT IF EXISTS users_nationality_check,
+	DROP CONSTRAINT IF EXISTS users_age_check,
+	DROP CONSTRAINT IF EXISTS users_patronymic_check,
+	DROP CONSTRAINT IF EXISTS users_surname_check,
+	DROP CONSTRAINT IF EXISTS users_name_check,
+	DROP COLUMN IF EXISTS updated_at,
+	DROP COLUMN IF EXISTS created_at,
+	ALTER COLUMN gender TYPE varchar USING gender::text,
+	ALTER COLUMN nationality TYPE varchar,
+	ALTER COLUMN patronymic TYPE varchar,
+	ALTER COLUMN surname TYPE varchar,
+	ALTER COLUMN name TYPE varchar;
+
+DROP TYPE IF EXISTS user_gender;
+
+INSERT INTO users SELECT * FROM users_unenriched;
+DROP TABLE IF EXISTS users_unenriched;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+-- unaccent is only stable since its dictionary may change, the wrapper
+-- pins the dictionary so it can be used in generated columns and indexes
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION immutable_unaccent(text) RETURNS text
+	LANGUAGE sql IMMUTABLE PARALLEL SAFE STRICT
+AS $$
+	SELECT public.unaccent('public.unaccent'::regdictionary, $1)
+$$;
+-- +goose StatementEnd
+
+-- Case and diacritic folded names for search
+ALTER TABLE users
+	ADD COLUMN search_text text GENERATED ALWAYS AS (
+		immutable_unaccent(lower(name || ' ' || surname || ' ' || coalesce(patronymic, '')))
+	) STORED,
+	ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+		to_tsvector('simple', immutable_unaccent(lower(name || ' ' || surname || ' ' || coalesce(patronymic, ''))))
+	) STORED;
+
+CREATE INDEX users_search_vector_idx ON users USING gin (search_vector);
+CREATE INDEX users_search_text_trgm_idx ON users USING gin (search_text gin_trgm_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS users_search_text_trgm_idx;
+DROP INDEX IF EXISTS users_search_vector_idx;
+
+ALTER TABLE users
+	DROP COLUMN IF EXISTS search_vector,
+	DROP COLUMN IF EXISTS search_text;
+
+DROP FUNCTION IF EXISTS immutable_unaccent(text);
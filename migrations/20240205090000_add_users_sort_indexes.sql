@@ -0,0 +1,22 @@
+-- +goose Up
+-- Sorted lists are tiebroken on id, so sortable columns are indexed together with it
+DROP INDEX IF EXISTS users_name_idx;
+DROP INDEX IF EXISTS users_age_idx;
+
+CREATE INDEX users_name_id_idx ON users (name, id);
+CREATE INDEX users_surname_id_idx ON users (surname, id);
+CREATE INDEX users_patronymic_id_idx ON users (patronymic, id);
+CREATE INDEX users_age_id_idx ON users (age, id);
+CREATE INDEX users_gender_id_idx ON users (gender, id);
+CREATE INDEX users_nationality_id_idx ON users (nationality, id);
+
+-- +goose Down
+DROP INDEX IF EXISTS users_nationality_id_idx;
+DROP INDEX IF EXISTS users_gender_id_idx;
+DROP INDEX IF EXISTS users_age_id_idx;
+DROP INDEX IF EXISTS users_patronymic_id_idx;
+DROP INDEX IF EXISTS users_surname_id_idx;
+DROP INDEX IF EXISTS users_name_id_idx;
+
+CREATE INDEX users_age_idx ON users (age);
+CREATE INDEX users_name_idx ON users (name);
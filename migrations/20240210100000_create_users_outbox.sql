@@ -0,0 +1,12 @@
+-- +goose Up
+-- Changes of users written by the same statement as the change and deleted once published
+CREATE TABLE users_outbox (
+	id bigserial PRIMARY KEY,
+	user_id integer NOT NULL,
+	event_type varchar(32) NOT NULL,
+	payload jsonb NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS users_outbox;
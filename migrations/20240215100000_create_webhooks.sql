@@ -0,0 +1,33 @@
+-- +goose Up
+CREATE TABLE webhook_subscriptions (
+	id serial PRIMARY KEY,
+	url text NOT NULL,
+	events text[] NOT NULL,
+	secret text NOT NULL,
+	created_at timestamptz NOT NULL DEFAULT now(),
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Every attempt to deliver an event to a subscription, kept as delivery log
+CREATE TABLE webhook_deliveries (
+	id bigserial PRIMARY KEY,
+	subscription_id integer NOT NULL REFERENCES webhook_subscriptions (id) ON DELETE CASCADE,
+	event_type varchar(32) NOT NULL,
+	payload jsonb NOT NULL,
+	status varchar(16) NOT NULL DEFAULT 'pending',
+	attempts integer NOT NULL DEFAULT 0,
+	response_status integer NOT NULL DEFAULT 0,
+	error text NOT NULL DEFAULT '',
+	next_attempt_at timestamptz NOT NULL DEFAULT now(),
+	created_at timestamptz NOT NULL DEFAULT now(),
+	updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+-- Due deliveries are polled by dispatcher
+CREATE INDEX webhook_deliveries_pending_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+CREATE INDEX webhook_deliveries_subscription_idx ON webhook_deliveries (subscription_id, id);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_subscriptions;
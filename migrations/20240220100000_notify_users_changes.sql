@@ -0,0 +1,43 @@
+-- +goose Up
+-- Ids of change notifications, shared by all replicas listening to them
+CREATE SEQUENCE users_events_seq;
+
+-- Notify listeners of users_events channel of every change of a user,
+-- payload is the same as of outbox events with the last state of deleted users
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_users_change() RETURNS trigger
+	LANGUAGE plpgsql
+AS $$
+DECLARE
+	u users;
+BEGIN
+	IF TG_OP = 'DELETE' THEN
+		u := OLD;
+	ELSE
+		u := NEW;
+	END IF;
+
+	PERFORM pg_notify('users_events', json_build_object(
+		'id', nextval('users_events_seq'),
+		'type', CASE TG_OP WHEN 'INSERT' THEN 'user.created' WHEN 'UPDATE' THEN 'user.updated' ELSE 'user.deleted' END,
+		'user_id', u.id,
+		'data', json_build_object(
+			'id', u.id, 'name', u.name, 'surname', u.surname, 'patronymic', u.patronymic,
+			'age', u.age, 'gender', u.gender, 'nationality', u.nationality
+		),
+		'occurred_at', now()
+	)::text);
+
+	RETURN NULL;
+END
+$$;
+-- +goose StatementEnd
+
+CREATE TRIGGER users_notify_change
+	AFTER INSERT OR UPDATE OR DELETE ON users
+	FOR EACH ROW EXECUTE FUNCTION notify_users_change();
+
+-- +goose Down
+DROP TRIGGER IF EXISTS users_notify_change ON users;
+DROP FUNCTION IF EXISTS notify_users_change();
+DROP SEQUENCE IF EXISTS users_events_seq;
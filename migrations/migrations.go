@@ -0,0 +1,12 @@
+package migrations
+
+import "embed"
+
+// SQL migrations embedded into the binary, use with goose.SetBaseFS and "." dir
+// for postgres or SQLiteDir for sqlite
+//
+//go:embed *.sql sqlite/*.sql
+var FS embed.FS
+
+// Dir of sqlite migrations in FS, they keep the same schema of users
+const SQLiteDir = "sqlite"
@@ -0,0 +1,25 @@
+-- +goose Up
+-- Users table with the same constraints as the postgres one, gender enum is a check
+CREATE TABLE users (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	name TEXT NOT NULL CHECK (length(name) <= 255 AND name GLOB '[A-Za-z]*' AND name NOT GLOB '*[^A-Za-z]*'),
+	surname TEXT NOT NULL CHECK (length(surname) <= 255 AND surname GLOB '[A-Za-z]*' AND surname NOT GLOB '*[^A-Za-z]*'),
+	patronymic TEXT CHECK (length(patronymic) <= 255 AND patronymic GLOB '[A-Za-z]*' AND patronymic NOT GLOB '*[^A-Za-z]*'),
+	age INTEGER NOT NULL CHECK (age BETWEEN 1 AND 100),
+	gender TEXT NOT NULL CHECK (gender IN ('male', 'female')),
+	nationality TEXT NOT NULL CHECK (nationality GLOB '[A-Za-z][A-Za-z]'),
+	created_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Sorted lists are tiebroken on id, so sortable columns are indexed together with it
+CREATE INDEX users_name_id_idx ON users (name, id);
+CREATE INDEX users_surname_id_idx ON users (surname, id);
+CREATE INDEX users_patronymic_id_idx ON users (patronymic, id);
+CREATE INDEX users_age_id_idx ON users (age, id);
+CREATE INDEX users_gender_id_idx ON users (gender, id);
+CREATE INDEX users_nationality_id_idx ON users (nationality, id);
+CREATE INDEX users_gender_nationality_age_idx ON users (gender, nationality, age);
+
+-- +goose Down
+DROP TABLE IF EXISTS users;